@@ -0,0 +1,23 @@
+import { createLayeredSigilData, readSigilDirectory } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+import { defaultTables } from './layered/default-tables';
+
+console.log('=== SIGIL Layered Tables ===\n');
+
+// Bundled defaults first, the user's directory on top
+const data = createLayeredSigilData([
+    { name: 'defaults', files: defaultTables },
+    { name: 'user', files: readSigilDirectory('./examples/layered/user-tables') }
+]);
+
+for (const table of ['creatures', 'treasure.coins', 'treasure.trinkets', 'encounter']) {
+    const origin = data.origin(table)!;
+    const shadowed = origin.shadowed.map(s => s.layer).join(', ');
+    console.log(`${table}: ${origin.layer} (${origin.files.join(', ')})${shadowed ? `, overrides ${shadowed}` : ''}`);
+}
+
+const engine = new SigilEngine(data.lists);
+console.log('\nEncounters:');
+for (let i = 0; i < 3; i++) {
+    console.log(`  ${i + 1}. ${engine.generate(data.templates.encounter[0])}`);
+}
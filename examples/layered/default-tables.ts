@@ -0,0 +1,26 @@
+// Tables shipped inside the application bundle rather than as loose files.
+// With Vite the same map comes from:
+//   import.meta.glob('./tables/**/*.yaml', { query: '?raw', import: 'default', eager: true })
+// and with webpack from require.context + raw-loader. Keys are relative paths.
+export const defaultTables: { [relativePath: string]: string } = {
+    'creatures.yaml': `
+creatures:
+  - wolf
+  - bear
+  - giant spider
+`,
+    'treasure.yaml': `
+treasure:
+  coins:
+    - copper pieces
+    - silver pieces
+  trinkets:
+    - a bone die
+    - a tarnished locket
+`,
+    'templates.yaml': `
+templates:
+  encounter:
+    - "A [creatures] guarding [treasure.coins] and [treasure.trinkets]"
+`
+};
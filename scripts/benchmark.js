@@ -0,0 +1,57 @@
+#!/usr/bin/env node
+
+// Parse and generation benchmarks against the built package (npm run bench).
+//   parse-large     load a generated ~40k-line table collection
+//   load-compiled   load the same collection saved by compileSigilData
+//   parse-entries   parse every entry of the collection as a template
+//   generate-deep   generate from tables that reference each other ten levels deep
+//   generate-wide   generate from every table in turn, more entries than the parse cache holds
+//   precompile      engine.precompile() over the collection
+//   deep-precompiled, wide-precompiled  the same two after precompile()
+// Heap growth per operation is reported when node runs with --expose-gc.
+const { createSigilData, compileSigilData, loadCompiled, SigilEngine } = require('../dist/src/index.js');
+const { parseCompleteTemplate } = require('../dist/src/template-parser.js');
+
+const TABLES = 2000;
+const ENTRIES = 19;
+
+function corpus() {
+    const lines = [];
+    for (let t = 0; t < TABLES; t++) {
+        lines.push(`table_${t}:`);
+        for (let e = 0; e < ENTRIES; e++) {
+            lines.push(`  - "entry ${e} of [table_${(t + 1) % TABLES}.capitalize?] {a|b} ^${e % 3 + 1}"`);
+        }
+    }
+    return lines.join('\n') + '\n';
+}
+
+function bench(name, iterations, run) {
+    run(); // warm up
+    if (global.gc) global.gc();
+    const heapBefore = process.memoryUsage().heapUsed;
+    const start = process.hrtime.bigint();
+    for (let i = 0; i < iterations; i++) run();
+    const elapsed = Number(process.hrtime.bigint() - start) / 1e6;
+    const heap = global.gc ? `, ${Math.round((process.memoryUsage().heapUsed - heapBefore) / iterations)} B/op heap growth` : '';
+    console.log(`${name.padEnd(16)} ${iterations} ops, ${(elapsed / iterations).toFixed(3)} ms/op${heap}`);
+}
+
+const source = corpus();
+console.log(`corpus: ${source.split('\n').length - 1} lines, ${TABLES} tables`);
+bench('parse-large', 5, () => createSigilData([source]));
+const compiled = compileSigilData({ 'corpus.yaml': source });
+bench('load-compiled', 5, () => loadCompiled(compiled));
+const entries = Object.values(createSigilData([source]).lists).flat();
+bench('parse-entries', 5, () => entries.forEach(entry => parseCompleteTemplate(entry)));
+
+const engine = new SigilEngine(createSigilData([source]).lists, { seed: 1, maxDepth: 12 });
+bench('generate-deep', 2000, () => engine.generate('[table_0] [table_5]'));
+let table = 0;
+bench('generate-wide', 2000, () => engine.generate(`[table_${table++ % TABLES}]`));
+const lists = createSigilData([source]).lists;
+bench('precompile', 5, () => new SigilEngine(lists).precompile());
+const precompiled = new SigilEngine(createSigilData([source]).lists, { seed: 1, maxDepth: 12 });
+precompiled.precompile();
+bench('deep-precompiled', 2000, () => precompiled.generate('[table_0] [table_5]'));
+bench('wide-precompiled', 2000, () => precompiled.generate(`[table_${table++ % TABLES}]`));
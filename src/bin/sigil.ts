@@ -0,0 +1,10 @@
+#!/usr/bin/env node
+import * as fs from 'fs';
+import { runCli } from '../cli';
+import { decodeSigilFile } from '../encoding';
+
+process.exitCode = runCli(process.argv.slice(2), {
+    stdout: text => process.stdout.write(text),
+    stderr: text => process.stderr.write(text),
+    readStdin: () => decodeSigilFile(fs.readFileSync(0)).text
+});
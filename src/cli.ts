@@ -0,0 +1,523 @@
+/**
+ * SIGIL Command Line
+ *   sigil gen -f tables/ -t "A [adjective] [creature]" -n 10 --seed 42
+ *   sigil validate tables/
+ *   sigil tables -f monsters.yaml
+ *   sigil fmt -w tables/
+ *   sigil convert --from perchance generator.txt -o tables.yaml
+ *   sigil compile tables/ -o tables.sgc && sigil gen -f tables.sgc -t encounter
+ *   sigil serve -f tables/ --addr :8080
+ *
+ * Table files and directories can be given several times; with none, YAML is
+ * read from stdin. Exit codes: 0 success, 1 generation or validation errors,
+ * 2 bad usage or unreadable input.
+ */
+
+import * as fs from 'fs';
+import * as path from 'path';
+import { SigilEngine } from './template-engine';
+import { createSigilDataFromFiles, readSigilDirectory, readSigilFile, listTables, parseYamlContent, mergeLists } from './yaml-loader';
+import type { LoadedData, SigilData } from './yaml-loader';
+import { validateSigil } from './validator';
+import { formatSigil } from './formatter';
+import { renderMarkdown, renderHTML } from './renderer';
+import { importPerchance, importMarkdownTable } from './converters';
+import type { ConversionResult } from './converters';
+import { importCsv, importJson } from './importer';
+import { TableSet } from './table-builder';
+import { encodeSigilFile } from './encoding';
+import { compileSigilData, loadCompiled, isCompiled } from './compiled';
+import { createSigilServer } from './server';
+import type { DecodedFile } from './encoding';
+import type { ValidationIssue } from './validator';
+import { SigilError, ParseError, withFile } from './errors';
+import { VERSION } from './index';
+
+export interface CliIO {
+    stdout: (text: string) => void;
+    stderr: (text: string) => void;
+    /** Whole of standard input; only called when no table files are given */
+    readStdin: () => string;
+}
+
+const USAGE = `Usage: sigil <command> [options]
+
+Commands:
+  gen        Generate text from a template
+  validate   Check table files and report problems with their positions
+  tables     List the tables defined in table files
+  fmt        Print table files in the canonical layout, or rewrite them with -w
+  convert    Turn a Perchance, Markdown, CSV or JSON file into SIGIL tables
+  compile    Parse table files once into a file that gen and tables load quickly
+  serve      Answer generate, tables and validate requests as a JSON HTTP API
+
+Options:
+  -f, --file <path>      Table file or directory (repeatable; default: stdin)
+  -t, --template <text>  Template to generate, or the name of a loaded template (gen)
+  -n, --count <n>        Number of results (gen, default 1)
+      --seed <seed>      Seed for reproducible output (gen)
+      --format <format>  text (default) or json; gen also takes markdown or html
+      --trace            Print each resolution step to stderr as a JSON line (gen)
+  -w, --write            Write formatted files back instead of printing them (fmt)
+      --order <order>    preserve (default) or alphabetical table order (fmt)
+      --from <format>    perchance, markdown, csv or json (convert)
+  -o, --output <path>    File to write instead of stdout (convert, compile)
+      --addr <host:port>  Address to listen on (serve, default :8080)
+  -h, --help             Show this help
+  -v, --version          Show the version
+`;
+
+class UsageError extends Error {}
+
+interface CliOptions {
+    command?: string;
+    files: string[];
+    template?: string;
+    count: number;
+    seed?: string;
+    format: 'text' | 'json' | 'markdown' | 'html';
+    write: boolean;
+    trace: boolean;
+    order: 'preserve' | 'alphabetical';
+    from?: 'perchance' | 'markdown' | 'csv' | 'json';
+    output?: string;
+    addr?: string;
+}
+
+/**
+ * Run the CLI with the given arguments (without "node sigil")
+ *
+ * @returns The process exit code
+ */
+export function runCli(args: string[], io: CliIO): number {
+    let options: CliOptions;
+    try {
+        options = parseArgs(args);
+    } catch (error) {
+        io.stderr(`sigil: ${error instanceof Error ? error.message : error}\n\n${USAGE}`);
+        return 2;
+    }
+
+    if (options.command === 'help') {
+        io.stdout(USAGE);
+        return 0;
+    }
+    if (options.command === 'version') {
+        io.stdout(`${VERSION}\n`);
+        return 0;
+    }
+
+    if (options.command === 'fmt') {
+        return format(options, io);
+    }
+    if (options.command === 'convert') {
+        return convert(options, io);
+    }
+
+    let sources: { [file: string]: string };
+    try {
+        sources = readSources(options.files, io);
+    } catch (error) {
+        io.stderr(`sigil: ${error instanceof Error ? error.message : error}\n`);
+        return 2;
+    }
+
+    try {
+        switch (options.command) {
+            case 'gen': return generate(sources, options, io);
+            case 'validate': return validate(sources, options, io);
+            case 'compile': return compile(sources, options, io);
+            case 'serve': return serve(sources, options, io);
+            default: return tables(sources, options, io);
+        }
+    } catch (error) {
+        if (error instanceof SigilError) {
+            io.stderr(`sigil: ${error.message}\n`);
+            return 1;
+        }
+        throw error;
+    }
+}
+
+function parseArgs(args: string[]): CliOptions {
+    const options: CliOptions = { files: [], count: 1, format: 'text', write: false, trace: false, order: 'preserve' };
+    const positional: string[] = [];
+
+    for (let i = 0; i < args.length; i++) {
+        let arg = args[i];
+        let value: string | undefined;
+        const eq = arg.indexOf('=');
+        if (arg.startsWith('--') && eq !== -1) {
+            value = arg.slice(eq + 1);
+            arg = arg.slice(0, eq);
+        }
+        const next = () => {
+            if (value !== undefined) return value;
+            if (i + 1 >= args.length) throw new UsageError(`${arg} needs a value`);
+            return args[++i];
+        };
+
+        switch (arg) {
+            case '-h': case '--help': return { ...options, command: 'help' };
+            case '-v': case '--version': return { ...options, command: 'version' };
+            case '-f': case '--file': options.files.push(next()); break;
+            case '-t': case '--template': options.template = next(); break;
+            case '--seed': options.seed = next(); break;
+            case '-w': case '--write': options.write = true; break;
+            case '--trace': options.trace = true; break;
+            case '-o': case '--output': options.output = next(); break;
+            case '--addr': options.addr = next(); break;
+            case '--from': {
+                const from = next();
+                if (from !== 'perchance' && from !== 'markdown' && from !== 'csv' && from !== 'json') {
+                    throw new UsageError(`--from must be perchance, markdown, csv or json, got "${from}"`);
+                }
+                options.from = from;
+                break;
+            }
+            case '--order': {
+                const order = next();
+                if (order !== 'preserve' && order !== 'alphabetical') {
+                    throw new UsageError(`--order must be preserve or alphabetical, got "${order}"`);
+                }
+                options.order = order;
+                break;
+            }
+            case '-n': case '--count': {
+                const text = next();
+                options.count = Number(text);
+                if (!Number.isInteger(options.count) || options.count < 0) {
+                    throw new UsageError(`--count must be a whole number, got "${text}"`);
+                }
+                break;
+            }
+            case '--format': {
+                const format = next();
+                if (format !== 'text' && format !== 'json' && format !== 'markdown' && format !== 'html') {
+                    throw new UsageError(`--format must be text, json, markdown or html, got "${format}"`);
+                }
+                options.format = format;
+                break;
+            }
+            default:
+                if (arg.startsWith('-') && arg !== '-') throw new UsageError(`Unknown option ${arg}`);
+                positional.push(arg);
+        }
+    }
+
+    options.command = positional.shift();
+    if (!options.command) {
+        throw new UsageError('Missing command');
+    }
+    if (!['gen', 'validate', 'tables', 'fmt', 'convert', 'compile', 'serve'].includes(options.command)) {
+        throw new UsageError(`Unknown command "${options.command}"`);
+    }
+    // "sigil validate tables/" reads like a path list
+    options.files.push(...positional);
+    if (options.command === 'gen' && options.template === undefined) {
+        throw new UsageError('gen needs a template (-t)');
+    }
+    if ((options.format === 'markdown' || options.format === 'html') && options.command !== 'gen') {
+        throw new UsageError(`--format ${options.format} is only for gen`);
+    }
+    if (options.trace && options.command !== 'gen') {
+        throw new UsageError('--trace is only for gen');
+    }
+    if (options.write && (options.files.length === 0 || options.files.includes('-'))) {
+        throw new UsageError('-w needs table files to write to');
+    }
+    if (options.command === 'convert' && options.from === undefined) {
+        throw new UsageError('convert needs an input format (--from)');
+    }
+    if (options.command === 'convert' && options.files.length > 1) {
+        throw new UsageError('convert takes one input file');
+    }
+    if (options.from !== undefined && options.command !== 'convert') {
+        throw new UsageError('--from is only for convert');
+    }
+    if (options.output !== undefined && options.command !== 'convert' && options.command !== 'compile') {
+        throw new UsageError('-o is only for convert and compile');
+    }
+    if (options.addr !== undefined && (options.command !== 'serve' || !/^[^:]*:\d+$/.test(options.addr))) {
+        throw new UsageError(options.command !== 'serve' ? '--addr is only for serve' : `--addr must be [host]:port, got "${options.addr}"`);
+    }
+    return options;
+}
+
+// { display path: YAML source } for every file, expanding directories; stdin when none or "-"
+function readSources(files: string[], io: CliIO): { [file: string]: string } {
+    const sources: { [file: string]: string } = {};
+    if (files.length === 0) files = ['-'];
+
+    for (const file of files) {
+        if (file === '-') {
+            sources['<stdin>'] = io.readStdin();
+        } else if (fs.existsSync(file) && fs.statSync(file).isDirectory()) {
+            for (const relativePath of Object.keys(readSigilDirectory(file))) {
+                const filePath = path.join(file, relativePath);
+                sources[filePath] = readText(filePath, io).text;
+            }
+        } else {
+            try {
+                sources[file] = readText(file, io).text;
+            } catch (error) {
+                throw new Error(`Cannot read "${file}": ${error instanceof Error ? error.message : error}`);
+            }
+        }
+    }
+
+    // Files pulled in with "@include" are read too; missing ones are left for the loader to report
+    const pending = Object.keys(sources).filter(file => file !== '<stdin>');
+    while (pending.length > 0) {
+        const file = pending.shift()!;
+        for (const include of includesOf(sources[file])) {
+            const target = path.join(path.dirname(file), include);
+            if (sources[target] === undefined && fs.existsSync(target)) {
+                sources[target] = readText(target, io).text;
+                pending.push(target);
+            }
+        }
+    }
+    return sources;
+}
+
+// A file in any encoding; bytes that did not decode are reported, not fatal
+function readText(file: string, io: CliIO): DecodedFile {
+    const decoded = readSigilFile(file);
+    if (decoded.replaced > 0) {
+        io.stderr(`${file}: warning: ${decoded.replaced} invalid byte sequence${decoded.replaced === 1 ? '' : 's'} replaced with U+FFFD\n`);
+    }
+    return decoded;
+}
+
+function includesOf(source: string): string[] {
+    try {
+        const include = parseYamlContent(source)['@include'];
+        return (Array.isArray(include) ? include : [include]).filter(item => typeof item === 'string');
+    } catch (error) {
+        return [];
+    }
+}
+
+function generate(sources: { [file: string]: string }, options: CliOptions, io: CliIO): number {
+    const data = loadSources(sources);
+    const engine = new SigilEngine(withTemplates(data), {
+        errorMode: 'strict',
+        seed: options.seed,
+        trace: options.trace ? event => io.stderr(`${JSON.stringify(event)}\n`) : undefined
+    });
+
+    // A bare loaded template name picks one of that template's entries
+    const name = options.template!;
+    const template = data.templates[name] !== undefined && data.lists.templates === undefined ? `[templates.${name}]` : name;
+
+    if (options.format !== 'text') {
+        const render = options.format === 'markdown' ? renderMarkdown : options.format === 'html' ? renderHTML : JSON.stringify;
+        for (let i = 0; i < options.count; i++) {
+            io.stdout(`${render(engine.generateStructured(template))}\n`);
+        }
+    } else {
+        for (const result of engine.generateStream(template, options.count)) {
+            io.stdout(`${result}\n`);
+        }
+    }
+    return 0;
+}
+
+function validate(sources: { [file: string]: string }, options: CliOptions, io: CliIO): number {
+    // References may point into any of the files, so check each against the whole set
+    let lists: SigilData | undefined;
+    const issues: (ValidationIssue & { file: string })[] = [];
+    try {
+        lists = withTemplates(createSigilDataFromFiles(sources));
+    } catch (error) {
+        if (!(error instanceof SigilError)) throw error;
+        if (['table_conflict', 'invalid_include', 'include_cycle'].includes(error.code)) {
+            issues.push({ file: error.file || '', severity: 'error', message: error.description, line: 1, column: 1, table: error.table });
+        }
+        // Other load errors (including bad "@extend" targets) are found again by validateSigil with their positions
+        lists = mergeParsable(sources);
+    }
+
+    for (const [file, source] of Object.entries(sources)) {
+        // Without the merged set, references into other files cannot be checked for sure
+        const found = validateSigil(source, lists ? { data: lists } : { incomplete: Object.keys(sources).length > 1 });
+        issues.push(...found.map(issue => ({ file, ...issue })));
+    }
+
+    if (options.format === 'json') {
+        io.stdout(`${JSON.stringify(issues, null, 2)}\n`);
+    } else {
+        for (const issue of issues) {
+            io.stdout(`${issue.file}:${issue.line}:${issue.column}: ${issue.severity}: ${issue.message}\n`);
+        }
+    }
+    return issues.some(issue => issue.severity === 'error') ? 1 : 0;
+}
+
+function tables(sources: { [file: string]: string }, options: CliOptions, io: CliIO): number {
+    const data = loadSources(sources);
+    const names = listTables(data.lists).sort();
+    const templates = Object.keys(data.templates).sort();
+
+    if (options.format === 'json') {
+        io.stdout(`${JSON.stringify({ tables: names, templates }, null, 2)}\n`);
+    } else {
+        io.stdout(names.map(name => `${name}\n`).join(''));
+        io.stdout(templates.map(name => `templates.${name}\n`).join(''));
+    }
+    return 0;
+}
+
+function compile(sources: { [file: string]: string }, options: CliOptions, io: CliIO): number {
+    const compiledFile = Object.keys(sources).find(file => isCompiled(sources[file]));
+    if (compiledFile !== undefined) {
+        throw new ParseError('invalid_compiled', 'Already a compiled table set', { file: compiledFile });
+    }
+    const compiled = compileSigilData(sources);
+    if (options.output === undefined) {
+        io.stdout(`${compiled}\n`);
+    } else {
+        fs.writeFileSync(options.output, compiled);
+    }
+    return 0;
+}
+
+// Keeps running after runCli returns; SIGINT or SIGTERM stop new connections and let open requests finish
+function serve(sources: { [file: string]: string }, options: CliOptions, io: CliIO): number {
+    const server = createSigilServer(loadSources(sources));
+    const [host, port] = (options.addr ?? ':8080').split(':');
+    server.on('error', error => {
+        io.stderr(`sigil: ${error.message}\n`);
+        process.exitCode = 2;
+    });
+    server.listen(Number(port), host || undefined, () => {
+        const address = server.address();
+        const shown = typeof address === 'object' && address ? `${host || 'localhost'}:${address.port}` : options.addr;
+        io.stderr(`Serving ${Object.keys(sources).length} file${Object.keys(sources).length === 1 ? '' : 's'} on http://${shown}\n`);
+    });
+    const stop = () => {
+        io.stderr('Shutting down\n');
+        server.close();
+        server.closeIdleConnections?.();
+    };
+    process.once('SIGINT', stop);
+    process.once('SIGTERM', stop);
+    return 0;
+}
+
+// Each file on its own; a file that does not load is reported and left untouched
+function format(options: CliOptions, io: CliIO): number {
+    const files: string[] = [];
+    for (const file of options.files.length > 0 ? options.files : ['-']) {
+        if (file !== '-' && fs.existsSync(file) && fs.statSync(file).isDirectory()) {
+            files.push(...Object.keys(readSigilDirectory(file)).map(relativePath => path.join(file, relativePath)));
+        } else {
+            files.push(file);
+        }
+    }
+
+    let failed = false;
+    for (const file of files) {
+        let source: string;
+        let decoded: DecodedFile | undefined;
+        try {
+            decoded = file === '-' ? undefined : readText(file, io);
+            source = decoded ? decoded.text : io.readStdin();
+        } catch (error) {
+            io.stderr(`sigil: Cannot read "${file}": ${error instanceof Error ? error.message : error}\n`);
+            return 2;
+        }
+        let formatted: string;
+        try {
+            formatted = formatSigil(source, { order: options.order });
+        } catch (error) {
+            io.stderr(`sigil: ${file === '-' ? '<stdin>' : file}: ${error instanceof Error ? error.message : error}\n`);
+            failed = true;
+            continue;
+        }
+        if (!options.write) {
+            io.stdout(formatted);
+        } else if (formatted !== source) {
+            // Written back in the file's own encoding and line endings
+            fs.writeFileSync(file, encodeSigilFile(formatted, decoded));
+            io.stdout(`${file}\n`);
+        }
+    }
+    return failed ? 1 : 0;
+}
+
+// One input converted to SIGIL YAML; what could not be converted is warned about on stderr
+function convert(options: CliOptions, io: CliIO): number {
+    const file = options.files[0] ?? '-';
+    const display = file === '-' ? '<stdin>' : file;
+    let content: string;
+    try {
+        content = file === '-' ? io.readStdin() : readText(file, io).text;
+    } catch (error) {
+        io.stderr(`sigil: Cannot read "${file}": ${error instanceof Error ? error.message : error}\n`);
+        return 2;
+    }
+
+    // CSV and JSON hold a single table, named after the file
+    const name = file === '-' ? 'table' : path.basename(file, path.extname(file)).replace(/[^\w-]+/g, '_');
+    let result: ConversionResult;
+    try {
+        switch (options.from) {
+            case 'perchance': result = importPerchance(content); break;
+            case 'markdown': result = importMarkdownTable(content, { name }); break;
+            case 'csv': result = { tables: new TableSet().register(importCsv(content, { name })), warnings: [] }; break;
+            default: result = { tables: new TableSet().register(importJson(content, { name })), warnings: [] };
+        }
+    } catch (error) {
+        io.stderr(`sigil: ${display}: ${error instanceof Error ? error.message : error}\n`);
+        return 1;
+    }
+
+    for (const warning of result.warnings) {
+        io.stderr(`${display}:${warning.line}: warning: ${warning.message}\n`);
+    }
+    if (result.tables.names().length === 0) {
+        io.stderr(`sigil: ${display}: no tables found\n`);
+        return 1;
+    }
+    const source = result.tables.toSource();
+    if (options.output === undefined) {
+        io.stdout(source);
+    } else {
+        fs.writeFileSync(options.output, source);
+    }
+    return 0;
+}
+
+// The tables of YAML files, or of one file written by "sigil compile"
+function loadSources(sources: { [file: string]: string }): LoadedData {
+    const compiledFile = Object.keys(sources).find(file => isCompiled(sources[file]));
+    if (compiledFile === undefined) {
+        return createSigilDataFromFiles(sources);
+    }
+    if (Object.keys(sources).length > 1) {
+        throw new ParseError('invalid_compiled', 'A compiled table set cannot be loaded with other files', { file: compiledFile });
+    }
+    try {
+        return loadCompiled(sources[compiledFile]);
+    } catch (error) {
+        throw withFile(error, compiledFile);
+    }
+}
+
+// Every file's tables merged without "@extend", or undefined when a file does not parse
+function mergeParsable(sources: { [file: string]: string }): SigilData | undefined {
+    try {
+        return mergeLists(Object.values(sources).map(source => parseYamlContent(source)));
+    } catch (error) {
+        return undefined;
+    }
+}
+
+// Templates become a "templates" group so [templates.name] picks one of their entries
+function withTemplates(data: LoadedData): SigilData {
+    return data.lists.templates === undefined && Object.keys(data.templates).length > 0
+        ? { ...data.lists, templates: data.templates }
+        : data.lists;
+}
@@ -0,0 +1,114 @@
+/**
+ * SIGIL Compiled Table Sets
+ * Parsing a large collection on every start is slow. compileSigilData does it
+ * once and saves the result (includes, extensions and roll tables already
+ * applied) as versioned JSON, which loads without a YAML parser:
+ *
+ *   const files = readSigilDirectory('tables/');
+ *   fs.writeFileSync('tables.sgc', compileSigilData(files));
+ *   const data = loadCompiled(fs.readFileSync('tables.sgc', 'utf8'), { sources: files });
+ *
+ * The saved hash covers the content of every source file, so passing `sources`
+ * turns a stale compiled file into a `stale_compiled` ParseError instead of
+ * old tables; catch it and compile again.
+ */
+
+import { createSigilDataFromFiles } from './yaml-loader';
+import type { DirectoryLoadOptions, LoadedData } from './yaml-loader';
+import { ParseError } from './errors';
+
+/** Bumped whenever the saved shape changes; other versions are refused */
+export const COMPILED_FORMAT_VERSION = 1;
+
+const FORMAT = 'sigil-compiled';
+
+export interface CompiledSigilData extends LoadedData {
+    format: typeof FORMAT;
+    version: number;
+    /** compiledSourceHash of the files it was compiled from */
+    sourceHash: string;
+}
+
+export interface LoadCompiledOptions {
+    /** The source files, to check that the compiled file is still up to date */
+    sources?: { [relativePath: string]: string };
+    /** The options the files were compiled with */
+    loadOptions?: DirectoryLoadOptions;
+}
+
+/**
+ * Parse a set of files as createSigilDataFromFiles would and save the result
+ * as compiled JSON
+ */
+export function compileSigilData(files: { [relativePath: string]: string }, options: DirectoryLoadOptions = {}): string {
+    const { lists, templates } = createSigilDataFromFiles(files, options);
+    const compiled: CompiledSigilData = {
+        format: FORMAT,
+        version: COMPILED_FORMAT_VERSION,
+        sourceHash: compiledSourceHash(files, options),
+        lists,
+        templates
+    };
+    return JSON.stringify(compiled);
+}
+
+/**
+ * Load tables saved by compileSigilData. Throws ParseError `invalid_compiled`
+ * for anything else (including other format versions) and `stale_compiled`
+ * when `sources` no longer match.
+ */
+export function loadCompiled(content: string, options: LoadCompiledOptions = {}): LoadedData {
+    let compiled: Partial<CompiledSigilData>;
+    try {
+        compiled = JSON.parse(content);
+    } catch (error) {
+        throw new ParseError('invalid_compiled', 'Not a compiled table set');
+    }
+    if (!isCompiledSigilData(compiled)) {
+        throw new ParseError('invalid_compiled', 'Not a compiled table set');
+    }
+    if (compiled.version !== COMPILED_FORMAT_VERSION) {
+        throw new ParseError('invalid_compiled',
+            `Compiled table set has format version ${compiled.version}; this version of SIGIL reads ${COMPILED_FORMAT_VERSION}`);
+    }
+    if (options.sources && compiledSourceHash(options.sources, options.loadOptions) !== compiled.sourceHash) {
+        throw new ParseError('stale_compiled', 'Compiled table set is out of date with its source files');
+    }
+    return { lists: compiled.lists, templates: compiled.templates };
+}
+
+/**
+ * True when the text looks like a compiled table set (of any version)
+ */
+export function isCompiled(content: string): boolean {
+    if (!content.trimStart().startsWith('{')) return false;
+    try {
+        return isCompiledSigilData(JSON.parse(content));
+    } catch (error) {
+        return false;
+    }
+}
+
+/**
+ * A hash of the files' contents (not their order) and the namespace option
+ */
+export function compiledSourceHash(files: { [relativePath: string]: string }, options: DirectoryLoadOptions = {}): string {
+    // With namespaces the paths name the tables, so they count too
+    const parts = Object.entries(files).map(([file, content]) => options.namespace ? `${file}\0${content}` : content).sort();
+    const text = `${options.namespace ? 'namespace' : ''}\0${parts.join('\0')}`;
+    // Two 32-bit FNV-1a passes with different offsets make a 64-bit hash
+    let low = 0x811c9dc5;
+    let high = 0x01000193;
+    for (let i = 0; i < text.length; i++) {
+        const code = text.charCodeAt(i);
+        low = Math.imul(low ^ code, 0x01000193);
+        high = Math.imul(high ^ code, 0x01000193) ^ (low >>> 15);
+    }
+    return (high >>> 0).toString(16).padStart(8, '0') + (low >>> 0).toString(16).padStart(8, '0');
+}
+
+function isCompiledSigilData(value: unknown): value is CompiledSigilData {
+    const compiled = value as Partial<CompiledSigilData> | null;
+    return typeof compiled === 'object' && compiled !== null && compiled.format === FORMAT
+        && typeof compiled.lists === 'object' && typeof compiled.templates === 'object';
+}
@@ -0,0 +1,342 @@
+/**
+ * SIGIL Format Converters
+ * Best-effort conversion of generators written for other tools into a TableSet:
+ * - Perchance lists: a list name, then its items indented below it
+ * - Markdown pipe tables, e.g. a "| d20 | Result |" roll table under a heading
+ *
+ * Constructs SIGIL has no counterpart for (JavaScript in Perchance references,
+ * imports, dynamic weights, extra table columns, ...) are dropped, and every
+ * drop is reported as a warning with its line instead of failing the import.
+ * The result can be used as engine data (toData) or saved with toSource.
+ */
+
+import { SigilTable, TableSet } from './table-builder';
+import type { ImportOptions } from './importer';
+
+export interface ConversionWarning {
+    /** 1-based line in the converted text */
+    line: number;
+    message: string;
+}
+
+export interface ConversionResult {
+    tables: TableSet;
+    warnings: ConversionWarning[];
+}
+
+interface SourceLine {
+    line: number;
+    indent: number;
+    text: string;
+    children: SourceLine[];
+}
+
+// Perchance methods with a SIGIL modifier; selectOne is what a reference does anyway
+const PERCHANCE_METHODS: { [method: string]: string } = {
+    selectOne: '',
+    sentenceCase: 'capitalize',
+    upperCase: 'upper',
+    lowerCase: 'lowercase',
+    pluralForm: 'pluralForm'
+};
+
+const DICE_HEADER = /^(\d*)d(\d+|%)$/i;
+const RANGE_CELL = /^(\d+)\s*(?:[-–—]|to)\s*(\d+)$|^(\d+)$/i;
+
+/**
+ * Convert Perchance-style lists. Sublists (items with items of their own)
+ * become tables named parent_sublist, and "$output = ..." becomes an output table.
+ */
+export function importPerchance(content: string): ConversionResult {
+    const warnings: ConversionWarning[] = [];
+    const warn = (line: number, message: string) => warnings.push({ line, message });
+    const tables = new TableSet();
+
+    const roots = indentTree(content.replace(/^﻿/, '').split(/\r?\n/).map(stripPerchanceComment));
+
+    // Sublist names first, so [creature.mammal] can point at creature_mammal wherever it is used
+    const lists = new Map<string, SourceLine>();
+    const collect = (node: SourceLine, name: string) => {
+        lists.set(name, node);
+        for (const child of node.children) {
+            if (child.children.length > 0) collect(child, `${name}_${tableName(child.text)}`);
+        }
+    };
+    for (const root of roots) {
+        const assignment = root.text.match(/^\$?([A-Za-z_][\w-]*)\s*=\s*(.*)$/);
+        if (assignment) {
+            lists.set(assignment[1], { ...root, text: assignment[1], children: [{ ...root, text: assignment[2], children: [] }] });
+        } else if (!/^[A-Za-z_][\w-]*$/.test(root.text)) {
+            warn(root.line, `Dropped "${root.text}": not a list name`);
+        } else if (root.children.length === 0) {
+            warn(root.line, `Dropped list "${root.text}": it has no items`);
+        } else {
+            collect(root, root.text);
+        }
+    }
+
+    for (const [name, list] of lists) {
+        const table = new SigilTable(name);
+        for (const item of list.children) {
+            if (item.children.length > 0) {
+                table.add(`[${name}_${tableName(item.text)}]`);
+                continue;
+            }
+            const entry = convertPerchanceEntry(item.text, item.line, lists, warn);
+            if (entry) table.add(entry.text, entry.weight);
+        }
+        if (table.entries.length > 0) {
+            tables.register(table);
+        } else {
+            warn(list.line, `Dropped list "${name}": none of its items could be converted`);
+        }
+    }
+    return { tables, warnings };
+}
+
+/**
+ * Convert every pipe table in Markdown text. A table is named after the heading
+ * above it (or options.name, default "table"). A dice column header (d20, 2d6, d%)
+ * or a range/roll column makes a roll table; a weight column is used as weights.
+ * Cell text is literal: brackets and braces are escaped, links keep their text.
+ */
+export function importMarkdownTable(content: string, options: ImportOptions = {}): ConversionResult {
+    const warnings: ConversionWarning[] = [];
+    const warn = (line: number, message: string) => warnings.push({ line, message });
+    const tables = new TableSet();
+    const lines = content.replace(/^﻿/, '').split(/\r?\n/);
+    let heading: string | undefined;
+
+    for (let i = 0; i < lines.length; i++) {
+        const title = lines[i].match(/^#{1,6}\s+(.+?)\s*#*\s*$/);
+        if (title) {
+            heading = title[1];
+            continue;
+        }
+        if (!lines[i].includes('|') || !isSeparatorRow(lines[i + 1] || '')) continue;
+
+        const header = splitRow(lines[i]);
+        const rows: { line: number; cells: string[] }[] = [];
+        let next = i + 2;
+        while (next < lines.length && lines[next].includes('|') && lines[next].trim() !== '') {
+            rows.push({ line: next + 1, cells: splitRow(lines[next]) });
+            next++;
+        }
+
+        const name = uniqueName(tableName(heading ?? options.name ?? 'table'), tables);
+        const table = convertMarkdownTable(name, header, rows, i + 1, warn);
+        if (table) tables.register(table);
+        heading = undefined;
+        i = next - 1;
+    }
+    return { tables, warnings };
+}
+
+function convertPerchanceEntry(
+    item: string,
+    line: number,
+    lists: Map<string, SourceLine>,
+    warn: (line: number, message: string) => void
+): { text: string; weight: number } | undefined {
+    let text = item;
+    let weight = 1;
+    const weighted = text.match(/^(.*?)\s*\^(\d*\.?\d+)$/);
+    const dynamic = text.match(/^(.*?)\s*\^\[.*\]$/);
+    if (weighted) {
+        text = weighted[1];
+        weight = parseFloat(weighted[2]);
+        if (weight <= 0) {
+            warn(line, `Dropped "${item}": its weight is zero`);
+            return undefined;
+        }
+    } else if (dynamic) {
+        text = dynamic[1];
+        warn(line, `Dropped the dynamic weight of "${text}"`);
+    }
+
+    if (/\{import:/.test(text)) {
+        warn(line, `Dropped "${item}": imports of other generators are not supported`);
+        return undefined;
+    }
+    if (text.includes('{A}')) {
+        text = text.replace(/\{A\}/g, '{a}');
+        warn(line, 'Wrote {A} as {a}: SIGIL articles are lowercase');
+    }
+
+    let unsupported: string | undefined;
+    text = text.replace(/\[([^\[\]]*)\]/g, (whole, inner: string) => {
+        const reference = convertPerchanceReference(inner.trim(), lists, method => warn(line, `Dropped .${method} from [${inner}]`));
+        if (reference === undefined) unsupported ??= whole;
+        return reference ?? whole;
+    });
+    if (unsupported) {
+        warn(line, `Dropped "${item}": ${unsupported} is code SIGIL cannot run`);
+        return undefined;
+    }
+    if (text.trim() === '') return undefined;
+    return { text: text.trim(), weight };
+}
+
+// "list.method.method" -> "[list.modifier*n]"; undefined when the reference is code
+function convertPerchanceReference(inner: string, lists: Map<string, SourceLine>, dropped: (method: string) => void): string | undefined {
+    if (!/^[A-Za-z_][\w-]*(\.\w+(\(\d*\))?)*$/.test(inner)) return undefined;
+    const [first, ...rest] = inner.split('.');
+    let path = first;
+    const modifiers: string[] = [];
+    let count = '';
+    for (const part of rest) {
+        const call = part.match(/^(\w+)(?:\((\d*)\))?$/)!;
+        const [, method, argument] = call;
+        if (argument === undefined && lists.has(`${path}_${method}`)) {
+            path = `${path}_${method}`;
+        } else if ((method === 'selectMany' || method === 'selectUnique') && argument) {
+            if (method === 'selectUnique') modifiers.push('unique');
+            count = `*${argument}`;
+        } else if (PERCHANCE_METHODS[method] !== undefined) {
+            if (PERCHANCE_METHODS[method]) modifiers.push(PERCHANCE_METHODS[method]);
+        } else {
+            dropped(part);
+        }
+    }
+    return `[${[path, ...modifiers].join('.')}${count}]`;
+}
+
+function convertMarkdownTable(
+    name: string,
+    header: string[],
+    rows: { line: number; cells: string[] }[],
+    line: number,
+    warn: (line: number, message: string) => void
+): SigilTable | undefined {
+    const lower = header.map(cell => cell.toLowerCase());
+    const diceColumn = header.findIndex(cell => DICE_HEADER.test(cell));
+    const rangeColumn = diceColumn !== -1 ? diceColumn : lower.findIndex(cell => ['range', 'roll', 'die', 'dice'].includes(cell));
+    const weightColumn = lower.findIndex(cell => cell === 'weight' || cell === 'w');
+    let textColumn = lower.findIndex(cell => ['text', 'entry', 'result', 'value', 'item', 'name'].includes(cell));
+    if (textColumn === -1) textColumn = header.findIndex((_, index) => index !== rangeColumn && index !== weightColumn);
+    if (textColumn === -1) {
+        warn(line, `Dropped table "${name}": it has no text column`);
+        return undefined;
+    }
+    for (let index = 0; index < header.length; index++) {
+        if (index !== textColumn && index !== rangeColumn && index !== weightColumn) {
+            warn(line, `Dropped column "${header[index]}" of table "${name}"`);
+        }
+    }
+
+    const percentile = diceColumn !== -1 && /d(%|100)$/i.test(header[diceColumn]);
+    const table = new SigilTable(name);
+    for (const row of rows) {
+        const text = markdownText(row.cells[textColumn] ?? '', () => warn(row.line, 'Dropped a link target, keeping its text'));
+        if (text === '') {
+            warn(row.line, 'Dropped a row with no text');
+            continue;
+        }
+        let weight = 1;
+        if (weightColumn !== -1 && (row.cells[weightColumn] ?? '') !== '') {
+            weight = Number(row.cells[weightColumn]);
+            if (!Number.isFinite(weight) || weight <= 0) {
+                warn(row.line, `Dropped "${text}": invalid weight "${row.cells[weightColumn]}"`);
+                continue;
+            }
+        }
+        if (rangeColumn === -1) {
+            table.add(text, weight);
+            continue;
+        }
+        const range = (row.cells[rangeColumn] ?? '').match(RANGE_CELL);
+        if (!range) {
+            warn(row.line, `Dropped "${text}": "${row.cells[rangeColumn] ?? ''}" is not a roll or range`);
+            continue;
+        }
+        // On a d% table 00 stands for 100
+        const bound = (cell: string) => (percentile && /^0+$/.test(cell) ? 100 : parseInt(cell, 10));
+        const min = bound(range[1] ?? range[3]);
+        const max = bound(range[2] ?? range[3]);
+        if (max < min) {
+            warn(row.line, `Dropped "${text}": range ${min}-${max} runs backwards`);
+            continue;
+        }
+        table.entries.push({ text, weight: 1, min, max });
+    }
+
+    if (table.entries.length === 0) {
+        warn(line, `Dropped table "${name}": it has no rows`);
+        return undefined;
+    }
+    if (diceColumn !== -1) {
+        const die = header[diceColumn].toLowerCase().replace(/^d/, '1d').replace('d%', 'd100');
+        const highest = Math.max(...table.entries.map(entry => entry.max!));
+        if (die !== `1d${highest}`) table.die = header[diceColumn].toLowerCase();
+    }
+    return table;
+}
+
+// Perchance comments start with "//" at the start of a line or after a space
+function stripPerchanceComment(line: string): string {
+    return line.replace(/(^|\s)\/\/.*$/, '').trimEnd();
+}
+
+// Lines nested under the closest less indented line (tabs count as two spaces)
+function indentTree(lines: string[]): SourceLine[] {
+    const root: SourceLine = { line: 0, indent: -1, text: '', children: [] };
+    const stack = [root];
+    lines.forEach((raw, index) => {
+        const text = raw.trim();
+        if (text === '') return;
+        const indent = raw.replace(/\t/g, '  ').search(/\S/);
+        while (stack[stack.length - 1].indent >= indent) stack.pop();
+        const node: SourceLine = { line: index + 1, indent, text, children: [] };
+        stack[stack.length - 1].children.push(node);
+        stack.push(node);
+    });
+    return root.children;
+}
+
+function isSeparatorRow(line: string): boolean {
+    return /^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$/.test(line) && line.includes('-');
+}
+
+// Cells of "| a | b \| c |" -> ["a", "b \| c"]; escaped pipes stay escaped for SIGIL
+function splitRow(line: string): string[] {
+    const cells: string[] = [];
+    let cell = '';
+    const text = line.trim().replace(/^\|/, '').replace(/(^|[^\\])\|$/, '$1');
+    for (let i = 0; i < text.length; i++) {
+        if (text[i] === '\\' && text[i + 1] === '|') {
+            cell += '\\|';
+            i++;
+        } else if (text[i] === '|') {
+            cells.push(cell.trim());
+            cell = '';
+        } else {
+            cell += text[i];
+        }
+    }
+    cells.push(cell.trim());
+    return cells;
+}
+
+// Cell text as a literal SIGIL entry: links keep their text, sigil characters are escaped
+function markdownText(cell: string, droppedLink: () => void): string {
+    return cell
+        .replace(/!?\[([^\]]*)\]\([^)]*\)/g, (_, text: string) => {
+            droppedLink();
+            return text;
+        })
+        .replace(/<br\s*\/?>/gi, ' ')
+        .replace(/(^|[^\\])([\[\]{}])/g, '$1\\$2')
+        .replace(/(^|[^\\])([\[\]{}])/g, '$1\\$2')
+        .trim();
+}
+
+// "Wandering Monsters (Forest)" -> "wandering_monsters_forest"
+function tableName(text: string): string {
+    return text.toLowerCase().replace(/[^a-z0-9_-]+/g, '_').replace(/^_+|_+$/g, '') || 'table';
+}
+
+function uniqueName(name: string, tables: TableSet): string {
+    let unique = name;
+    for (let n = 2; tables.lookup(unique); n++) unique = `${name}_${n}`;
+    return unique;
+}
@@ -0,0 +1,117 @@
+/**
+ * SIGIL Dependency Graphs
+ * Which tables a template can reach, found without generating anything:
+ *
+ *   engine.dependencies('[encounter] in the [place.[biome]]')
+ *   // tables: ['encounter', 'monster', 'biome'], missing: ['monster'],
+ *   // dynamic: ['place.[biome]'], edges: [{ from: '', to: 'encounter' }, ...]
+ *
+ * Every entry of every reachable table is followed, so a table counts as soon as
+ * some pick could use it. References whose path is built while generating (such
+ * as [place.[biome]]) are reported as dynamic nodes; the tables inside their path
+ * are followed as usual. Names that a reachable entry captures with "->name" are
+ * variables, not missing tables.
+ */
+
+import { parseCompleteTemplate, walkTemplateNodes } from './template-parser';
+
+export interface DependencyEdge {
+    /** The table whose entries hold the reference, or '' for the template itself */
+    from: string;
+    /** A table (found or missing) or a dynamic path */
+    to: string;
+}
+
+export interface DependencyGraph {
+    /** Defined tables the template reaches, in the order they were found */
+    tables: string[];
+    /** Referenced tables that are not defined */
+    missing: string[];
+    /** Reference paths built at generation time, e.g. "place.[biome]" */
+    dynamic: string[];
+    edges: DependencyEdge[];
+}
+
+/**
+ * Finds a referenced table: its name with any modifiers taken off, and the text
+ * of its entries; undefined when no table has that name
+ */
+export type DependencyResolver = (tablePath: string) => { table: string; entries: string[] } | undefined;
+
+/**
+ * Walk a template and every table it can reach
+ */
+export function findDependencies(template: string, resolve: DependencyResolver): DependencyGraph {
+    const graph: DependencyGraph = { tables: [], missing: [], dynamic: [], edges: [] };
+    const captured = new Set<string>();
+    const unresolved: DependencyEdge[] = [];
+    const edgeKeys = new Set<string>();
+    const addEdge = (from: string, to: string) => {
+        if (edgeKeys.has(`${from}\n${to}`)) return;
+        edgeKeys.add(`${from}\n${to}`);
+        graph.edges.push({ from, to });
+    };
+
+    const pending: { from: string; text: string }[] = [{ from: '', text: template }];
+    while (pending.length > 0) {
+        const { from, text } = pending.shift()!;
+        for (const reference of scanReferences(text, captured)) {
+            if (/[\[{]/.test(reference)) {
+                if (!graph.dynamic.includes(reference)) graph.dynamic.push(reference);
+                addEdge(from, reference);
+                // "place.[biome]" still depends on biome
+                pending.push({ from, text: reference });
+                continue;
+            }
+            const found = resolve(reference);
+            if (!found) {
+                unresolved.push({ from, to: reference });
+                continue;
+            }
+            addEdge(from, found.table);
+            if (!graph.tables.includes(found.table)) {
+                graph.tables.push(found.table);
+                pending.push(...found.entries.map(entry => ({ from: found.table, text: entry })));
+            }
+        }
+    }
+
+    // Captures can come after the reference that reads them, so missing tables are settled last
+    for (const edge of unresolved) {
+        if (captured.has(edge.to)) continue;
+        if (!graph.missing.includes(edge.to)) graph.missing.push(edge.to);
+        addEdge(edge.from, edge.to);
+    }
+    return graph;
+}
+
+/**
+ * The graph in Graphviz DOT syntax; missing tables are dashed and dynamic paths
+ * are diamonds, e.g. for `dot -Tsvg`
+ */
+export function dependencyGraphToDot(graph: DependencyGraph): string {
+    const id = (name: string) => JSON.stringify(name === '' ? 'template' : name);
+    const lines = ['digraph dependencies {', `    ${id('')} [shape=box];`];
+    for (const name of graph.missing) lines.push(`    ${id(name)} [style=dashed];`);
+    for (const name of graph.dynamic) lines.push(`    ${id(name)} [shape=diamond];`);
+    for (const edge of graph.edges) lines.push(`    ${id(edge.from)} -> ${id(edge.to)};`);
+    lines.push('}');
+    return lines.join('\n') + '\n';
+}
+
+// Reference paths in one template, noting the variables it captures on the way
+function scanReferences(text: string, captured: Set<string>): string[] {
+    const references: string[] = [];
+    try {
+        walkTemplateNodes(parseCompleteTemplate(text), node => {
+            if (node.type === 'capture') {
+                captured.add(node.variable);
+            } else if (node.type === 'table' && !references.includes(node.tablePath)) {
+                references.push(node.tablePath);
+            }
+        });
+    } catch (error) {
+        // text that does not parse refers to nothing
+    }
+    return references;
+}
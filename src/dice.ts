@@ -0,0 +1,146 @@
+/**
+ * SIGIL Dice Notation
+ * Parses and rolls standard tabletop dice expressions used inside reference sigils:
+ * - [1d4], [d20], [d%] (percentile)
+ * - [2d6+3], [1d8-1+2] (flat modifiers are summed)
+ * - [4d6kh3], [2d20kl1] (keep highest/lowest N)
+ * - [4d6dl1], [3d6dh1] (drop lowest/highest N)
+ */
+
+import { RandomSource } from './random';
+
+export interface DiceSpec {
+    expression: string;
+    count: number;
+    sides: number;
+    keep?: { highest: boolean; count: number };
+    modifier: number;
+}
+
+export interface DiceRoll {
+    total: number;
+    rolls: number[];  // every die rolled, in roll order
+    kept: number[];   // the dice that count towards the total
+}
+
+const MAX_DICE = 1000;
+
+const DICE_PATTERN = /^(\d*)d(\d+|%)(?:(kh|kl|k|dh|dl|d)(\d+))?((?:[+-]\d+)*)$/i;
+
+/**
+ * Decide whether reference content is meant as a dice expression: the whole of
+ * it must be dice notation, so tables like "2dungeons" or "d20_results" are
+ * looked up. Counts and sides out of range ("0d6", "4d6kh5") still count as
+ * dice, so the roll reports them.
+ */
+export function isDiceExpression(content: string): boolean {
+    return DICE_PATTERN.test(content.trim());
+}
+
+/**
+ * Parse dice notation into a DiceSpec.
+ * Throws an Error naming the expression when it is not valid dice notation.
+ */
+export function parseDice(expression: string): DiceSpec {
+    const text = expression.trim();
+    const match = text.match(DICE_PATTERN);
+    if (!match) {
+        throw new Error(`Invalid dice expression "${text}": expected NdS with optional kh/kl/dh/dl and +/- modifiers`);
+    }
+
+    const [, countPart, sidesPart, keepMode, keepPart, modifierPart] = match;
+    const count = countPart ? parseInt(countPart) : 1;
+    const sides = sidesPart === '%' ? 100 : parseInt(sidesPart);
+
+    if (count < 1 || count > MAX_DICE) {
+        throw new Error(`Invalid dice expression "${text}": dice count must be between 1 and ${MAX_DICE}`);
+    }
+    if (sides < 1) {
+        throw new Error(`Invalid dice expression "${text}": dice must have at least one side`);
+    }
+
+    let keep: DiceSpec['keep'];
+    if (keepMode) {
+        const mode = keepMode.toLowerCase();
+        const n = parseInt(keepPart);
+        if (mode.startsWith('k')) {
+            if (n < 1 || n > count) {
+                throw new Error(`Invalid dice expression "${text}": cannot keep ${n} of ${count} dice`);
+            }
+            keep = { highest: mode !== 'kl', count: n };
+        } else {
+            if (n >= count) {
+                throw new Error(`Invalid dice expression "${text}": cannot drop ${n} of ${count} dice`);
+            }
+            // Dropping the lowest N is keeping the highest (count - N), and vice versa
+            keep = { highest: mode !== 'dh', count: count - n };
+        }
+    }
+
+    const modifier = (modifierPart.match(/[+-]\d+/g) || []).reduce((sum, term) => sum + parseInt(term), 0);
+
+    return { expression: text, count, sides, keep, modifier };
+}
+
+/**
+ * Roll a parsed dice expression
+ */
+export function rollDice(spec: DiceSpec, random: RandomSource = Math.random): DiceRoll {
+    const rolls: number[] = [];
+    for (let i = 0; i < spec.count; i++) {
+        rolls.push(Math.floor(random() * spec.sides) + 1);
+    }
+
+    let kept = rolls;
+    if (spec.keep) {
+        const { highest, count } = spec.keep;
+        const sorted = [...rolls].sort((a, b) => highest ? b - a : a - b);
+        kept = sorted.slice(0, count);
+    }
+
+    const total = kept.reduce((sum, value) => sum + value, 0) + spec.modifier;
+    return { total, rolls, kept };
+}
+
+/**
+ * Every total a dice expression can produce with the number of ways to roll it,
+ * lowest total first. Returns undefined when there are too many combinations to
+ * count ("1000d1000", or keep/drop over many dice), since callers then sample instead.
+ */
+export function diceDistribution(spec: DiceSpec, limit: number = 1000000): { total: number; ways: number }[] | undefined {
+    let ways: Map<number, number>;
+    if (!spec.keep) {
+        // Add one die at a time: ways to reach each sum
+        if (Math.pow(spec.count * spec.sides, 2) > limit) return undefined;
+        ways = new Map([[0, 1]]);
+        for (let i = 0; i < spec.count; i++) {
+            const next = new Map<number, number>();
+            for (const [sum, count] of ways) {
+                for (let face = 1; face <= spec.sides; face++) {
+                    next.set(sum + face, (next.get(sum + face) || 0) + count);
+                }
+            }
+            ways = next;
+        }
+    } else {
+        // Keeping dice depends on the whole roll, so walk every combination
+        if (Math.pow(spec.sides, spec.count) > limit) return undefined;
+        ways = new Map();
+        const rolls = new Array(spec.count).fill(1);
+        for (;;) {
+            const { highest, count } = spec.keep;
+            const kept = [...rolls].sort((a, b) => highest ? b - a : a - b).slice(0, count);
+            const sum = kept.reduce((total, value) => total + value, 0);
+            ways.set(sum, (ways.get(sum) || 0) + 1);
+
+            let i = 0;
+            while (i < rolls.length && rolls[i] === spec.sides) rolls[i++] = 1;
+            if (i === rolls.length) break;
+            rolls[i]++;
+        }
+    }
+
+    return [...ways.entries()]
+        .sort((a, b) => a[0] - b[0])
+        .map(([sum, count]) => ({ total: sum + spec.modifier, ways: count }));
+}
@@ -0,0 +1,183 @@
+/**
+ * SIGIL File Encodings
+ * Table files shared around come as UTF-8 (with or without a BOM), UTF-16 and
+ * Windows-1252. decodeSigilFile turns any of them into plain text and notes
+ * what it found, so a tool can write the file back the same way:
+ *
+ *   const file = decodeSigilFile(fs.readFileSync('tables.yaml'));
+ *   file.text                                        // LF line endings, no BOM
+ *   fs.writeFileSync('tables.yaml', encodeSigilFile(formatSigil(file.text), file));
+ *
+ * Bytes that are not valid in the detected encoding become U+FFFD and are
+ * counted in `replaced` instead of failing the read. Works in browsers too
+ * (no Buffer needed).
+ */
+
+export type SigilFileEncoding = 'utf-8' | 'utf-16le' | 'utf-16be' | 'windows-1252';
+
+export interface DecodedFile {
+    /** The file's text with "\n" line endings and without a BOM */
+    text: string;
+    encoding: SigilFileEncoding;
+    /** Whether the file started with a byte order mark */
+    bom: boolean;
+    /** 'crlf' when most lines ended in "\r\n" */
+    lineEnding: 'lf' | 'crlf';
+    /** Invalid byte sequences replaced with U+FFFD */
+    replaced: number;
+}
+
+export type EncodeOptions = Partial<Pick<DecodedFile, 'encoding' | 'bom' | 'lineEnding'>>;
+
+// Windows-1252 characters in 0x80-0x9F; the other bytes there are C1 controls, and the rest match Latin-1
+const WINDOWS_1252: { [byte: number]: number } = {
+    0x80: 0x20AC, 0x82: 0x201A, 0x83: 0x0192, 0x84: 0x201E, 0x85: 0x2026, 0x86: 0x2020, 0x87: 0x2021,
+    0x88: 0x02C6, 0x89: 0x2030, 0x8A: 0x0160, 0x8B: 0x2039, 0x8C: 0x0152, 0x8E: 0x017D,
+    0x91: 0x2018, 0x92: 0x2019, 0x93: 0x201C, 0x94: 0x201D, 0x95: 0x2022, 0x96: 0x2013, 0x97: 0x2014,
+    0x98: 0x02DC, 0x99: 0x2122, 0x9A: 0x0161, 0x9B: 0x203A, 0x9C: 0x0153, 0x9E: 0x017E, 0x9F: 0x0178
+};
+const TO_WINDOWS_1252 = new Map(Object.entries(WINDOWS_1252).map(([byte, code]) => [code, Number(byte)]));
+
+/**
+ * Detect a file's encoding (BOM first, then UTF-16 and UTF-8 patterns,
+ * else Windows-1252) and decode it
+ */
+export function decodeSigilFile(bytes: Uint8Array): DecodedFile {
+    let encoding: SigilFileEncoding;
+    let bom = true;
+    let body = bytes;
+    if (bytes[0] === 0xEF && bytes[1] === 0xBB && bytes[2] === 0xBF) {
+        encoding = 'utf-8';
+        body = bytes.subarray(3);
+    } else if (bytes[0] === 0xFF && bytes[1] === 0xFE) {
+        encoding = 'utf-16le';
+        body = bytes.subarray(2);
+    } else if (bytes[0] === 0xFE && bytes[1] === 0xFF) {
+        encoding = 'utf-16be';
+        body = bytes.subarray(2);
+    } else {
+        bom = false;
+        encoding = sniffEncoding(bytes);
+    }
+
+    let text: string;
+    let replaced = 0;
+    if (encoding === 'windows-1252') {
+        text = '';
+        for (const byte of body) text += String.fromCharCode(WINDOWS_1252[byte] ?? byte);
+    } else if (encoding === 'utf-8') {
+        text = new TextDecoder('utf-8').decode(body);
+        replaced = countReplacements(text) - countEncodedReplacements(body);
+    } else {
+        const units = encoding === 'utf-16le' ? body : swapBytes(body);
+        text = new TextDecoder('utf-16le').decode(units);
+        replaced = countReplacements(text) - countUtf16Replacements(units);
+    }
+
+    const crlf = (text.match(/\r\n/g) || []).length;
+    const lf = (text.match(/\n/g) || []).length;
+    return {
+        text: text.replace(/\r\n/g, '\n'),
+        encoding,
+        bom,
+        lineEnding: crlf > 0 && crlf * 2 >= lf ? 'crlf' : 'lf',
+        replaced
+    };
+}
+
+/**
+ * Encode text for saving, by default as UTF-8 with "\n" line endings; pass a
+ * DecodedFile to write a file back the way it was read. Characters that
+ * Windows-1252 cannot hold are written as "?".
+ */
+export function encodeSigilFile(text: string, options: EncodeOptions = {}): Uint8Array {
+    const encoding = options.encoding ?? 'utf-8';
+    const lines = text.replace(/\r\n/g, '\n');
+    const content = options.lineEnding === 'crlf' ? lines.replace(/\n/g, '\r\n') : lines;
+
+    if (encoding === 'utf-8') {
+        const encoded = new TextEncoder().encode(content);
+        return options.bom ? concat([0xEF, 0xBB, 0xBF], encoded) : encoded;
+    }
+    if (encoding === 'windows-1252') {
+        const bytes = new Uint8Array(content.length);
+        for (let i = 0; i < content.length; i++) {
+            const code = content.charCodeAt(i);
+            bytes[i] = code < 0x80 || (code >= 0xA0 && code <= 0xFF) ? code : TO_WINDOWS_1252.get(code) ?? 0x3F;
+        }
+        return bytes;
+    }
+
+    // UTF-16 gets its BOM unless told otherwise, since readers rely on it
+    const bytes = new Uint8Array(content.length * 2);
+    const littleEndian = encoding === 'utf-16le';
+    for (let i = 0; i < content.length; i++) {
+        const code = content.charCodeAt(i);
+        bytes[i * 2] = littleEndian ? code & 0xFF : code >> 8;
+        bytes[i * 2 + 1] = littleEndian ? code >> 8 : code & 0xFF;
+    }
+    return options.bom === false ? bytes : concat(littleEndian ? [0xFF, 0xFE] : [0xFE, 0xFF], bytes);
+}
+
+// Without a BOM: mostly-ASCII UTF-16 has a zero in every other byte, and UTF-8 either decodes
+// cleanly or has more valid multi-byte sequences than broken ones
+function sniffEncoding(bytes: Uint8Array): SigilFileEncoding {
+    const pairs = Math.floor(bytes.length / 2);
+    if (pairs > 0) {
+        let evenZeros = 0;
+        let oddZeros = 0;
+        for (let i = 0; i < pairs * 2; i += 2) {
+            if (bytes[i] === 0) evenZeros++;
+            if (bytes[i + 1] === 0) oddZeros++;
+        }
+        if (oddZeros > pairs * 0.4 && evenZeros < pairs * 0.05) return 'utf-16le';
+        if (evenZeros > pairs * 0.4 && oddZeros < pairs * 0.05) return 'utf-16be';
+    }
+
+    try {
+        new TextDecoder('utf-8', { fatal: true }).decode(bytes);
+        return 'utf-8';
+    } catch (error) {
+        const text = new TextDecoder('utf-8').decode(bytes);
+        const multiByte = (text.match(/[^\u0000-\u007F\uFFFD]/g) || []).length;
+        return multiByte > countReplacements(text) ? 'utf-8' : 'windows-1252';
+    }
+}
+
+function countReplacements(text: string): number {
+    return (text.match(/\uFFFD/g) || []).length;
+}
+
+// U+FFFD written in the file itself, which is not a replacement
+function countEncodedReplacements(bytes: Uint8Array): number {
+    let count = 0;
+    for (let i = 0; i + 2 < bytes.length; i++) {
+        if (bytes[i] === 0xEF && bytes[i + 1] === 0xBF && bytes[i + 2] === 0xBD) count++;
+    }
+    return count;
+}
+
+function countUtf16Replacements(littleEndian: Uint8Array): number {
+    let count = 0;
+    for (let i = 0; i + 1 < littleEndian.length; i += 2) {
+        if (littleEndian[i] === 0xFD && littleEndian[i + 1] === 0xFF) count++;
+    }
+    return count;
+}
+
+function swapBytes(bytes: Uint8Array): Uint8Array {
+    const swapped = new Uint8Array(bytes.length);
+    for (let i = 0; i + 1 < bytes.length; i += 2) {
+        swapped[i] = bytes[i + 1];
+        swapped[i + 1] = bytes[i];
+    }
+    if (bytes.length % 2 === 1) swapped[bytes.length - 1] = bytes[bytes.length - 1];
+    return swapped;
+}
+
+function concat(prefix: number[], bytes: Uint8Array): Uint8Array {
+    const joined = new Uint8Array(prefix.length + bytes.length);
+    joined.set(prefix);
+    joined.set(bytes, prefix.length);
+    return joined;
+}
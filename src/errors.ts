@@ -0,0 +1,206 @@
+/**
+ * SIGIL Error Types
+ * Loading problems are thrown as ParseError. Generation problems are thrown as
+ * ResolveError (or a more specific subclass) when errorMode is 'strict'; in the
+ * default graceful mode the same conditions are reported through debug warnings.
+ *
+ * Every error carries a stable `code` for programmatic handling plus whatever
+ * position is known (file, line, column, table), and serialises to JSON.
+ */
+
+export type SigilErrorCode =
+    // loading
+    | 'yaml_syntax'
+    | 'invalid_weight'
+    | 'invalid_roll_table'
+    | 'table_conflict'
+    | 'file_read'
+    | 'invalid_include'
+    | 'include_cycle'
+    | 'invalid_extend'
+    | 'invalid_macro'
+    | 'invalid_compiled'
+    | 'stale_compiled'
+    // generation
+    | 'unknown_table'
+    | 'not_a_list'
+    | 'invalid_dice'
+    | 'invalid_expression'
+    | 'invalid_count'
+    | 'undefined_variable'
+    | 'invalid_comparison'
+    | 'unknown_function'
+    | 'function_error'
+    | 'not_a_number'
+    | 'roll_out_of_range'
+    | 'entry_out_of_range'
+    | 'unique_exhausted'
+    | 'distinct_exhausted'
+    | 'no_tag_match'
+    | 'recursion_limit'
+    | 'unbalanced_sigil'
+    | 'timeout'
+    | 'aborted';
+
+export interface SigilErrorLocation {
+    file?: string;
+    line?: number;    // 1-based
+    column?: number;  // 1-based
+    table?: string;   // dotted table path
+}
+
+/**
+ * Base class of every error SIGIL raises on purpose
+ */
+export class SigilError extends Error {
+    public readonly code: SigilErrorCode;
+    /** The message without the position suffix */
+    public readonly description: string;
+    public readonly file?: string;
+    public readonly line?: number;
+    public readonly column?: number;
+    public readonly table?: string;
+
+    constructor(code: SigilErrorCode, description: string, location: SigilErrorLocation = {}) {
+        super(description + formatLocation(location));
+        this.name = 'SigilError';
+        this.code = code;
+        this.description = description;
+        this.file = location.file;
+        this.line = location.line;
+        this.column = location.column;
+        this.table = location.table;
+    }
+
+    toJSON(): { name: string; code: SigilErrorCode; message: string } & SigilErrorLocation {
+        return {
+            name: this.name,
+            code: this.code,
+            message: this.description,
+            file: this.file,
+            line: this.line,
+            column: this.column,
+            table: this.table
+        };
+    }
+}
+
+/**
+ * Table source could not be loaded (bad YAML, invalid weights or roll tables, conflicts)
+ */
+export class ParseError extends SigilError {
+    constructor(code: SigilErrorCode, description: string, location: SigilErrorLocation = {}) {
+        super(code, description, location);
+        this.name = 'ParseError';
+    }
+}
+
+/**
+ * A template could not be fully generated (unknown table, bad dice, undefined variable, ...)
+ */
+export class ResolveError extends SigilError {
+    constructor(code: SigilErrorCode, description: string, location: SigilErrorLocation = {}) {
+        super(code, description, location);
+        this.name = 'ResolveError';
+    }
+}
+
+/**
+ * Expansion went deeper than maxDepth, usually because tables reference
+ * each other in a loop (a -> b -> a).
+ */
+export class RecursionError extends ResolveError {
+    /** Table paths being expanded when the limit was hit, outermost first */
+    public readonly chain: string[];
+    public readonly maxDepth: number;
+
+    constructor(chain: string[], maxDepth: number) {
+        super('recursion_limit', `Maximum recursion depth (${maxDepth}) exceeded: ${describeChain(chain) || '(template)'}`,
+            { table: chain[chain.length - 1] });
+        this.name = 'RecursionError';
+        this.chain = chain;
+        this.maxDepth = maxDepth;
+    }
+}
+
+// "x -> a -> b -> a -> b -> a" -> "x -> a -> b (repeats ×2) -> a": a cycle at the end of the chain is written once
+function describeChain(chain: string[]): string {
+    let best: { start: number; period: number; repeats: number } | undefined;
+    let shortest = chain.length;
+    for (let period = 1; period * 2 <= chain.length; period++) {
+        let start = chain.length - period;
+        while (start > 0 && chain[start - 1] === chain[start - 1 + period]) start--;
+        const repeats = Math.floor((chain.length - start) / period);
+        const length = chain.length - (repeats - 1) * period;
+        if (repeats >= 2 && length < shortest) {
+            best = { start, period, repeats };
+            shortest = length;
+        }
+    }
+    if (!best) return chain.join(' -> ');
+    const { start, period, repeats } = best;
+    const rest = chain.slice(start + repeats * period);
+    return [...chain.slice(0, start), `${chain.slice(start, start + period).join(' -> ')} (repeats ×${repeats})`, ...rest].join(' -> ');
+}
+
+/**
+ * A function registered with registerFunction threw, or returned something
+ * other than text
+ */
+export class FunctionError extends ResolveError {
+    public readonly functionName: string;
+    /** The arguments it was called with, after resolving them */
+    public readonly args: string[];
+    /** What the function threw, if it threw */
+    public readonly error?: unknown;
+
+    constructor(functionName: string, args: string[], problem: string, error?: unknown) {
+        super('function_error', `Function "${functionName}"(${args.map(arg => JSON.stringify(arg)).join(', ')}) ${problem}`);
+        this.name = 'FunctionError';
+        this.functionName = functionName;
+        this.args = args;
+        this.error = error;
+    }
+}
+
+/**
+ * A distinct batch could not find enough different results within its attempt
+ * budget, usually because the template has fewer possible outputs than requested.
+ */
+export class DistinctBatchError extends ResolveError {
+    /** The distinct results that were found, in generation order */
+    public readonly results: string[];
+    public readonly requested: number;
+
+    constructor(results: string[], requested: number, attempts: number) {
+        super('distinct_exhausted', `Only ${results.length} of ${requested} distinct results found after ${attempts} attempts`);
+        this.name = 'DistinctBatchError';
+        this.results = results;
+        this.requested = requested;
+    }
+}
+
+/**
+ * The same error with the file it came from filled in (used by the file loaders)
+ */
+export function withFile(error: unknown, file: string): SigilError {
+    if (error instanceof SigilError) {
+        return new ParseError(error.code, error.description, { file, line: error.line, column: error.column, table: error.table });
+    }
+    return new ParseError('file_read', error instanceof Error ? error.message : String(error), { file });
+}
+
+// " (at tables.yaml:4:3)", " (at line 4, column 3)", " (in table "loot")"
+function formatLocation(location: SigilErrorLocation): string {
+    const { file, line, column } = location;
+    if (file && line !== undefined) {
+        return ` (at ${file}:${line}${column !== undefined ? `:${column}` : ''})`;
+    }
+    if (line !== undefined) {
+        return ` (at line ${line}${column !== undefined ? `, column ${column}` : ''})`;
+    }
+    if (file) {
+        return ` (in ${file})`;
+    }
+    return '';
+}
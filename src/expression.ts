@@ -0,0 +1,107 @@
+/**
+ * SIGIL Arithmetic Expressions
+ * Evaluates the body of an [= ...] sigil once nested sigils have been resolved:
+ * - numbers (integer or decimal), + - * / %, unary minus and parentheses
+ * - bare names refer to variables, which must hold numbers (no hyphens, so a-b subtracts)
+ *
+ * Errors name the whole expression so they can be traced back to the template.
+ */
+
+type Token = { kind: 'number', value: number } | { kind: 'name', value: string } | { kind: 'op', value: string };
+
+const TOKEN_PATTERN = /\s*(?:(\d+(?:\.\d+)?|\.\d+)|([A-Za-z_]\w*)|([-+*/%()]))/y;
+
+/**
+ * Evaluate an arithmetic expression.
+ *
+ * @param expression Expression text, e.g. "str / 2 - 5"
+ * @param lookup Resolves a variable name to its value (undefined when not set)
+ * @returns The numeric result
+ */
+export function evaluateExpression(expression: string, lookup: (name: string) => string | undefined): number {
+    const fail = (problem: string): never => {
+        throw new Error(`${problem} in expression "${expression.trim()}"`);
+    };
+
+    const tokens = tokenize(expression, fail);
+    let pos = 0;
+
+    const peek = () => tokens[pos];
+    const isOp = (token: Token | undefined, ...ops: string[]) => token?.kind === 'op' && ops.includes(token.value);
+
+    // expr := term (('+' | '-') term)*
+    const parseExpr = (): number => {
+        let value = parseTerm();
+        while (isOp(peek(), '+', '-')) {
+            const op = tokens[pos++].value;
+            const right = parseTerm();
+            value = op === '+' ? value + right : value - right;
+        }
+        return value;
+    };
+
+    // term := factor (('*' | '/' | '%') factor)*
+    const parseTerm = (): number => {
+        let value = parseFactor();
+        while (isOp(peek(), '*', '/', '%')) {
+            const op = tokens[pos++].value;
+            const right = parseFactor();
+            if (op !== '*' && right === 0) fail('Division by zero');
+            value = op === '*' ? value * right : op === '/' ? value / right : value % right;
+        }
+        return value;
+    };
+
+    // factor := ('-' | '+') factor | number | name | '(' expr ')'
+    const parseFactor = (): number => {
+        const token = tokens[pos++];
+        if (!token) return fail('Unexpected end');
+        if (isOp(token, '-')) return -parseFactor();
+        if (isOp(token, '+')) return parseFactor();
+        if (token.kind === 'number') return token.value;
+        if (token.kind === 'name') {
+            const raw = lookup(token.value);
+            if (raw === undefined) fail(`Undefined variable "${token.value}"`);
+            const value = Number(raw!.trim());
+            if (raw!.trim() === '' || !Number.isFinite(value)) fail(`Variable "${token.value}" is not a number ("${raw}")`);
+            return value;
+        }
+        if (isOp(token, '(')) {
+            const value = parseExpr();
+            if (!isOp(tokens[pos++], ')')) fail('Missing ")"');
+            return value;
+        }
+        return fail(`Unexpected "${token.value}"`);
+    };
+
+    if (tokens.length === 0) fail('Empty expression');
+    const result = parseExpr();
+    if (pos < tokens.length) fail(`Unexpected "${tokens[pos].value}"`);
+    return result;
+}
+
+/**
+ * Render a result without float noise (0.1 + 0.2 -> "0.3")
+ */
+export function formatNumber(value: number): string {
+    return Number.isInteger(value) ? String(value) : String(Number(value.toFixed(10)));
+}
+
+function tokenize(expression: string, fail: (problem: string) => never): Token[] {
+    const tokens: Token[] = [];
+    let index = 0;
+    while (index < expression.length) {
+        if (expression.slice(index).trim() === '') break;
+        TOKEN_PATTERN.lastIndex = index;
+        const match = TOKEN_PATTERN.exec(expression);
+        if (!match) {
+            fail(`Non-numeric operand "${expression.slice(index).trim().split(/\s+/)[0]}"`);
+        }
+        const [whole, number, name, op] = match!;
+        if (number !== undefined) tokens.push({ kind: 'number', value: parseFloat(number) });
+        else if (name !== undefined) tokens.push({ kind: 'name', value: name });
+        else tokens.push({ kind: 'op', value: op });
+        index += whole.length;
+    }
+    return tokens;
+}
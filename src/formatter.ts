@@ -0,0 +1,419 @@
+/**
+ * SIGIL Formatter
+ * Rewrites a table file in one canonical layout, so files edited by several
+ * people stay consistent:
+ *
+ *   "monsters" :                 monsters:
+ *       - goblin^3         ->      - goblin ^3
+ *       - "[size] dragon ^1"       - "[size] dragon ^1"
+ *       - orc   ^2                 - orc    ^2
+ *
+ * - two-space indentation, with list items indented under their table
+ * - headers without stray quotes or spaces ("@count" settings always double-quoted)
+ * - one space after "-" and ":", weights of unquoted entries lined up within each list
+ * - comments kept, runs of blank lines collapsed into one
+ *
+ * Formatting is idempotent, and a file that does not load is refused with the
+ * loader's error instead of being rewritten.
+ */
+
+import { parseYamlContent } from './yaml-loader';
+import type { SigilData } from './yaml-loader';
+import { WEIGHT_PATTERN } from './weights';
+
+export interface FormatOptions {
+    /** 'preserve' (default) keeps tables in file order; 'alphabetical' sorts them by name, settings first */
+    order?: 'preserve' | 'alphabetical';
+}
+
+interface Line {
+    kind: 'blank' | 'comment' | 'key' | 'item' | 'marker' | 'text';
+    indent: number;
+    text: string;
+    /** Key lines: the table or setting name */
+    name?: string;
+    /** Weighted item lines: the list they are aligned in, and the text around the weight */
+    list?: number;
+    weighted?: { head: string; weight: string; tail: string };
+}
+
+// An open table, group or list item that the following lines may be nested in
+interface Parent {
+    old: number;       // column in the source
+    indent: number;    // column in the output
+    kind: 'key' | 'item';
+    id: number;
+}
+
+interface Key {
+    name: string;
+    raw: string;    // as written, with its quotes
+    value: string;  // the rest of the line
+}
+
+// Lines that belong to a value started on an earlier line
+interface Continuation {
+    mode: 'block' | 'quoted' | 'flow' | 'plain';
+    old: number;       // column of the owning key or item
+    indent: number;    // its output column
+    shift?: number;    // block scalars: output column minus source column
+    keep?: boolean;    // block scalars with "+" chomping keep trailing blank lines
+    quote?: string;
+    depth?: number;    // open flow brackets
+}
+
+const PLAIN_NAME = /^[A-Za-z_][\w-]*$/;
+const YAML_WORDS = /^(true|false|null|yes|no|on|off|y|n)$/i;
+
+/**
+ * Format SIGIL YAML source
+ *
+ * @param source Table file content
+ * @returns The formatted source, ending in a single newline
+ */
+export function formatSigil(source: string, options: FormatOptions = {}): string {
+    const before = parseYamlContent(source);
+
+    let lines = layout(source.replace(/^\uFEFF/, ''));
+    alignWeights(lines);
+    if (options.order === 'alphabetical') {
+        lines = sortTopLevel(lines);
+    }
+    const text = lines.map(line => line.kind === 'blank' ? '' : ' '.repeat(line.indent) + line.text).join('\n');
+    const formatted = text === '' ? '' : `${text}\n`;
+
+    if (!sameTables(before, parseYamlContent(formatted))) {
+        throw new Error('Formatting would change what this file defines; it was left as is');
+    }
+    return formatted;
+}
+
+// Re-indent every line and normalise headers, items and weights
+function layout(source: string): Line[] {
+    const lines: Line[] = [];
+    const stack: Parent[] = [];
+    let nextId = 0;
+    let pendingBlank = false;
+    let continuation: Continuation | undefined;
+    let blockBlanks = 0;
+
+    const push = (line: Line) => {
+        if (pendingBlank && lines.length > 0 && line.kind !== 'text') lines.push({ kind: 'blank', indent: 0, text: '' });
+        pendingBlank = false;
+        lines.push(line);
+    };
+    const parentIndent = (old: number, item: boolean) => {
+        while (stack.length > 0) {
+            const top = stack[stack.length - 1];
+            if (top.old < old || (top.old === old && item && top.kind === 'key')) break;
+            stack.pop();
+        }
+        return stack.length > 0 ? stack[stack.length - 1].indent + 2 : 0;
+    };
+
+    for (const raw of source.split(/\r?\n/)) {
+        const old = raw.length - raw.trimStart().length;
+        const content = raw.trim();
+
+        if (continuation?.mode === 'block') {
+            const block = continuation;
+            if (content === '') {
+                blockBlanks++;
+                continue;
+            }
+            if (old > block.old) {
+                if (block.shift === undefined) block.shift = block.indent + 2 - old;
+                for (; blockBlanks > 0; blockBlanks--) lines.push({ kind: 'text', indent: 0, text: '' });
+                lines.push({ kind: 'text', indent: Math.max(0, old + block.shift), text: raw.slice(old) });
+                continue;
+            }
+            for (; blockBlanks > 0; blockBlanks--) {
+                if (block.keep) lines.push({ kind: 'text', indent: 0, text: '' }); else pendingBlank = true;
+            }
+            continuation = undefined;
+        } else if (continuation?.mode === 'quoted' || continuation?.mode === 'flow') {
+            // Leading space is not part of a folded line, but blank lines are
+            lines.push({ kind: 'text', indent: content === '' ? 0 : continuation.indent + 2, text: content });
+            continuation = continues(continuation, content);
+            continue;
+        }
+
+        if (content === '') {
+            pendingBlank = lines.length > 0;
+            continue;
+        }
+        if (content.startsWith('#')) {
+            const parent = [...stack].reverse().find(p => p.old < old);
+            push({ kind: 'comment', indent: parent ? parent.indent + 2 : 0, text: content });
+            continue;
+        }
+        if (/^(---|\.\.\.)(\s|$)/.test(content)) {
+            stack.length = 0;
+            continuation = undefined;
+            push({ kind: 'marker', indent: 0, text: content });
+            continue;
+        }
+
+        const isItem = /^-(\s|$)/.test(content);
+        const isKey = !isItem && matchKey(content) !== undefined;
+        if (!isItem && !isKey && continuation?.mode === 'plain' && old > continuation.old) {
+            lines.push({ kind: 'text', indent: continuation.indent + 2, text: content });
+            continue;
+        }
+        continuation = undefined;
+
+        const indent = parentIndent(old, isItem);
+        let rest = content;
+        let column = old;
+        let prefix = '';
+        let outColumn = indent;
+        let list: number | undefined;
+        while (/^-(\s|$)/.test(rest)) {
+            const after = rest.slice(1).trimStart();
+            if (prefix === '') list = stack.length > 0 ? stack[stack.length - 1].id : -1;
+            stack.push({ old: column, indent: outColumn, kind: 'item', id: nextId++ });
+            column += rest.length - after.length;
+            outColumn += 2;
+            prefix += after === '' ? '-' : '- ';
+            rest = after;
+        }
+
+        const key = matchKey(rest);
+        let line: Line;
+        let value: string;
+        if (key) {
+            const header = formatName(key);
+            value = key.value;
+            if (value === '' || value.startsWith('#')) {
+                stack.push({ old: column, indent: outColumn, kind: 'key', id: nextId++ });
+            }
+            line = { kind: prefix ? 'item' : 'key', indent, text: prefix + header + ':', name: key.name };
+            const formatted = formatValue(value, false);
+            if (formatted) line.text += ' ' + formatted;
+        } else {
+            value = rest;
+            const weighted = splitWeight(value);
+            line = { kind: prefix ? 'item' : 'text', indent, text: prefix + formatValue(value, true) };
+            if (weighted && prefix === '- ') {
+                line.list = list;
+                line.weighted = { head: prefix + weighted.head, weight: weighted.weight, tail: weighted.tail };
+            }
+        }
+        push(line);
+
+        // Values spanning lines are owned by their key, or by the "-" for bare entries
+        const owner = key || !prefix ? { old: column, indent: outColumn } : stack[stack.length - 1];
+        const start = value.match(/^[|>]([+-]?)\d*(\s|$)/);
+        const mode = startMode(value);
+        if (start) {
+            continuation = { mode: 'block', old: owner.old, indent: owner.indent, keep: start[1] === '+' };
+            if (/\d/.test(value.split(/\s/)[0])) continuation.shift = owner.indent - owner.old;
+        } else if (value === '' || value.startsWith('#')) {
+            continuation = undefined;
+        } else if (mode === 'plain') {
+            continuation = { mode, old: owner.old, indent: owner.indent };
+        } else {
+            continuation = continues({ mode, old: owner.old, indent: owner.indent, quote: value[0], depth: 0 }, value);
+        }
+        blockBlanks = 0;
+    }
+    return lines;
+}
+
+// The continuation after a line of a quoted or flow value, or undefined once it is closed
+function continues(continuation: Continuation, text: string): Continuation | undefined {
+    if (continuation.mode === 'quoted') {
+        const first = text.startsWith(continuation.quote!) && continuation.depth === 0 ? 1 : 0;
+        continuation.depth = 1;
+        return closingQuote(text, first, continuation.quote!) === -1 ? continuation : undefined;
+    }
+    if (continuation.mode === 'flow') {
+        let depth = continuation.depth!;
+        let quote: string | undefined;
+        for (let i = 0; i < text.length; i++) {
+            const char = text[i];
+            if (quote) {
+                const close = closingQuote(text, i, quote);
+                if (close === -1) break;
+                i = close;
+                quote = undefined;
+            } else if (char === '"' || char === "'") {
+                quote = char;
+            } else if (char === '[' || char === '{') {
+                depth++;
+            } else if (char === ']' || char === '}') {
+                depth--;
+            } else if (char === '#' && /\s/.test(text[i - 1] || ' ')) {
+                break;
+            }
+        }
+        continuation.depth = depth;
+        return depth > 0 ? continuation : undefined;
+    }
+    return undefined;
+}
+
+function startMode(value: string): Continuation['mode'] {
+    if (value[0] === '"' || value[0] === "'") return 'quoted';
+    if (value[0] === '[' || value[0] === '{') return 'flow';
+    return 'plain';
+}
+
+// Index of the quote closing a string whose text starts at i, or -1
+function closingQuote(text: string, i: number, quote: string): number {
+    for (; i < text.length; i++) {
+        if (quote === '"' && text[i] === '\\') {
+            i++;
+        } else if (text[i] === quote) {
+            if (quote === "'" && text[i + 1] === "'") i++;
+            else return i;
+        }
+    }
+    return -1;
+}
+
+// "name: value", "\"@count\" : 1d4"; undefined when the text is not a mapping key
+function matchKey(text: string): Key | undefined {
+    const quote = text[0];
+    if (quote === '"' || quote === "'") {
+        const close = closingQuote(text, 1, quote);
+        if (close === -1) return undefined;
+        const match = text.slice(close + 1).match(/^[ \t]*:(\s+|$)/);
+        if (!match) return undefined;
+        return { name: text.slice(1, close), raw: text.slice(0, close + 1), value: text.slice(close + 1 + match[0].length) };
+    }
+    const match = text.match(/^([^\s\[\]{}#"'|>&*!%@`,?-][^#]*?|[?-][^\s#][^#]*?)[ \t]*:(\s+|$)/);
+    if (!match) return undefined;
+    return { name: match[1], raw: match[1], value: text.slice(match[0].length) };
+}
+
+// Settings are always written "@name"; other quoted names lose quotes they do not need
+function formatName(key: Key): string {
+    if (key.raw === key.name) return key.name;
+    if (PLAIN_NAME.test(key.name) && !YAML_WORDS.test(key.name)) return key.name;
+    if (key.name.startsWith('@') && !/["'\\]/.test(key.name)) return `"${key.name}"`;
+    return key.raw;
+}
+
+// A value with its trailing comment; entries get a single space before their weight
+function formatValue(value: string, entry: boolean): string {
+    const weighted = entry ? splitWeight(value) : undefined;
+    if (weighted) return `${weighted.head} ${weighted.weight}${weighted.tail}`;
+    return value;
+}
+
+// "goblin^3  # common" -> head "goblin", weight "^3", tail "  # common". Quoted
+// scalars are left alone: their spacing is part of the value.
+function splitWeight(value: string): { head: string; weight: string; tail: string } | undefined {
+    if (/^[\[{|>&*!%@`"']/.test(value)) return undefined;
+    const comment = value.search(/\s#/);
+    const body = (comment === -1 ? value : value.slice(0, comment)).trimEnd();
+    const match = body.match(WEIGHT_PATTERN);
+    if (!match) return undefined;
+    return { head: match[1].trimEnd(), weight: `^${match[2]}`, tail: value.slice(body.length) };
+}
+
+// Weights of one list start in the same column
+function alignWeights(lines: Line[]): void {
+    const widths = new Map<number, number>();
+    for (const line of lines) {
+        if (line.weighted) widths.set(line.list!, Math.max(widths.get(line.list!) ?? 0, line.weighted.head.length));
+    }
+    for (const line of lines) {
+        if (!line.weighted) continue;
+        const { head, weight, tail } = line.weighted;
+        line.text = `${head.padEnd(widths.get(line.list!)!)} ${weight}${tail}`;
+    }
+}
+
+// Each document of a multi-document file is sorted on its own
+function sortTopLevel(lines: Line[]): Line[] {
+    const sorted: Line[] = [];
+    let start = 0;
+    for (let i = 0; i <= lines.length; i++) {
+        if (i === lines.length || lines[i].kind === 'marker') {
+            sorted.push(...sortRegion(lines.slice(start, i), 0));
+            if (i < lines.length) sorted.push(lines[i]);
+            start = i + 1;
+        }
+    }
+    return sorted;
+}
+
+/**
+ * Sort the tables of one mapping by name, each with the comments written
+ * directly above it. Settings keep their place in front; lists and roll
+ * tables ("1-3:" keys) keep their order. Nested groups are sorted too.
+ */
+function sortRegion(lines: Line[], indent: number): Line[] {
+    const keys: number[] = [];
+    for (let i = 0; i < lines.length; i++) {
+        if (lines[i].indent !== indent || lines[i].kind === 'blank' || lines[i].kind === 'comment' || lines[i].kind === 'text') continue;
+        if (lines[i].kind !== 'key') return lines;
+        keys.push(i);
+    }
+    if (keys.length === 0) return lines;
+
+    // Blocks start at the comments directly above their header; the blank line before them separates blocks
+    const starts = keys.map(key => {
+        let start = key;
+        while (start > 0 && lines[start - 1].kind === 'comment' && lines[start - 1].indent === indent) start--;
+        return start;
+    });
+    const separated = (start: number) => start > 0 && lines[start - 1].kind === 'blank';
+    const prefix = lines.slice(0, starts[0] - (separated(starts[0]) ? 1 : 0));
+
+    // Comments and blank lines after the last table's content stay at the end
+    let end = lines.length;
+    while (end > keys[keys.length - 1] + 1 && (lines[end - 1].kind === 'blank'
+        || (lines[end - 1].kind === 'comment' && lines[end - 1].indent === indent))) end--;
+    const tail = lines.slice(end);
+
+    const blocks = keys.map((key, n) => {
+        const next = n + 1 < keys.length ? starts[n + 1] - (separated(starts[n + 1]) ? 1 : 0) : end;
+        const children = sortRegion(lines.slice(key + 1, next), indent + 2);
+        return {
+            name: lines[key].name!,
+            separated: separated(starts[n]),
+            lines: [...lines.slice(starts[n], key + 1), ...children]
+        };
+    });
+
+    const names = blocks.map(block => block.name);
+    let order = blocks;
+    if (!names.some(name => /^\d+(-\d+)?$/.test(name))) {
+        const settings = blocks.filter(block => block.name.startsWith('@'));
+        const tables = blocks.filter(block => !block.name.startsWith('@')).sort((a, b) => compareNames(a.name, b.name));
+        order = [...settings, ...tables];
+    }
+
+    const result = [...prefix];
+    order.forEach((block, n) => {
+        // The first position keeps the file's own spacing, so sorting twice changes nothing
+        const separated = n === 0 ? blocks[0].separated : block.separated;
+        if (separated) result.push({ kind: 'blank', indent: 0, text: '' });
+        result.push(...block.lines);
+    });
+    result.push(...tail);
+    return result;
+}
+
+function compareNames(a: string, b: string): number {
+    const x = a.toLowerCase();
+    const y = b.toLowerCase();
+    if (x !== y) return x < y ? -1 : 1;
+    return a < b ? -1 : a > b ? 1 : 0;
+}
+
+// Same tables and entries; only the spacing before weights may differ
+function sameTables(before: SigilData, after: SigilData): boolean {
+    const normalise = (value: any): any => {
+        if (typeof value === 'string') return value.replace(WEIGHT_PATTERN, '$1 ^$2');
+        if (Array.isArray(value)) return value.map(normalise);
+        if (value && typeof value === 'object') {
+            return Object.fromEntries(Object.keys(value).sort().map(key => [key, normalise(value[key])]));
+        }
+        return value;
+    };
+    return JSON.stringify(normalise(before)) === JSON.stringify(normalise(after));
+}
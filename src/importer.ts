@@ -0,0 +1,293 @@
+/**
+ * SIGIL Table Import
+ * Converts spreadsheet (CSV) and JSON content into native SIGIL tables:
+ * - one column (or key) for the entry text
+ * - an optional weight column; blank weights default to 1
+ * - optional min/max (or a single "1-3" range) columns, which produce a roll table
+ *
+ * The result can be used directly as engine data or written out as SIGIL YAML.
+ */
+
+import * as YAML from 'yaml';
+import { DIE_KEY } from './roll-table';
+
+export interface ImportOptions {
+    /** Table name used by importedTableToSource (default: "table") */
+    name?: string;
+    /**
+     * Whether the first CSV row holds column names. 'auto' (default) treats it as a
+     * header when it names a known column (text, weight, min, max, range) or one
+     * given in the column options.
+     */
+    header?: boolean | 'auto';
+    /** Column (header name or 0-based index) / JSON key for each field */
+    textColumn?: string | number;
+    weightColumn?: string | number;
+    minColumn?: string | number;
+    maxColumn?: string | number;
+    rangeColumn?: string | number;
+}
+
+export interface ImportedEntry {
+    text: string;
+    weight: number;
+    min?: number;
+    max?: number;
+}
+
+export interface ImportedTable {
+    name: string;
+    entries: ImportedEntry[];
+    /** Die of a roll table, written as "@die" (default: d<highest max>) */
+    die?: string;
+}
+
+type Field = 'text' | 'weight' | 'min' | 'max' | 'range';
+
+const FIELD_NAMES: { [field in Field]: string[] } = {
+    text: ['text', 'entry', 'result', 'value', 'item', 'name'],
+    weight: ['weight', 'w'],
+    min: ['min', 'from', 'low'],
+    max: ['max', 'to', 'high'],
+    range: ['range', 'roll', 'die', 'dice']
+};
+
+const RANGE_CELL = /^(\d+)\s*[-\u2013]\s*(\d+)$|^(\d+)$/;
+
+/**
+ * Convert CSV text into a table. Handles quoted fields (with commas, brackets,
+ * doubled quotes and line breaks), CRLF line endings and blank lines.
+ */
+export function importCsv(content: string, options: ImportOptions = {}): ImportedTable {
+    const rows = parseCsvRows(content).filter(row => row.some(cell => cell.trim() !== ''));
+    if (rows.length === 0) {
+        return { name: options.name || 'table', entries: [] };
+    }
+
+    const named = (['text', 'weight', 'min', 'max', 'range'] as Field[])
+        .map(field => options[`${field}Column` as keyof ImportOptions])
+        .filter((option): option is string => typeof option === 'string')
+        .map(option => option.toLowerCase());
+    const hasHeader = options.header === 'auto' || options.header === undefined
+        ? rows[0].some(cell => findField(cell) !== undefined || named.includes(cell.trim().toLowerCase()))
+        : options.header;
+    const header = hasHeader ? rows[0].map(cell => cell.trim()) : undefined;
+    const body = hasHeader ? rows.slice(1) : rows;
+
+    const columns = resolveColumns(header, body, options);
+    const entries = body.map((row, i) => {
+        const rowNumber = i + (hasHeader ? 2 : 1);
+        const cell = (field: Field) => {
+            const index = columns[field];
+            return index === undefined ? undefined : row[index];
+        };
+        return buildEntry(cell('text'), cell('weight'), cell('min'), cell('max'), cell('range'), `Row ${rowNumber}`);
+    });
+
+    return finishTable(entries, options);
+}
+
+/**
+ * Convert a JSON array into a table. Items are either strings or objects with
+ * text/weight/min/max/range keys (names configurable like CSV columns).
+ */
+export function importJson(content: string | unknown[], options: ImportOptions = {}): ImportedTable {
+    let items: unknown;
+    try {
+        items = typeof content === 'string' ? JSON.parse(content) : content;
+    } catch (error) {
+        throw new Error(`Invalid JSON: ${error instanceof Error ? error.message : error}`);
+    }
+    if (!Array.isArray(items)) {
+        throw new Error('Expected a JSON array of entries');
+    }
+
+    const entries = items.map((item, i) => {
+        const where = `Item ${i + 1}`;
+        if (typeof item === 'string' || typeof item === 'number') {
+            return buildEntry(String(item), undefined, undefined, undefined, undefined, where);
+        }
+        if (typeof item !== 'object' || item === null || Array.isArray(item)) {
+            throw new Error(`${where}: expected a string or an object`);
+        }
+        const record = item as { [key: string]: unknown };
+        const value = (field: Field) => {
+            const option = options[`${field}Column` as keyof ImportOptions];
+            const key = typeof option === 'string'
+                ? option
+                : Object.keys(record).find(k => FIELD_NAMES[field].includes(k.toLowerCase()));
+            const found = key !== undefined ? record[key] : undefined;
+            return found === undefined || found === null ? undefined : String(found);
+        };
+        return buildEntry(value('text'), value('weight'), value('min'), value('max'), value('range'), where);
+    });
+
+    return finishTable(entries, options);
+}
+
+/**
+ * The table as engine data: a weighted list, or a roll table when entries carry ranges
+ */
+export function importedTableToData(table: ImportedTable): string[] | { [range: string]: string } {
+    if (table.entries.length > 0 && table.entries[0].min !== undefined) {
+        // Without a single N-M range only "@die" marks the result as a roll table
+        const spans = table.entries.some(entry => entry.min !== entry.max);
+        const die = table.die ?? (spans ? undefined : `d${Math.max(...table.entries.map(entry => entry.max!))}`);
+        const rolls: { [range: string]: string } = die ? { [DIE_KEY]: die } : {};
+        for (const entry of table.entries) {
+            rolls[entry.min === entry.max ? `${entry.min}` : `${entry.min}-${entry.max}`] = entry.text;
+        }
+        return rolls;
+    }
+    return table.entries.map(entry => entry.weight === 1 ? entry.text : `${entry.text} ^${entry.weight}`);
+}
+
+/**
+ * The table written out in SIGIL YAML syntax
+ */
+export function importedTableToSource(table: ImportedTable): string {
+    return YAML.stringify({ [table.name]: importedTableToData(table) });
+}
+
+// All-or-nothing ranges, so the output is either a list or a complete roll table
+function finishTable(entries: ImportedEntry[], options: ImportOptions): ImportedTable {
+    const ranged = entries.filter(entry => entry.min !== undefined).length;
+    if (ranged > 0 && ranged < entries.length) {
+        const missing = entries.findIndex(entry => entry.min === undefined);
+        throw new Error(`Entry ${missing + 1} ("${entries[missing].text}") has no range, but other entries do`);
+    }
+    return { name: options.name || 'table', entries };
+}
+
+function buildEntry(
+    text: string | undefined,
+    weight: string | undefined,
+    min: string | undefined,
+    max: string | undefined,
+    range: string | undefined,
+    where: string
+): ImportedEntry {
+    if (text === undefined || text.trim() === '') {
+        throw new Error(`${where}: missing entry text`);
+    }
+    const entry: ImportedEntry = { text: text.trim(), weight: 1 };
+
+    if (weight !== undefined && weight.trim() !== '') {
+        const parsed = Number(weight.trim());
+        if (!Number.isFinite(parsed) || parsed <= 0) {
+            throw new Error(`${where}: invalid weight "${weight.trim()}" (must be a number greater than zero)`);
+        }
+        entry.weight = parsed;
+    }
+
+    if (range !== undefined && range.trim() !== '') {
+        const match = range.trim().match(RANGE_CELL);
+        if (!match) {
+            throw new Error(`${where}: invalid range "${range.trim()}" (expected a number or a range like 1-3)`);
+        }
+        entry.min = parseInt(match[1] ?? match[3]);
+        entry.max = parseInt(match[2] ?? match[3]);
+    } else if (min !== undefined && min.trim() !== '') {
+        entry.min = parseRangeNumber(min, where);
+        entry.max = max !== undefined && max.trim() !== '' ? parseRangeNumber(max, where) : entry.min;
+    }
+    if (entry.min !== undefined && entry.max! < entry.min) {
+        throw new Error(`${where}: range ${entry.min}-${entry.max} runs backwards`);
+    }
+    return entry;
+}
+
+function parseRangeNumber(cell: string, where: string): number {
+    const text = cell.trim();
+    if (!/^\d+$/.test(text)) {
+        throw new Error(`${where}: invalid range bound "${text}"`);
+    }
+    return parseInt(text);
+}
+
+function findField(name: string): Field | undefined {
+    const key = name.trim().toLowerCase();
+    return (Object.keys(FIELD_NAMES) as Field[]).find(field => FIELD_NAMES[field].includes(key));
+}
+
+// Column index per field: explicit options first, then header names, then position
+function resolveColumns(header: string[] | undefined, body: string[][], options: ImportOptions): { [field in Field]?: number } {
+    const columns: { [field in Field]?: number } = {};
+    for (const field of Object.keys(FIELD_NAMES) as Field[]) {
+        const option = options[`${field}Column` as keyof ImportOptions];
+        if (typeof option === 'number') {
+            columns[field] = option;
+        } else if (typeof option === 'string') {
+            const index = header ? header.findIndex(name => name.toLowerCase() === option.toLowerCase()) : -1;
+            if (index === -1) {
+                throw new Error(`Column "${option}" not found in CSV header`);
+            }
+            columns[field] = index;
+        } else if (header) {
+            const index = header.findIndex(name => findField(name) === field);
+            if (index !== -1) columns[field] = index;
+        }
+    }
+
+    if (!header && Object.keys(columns).length === 0) {
+        // Headerless: "1-3,wolves" reads as range + text, otherwise text + weight
+        const width = Math.max(...body.map(row => row.length));
+        if (width >= 2 && body.every(row => RANGE_CELL.test(row[0].trim()))) {
+            columns.range = 0;
+            columns.text = 1;
+        } else {
+            columns.text = 0;
+            if (width >= 2) columns.weight = 1;
+        }
+    }
+
+    if (columns.text === undefined) {
+        throw new Error('No entry text column found (expected a column named text, entry, result, value, item or name)');
+    }
+    return columns;
+}
+
+// RFC 4180 style: "..." quotes fields, "" is a literal quote inside them
+function parseCsvRows(content: string): string[][] {
+    const rows: string[][] = [];
+    let row: string[] = [];
+    let field = '';
+    let quoted = false;
+    const text = content.replace(/^\uFEFF/, '');
+
+    for (let i = 0; i < text.length; i++) {
+        const char = text[i];
+        if (quoted) {
+            if (char === '"' && text[i + 1] === '"') {
+                field += '"';
+                i++;
+            } else if (char === '"') {
+                quoted = false;
+            } else {
+                field += char;
+            }
+        } else if (char === '"' && field.trim() === '') {
+            field = '';
+            quoted = true;
+        } else if (char === ',') {
+            row.push(field);
+            field = '';
+        } else if (char === '\n' || char === '\r') {
+            if (char === '\r' && text[i + 1] === '\n') i++;
+            row.push(field);
+            rows.push(row);
+            row = [];
+            field = '';
+        } else {
+            field += char;
+        }
+    }
+    if (quoted) {
+        throw new Error('Unterminated quoted field in CSV');
+    }
+    if (field !== '' || row.length > 0) {
+        row.push(field);
+        rows.push(row);
+    }
+    return rows;
+}
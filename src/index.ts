@@ -5,13 +5,82 @@
 
 // Export main engine and utilities
 export { SigilEngine } from './template-engine';
-export type { SigilData } from './yaml-loader';
+export type { SigilData, DirectoryLoadOptions, SigilLayer, TableOrigin, LayeredData } from './yaml-loader';
+export type { TemplateOptions, GenerateOptions, BatchOptions, AnalyzeOptions, EntryOptions, Distribution, Outcome, SeededResult, GenerationNode, TraceEvent, TableInfo, EntryInfo, ErrorMode, ModifierFunction, SigilFunction, FunctionContext } from './template-engine';
+export { pluralize, PLURAL_OVERRIDES } from './template-engine';
+
+// Tables built in code or fetched lazily by providers
+export { SigilTable, TableSet } from './table-builder';
+export type { TableProvider } from './table-builder';
+
+// Sessions keep variables across generate() calls
+export { SigilSession } from './session';
+
+// Seedable random source for reproducible generation
+export { createRandom, hashSeed } from './random';
+export type { RandomSource } from './random';
 
 // Browser-friendly YAML parsing functions
-export { parseYamlContent, createSigilData, createSingleSigilData, mergeLists, extractTemplates } from './yaml-loader';
+export { parseYamlContent, createSigilData, createSingleSigilData, createSigilDataFromFiles, createLayeredSigilData, listTables, mergeLists, extractTemplates } from './yaml-loader';
 
 // Node.js file loading functions (will throw runtime errors in browser)
-export { loadSigilData, loadSingleFile } from './yaml-loader';
+export { loadSigilData, loadSingleFile, loadSigilDirectory, readSigilDirectory, readSigilFile } from './yaml-loader';
+
+// Table sets parsed once and saved as JSON
+export { compileSigilData, loadCompiled, isCompiled, compiledSourceHash, COMPILED_FORMAT_VERSION } from './compiled';
+export type { CompiledSigilData, LoadCompiledOptions } from './compiled';
+
+// JSON HTTP API over a table set (Node.js only)
+export { createSigilServer, createSigilHandler } from './server';
+export type { ServeOptions } from './server';
+
+// Detecting and keeping file encodings and line endings
+export { decodeSigilFile, encodeSigilFile } from './encoding';
+export type { DecodedFile, EncodeOptions, SigilFileEncoding } from './encoding';
+
+// Error types (loading always throws; generation throws in strict mode)
+export { SigilError, ParseError, ResolveError, RecursionError, DistinctBatchError, FunctionError } from './errors';
+export type { SigilErrorCode, SigilErrorLocation } from './errors';
+
+// Static validation with line/column positions
+export { validateSigil } from './validator';
+export type { ValidationIssue, ValidationOptions, ValidationSeverity } from './validator';
+
+// Canonical layout for table files
+export { formatSigil } from './formatter';
+export type { FormatOptions } from './formatter';
+
+// Markdown and HTML output
+export { renderMarkdown, renderHTML } from './renderer';
+
+// Tables a template depends on
+export { findDependencies, dependencyGraphToDot } from './dependencies';
+export type { DependencyGraph, DependencyEdge, DependencyResolver } from './dependencies';
+
+// Lossless token stream for editor syntax highlighting
+export { tokenize, encodeSemanticTokens, SEMANTIC_TOKEN_LEGEND } from './tokenizer';
+export type { Token, TokenKind, TokenizeOptions } from './tokenizer';
+
+// Locale data for the number and list modifiers
+export { LOCALES, getLocale, numberToWords, numberToOrdinal, formatGrouped, joinList } from './locale';
+export type { LocaleData, ScaleWords } from './locale';
+
+// Dice notation utilities
+export { parseDice, rollDice, diceDistribution } from './dice';
+export type { DiceSpec, DiceRoll } from './dice';
+export { isRollTable, parseRollTable } from './roll-table';
+export { evaluateExpression } from './expression';
+export { getTableSettings, joinPicks } from './table-settings';
+export type { TableSettings } from './table-settings';
+export { splitTags, parseTagFilter } from './tags';
+export type { TagFilter } from './tags';
+export type { RollTable, RollTableEntry } from './roll-table';
+
+// Converting spreadsheet and JSON content into tables
+export { importCsv, importJson, importedTableToData, importedTableToSource } from './importer';
+export type { ImportOptions, ImportedTable, ImportedEntry } from './importer';
+export { importPerchance, importMarkdownTable } from './converters';
+export type { ConversionResult, ConversionWarning } from './converters';
 
 // Export Markov generation utilities
 export { SigilMarkov, generateMarkov } from './markov-generator';
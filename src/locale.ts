@@ -0,0 +1,221 @@
+/**
+ * SIGIL Locales
+ * Language data behind the number and list modifiers:
+ *   [n.words]     3 -> "three"            [n.ordinal]   3 -> "third"
+ *   [n.number]    12345.5 -> "12,345.5"   [n.currency]  1234.5 -> "$1,234.50"
+ *   [animal.list*3] -> "wolf, bear, and owl"
+ *
+ * Everything language-specific is data in a LocaleData object, so adding a
+ * language means adding an entry to LOCALES (or passing the object as the
+ * engine's `locale` option), not writing code.
+ */
+
+export interface ScaleWords {
+    /** The scale on its own, e.g. "one thousand", "eine Million" */
+    one: string;
+    /** Several of it; "{n}" is the count in words, e.g. "{n} thousand", "{n} Millionen" */
+    other: string;
+    /** Between the scale and the rest of the number, e.g. " " or "" */
+    join: string;
+}
+
+export interface LocaleData {
+    /** Digit group separator for [n.number] and [n.currency] */
+    thousands: string;
+    /** Decimal mark for [n.number] and [n.currency] */
+    decimal: string;
+    /** Currency layout; "{n}" is the amount with two decimals */
+    currency: string;
+
+    /** Joiners for [table.list]: first items, the last item, and exactly two items */
+    list: { join: string; lastJoin: string; pairJoin: string };
+
+    words: {
+        /** 0-19 */
+        units: string[];
+        /** Index 2-9: twenty to ninety */
+        tens: string[];
+        /** How tens and units combine: "{tens}-{unit}" (twenty-one) or "{unit}und{tens}" (einundzwanzig) */
+        tensUnits: string;
+        /** Hundreds: "{unit} hundred" */
+        hundred: string;
+        /** Between hundreds and the rest: " " (one hundred five) or "" (hunderteins) */
+        hundredJoin: string;
+        /** Thousand, million, billion */
+        scales: ScaleWords[];
+        /** Forms used inside a larger number, e.g. German "ein" (einhundert) instead of "eins" */
+        compoundUnits?: { [n: number]: string };
+        /** Negative numbers; "{n}" is the number in words */
+        negative: string;
+    };
+
+    ordinal: {
+        /** Word endings replaced outright, e.g. one -> first */
+        irregular: { [ending: string]: string };
+        /** Replaced word endings before the suffix is added, e.g. twenty -> twentieth */
+        endings?: { [ending: string]: string };
+        /** Suffixes by the last two digits (1-99), first match wins */
+        suffixes?: { from: number; to: number; suffix: string }[];
+        /** Suffix used otherwise */
+        suffix: string;
+    };
+}
+
+export const LOCALES: { [code: string]: LocaleData } = {
+    en: {
+        thousands: ',',
+        decimal: '.',
+        currency: '${n}',
+        list: { join: ', ', lastJoin: ', and ', pairJoin: ' and ' },
+        words: {
+            units: ['zero', 'one', 'two', 'three', 'four', 'five', 'six', 'seven', 'eight', 'nine', 'ten',
+                'eleven', 'twelve', 'thirteen', 'fourteen', 'fifteen', 'sixteen', 'seventeen', 'eighteen', 'nineteen'],
+            tens: ['', '', 'twenty', 'thirty', 'forty', 'fifty', 'sixty', 'seventy', 'eighty', 'ninety'],
+            tensUnits: '{tens}-{unit}',
+            hundred: '{unit} hundred',
+            hundredJoin: ' ',
+            scales: [
+                { one: 'one thousand', other: '{n} thousand', join: ' ' },
+                { one: 'one million', other: '{n} million', join: ' ' },
+                { one: 'one billion', other: '{n} billion', join: ' ' }
+            ],
+            negative: 'minus {n}'
+        },
+        ordinal: {
+            irregular: { one: 'first', two: 'second', three: 'third', five: 'fifth', eight: 'eighth', nine: 'ninth', twelve: 'twelfth' },
+            endings: { y: 'ie' },
+            suffix: 'th'
+        }
+    },
+    de: {
+        thousands: '.',
+        decimal: ',',
+        currency: '{n} €',
+        list: { join: ', ', lastJoin: ' und ', pairJoin: ' und ' },
+        words: {
+            units: ['null', 'eins', 'zwei', 'drei', 'vier', 'fünf', 'sechs', 'sieben', 'acht', 'neun', 'zehn',
+                'elf', 'zwölf', 'dreizehn', 'vierzehn', 'fünfzehn', 'sechzehn', 'siebzehn', 'achtzehn', 'neunzehn'],
+            tens: ['', '', 'zwanzig', 'dreißig', 'vierzig', 'fünfzig', 'sechzig', 'siebzig', 'achtzig', 'neunzig'],
+            tensUnits: '{unit}und{tens}',
+            hundred: '{unit}hundert',
+            hundredJoin: '',
+            scales: [
+                { one: 'eintausend', other: '{n}tausend', join: '' },
+                { one: 'eine Million', other: '{n} Millionen', join: ' ' },
+                { one: 'eine Milliarde', other: '{n} Milliarden', join: ' ' }
+            ],
+            compoundUnits: { 1: 'ein' },
+            negative: 'minus {n}'
+        },
+        ordinal: {
+            irregular: { null: 'nullte', eins: 'erste', drei: 'dritte', sieben: 'siebte', acht: 'achte' },
+            suffixes: [{ from: 1, to: 19, suffix: 'te' }],
+            suffix: 'ste'
+        }
+    }
+};
+
+/**
+ * Look up a locale by code; "de-AT" falls back to "de". Throws for unknown codes.
+ */
+export function getLocale(locale: string | LocaleData = 'en'): LocaleData {
+    if (typeof locale !== 'string') return locale;
+    const data = LOCALES[locale] ?? LOCALES[locale.split(/[-_]/)[0].toLowerCase()];
+    if (!data) {
+        throw new Error(`Unknown locale "${locale}" (available: ${Object.keys(LOCALES).join(', ')})`);
+    }
+    return data;
+}
+
+/**
+ * A whole number in words: 21 -> "twenty-one". Undefined past the largest scale.
+ */
+export function numberToWords(n: number, locale: LocaleData = LOCALES.en): string | undefined {
+    const words = locale.words;
+    if (!Number.isInteger(n) || Math.abs(n) >= Math.pow(1000, words.scales.length + 1)) return undefined;
+    if (n < 0) return words.negative.replace('{n}', numberToWords(-n, locale)!);
+    if (n === 0) return words.units[0];
+
+    const compound = (unit: number) => words.compoundUnits?.[unit] ?? words.units[unit];
+
+    // 1-999; a scale count ends in the compound form (German "hunderteintausend")
+    const belowThousand = (value: number, scaleCount: boolean): string => {
+        const hundreds = Math.floor(value / 100);
+        const rest = value % 100;
+        let text = '';
+        if (rest > 0 && rest < 20) {
+            text = scaleCount ? compound(rest) : words.units[rest];
+        } else if (rest >= 20) {
+            const unit = rest % 10;
+            const tens = words.tens[Math.floor(rest / 10)];
+            text = unit === 0 ? tens : words.tensUnits.replace('{tens}', tens).replace('{unit}', compound(unit));
+        }
+        if (hundreds > 0) {
+            const hundredText = words.hundred.replace('{unit}', compound(hundreds));
+            text = text ? hundredText + words.hundredJoin + text : hundredText;
+        }
+        return text;
+    };
+
+    // Largest scale first; each scale's join separates it from whatever follows
+    let text = '';
+    let join = '';
+    let remaining = n;
+    for (let scale = words.scales.length; scale >= 1; scale--) {
+        const size = Math.pow(1000, scale);
+        const count = Math.floor(remaining / size);
+        remaining %= size;
+        if (count === 0) continue;
+        const scaleWords = words.scales[scale - 1];
+        text += join + (count === 1 ? scaleWords.one : scaleWords.other.replace('{n}', belowThousand(count, true)));
+        join = scaleWords.join;
+    }
+    if (remaining > 0) {
+        text += join + belowThousand(remaining, false);
+    }
+    return text;
+}
+
+/**
+ * A whole number as an ordinal word: 3 -> "third", 21 -> "twenty-first"
+ */
+export function numberToOrdinal(n: number, locale: LocaleData = LOCALES.en): string | undefined {
+    const cardinal = numberToWords(n, locale);
+    if (cardinal === undefined) return undefined;
+
+    const { irregular, endings = {}, suffixes = [], suffix } = locale.ordinal;
+    for (const [ending, replacement] of Object.entries(irregular)) {
+        if (cardinal.endsWith(ending)) return cardinal.slice(0, -ending.length) + replacement;
+    }
+    let stem = cardinal;
+    for (const [ending, replacement] of Object.entries(endings)) {
+        if (stem.endsWith(ending)) {
+            stem = stem.slice(0, -ending.length) + replacement;
+            break;
+        }
+    }
+    const lastTwo = Math.abs(n) % 100;
+    const rule = suffixes.find(s => lastTwo >= s.from && lastTwo <= s.to);
+    return stem + (rule ? rule.suffix : suffix);
+}
+
+/**
+ * Digits grouped with the locale's separators: 12345.5 -> "12,345.5"
+ */
+export function formatGrouped(value: number, locale: LocaleData = LOCALES.en, decimals?: number): string {
+    const fixed = decimals !== undefined ? Math.abs(value).toFixed(decimals) : String(Math.abs(value));
+    if (/e/i.test(fixed)) return String(value);
+    const [whole, fraction] = fixed.split('.');
+    const grouped = whole.replace(/\B(?=(\d{3})+(?!\d))/g, locale.thousands);
+    return (value < 0 ? '-' : '') + grouped + (fraction !== undefined ? locale.decimal + fraction : '');
+}
+
+/**
+ * Join items as a spoken list: "a", "a and b", "a, b, and c"
+ */
+export function joinList(items: string[], locale: LocaleData = LOCALES.en): string {
+    const { join, lastJoin, pairJoin } = locale.list;
+    if (items.length < 2) return items.join('');
+    if (items.length === 2) return items[0] + pairJoin + items[1];
+    return items.slice(0, -1).join(join) + lastJoin + items[items.length - 1];
+}
@@ -0,0 +1,50 @@
+/**
+ * SIGIL Random Source
+ * Small seedable pseudo-random generator so that a generation can be reproduced
+ * exactly from its seed. Math.random cannot be seeded, so the engine threads one
+ * of these through every random decision instead.
+ */
+
+/**
+ * A function returning a float in [0, 1), compatible with Math.random
+ */
+export type RandomSource = () => number;
+
+/**
+ * Reduce a string or number seed to an unsigned 32-bit integer (FNV-1a for strings)
+ */
+export function hashSeed(seed: string | number): number {
+    if (typeof seed === 'number' && Number.isFinite(seed)) {
+        return Math.floor(Math.abs(seed)) >>> 0;
+    }
+
+    const text = String(seed);
+    let hash = 0x811c9dc5;
+    for (let i = 0; i < text.length; i++) {
+        hash ^= text.charCodeAt(i);
+        hash = Math.imul(hash, 0x01000193);
+    }
+    return hash >>> 0;
+}
+
+/**
+ * Create a deterministic random source (mulberry32) from a seed
+ */
+export function createRandom(seed: string | number): RandomSource {
+    let state = hashSeed(seed);
+
+    return () => {
+        state = (state + 0x6d2b79f5) >>> 0;
+        let t = state;
+        t = Math.imul(t ^ (t >>> 15), t | 1);
+        t ^= t + Math.imul(t ^ (t >>> 7), t | 61);
+        return ((t ^ (t >>> 14)) >>> 0) / 4294967296;
+    };
+}
+
+/**
+ * Pick a fresh seed for callers that want a reproducible run but did not supply one
+ */
+export function randomSeed(): number {
+    return Math.floor(Math.random() * 4294967296) >>> 0;
+}
@@ -0,0 +1,89 @@
+/**
+ * SIGIL Renderers
+ * Turn a generateStructured() tree into Markdown or HTML, using the render hints
+ * tables declare with "@style" and "@link":
+ *
+ *   npc_name:
+ *     "@style": bold
+ *     "@link": https://wiki.example/npc/{text}
+ *     "@entries":
+ *       - Aldric
+ *
+ *   renderMarkdown(engine.generateStructured('You meet [npc_name]'))
+ *   // "You meet [**Aldric**](https://wiki.example/npc/Aldric)"
+ *
+ * generate() keeps returning plain text. Styles other than bold and italic are
+ * ignored, so unknown hints fall back to plain text. Text that was changed after
+ * a reference produced it (e.g. by [template.upper]) is rendered without that
+ * reference's markup.
+ */
+
+import type { GenerationNode } from './template-engine';
+
+interface Markup {
+    text: (text: string) => string;
+    styles: { [style: string]: (inner: string) => string };
+    link: (inner: string, href: string) => string;
+}
+
+const MARKDOWN: Markup = {
+    text: text => text.replace(/[\\`*_\[\]<>]/g, '\\$&'),
+    styles: {
+        bold: inner => `**${inner}**`,
+        italic: inner => `_${inner}_`
+    },
+    link: (inner, href) => `[${inner}](${href.replace(/\(/g, '%28').replace(/\)/g, '%29').replace(/\s/g, '%20')})`
+};
+
+const HTML: Markup = {
+    text: escapeHtml,
+    styles: {
+        bold: inner => `<strong>${inner}</strong>`,
+        italic: inner => `<em>${inner}</em>`
+    },
+    link: (inner, href) => `<a href="${escapeHtml(href)}">${inner}</a>`
+};
+
+/**
+ * The tree's text as Markdown; Markdown characters in generated text are escaped
+ */
+export function renderMarkdown(node: GenerationNode): string {
+    return render(node, MARKDOWN, false);
+}
+
+/**
+ * The tree's text as an HTML fragment; all generated text is HTML-escaped
+ */
+export function renderHTML(node: GenerationNode): string {
+    return render(node, HTML, false);
+}
+
+// Children are found in order inside their parent's text; links never nest
+function render(node: GenerationNode, markup: Markup, inLink: boolean): string {
+    const linked = !inLink && node.link !== undefined;
+    let out = '';
+    let cursor = 0;
+    for (const child of node.children) {
+        const at = child.text ? node.text.indexOf(child.text, cursor) : -1;
+        if (at === -1) continue;
+        out += markup.text(node.text.slice(cursor, at)) + render(child, markup, inLink || linked);
+        cursor = at + child.text.length;
+    }
+    out += markup.text(node.text.slice(cursor));
+
+    if (out === '') return out;
+    for (const style of node.style || []) {
+        const apply = markup.styles[style];
+        if (apply) out = apply(out);
+    }
+    return linked ? markup.link(out, node.link!) : out;
+}
+
+function escapeHtml(text: string): string {
+    return text
+        .replace(/&/g, '&amp;')
+        .replace(/</g, '&lt;')
+        .replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;')
+        .replace(/'/g, '&#39;');
+}
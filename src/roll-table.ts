@@ -0,0 +1,144 @@
+/**
+ * SIGIL Roll Tables
+ * Classic TTRPG tables whose entries are keyed by die results instead of weights:
+ *
+ *   encounters:
+ *     "@die": d20     # optional; inferred as d<highest number> when omitted
+ *     1-12: wolves
+ *     13-19: bandits
+ *     20: a dragon
+ *
+ * Every possible result of the die must map to exactly one entry. Other "@" keys
+ * are table settings (see table-settings.ts).
+ */
+
+import { DiceSpec, parseDice, rollDice } from './dice';
+import { RandomSource } from './random';
+import { isSettingKey } from './table-settings';
+
+export interface RollTableEntry {
+    min: number;
+    max: number;
+    value: string;
+}
+
+export interface RollTable {
+    die: DiceSpec;
+    min: number;   // lowest possible roll
+    max: number;   // highest possible roll
+    entries: RollTableEntry[];  // sorted by min
+}
+
+export const DIE_KEY = '@die';
+
+const RANGE_KEY = /^(\d+)(?:-(\d+))?$/;
+const MAX_SPAN = 10000;
+
+/**
+ * True when a table is a mapping of number ranges to plain entries that says it
+ * is rolled on: it has a "@die" setting or at least one "N-M" range key.
+ * Other numbered mappings (years: { 1990: old, 2020: new }) and groups of
+ * numbered sub-lists (loot: { 1: [...], 2: [...] }) are not roll tables.
+ */
+export function isRollTable(value: unknown): boolean {
+    if (typeof value !== 'object' || value === null || Array.isArray(value)) return false;
+
+    let ranges = 0;
+    let spans = false;
+    for (const [key, entry] of Object.entries(value)) {
+        if (isSettingKey(key)) continue;
+        if (!RANGE_KEY.test(key) || (typeof entry === 'object' && entry !== null)) return false;
+        if (key.includes('-')) spans = true;
+        ranges++;
+    }
+    return ranges > 0 && (spans || (value as { [key: string]: unknown })[DIE_KEY] !== undefined);
+}
+
+/**
+ * Parse a range-keyed table, rejecting gaps, overlaps and results the die cannot produce.
+ * Throws an Error listing the offending numbers.
+ */
+export function parseRollTable(value: { [key: string]: any }): RollTable {
+    const entries: RollTableEntry[] = [];
+    for (const [key, entry] of Object.entries(value)) {
+        if (isSettingKey(key)) continue;
+        const match = key.match(RANGE_KEY);
+        if (!match) {
+            throw new Error(`"${key}" is not a number or range like 1-3`);
+        }
+        const min = parseInt(match[1]);
+        const max = match[2] !== undefined ? parseInt(match[2]) : min;
+        if (max < min) {
+            throw new Error(`range "${key}" runs backwards`);
+        }
+        entries.push({ min, max, value: entry === null || entry === undefined ? '' : String(entry) });
+    }
+    entries.sort((a, b) => a.min - b.min);
+
+    const highest = Math.max(...entries.map(e => e.max));
+    const die = parseDice(value[DIE_KEY] !== undefined ? String(value[DIE_KEY]) : `d${highest}`);
+    const kept = die.keep ? die.keep.count : die.count;
+    const min = kept + die.modifier;
+    const max = kept * die.sides + die.modifier;
+
+    if (max - min + 1 > MAX_SPAN) {
+        throw new Error(`${die.expression} has more than ${MAX_SPAN} possible results`);
+    }
+
+    const outside = entries.filter(e => e.min < min || e.max > max);
+    if (outside.length > 0) {
+        throw new Error(`${die.expression} rolls ${min}-${max}, but entries cover ${formatNumbers(
+            outside.flatMap(e => numbersBetween(e.min, e.max)).filter(n => n < min || n > max))}`);
+    }
+
+    const hits = new Array(max - min + 1).fill(0);
+    for (const entry of entries) {
+        for (let n = entry.min; n <= entry.max; n++) hits[n - min]++;
+    }
+    const missing = hits.map((count, i) => count === 0 ? i + min : -1).filter(n => n >= 0);
+    const overlapping = hits.map((count, i) => count > 1 ? i + min : -1).filter(n => n >= 0);
+
+    const problems: string[] = [];
+    if (missing.length > 0) problems.push(`missing ${formatNumbers(missing)}`);
+    if (overlapping.length > 0) problems.push(`overlapping ${formatNumbers(overlapping)}`);
+    if (problems.length > 0) {
+        throw new Error(`ranges for ${die.expression} (${min}-${max}) are ${problems.join(' and ')}`);
+    }
+
+    return { die, min, max, entries };
+}
+
+/**
+ * The entry a given roll lands on, or undefined when the roll is out of range
+ */
+export function findRollEntry(table: RollTable, roll: number): RollTableEntry | undefined {
+    return table.entries.find(entry => roll >= entry.min && roll <= entry.max);
+}
+
+/**
+ * Roll the table's die and return the matching entry
+ */
+export function rollOnTable(table: RollTable, random: RandomSource = Math.random): { roll: number; entry: RollTableEntry } {
+    const roll = rollDice(table.die, random).total;
+    return { roll, entry: findRollEntry(table, roll)! };
+}
+
+function numbersBetween(min: number, max: number): number[] {
+    const numbers: number[] = [];
+    for (let n = min; n <= max; n++) numbers.push(n);
+    return numbers;
+}
+
+// [1, 2, 3, 7] -> "1-3, 7"
+function formatNumbers(numbers: number[]): string {
+    const parts: string[] = [];
+    let start = numbers[0];
+    for (let i = 1; i <= numbers.length; i++) {
+        if (i === numbers.length || numbers[i] !== numbers[i - 1] + 1) {
+            const end = numbers[i - 1];
+            parts.push(start === end ? `${start}` : `${start}-${end}`);
+            start = numbers[i];
+        }
+    }
+    return parts.join(', ');
+}
@@ -0,0 +1,226 @@
+/**
+ * SIGIL HTTP Server (Node.js only)
+ * A small JSON API over one loaded table set, for tools that cannot call the
+ * library directly:
+ *
+ *   POST /generate  { "template": "[encounter]", "seed": 42, "count": 3, "vars": {}, "tags": [] }
+ *                   -> { "seed": 42, "results": ["...", "...", "..."] }
+ *                   ("structured": true returns GenerationNode trees instead of text)
+ *   GET  /tables    -> { "tables": [...], "templates": [...] }
+ *   POST /validate  { "source": "<YAML>" } -> { "issues": [ValidationIssue, ...] }
+ *
+ *   const server = createSigilServer(loadSigilDirectory('tables/'), { timeout: 500 });
+ *   server.listen(8080);
+ *
+ * Failures answer { "error": { code, message, ... } } in the SigilError.toJSON()
+ * shape: 400 for bad requests, 422 for templates that cannot be generated and
+ * 503 when a request runs out of time. The handler keeps a frozen copy of the
+ * table set and one precompiled engine over it. Every request passes its own
+ * seed to the engine, and variables and unique picks never outlive a call, so
+ * requests never see each other's random stream, variables or unique picks.
+ */
+
+import type { IncomingMessage, ServerResponse, Server } from 'http';
+import { SigilEngine } from './template-engine';
+import type { TemplateOptions, GenerationNode } from './template-engine';
+import { listTables } from './yaml-loader';
+import type { LoadedData, SigilData } from './yaml-loader';
+import { validateSigil } from './validator';
+import { randomSeed } from './random';
+import { SigilError } from './errors';
+
+export interface ServeOptions {
+    /** Time budget per request in milliseconds, however many results it asks for (default 2000) */
+    timeout?: number;
+    /** Most results one /generate request may ask for (default 100) */
+    maxCount?: number;
+    /** Largest accepted request body in bytes (default 1 MiB) */
+    maxBodySize?: number;
+    /** Options for the handler's engine; errors are always strict and .unique picks last one call */
+    engine?: TemplateOptions;
+}
+
+interface GenerateRequest {
+    template?: unknown;
+    seed?: unknown;
+    count?: unknown;
+    vars?: unknown;
+    tags?: unknown;
+    structured?: unknown;
+}
+
+class RequestError extends Error {
+    public readonly status: number;
+
+    constructor(status: number, message: string) {
+        super(message);
+        this.status = status;
+    }
+}
+
+/**
+ * A Node.js request listener serving the API, for mounting in an existing server
+ */
+export function createSigilHandler(data: LoadedData, options: ServeOptions = {}): (request: IncomingMessage, response: ServerResponse) => void {
+    // Templates become a "templates" group, so a template name resolves like in the CLI
+    const lists: SigilData = frozenCopy(data.lists.templates === undefined && Object.keys(data.templates).length > 0
+        ? { ...data.lists, templates: data.templates }
+        : data.lists);
+    const templates = new Set(data.lists.templates === undefined ? Object.keys(data.templates) : []);
+    const maxCount = options.maxCount ?? 100;
+    const timeout = options.timeout ?? 2000;
+    const engine = new SigilEngine(lists, { ...options.engine, errorMode: 'strict', timeout, uniqueScope: 'call' });
+    engine.precompile();
+
+    const routes: { [route: string]: (body: unknown) => unknown } = {
+        'GET /tables': () => ({ tables: listTables(data.lists).sort(), templates: Object.keys(data.templates).sort() }),
+        'POST /validate': body => {
+            const source = (body as { source?: unknown } | null)?.source;
+            if (typeof source !== 'string') throw new RequestError(400, '"source" must be a string of YAML');
+            return { issues: validateSigil(source, { data: lists }) };
+        },
+        'POST /generate': body => {
+            const request = parseGenerateRequest(body, maxCount);
+            const seed = request.seed ?? randomSeed();
+            const template = templates.has(request.template) ? `[templates.${request.template}]` : request.template;
+            // The seed goes with the call, so the shared engine itself never changes
+            const batch = { vars: request.vars, tags: request.tags, seed };
+            const results: (string | GenerationNode)[] = request.structured
+                ? engine.generateStructuredBatch(template, request.count, batch)
+                : engine.generateBatch(template, request.count, batch);
+            return { seed, results };
+        }
+    };
+
+    return (request, response) => {
+        const route = `${request.method} ${(request.url || '/').split('?')[0]}`;
+        const handle = routes[route];
+        if (!handle) {
+            const known = Object.keys(routes).some(key => key.endsWith(` ${route.split(' ')[1]}`));
+            sendError(response, known ? 405 : 404, known ? `${request.method} is not allowed here` : `No route for ${route}`);
+            return;
+        }
+        readBody(request, options.maxBodySize ?? 1024 * 1024, (error, text) => {
+            if (error) {
+                sendError(response, error.status, error.message);
+                return;
+            }
+            try {
+                let body: unknown = null;
+                if (request.method === 'POST') {
+                    try {
+                        body = JSON.parse(text);
+                    } catch (parseError) {
+                        throw new RequestError(400, 'The request body is not valid JSON');
+                    }
+                }
+                send(response, 200, handle(body));
+            } catch (failure) {
+                if (failure instanceof RequestError) {
+                    sendError(response, failure.status, failure.message);
+                } else if (failure instanceof SigilError) {
+                    send(response, failure.code === 'timeout' ? 503 : 422, { error: failure.toJSON() });
+                } else {
+                    sendError(response, 500, failure instanceof Error ? failure.message : String(failure));
+                }
+            }
+        });
+    };
+}
+
+/**
+ * An http.Server serving the API; call listen() on it and close() to stop,
+ * which lets requests in progress finish
+ */
+export function createSigilServer(data: LoadedData, options: ServeOptions = {}): Server {
+    // Loaded on demand, like the file system modules of the loaders, so browser bundles never need it
+    const http = require('http');
+    return http.createServer(createSigilHandler(data, options));
+}
+
+function parseGenerateRequest(body: unknown, maxCount: number): { template: string; seed?: string | number; count: number; vars?: { [name: string]: string }; tags?: string[]; structured: boolean } {
+    if (typeof body !== 'object' || body === null || Array.isArray(body)) {
+        throw new RequestError(400, 'The request body must be a JSON object');
+    }
+    const request = body as GenerateRequest;
+    if (typeof request.template !== 'string') {
+        throw new RequestError(400, '"template" must be a string');
+    }
+    if (request.seed !== undefined && typeof request.seed !== 'string' && typeof request.seed !== 'number') {
+        throw new RequestError(400, '"seed" must be a string or number');
+    }
+    const count = request.count ?? 1;
+    if (typeof count !== 'number' || !Number.isInteger(count) || count < 1 || count > maxCount) {
+        throw new RequestError(400, `"count" must be a whole number from 1 to ${maxCount}`);
+    }
+    const vars = request.vars;
+    if (vars !== undefined && (typeof vars !== 'object' || vars === null || Array.isArray(vars)
+        || Object.values(vars).some(value => typeof value !== 'string'))) {
+        throw new RequestError(400, '"vars" must be an object of strings');
+    }
+    const tags = request.tags;
+    if (tags !== undefined && (!Array.isArray(tags) || tags.some(tag => typeof tag !== 'string'))) {
+        throw new RequestError(400, '"tags" must be an array of strings');
+    }
+    return {
+        template: request.template,
+        seed: request.seed as string | number | undefined,
+        count,
+        vars: vars as { [name: string]: string } | undefined,
+        tags: tags as string[] | undefined,
+        structured: request.structured === true
+    };
+}
+
+function readBody(request: IncomingMessage, limit: number, done: (error: RequestError | undefined, text: string) => void): void {
+    const chunks: Buffer[] = [];
+    let size = 0;
+    let failed = false;
+    request.on('data', (chunk: Buffer) => {
+        size += chunk.length;
+        if (size > limit && !failed) {
+            failed = true;
+            done(new RequestError(413, `The request body is larger than ${limit} bytes`), '');
+            request.resume();
+        } else if (!failed) {
+            chunks.push(chunk);
+        }
+    });
+    request.on('end', () => {
+        if (!failed) done(undefined, Buffer.concat(chunks).toString('utf8'));
+    });
+}
+
+function send(response: ServerResponse, status: number, body: unknown): void {
+    const text = JSON.stringify(body);
+    response.writeHead(status, { 'Content-Type': 'application/json; charset=utf-8', 'Content-Length': Buffer.byteLength(text) });
+    response.end(text);
+}
+
+const ERROR_CODES: { [status: number]: string } = {
+    400: 'bad_request',
+    404: 'not_found',
+    405: 'method_not_allowed',
+    413: 'body_too_large'
+};
+
+// Errors that are not SigilErrors get the same { error: { code, message } } shape
+function sendError(response: ServerResponse, status: number, message: string): void {
+    send(response, status, { error: { code: ERROR_CODES[status] ?? 'internal_error', message } });
+}
+
+// A frozen deep copy, leaving the caller's data as it was; shared and circular
+// references (YAML aliases) stay shared in the copy
+function frozenCopy<T>(value: T, copies = new Map<object, unknown>()): T {
+    if (typeof value !== 'object' || value === null) return value;
+    let copy = copies.get(value);
+    if (copy === undefined) {
+        const target: any = Array.isArray(value) ? [] : {};
+        copies.set(value, target);
+        for (const [key, child] of Object.entries(value)) {
+            Object.defineProperty(target, key, { value: frozenCopy(child, copies), enumerable: true, writable: true, configurable: true });
+        }
+        copy = Object.freeze(target);
+    }
+    return copy as T;
+}
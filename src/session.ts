@@ -0,0 +1,107 @@
+/**
+ * SIGIL Sessions
+ * Keeps variables alive across generate() calls, so a settlement generated
+ * first is still known when its events are generated later:
+ *
+ *   const session = new SigilSession(engine);
+ *   session.generate('[town_name->town] lies by the river');
+ *   session.generate('Trouble in [town]: [event]');
+ *
+ * Session state can be saved with snapshot() and loaded again with restore().
+ */
+
+import { SigilEngine, GenerateOptions } from './template-engine';
+
+const SNAPSHOT_VERSION = 1;
+
+export class SigilSession {
+    private engine: SigilEngine;
+    private vars: Map<string, string>;
+
+    constructor(engine: SigilEngine, vars: { [name: string]: string } = {}) {
+        this.engine = engine;
+        this.vars = new Map(Object.entries(vars));
+    }
+
+    /**
+     * Generate with the session's variables in scope. Variables the template
+     * captures under new names are kept in the session. Per-call `vars` and
+     * captures both win over session variables of the same name for this call
+     * only; the session value is left as it was (use set() to change it).
+     *
+     * @param template SIGIL template string to process
+     * @param options Per-call options (pre-set variables)
+     * @returns Generated content string
+     */
+    public generate(template: string, options: GenerateOptions = {}): string {
+        const callVars = options.vars || {};
+        const { text, vars } = this.engine.generateWithVariables(template, {
+            ...options,
+            vars: { ...this.variables(), ...callVars }
+        });
+
+        for (const [name, value] of Object.entries(vars)) {
+            if (this.vars.has(name) || Object.prototype.hasOwnProperty.call(callVars, name)) continue;
+            this.vars.set(name, value);
+        }
+        return text;
+    }
+
+    /**
+     * All session variables as a plain object
+     */
+    public variables(): { [name: string]: string } {
+        const vars: { [name: string]: string } = {};
+        for (const [name, value] of this.vars) vars[name] = value;
+        return vars;
+    }
+
+    public get(name: string): string | undefined {
+        return this.vars.get(name);
+    }
+
+    public set(name: string, value: string): void {
+        this.vars.set(name, value);
+    }
+
+    /**
+     * Remove a session variable
+     *
+     * @returns false when the variable was not set
+     */
+    public delete(name: string): boolean {
+        return this.vars.delete(name);
+    }
+
+    public clear(): void {
+        this.vars.clear();
+    }
+
+    /**
+     * Session state as a JSON string, e.g. to write to a file
+     */
+    public snapshot(): string {
+        return JSON.stringify({ version: SNAPSHOT_VERSION, vars: this.variables() });
+    }
+
+    /**
+     * Replace the session state with a snapshot() result.
+     * Throws when the snapshot is not valid; the current state is kept in that case.
+     */
+    public restore(snapshot: string): void {
+        let parsed: any;
+        try {
+            parsed = JSON.parse(snapshot);
+        } catch (error) {
+            throw new Error(`Invalid session snapshot: ${error instanceof Error ? error.message : error}`);
+        }
+        if (typeof parsed !== 'object' || parsed === null || parsed.version !== SNAPSHOT_VERSION) {
+            throw new Error(`Invalid session snapshot: expected version ${SNAPSHOT_VERSION}`);
+        }
+        if (typeof parsed.vars !== 'object' || parsed.vars === null || Array.isArray(parsed.vars)
+            || Object.values(parsed.vars).some(value => typeof value !== 'string')) {
+            throw new Error('Invalid session snapshot: "vars" must map names to strings');
+        }
+        this.vars = new Map(Object.entries(parsed.vars as { [name: string]: string }));
+    }
+}
@@ -0,0 +1,115 @@
+/**
+ * SIGIL Tables From Code
+ * Builds tables without writing SIGIL files, for entries that live in a
+ * database or another program:
+ *
+ *   const tables = new TableSet()
+ *       .register(new SigilTable('monster').add('goblin', 3).add('[size] dragon'));
+ *   const engine = new SigilEngine(tables.toData());
+ *
+ * Tables that are too many (or too costly) to build up front can come from a
+ * TableProvider instead, which the engine asks for names it does not know.
+ * Entry text may contain SIGIL syntax either way; it is processed like file entries.
+ */
+
+import * as YAML from 'yaml';
+import type { SigilData } from './yaml-loader';
+import { importedTableToData } from './importer';
+import type { ImportedTable, ImportedEntry } from './importer';
+
+/**
+ * Looked up by the engine for every table reference its data does not define.
+ * Answers (including undefined) are kept for the rest of the generate call,
+ * so a table picked from repeatedly is only fetched once per call.
+ */
+export interface TableProvider {
+    /**
+     * @param name Dotted table path as written in the template, e.g. "npc.names"
+     * @returns The table, its entries ("text" or "text ^weight"), or undefined when unknown
+     */
+    lookup(name: string): ImportedTable | string[] | undefined;
+}
+
+/**
+ * A weighted table built in code
+ */
+export class SigilTable implements ImportedTable {
+    public readonly name: string;
+    public readonly entries: ImportedEntry[] = [];
+    public die?: string;
+
+    constructor(name: string) {
+        if (!/^[^.\s[\]{}]+(\.[^.\s[\]{}]+)*$/.test(name)) {
+            throw new Error(`Invalid table name "${name}"`);
+        }
+        this.name = name;
+    }
+
+    /**
+     * Add an entry; weights work like "text ^weight" in a table file
+     */
+    public add(text: string, weight: number = 1): this {
+        if (!Number.isFinite(weight) || weight <= 0) {
+            throw new Error(`Invalid weight ${weight} on "${text}" (must be a number greater than zero)`);
+        }
+        this.entries.push({ text, weight });
+        return this;
+    }
+}
+
+/**
+ * Named tables to generate from, usable as engine data or as a provider
+ */
+export class TableSet implements TableProvider {
+    private tables: Map<string, ImportedTable> = new Map();
+
+    /**
+     * Add a table, replacing one registered under the same name
+     */
+    public register(table: ImportedTable): this {
+        this.tables.set(table.name, table);
+        return this;
+    }
+
+    public lookup(name: string): ImportedTable | undefined {
+        return this.tables.get(name);
+    }
+
+    public names(): string[] {
+        return [...this.tables.keys()];
+    }
+
+    /**
+     * The tables as engine data; dotted names become groups ("npc.names" -> npc: { names })
+     */
+    public toData(): SigilData {
+        const data: SigilData = {};
+        const groups = new Set<object>([data]);
+        for (const [name, table] of this.tables) {
+            const keys = name.split('.');
+            let group = data;
+            for (let i = 0; i < keys.length; i++) {
+                const key = keys[i];
+                const last = i === keys.length - 1;
+                if (group[key] !== undefined && (last || !groups.has(group[key]))) {
+                    throw new Error(`Table "${name}" conflicts with table "${keys.slice(0, i + 1).join('.')}"`);
+                }
+                if (last) {
+                    group[key] = importedTableToData(table);
+                } else {
+                    group[key] ??= {};
+                    groups.add(group[key]);
+                    group = group[key];
+                }
+            }
+        }
+        return data;
+    }
+
+    /**
+     * The tables written out in SIGIL YAML syntax, grouped like toData
+     */
+    public toSource(): string {
+        return YAML.stringify(this.toData());
+    }
+}
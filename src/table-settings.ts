@@ -0,0 +1,93 @@
+/**
+ * SIGIL Table Settings
+ * A table can be written as a mapping with "@"-prefixed settings next to its entries,
+ * so a plain [table] reference picks several items and joins them naturally:
+ *
+ *   chest_contents:
+ *     "@count": 1d4          # number, range ("0-2") or dice expression
+ *     "@join": ", "
+ *     "@last-join": " and "
+ *     "@unique": true        # never repeat an entry within one reference
+ *     "@empty": an empty chest
+ *     "@style": bold         # render hints, see renderer.ts
+ *     "@tags": rare, cursed  # tags its entries may end with, see tags.ts
+ *     "@entries":
+ *       - gold coins
+ *       - a dagger
+ *
+ * Roll tables (see roll-table.ts) accept the same settings beside their ranges.
+ */
+
+export interface TableSettings {
+    count?: string;
+    join?: string;
+    lastJoin?: string;
+    unique?: boolean;
+    empty?: string;
+    style?: string;   // render hints for renderMarkdown/renderHTML: bold, italic
+    link?: string;    // link target for rendered output; {text} is replaced by the picked text
+    tags?: string[];  // the tag names its entries may end with, lowercase
+}
+
+export const ENTRIES_KEY = '@entries';
+
+const SETTING_KEYS: { [key: string]: keyof TableSettings } = {
+    '@count': 'count',
+    '@join': 'join',
+    '@last-join': 'lastJoin',
+    '@unique': 'unique',
+    '@empty': 'empty',
+    '@style': 'style',
+    '@link': 'link',
+    '@tags': 'tags'
+};
+
+/**
+ * True for keys that configure a table rather than hold an entry or subtable
+ */
+export function isSettingKey(key: string): boolean {
+    return key.startsWith('@');
+}
+
+/**
+ * The settings declared on a table value (empty when it has none, e.g. a plain list)
+ */
+export function getTableSettings(value: unknown): TableSettings {
+    const settings: TableSettings = {};
+    if (typeof value !== 'object' || value === null || Array.isArray(value)) return settings;
+
+    for (const [key, setting] of Object.entries(value)) {
+        const name = SETTING_KEYS[key];
+        if (!name || setting === null || setting === undefined) continue;
+        if (name === 'unique') {
+            settings.unique = setting === true || setting === 'true';
+        } else if (name === 'tags') {
+            // A list or a comma-separated string: "urban, night"
+            settings.tags = (Array.isArray(setting) ? setting.map(String) : String(setting).split(','))
+                .map(tag => tag.trim().toLowerCase())
+                .filter(tag => tag);
+        } else {
+            settings[name] = String(setting);
+        }
+    }
+    return settings;
+}
+
+/**
+ * The entry list of a table that keeps its entries under "@entries"
+ */
+export function getTableEntries(value: unknown): unknown[] | undefined {
+    if (typeof value !== 'object' || value === null || Array.isArray(value)) return undefined;
+    const entries = (value as { [key: string]: unknown })[ENTRIES_KEY];
+    return Array.isArray(entries) ? entries : undefined;
+}
+
+/**
+ * Join picked items: "a", "a and b", "a, b and c"
+ */
+export function joinPicks(items: string[], settings: TableSettings): string {
+    const join = settings.join ?? ', ';
+    const lastJoin = settings.lastJoin ?? join;
+    if (items.length < 2) return items.join('');
+    return items.slice(0, -1).join(join) + lastJoin + items[items.length - 1];
+}
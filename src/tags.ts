@@ -0,0 +1,88 @@
+/**
+ * SIGIL Entry Tags
+ * A table declares the tags its entries use, an entry can end with a group of
+ * them (before its weight), and a reference can keep only the entries that have,
+ * or lack, certain tags:
+ *
+ *   encounters:
+ *     "@tags": urban, night, wild, winter
+ *     "@entries":
+ *       - a pickpocket (urban, night)
+ *       - a patrol of guards (urban) ^2
+ *       - wolves (wild, night, winter)
+ *       - a sword (rusty)              # not declared, so part of the text
+ *
+ *   [encounters #urban #night]     entries tagged both urban and night
+ *   [encounters #night #!winter]   tagged night but not winter
+ *
+ * Tags are not part of the generated text. Only a group of declared names is
+ * read as tags; a trailing parenthesis that is meant as text may also be
+ * escaped: "a pickpocket \(urban)".
+ */
+
+export interface TagFilter {
+    /** Tags an entry must all have */
+    include: string[];
+    /** Tags an entry must not have */
+    exclude: string[];
+}
+
+const ENTRY_TAGS = /\s+\(\s*([A-Za-z][\w-]*(?:\s*,\s*[A-Za-z][\w-]*)*)\s*\)$/;
+
+/**
+ * Split the trailing tag group off an entry (weight already removed), when every
+ * tag in it is one the table declares (its "@tags" setting, lowercase)
+ * "a pickpocket (urban, night)" -> { value: "a pickpocket", tags: ["urban", "night"] }
+ */
+export function splitTags(entry: string, declared: string[] = []): { value: string; tags: string[] } {
+    const match = declared.length > 0 ? entry.match(ENTRY_TAGS) : null;
+    if (!match || match.index === 0) return { value: entry, tags: [] };
+    const tags = match[1].split(',').map(tag => tag.trim().toLowerCase());
+    if (!tags.every(tag => declared.includes(tag))) return { value: entry, tags: [] };
+    return { value: entry.slice(0, match.index), tags };
+}
+
+/**
+ * The names in an entry's trailing "(a, b)" group, declared or not; undefined
+ * when it has none. The validator uses it to find tags a table does not declare.
+ */
+export function trailingTagNames(entry: string): string[] | undefined {
+    const match = entry.match(ENTRY_TAGS);
+    if (!match || match.index === 0) return undefined;
+    return match[1].split(',').map(tag => tag.trim().toLowerCase());
+}
+
+/**
+ * A filter from tag names; "!winter" excludes a tag. Returns undefined for no tags.
+ */
+export function parseTagFilter(tags: string[]): TagFilter | undefined {
+    const filter: TagFilter = { include: [], exclude: [] };
+    for (const tag of tags) {
+        const name = tag.replace(/^#/, '');
+        if (name.startsWith('!')) {
+            filter.exclude.push(name.slice(1).toLowerCase());
+        } else if (name) {
+            filter.include.push(name.toLowerCase());
+        }
+    }
+    return filter.include.length > 0 || filter.exclude.length > 0 ? filter : undefined;
+}
+
+/**
+ * Both filters at once; either may be missing
+ */
+export function combineTagFilters(a: TagFilter | undefined, b: TagFilter | undefined): TagFilter | undefined {
+    if (!a || !b) return a || b;
+    return { include: [...a.include, ...b.include], exclude: [...a.exclude, ...b.exclude] };
+}
+
+export function matchesTags(tags: string[], filter: TagFilter): boolean {
+    return filter.include.every(tag => tags.includes(tag)) && !filter.exclude.some(tag => tags.includes(tag));
+}
+
+/**
+ * The filter as written in a reference: "#urban #night #!winter"
+ */
+export function describeTagFilter(filter: TagFilter): string {
+    return [...filter.include.map(tag => `#${tag}`), ...filter.exclude.map(tag => `#!${tag}`)].join(' ');
+}
@@ -6,24 +6,313 @@
  * - {this|that} OR logic and {this&that} AND logic
  * - {a} indefinite articles
  * - {1-6} number ranges
+ * - [2d6+3] dice notation
+ * - [= ...] arithmetic expressions
+ * - roll tables keyed by die results (1-3: wolves)
  * - Nested expressions with proper precedence
  * 
  * Architecture: Single-pass AST evaluation for maintainability and support for complex nested expressions.
  */
 
-import { SigilData } from './yaml-loader';
+import { SigilData, listTables } from './yaml-loader';
 import { generateMarkov } from './markov-generator';
-import { parseCompleteTemplate, TemplateNode } from './template-parser';
+import { parseCompleteTemplate, walkTemplateNodes, unescapeSigils, findUnbalancedSigils, TemplateNode, ConditionOperand, ComparisonOperator } from './template-parser';
+import { RandomSource, createRandom, randomSeed } from './random';
+import { DiceSpec, parseDice, rollDice, diceDistribution } from './dice';
+import { ResolveError, RecursionError, DistinctBatchError, FunctionError } from './errors';
+import { evaluateExpression, formatNumber } from './expression';
+import { getTableSettings, getTableEntries, joinPicks } from './table-settings';
+import type { TableSettings } from './table-settings';
+import { RollTable, isRollTable, parseRollTable, findRollEntry, rollOnTable } from './roll-table';
+import { importedTableToData } from './importer';
+import type { TableProvider } from './table-builder';
+import { splitTags, parseTagFilter, combineTagFilters, matchesTags, describeTagFilter } from './tags';
+import type { TagFilter } from './tags';
+import { parseWeight, parseWeightedList } from './weights';
+import type { WeightedItem } from './weights';
+import { findDependencies } from './dependencies';
+import type { DependencyGraph } from './dependencies';
+import { LocaleData, getLocale, numberToWords, numberToOrdinal, formatGrouped, joinList } from './locale';
+
+// Weights live in their own module so the loaders can use them without the engine
+export { parseWeight, parseWeightedList };
+export type { WeightedItem };
+
+// Expansion depth allowed per generation call unless maxDepth is set
+const DEFAULT_MAX_DEPTH = 50;
+
+// Parsed templates kept per engine
+const PARSE_CACHE_SIZE = 10000;
+
+// Larger uniform choices ({1-100000}) are sampled rather than enumerated
+const MAX_BRANCHES = 10000;
+
+/**
+ * How generation problems (e.g. an unknown table) are handled:
+ * - graceful: substitute empty text and warn when debug is on (default)
+ * - strict: throw an Error
+ * - silent: substitute empty text without warning
+ * Malformed sigils such as invalid dice or unbalanced brackets are kept as
+ * written instead of becoming empty text.
+ */
+export type ErrorMode = 'graceful' | 'strict' | 'silent';
 
 export interface TemplateOptions {
     maxDepth?: number;
+    /** Extra irregular plurals for the plural modifiers, merged over PLURAL_OVERRIDES */
+    pluralOverrides?: { [singular: string]: string };
     debug?: boolean;
-    seed?: string;
+    seed?: string | number;
+    errorMode?: ErrorMode;
+    /** 'call' (default): .unique picks never repeat within one generate(); 'engine': never repeat until resetUnique() */
+    uniqueScope?: 'call' | 'engine';
+    /** What a .unique pick does once every entry was drawn: report an error (default) or start over */
+    uniqueExhausted?: 'error' | 'wrap';
+    /** Reroll a pick that repeats the previous pick from the same table in this call, as [table!] does for one reference */
+    noImmediateRepeat?: boolean;
+    /** How many times such a pick is rerolled before the repeat is accepted (default 1) */
+    repeatRerolls?: number;
+    /** Language for .words, .ordinal, .number, .currency and .list: a code from LOCALES ("en", "de") or your own data */
+    locale?: string | LocaleData;
+    /**
+     * What a reference to an undefined table produces: an error handled by errorMode (default),
+     * a visible placeholder like "«exotic-spices»" for spotting holes while writing tables, or empty text
+     */
+    missingTable?: 'error' | 'placeholder' | 'empty';
+    /** Default time budget in milliseconds for each generate call (and each whole batch or analysis) */
+    timeout?: number;
+    /** Asked in order for tables the data does not define, e.g. to fetch entries from a database */
+    providers?: TableProvider[];
+    /**
+     * When a "~" or "(nothing)" entry is picked, drop the doubled space and, where
+     * its sentence or clause ends there, the article or punctuation left in front
+     * of the reference (default true)
+     */
+    tidyNothing?: boolean;
+    /**
+     * Called with every resolution step of generate() and the other generating
+     * calls (analyze() is not traced); nothing is recorded when unset
+     */
+    trace?: (event: TraceEvent) => void;
 }
 
-export interface WeightedItem {
-    value: string;
+/**
+ * Per-call options for generate()
+ */
+export interface GenerateOptions {
+    /** Variables available to [name] references before generation starts */
+    vars?: { [name: string]: string };
+    /** Checked between expansion steps; once aborted, the abort reason is thrown */
+    signal?: AbortSignal;
+    /** Time budget in milliseconds, overriding the engine's timeout; running out throws a 'timeout' ResolveError */
+    timeout?: number;
+    /** Default tag filter for every table whose entries have tags, e.g. ['urban', 'night', '!winter'] */
+    tags?: string[];
+}
+
+/**
+ * Options for generateBatch() / generateStream()
+ */
+export interface BatchOptions extends GenerateOptions {
+    /** Skip results that were already produced in this batch */
+    distinct?: boolean;
+    /** Total generations allowed before a distinct batch gives up (default: 10 per requested result) */
+    maxAttempts?: number;
+    /** Seed for the whole batch; the same seed reproduces the same batch */
+    seed?: string | number;
+}
+
+/**
+ * Options for analyze()
+ */
+export interface AnalyzeOptions extends GenerateOptions {
+    /** Most expansion paths to enumerate before falling back to sampling (default 10000) */
+    maxPaths?: number;
+    /** Generations to sample when the template cannot be enumerated (default 10000) */
+    samples?: number;
+    /** Seed for the sampled fallback, so the estimate is reproducible */
+    seed?: string | number;
+}
+
+/**
+ * Options for resolveEntry()
+ */
+export interface EntryOptions extends GenerateOptions {
+    /** Seed for the preview (default: the engine's seed, if it has one) */
+    seed?: string | number;
+}
+
+/**
+ * One possible result of a template and how likely it is
+ */
+export interface Outcome {
+    text: string;
+    probability: number;
+    /** How often the text came up (sampled distributions only) */
+    count?: number;
+}
+
+/**
+ * Result of analyze(). `exact` tells whether the probabilities were computed
+ * from every expansion path or estimated from samples.
+ */
+export interface Distribution {
+    exact: boolean;
+    /** Expansion paths enumerated (exact) or generations sampled (estimated) */
+    runs: number;
+    /** Outcomes from most to least likely */
+    outcomes: Outcome[];
+}
+
+/**
+ * A table as listed by tables()
+ */
+export interface TableInfo {
+    /** Dotted table path */
+    name: string;
+    /** 'list' for plain and "@entries" lists, 'roll' for range-keyed tables, 'value' for a single text */
+    kind: 'list' | 'roll' | 'value';
+    entries: number;
+    /** The table's @count, @join, ... settings */
+    settings: TableSettings;
+}
+
+/**
+ * One entry of a table as returned by entries()
+ */
+export interface EntryInfo {
+    index: number;
+    /** The entry as written, with its weight and tags */
+    source: string;
+    /** The entry template without weight and tags */
+    text: string;
+    /** The entry's weight; for roll tables the width of its range */
     weight: number;
+    /** Chance that a plain [table] reference picks this entry */
+    probability: number;
+    tags: string[];
+    /** Die results that pick this entry (roll tables only) */
+    range?: { min: number; max: number };
+    /** Table paths the entry refers to, in order, without repeats */
+    references: string[];
+}
+
+/**
+ * Result of a seeded generation: the text plus the seed that reproduces it
+ */
+export interface SeededResult {
+    text: string;
+    seed: string | number;
+}
+
+/**
+ * One step of a structured generation (see generateStructured).
+ * JSON.stringify keeps the key order below; optional keys are left out when they do not apply.
+ */
+export interface GenerationNode {
+    /** 'template' for the root, otherwise what produced this text */
+    type: 'template' | 'table' | 'variable' | 'macro' | 'dice' | 'function';
+    /** Template source, table path, variable name, macro name, dice expression or function name */
+    name: string;
+    /** Position of the chosen entry in the table (tables only) */
+    index?: number;
+    /** Weight of the chosen entry; for roll tables the width of its range */
+    weight?: number;
+    /** Die result (dice and roll tables) */
+    roll?: number;
+    /** Final text of this step, with modifiers applied */
+    text: string;
+    /** Nested references expanded while producing this text, in order */
+    children: GenerationNode[];
+    /**
+     * 'missing' for references to undefined tables, 'empty' when the chosen entry produced no text,
+     * 'nothing' when a "~" or "(nothing)" entry was picked (tables only)
+     */
+    status?: 'missing' | 'empty' | 'nothing';
+    /** Render hints from the table's @style setting, e.g. ['bold'] (tables only) */
+    style?: string[];
+    /** Link target from the table's @link setting (tables only) */
+    link?: string;
+}
+
+/**
+ * One resolution step reported to the trace option, once its text is known.
+ * Steps are reported innermost first, so an entry's references come before it.
+ */
+export interface TraceEvent {
+    type: Exclude<GenerationNode['type'], 'template'> | 'modifier';
+    /** Table path, variable name, macro name, dice expression or modifier name */
+    name: string;
+    /** Position of the chosen entry (tables only) */
+    index?: number;
+    weight?: number;
+    /** Die result (dice and roll tables) */
+    roll?: number;
+    /** 1 for references in the template itself, 2 for references in their entries, ...; a modifier has its reference's */
+    depth: number;
+    /** Variables in scope when the step finished */
+    vars: { [name: string]: string };
+    text: string;
+    status?: GenerationNode['status'];
+    /** Text before and after the modifier (modifiers only; output is the same as text) */
+    input?: string;
+    output?: string;
+}
+
+/**
+ * Mutable state for a single generate() call.
+ * Everything random or depth-related lives here so nested expansions
+ * consume the random stream in a fixed, deterministic order.
+ */
+interface GenerationContext {
+    random: RandomSource;
+    depth: number;
+    drawn: Map<string, Set<number>>; // entries already used by .unique picks, per table
+    previous: Map<string, number>;   // index of the last entry picked from each table, for no-repeat rerolls
+    chain: string[];                 // tables currently being expanded, outermost first
+    vars: Map<string, string>;       // captured variables, visible for the rest of the call
+    pick?: { index: number; weight: number; roll?: number }; // details of the last table selection
+    trace?: GenerationNode[];        // open structured nodes, innermost last (generateStructured and the trace option only)
+    tree?: boolean;                  // keep finished nodes in their parents; only generateStructured returns the tree
+    onTrace?: (event: TraceEvent) => void;
+    fallback?: { missing: boolean }; // set for the table reference directly left of a "??"; notes a missing table
+    choices?: ChoiceScript;          // replayed choices instead of random draws (analyze only)
+    signal?: AbortSignal;
+    deadline?: Deadline;
+    cancelled?: unknown;             // the abort reason or timeout error, thrown whatever the errorMode
+    provided: Map<string, any>;      // provider answers by table path, for this call only
+    tags?: TagFilter;                // the call's default filter for tagged tables
+    nothing?: boolean;               // the text just produced is empty because a "~" / "(nothing)" entry was picked
+}
+
+/**
+ * The choices of one expansion path while analyze() enumerates a template.
+ * Every random decision becomes a numbered choice; forced choices replay an
+ * earlier path so the next run can branch off where it left off.
+ */
+interface ChoiceScript {
+    forced: number[];
+    taken: { index: number; weights: number[] | number }[];
+    probability: number;
+    inexact: boolean;  // the path used randomness that cannot be enumerated
+}
+
+interface ParsedTemplate {
+    node: TemplateNode;
+    unbalanced?: { char: string; index: number };  // first bracket that does not pair up
+}
+
+// A list's entries with weights and tags split off, kept while the list is unchanged
+interface PreparedList {
+    items: unknown[];         // the elements it was made from
+    declared: string[];       // the table's "@tags" when it was made
+    entries: WeightedItem[];  // parseWeight of each, by position
+}
+
+// When a call's time budget runs out
+interface Deadline {
+    at: number;       // Date.now() value
+    timeout: number;  // the budget in milliseconds, for the error message
 }
 
 export type TokenDescriptor = {
@@ -34,41 +323,35 @@ export type TokenDescriptor = {
 };
 
 /**
- * Parse weighted items from a list
- * "sword ^2" -> { value: "sword", weight: 2 }
+ * Select random item from weighted list.
+ * Items with a weight of zero or less are never selected; the total is taken
+ * over whatever items are passed in, so filtering the list first (exclusions)
+ * keeps the remaining entries in proportion to each other.
  */
-export function parseWeightedList(items: string[]): WeightedItem[] {
-    return items.map(item => {
-        const match = item.match(/^(.+?)\s*\^([\d.]+)$/);
-        if (match) {
-            return {
-                value: match[1].trim(),
-                weight: parseFloat(match[2])
-            };
-        }
-        return {
-            value: item,
-            weight: 1.0
-        };
-    });
+export function selectWeighted(items: WeightedItem[], random: RandomSource = Math.random): string {
+    const index = selectWeightedIndex(items, random);
+    return index >= 0 ? items[index].value : '';
 }
 
 /**
- * Select random item from weighted list
+ * Same as selectWeighted, but returns the index of the chosen item (-1 if none can be chosen)
  */
-export function selectWeighted(items: WeightedItem[]): string {
-    const totalWeight = items.reduce((sum, item) => sum + item.weight, 0);
-    let random = Math.random() * totalWeight;
+export function selectWeightedIndex(items: WeightedItem[], random: RandomSource = Math.random): number {
+    const totalWeight = items.reduce((sum, item) => sum + Math.max(0, item.weight), 0);
+    let roll = random() * totalWeight;
+    let lastSelectable = -1;
 
-    for (const item of items) {
-        random -= item.weight;
-        if (random <= 0) {
-            return item.value;
+    for (let i = 0; i < items.length; i++) {
+        if (items[i].weight <= 0) continue;
+        if (roll < items[i].weight) {
+            return i;
         }
+        roll -= items[i].weight;
+        lastSelectable = i;
     }
 
-    // Fallback to last item
-    return items[items.length - 1]?.value || '';
+    // Fallback to last selectable item (floating point rounding)
+    return lastSelectable;
 }
 
 /**
@@ -77,12 +360,91 @@ export function selectWeighted(items: WeightedItem[]): string {
  */
 export function getNestedValue(obj: any, path: string): any {
     return path.split('.').reduce((current, key) => {
-        return current && current[key] !== undefined ? current[key] : undefined;
+        // Own properties only, so "list.reverse" or "list.length" never resolve to built-ins
+        return current && typeof current === 'object' && Object.prototype.hasOwnProperty.call(current, key) ? current[key] : undefined;
     }, obj);
 }
 
 /**
- * Apply text modifiers (.capitalize, .lowercase, .pluralForm)
+ * A text modifier applied to a resolved reference, e.g. [monster.capitalize]
+ */
+export type ModifierFunction = (text: string) => string;
+
+/**
+ * What a template function gets besides its arguments. It is made afresh for
+ * every call from that generation's state, so a function draws from the right
+ * random stream and sees the right variables even with generations running side
+ * by side; the variables are a frozen copy.
+ */
+export interface FunctionContext {
+    /** The generation's random stream; drawing from it keeps seeded results reproducible */
+    random: RandomSource;
+    /** Variables set so far in this generation */
+    vars: { readonly [name: string]: string };
+    /** The table whose entry holds the call, or undefined for the template itself */
+    table?: string;
+}
+
+/**
+ * A function callable as [fn:name(...)]: gets the resolved arguments and returns
+ * the text (numbers are turned into text). Must not be async.
+ */
+export type SigilFunction = (args: string[], context: FunctionContext) => string | number;
+
+/**
+ * Irregular English plurals used by the plural modifiers (keys are lowercase)
+ */
+export const PLURAL_OVERRIDES: { [singular: string]: string } = {
+    child: 'children',
+    foot: 'feet',
+    goose: 'geese',
+    man: 'men',
+    mouse: 'mice',
+    ox: 'oxen',
+    person: 'people',
+    tooth: 'teeth',
+    woman: 'women',
+    dwarf: 'dwarves',
+    roof: 'roofs',
+    chief: 'chiefs',
+    sheep: 'sheep',
+    deer: 'deer',
+    fish: 'fish'
+};
+
+/**
+ * Simple English pluralization of the last word, with an override map for irregulars
+ * "dire wolf" -> "dire wolves", "mouse" -> "mice"
+ */
+export function pluralize(text: string, overrides: { [singular: string]: string } = PLURAL_OVERRIDES): string {
+    const match = text.match(/^(.*?)([A-Za-z]+)(\W*)$/);
+    if (match) {
+        const [, before, word, after] = match;
+        const override = overrides[word.toLowerCase()];
+        if (override) {
+            const cased = word[0] === word[0].toUpperCase() ? override.charAt(0).toUpperCase() + override.slice(1) : override;
+            return before + cased + after;
+        }
+    }
+
+    if (text.endsWith('s') || text.endsWith('sh') || text.endsWith('ch') || text.endsWith('x') || text.endsWith('z')) {
+        return text + 'es';
+    } else if (text.endsWith('y') && !/[aeiou]y$/.test(text)) {
+        return text.slice(0, -1) + 'ies';
+    } else if (text.endsWith('f')) {
+        return text.slice(0, -1) + 'ves';
+    } else if (text.endsWith('fe')) {
+        return text.slice(0, -2) + 'ves';
+    }
+    return text + 's';
+}
+
+/**
+ * Apply built-in text modifiers:
+ * - capitalize: first letter upper, rest lower
+ * - lowercase / upper (uppercase)
+ * - pluralForm (plural): English plural of the last word
+ * - a (an): prefix the indefinite article for the resolved text
  */
 export function applyModifier(text: string, modifier: string): string {
     switch (modifier) {
@@ -90,38 +452,48 @@ export function applyModifier(text: string, modifier: string): string {
             return text.charAt(0).toUpperCase() + text.slice(1).toLowerCase();
         case 'lowercase':
             return text.toLowerCase();
+        case 'upper':
+        case 'uppercase':
+            return text.toUpperCase();
         case 'pluralForm':
-            // Simple English pluralization
-            if (text.endsWith('s') || text.endsWith('sh') || text.endsWith('ch') || text.endsWith('x') || text.endsWith('z')) {
-                return text + 'es';
-            } else if (text.endsWith('y') && !/[aeiou]y$/.test(text)) {
-                return text.slice(0, -1) + 'ies';
-            } else if (text.endsWith('f')) {
-                return text.slice(0, -1) + 'ves';
-            } else if (text.endsWith('fe')) {
-                return text.slice(0, -2) + 'ves';
-            }
-            return text + 's';
+        case 'plural':
+            return pluralize(text);
+        case 'a':
+        case 'an':
+            return text ? `${getIndefiniteArticle(text)} ${text}` : text;
+        case 'round':
+        case 'floor':
+        case 'ceil': {
+            const value = Number(text.trim());
+            return text.trim() !== '' && Number.isFinite(value) ? String(Math[modifier as 'round' | 'floor' | 'ceil'](value)) : text;
+        }
         default:
             return text;
     }
 }
 
-// Helper: apply a chain of modifiers left-to-right
-function applyModifiersChain(text: string, modifiers?: string[]): string {
-    if (!modifiers || modifiers.length === 0) return text;
-    let out = text;
-    for (const mod of modifiers) {
-        out = applyModifier(out, mod);
-    }
-    return out;
-}
+// Modifiers understood out of the box; markov, unique and list change selection or joining, not text
+export const BUILT_IN_MODIFIERS = ['capitalize', 'lowercase', 'upper', 'uppercase', 'pluralForm', 'plural', 'a', 'an', 'round', 'floor', 'ceil', 'markov', 'unique', 'list'];
+
+// Number modifiers that depend on the engine's locale
+export const LOCALE_MODIFIERS = ['words', 'ordinal', 'number', 'currency'];
 
 // Helper: ensure exhaustive node type handling
 function assertNever(x: never): never {
     throw new Error(`Unhandled node type: ${JSON.stringify(x)}`);
 }
 
+// A template's syntax tree and its first unbalanced bracket
+function parseTemplate(template: string): ParsedTemplate {
+    return { node: parseCompleteTemplate(template), unbalanced: findUnbalancedSigils(template)[0] };
+}
+
+// What an aborted call throws: the signal's reason, or an "aborted" ResolveError
+// where the runtime does not set one (Node before 17.2)
+function abortReason(signal: AbortSignal): unknown {
+    return signal.reason !== undefined ? signal.reason : new ResolveError('aborted', 'Generation was aborted');
+}
+
 /**
  * Generate indefinite article (a/an)
  */
@@ -136,15 +508,123 @@ export function getIndefiniteArticle(word: string): string {
 export class SigilEngine {
     private lists: SigilData;
     private options: TemplateOptions;
-    private depth: number = 0;
+    private random: RandomSource;
+    private drawn: Map<string, Set<number>> = new Map();
+    private modifiers: Map<string, ModifierFunction> = new Map();
+    private functions: Map<string, SigilFunction> = new Map();
+    private rollTables: WeakMap<object, RollTable> = new WeakMap();
+    private preparedLists: WeakMap<unknown[], PreparedList> = new WeakMap();
+    private parsed: Map<string, ParsedTemplate> = new Map();
+    private compiled: Map<string, ParsedTemplate> = new Map();
+    private resolved?: Map<string, any>;
+    private locale: LocaleData;
 
     constructor(lists: SigilData, options: TemplateOptions = {}) {
         this.lists = lists;
         this.options = {
-            maxDepth: 10,
+            maxDepth: DEFAULT_MAX_DEPTH,
             debug: false,
+            errorMode: 'graceful',
+            uniqueScope: 'call',
+            uniqueExhausted: 'error',
+            missingTable: 'error',
             ...options
         };
+        this.random = this.options.seed !== undefined ? createRandom(this.options.seed) : Math.random;
+
+        this.locale = getLocale(this.options.locale);
+
+        for (const name of BUILT_IN_MODIFIERS) {
+            this.modifiers.set(name, text => applyModifier(text, name));
+        }
+        for (const name of LOCALE_MODIFIERS) {
+            this.modifiers.set(name, text => this.formatNumberText(text, name));
+        }
+        if (this.options.pluralOverrides) {
+            const overrides = { ...PLURAL_OVERRIDES, ...this.options.pluralOverrides };
+            const plural = (text: string) => pluralize(text, overrides);
+            this.modifiers.set('plural', plural);
+            this.modifiers.set('pluralForm', plural);
+        }
+    }
+
+    /**
+     * Register a custom text modifier, usable as [table.name] and chainable
+     * with the built-ins. Registering an existing name replaces it.
+     */
+    public registerModifier(name: string, fn: ModifierFunction): void {
+        this.modifiers.set(name, fn);
+    }
+
+    /**
+     * Register a function callable from templates as [fn:name(arg, "text")].
+     * Unquoted arguments are resolved like templates before the call. A name a
+     * table already uses is refused, to keep [name] and [fn:name()] apart;
+     * registering an existing function replaces it.
+     */
+    public registerFunction(name: string, fn: SigilFunction): void {
+        if (!/^[A-Za-z_][\w-]*$/.test(name)) {
+            throw new Error(`Invalid function name "${name}"`);
+        }
+        if (this.lists[name] !== undefined) {
+            throw new Error(`Function "${name}" has the same name as a table`);
+        }
+        this.functions.set(name, fn);
+    }
+
+    /**
+     * Reseed the engine's random stream. Subsequent generate() calls
+     * replay the same sequence for the same seed and data.
+     */
+    public setSeed(seed: string | number): void {
+        this.options.seed = seed;
+        this.random = createRandom(seed);
+    }
+
+    /**
+     * Parse every entry, macro and template and resolve every table path up
+     * front, so generation only evaluates. Lookups then answer from what was
+     * found here: call precompile() again after changing the engine's data.
+     */
+    public precompile(): void {
+        this.compiled.clear();
+        this.resolved = undefined;
+        const resolved = new Map<string, any>();
+        const compile = (text: unknown) => {
+            if (typeof text === 'string' && !this.compiled.has(text)) this.compiled.set(text, parseTemplate(text));
+        };
+        const visit = (group: any, prefix: string) => {
+            for (const key of Object.keys(group)) {
+                const tablePath = prefix ? `${prefix}.${key}` : key;
+                resolved.set(tablePath, group[key]);
+                if (typeof group[key] === 'object' && group[key] !== null && !Array.isArray(group[key])) visit(group[key], tablePath);
+            }
+        };
+        visit(this.lists, '');
+
+        for (const [key, value] of Object.entries(this.lists)) {
+            if (key.startsWith('@')) compile(value);
+            if (key === 'templates' && typeof value === 'object' && value !== null) Object.values(value).flat().forEach(compile);
+        }
+        for (const tablePath of listTables(this.lists)) {
+            const value = resolved.get(tablePath);
+            const declared = getTableSettings(value).tags ?? [];
+            if (isRollTable(value)) {
+                let table = this.rollTables.get(value);
+                try {
+                    table = table ?? parseRollTable(value);
+                } catch (error) {
+                    continue;  // reported when the table is used
+                }
+                this.rollTables.set(value, table);
+                table.entries.forEach(entry => compile(splitTags(entry.value, declared).value));
+            } else {
+                const list = getTableEntries(value) ?? value;
+                if (Array.isArray(list)) this.prepareEntries(list, declared).forEach(entry => compile(entry.value));
+                else compile(list);
+            }
+        }
+        this.resolved = resolved;
     }
 
     /**
@@ -156,11 +636,317 @@ export class SigilEngine {
      * 3. Apply post-processing (indefinite articles)
      * 
      * @param template SIGIL template string to process
+     * @param options Per-call options (pre-set variables)
      * @returns Generated content string
      */
-    public generate(template: string): string {
-        this.depth = 0;
-        return this.processTemplate(template);
+    public generate(template: string, options: GenerateOptions = {}): string {
+        return this.run(template, this.createContext(this.random, options));
+    }
+
+    /**
+     * Generate content and also return the variables as they stood at the end:
+     * the pre-set ones plus everything captured with [value->name].
+     *
+     * @param template SIGIL template string to process
+     * @param options Per-call options (pre-set variables)
+     * @returns Generated text and the final variables
+     */
+    public generateWithVariables(template: string, options: GenerateOptions = {}): { text: string; vars: { [name: string]: string } } {
+        const ctx = this.createContext(this.random, options);
+        const text = this.run(template, ctx);
+        return { text, vars: Object.fromEntries(ctx.vars) };
+    }
+
+    /**
+     * Generate content using a dedicated random stream for this call only.
+     * The same seed, template and data always produce the same text, and the
+     * engine's own stream is left untouched. When no seed is given a fresh one
+     * is chosen and returned so the run can be repeated later.
+     *
+     * @param template SIGIL template string to process
+     * @param seed Seed for this generation (optional)
+     * @param options Per-call options (pre-set variables)
+     * @returns Generated text together with the seed that produced it
+     */
+    public generateWithSeed(template: string, seed?: string | number, options: GenerateOptions = {}): SeededResult {
+        const usedSeed = seed !== undefined ? seed : randomSeed();
+        const text = this.run(template, this.createContext(createRandom(usedSeed), options));
+        return { text, seed: usedSeed };
+    }
+
+    /**
+     * Generate `count` results from one template. Each result is a separate
+     * generation (variables and .unique picks reset), all drawing from one stream.
+     *
+     * @param template SIGIL template string to process
+     * @param count Number of results wanted
+     * @param options distinct, maxAttempts, seed, signal and per-call vars
+     * @returns The results; fewer than `count` when a distinct batch ran out of
+     *          attempts (a DistinctBatchError in strict mode)
+     */
+    public generateBatch(template: string, count: number, options: BatchOptions = {}): string[] {
+        return [...this.generateStream(template, count, options)];
+    }
+
+    /**
+     * Like generateBatch, but yields each result as soon as it is generated so
+     * callers can show progress or stop early by breaking out of the loop.
+     * A timeout covers the whole batch, not each result.
+     */
+    public *generateStream(template: string, count: number, options: BatchOptions = {}): Generator<string, void, undefined> {
+        yield* this.stream(template, count, options, ctx => {
+            const text = this.run(template, ctx);
+            return { text, result: text };
+        });
+    }
+
+    /**
+     * Generate content and return how it was built: a tree with one child per
+     * table reference (plus dice and variables), recording the chosen entry.
+     * The root's `text` is exactly what generate() would have returned.
+     *
+     * @param template SIGIL template string to process
+     * @param options Per-call options (pre-set variables)
+     * @returns Root GenerationNode of type 'template'
+     */
+    public generateStructured(template: string, options: GenerateOptions = {}): GenerationNode {
+        return this.structure(template, this.createContext(this.random, options));
+    }
+
+    /**
+     * generateBatch for generateStructured: `count` trees from one stream, with
+     * the same distinct, seed and timeout handling
+     */
+    public generateStructuredBatch(template: string, count: number, options: BatchOptions = {}): GenerationNode[] {
+        return [...this.stream(template, count, options, ctx => {
+            const root = this.structure(template, ctx);
+            return { text: root.text, result: root };
+        })];
+    }
+
+    // The batch loop behind generateStream and generateStructuredBatch
+    private *stream<T>(template: string, count: number, options: BatchOptions, generateOne: (ctx: GenerationContext) => { text: string; result: T }): Generator<T, void, undefined> {
+        const random = options.seed !== undefined ? createRandom(options.seed) : this.random;
+        const maxAttempts = options.maxAttempts ?? count * 10;
+        const seen = new Set<string>();
+        const deadline = this.deadline(options);
+        let attempts = 0;
+        let produced = 0;
+
+        while (produced < count) {
+            if (options.distinct && attempts >= maxAttempts) break;
+            if (options.signal?.aborted) throw abortReason(options.signal);
+            attempts++;
+            const { text, result } = generateOne(this.createContext(random, options, deadline));
+            if (options.distinct) {
+                if (seen.has(text)) continue;
+                seen.add(text);
+            }
+            produced++;
+            yield result;
+        }
+
+        if (options.distinct && produced < count) {
+            this.fail(new DistinctBatchError([...seen], count, attempts));
+        }
+    }
+
+    // One generation that records its expansion tree
+    private structure(template: string, ctx: GenerationContext): GenerationNode {
+        const root = createGenerationNode('template', template);
+        ctx.trace = [root];
+        ctx.tree = true;
+        root.text = this.run(template, ctx);
+        return root;
+    }
+
+    /**
+     * Work out how likely each result of a template is, accounting for weights,
+     * nested tables, dice and every other random choice. Small templates are
+     * enumerated path by path and the probabilities are exact; when there are
+     * more than `maxPaths` paths (recursive tables, many dice) the distribution
+     * is estimated from `samples` generations instead and `exact` is false.
+     * Each path or sample is a separate generation, like generateBatch.
+     *
+     * @param template SIGIL template string to analyze
+     * @param options maxPaths, samples, seed and per-call vars
+     * @returns The outcomes with their probabilities, most likely first
+     */
+    public analyze(template: string, options: AnalyzeOptions = {}): Distribution {
+        const totals = new Map<string, number>();
+        const maxPaths = options.maxPaths ?? 10000;
+        const deadline = this.deadline(options);
+        let forced: number[] | undefined = [];
+        let paths = 0;
+
+        while (forced && paths < maxPaths) {
+            const choices: ChoiceScript = { forced, taken: [], probability: 1, inexact: false };
+            const ctx = this.createContext(() => {
+                choices.inexact = true;
+                return 0;
+            }, options, deadline);
+            ctx.drawn = new Map();
+            ctx.choices = choices;
+            ctx.trace = ctx.onTrace = undefined;
+            const text = this.run(template, ctx);
+            if (choices.inexact) break;
+
+            paths++;
+            totals.set(text, (totals.get(text) || 0) + choices.probability);
+            forced = nextPath(choices.taken);
+        }
+        if (!forced) {
+            return { exact: true, runs: paths, outcomes: sortOutcomes([...totals].map(([text, probability]) => ({ text, probability }))) };
+        }
+
+        const samples = options.samples ?? 10000;
+        const random = options.seed !== undefined ? createRandom(options.seed) : this.random;
+        const counts = new Map<string, number>();
+        for (let i = 0; i < samples; i++) {
+            const ctx = this.createContext(random, options, deadline);
+            ctx.drawn = new Map();
+            ctx.trace = ctx.onTrace = undefined;
+            const text = this.run(template, ctx);
+            counts.set(text, (counts.get(text) || 0) + 1);
+        }
+        return {
+            exact: false,
+            runs: samples,
+            outcomes: sortOutcomes([...counts].map(([text, count]) => ({ text, probability: count / samples, count })))
+        };
+    }
+
+    /**
+     * Draw up to `count` distinct entries from a table (without replacement).
+     * Weights apply to whatever is left in the pool. Each entry is processed
+     * for sigils like a normal [table] reference.
+     *
+     * @param tablePath Dot-notation path to the table
+     * @param count Number of distinct entries wanted
+     * @returns The processed entries; fewer than `count` if the table ran out
+     *          (or an error in strict mode, unless uniqueExhausted is 'wrap')
+     */
+    public pickUnique(tablePath: string, count: number): string[] {
+        const ctx = this.createContext(this.random);
+        const results: string[] = [];
+        for (let i = 0; i < count; i++) {
+            const item = this.selectFromTable(tablePath, [], ctx, true);
+            if (item === null) break;
+            results.push(this.processTemplate(item, ctx));
+        }
+        return results;
+    }
+
+    /**
+     * Look up the entry of a roll table for a die result rolled outside SIGIL
+     * (e.g. a physical d20), and process it like a normal pick.
+     *
+     * @param tablePath Dot-notation path to a range-keyed table
+     * @param roll The die result
+     * @returns The processed entry; empty (or an error in strict mode) when the roll is out of range
+     */
+    public rollOn(tablePath: string, roll: number): string {
+        const ctx = this.createContext(this.random);
+        const value = this.lookupTable(tablePath, ctx);
+        if (!isRollTable(value)) {
+            return this.fail(value === undefined
+                ? new ResolveError('unknown_table', `Unknown table or variable "${tablePath}"`, { table: tablePath })
+                : new ResolveError('not_a_list', `Table "${tablePath}" is not a roll table`, { table: tablePath }));
+        }
+        const table = this.getRollTable(tablePath, value);
+        if (!table) return '';
+
+        const entry = findRollEntry(table, roll);
+        if (!entry) {
+            return this.fail(new ResolveError('roll_out_of_range',
+                `Roll ${roll} is outside the ${table.min}-${table.max} range of roll table "${tablePath}"`, { table: tablePath }));
+        }
+
+        ctx.chain.push(tablePath);
+        return this.processTemplate(splitTags(entry.value, getTableSettings(value).tags).value, ctx);
+    }
+
+    /**
+     * Forget which entries .unique picks have used (only relevant with uniqueScope: 'engine')
+     */
+    public resetUnique(): void {
+        this.drawn.clear();
+    }
+
+    // Fresh per-call state; the unique pool is shared across calls only when asked for
+    private createContext(random: RandomSource, options: GenerateOptions = {}, deadline = this.deadline(options)): GenerationContext {
+        const ctx: GenerationContext = {
+            random,
+            depth: 0,
+            drawn: this.options.uniqueScope === 'engine' ? this.drawn : new Map(),
+            previous: new Map(),
+            chain: [],
+            vars: new Map(Object.entries(options.vars || {})),
+            signal: options.signal,
+            deadline,
+            provided: new Map(),
+            tags: parseTagFilter(options.tags || [])
+        };
+        if (this.options.trace) {
+            // Only the open steps are kept, so the events can carry their depth
+            ctx.trace = [createGenerationNode('template', '')];
+            ctx.onTrace = this.options.trace;
+        }
+        return ctx;
+    }
+
+    private deadline(options: GenerateOptions): Deadline | undefined {
+        const timeout = options.timeout ?? this.options.timeout;
+        return timeout !== undefined ? { at: Date.now() + timeout, timeout } : undefined;
+    }
+
+    // Generate from the top; a cancelled call leaves the engine's .unique pool as it was before the call
+    private run(template: string, ctx: GenerationContext): string {
+        const saved = (ctx.signal || ctx.deadline) && ctx.drawn === this.drawn
+            ? new Map([...this.drawn].map(([table, drawn]) => [table, new Set(drawn)]))
+            : undefined;
+        try {
+            return this.processTemplate(template, ctx);
+        } catch (error) {
+            if (saved && error === ctx.cancelled) {
+                this.drawn.clear();
+                saved.forEach((drawn, table) => this.drawn.set(table, drawn));
+            }
+            throw error;
+        }
+    }
+
+    // The data's table at a path, else the first provider's answer (asked once per call)
+    private lookupTable(tablePath: string, ctx: GenerationContext): any {
+        const value = this.resolved?.has(tablePath) ? this.resolved.get(tablePath) : getNestedValue(this.lists, tablePath);
+        const providers = this.options.providers;
+        if (value !== undefined || !providers?.length) return value;
+
+        if (!ctx.provided.has(tablePath)) {
+            let found: any;
+            for (const provider of providers) {
+                const table = provider.lookup(tablePath);
+                if (table === undefined) continue;
+                found = Array.isArray(table) ? table : importedTableToData(table);
+                break;
+            }
+            ctx.provided.set(tablePath, found);
+        }
+        return ctx.provided.get(tablePath);
+    }
+
+    // Stop between expansion steps once the call is aborted or out of time
+    private checkCancelled(ctx: GenerationContext): void {
+        if (ctx.cancelled === undefined) {
+            if (ctx.signal?.aborted) {
+                ctx.cancelled = abortReason(ctx.signal);
+            } else if (ctx.deadline && Date.now() > ctx.deadline.at) {
+                ctx.cancelled = new ResolveError('timeout', `Generation timed out after ${ctx.deadline.timeout} ms`);
+            } else {
+                return;
+            }
+        }
+        throw ctx.cancelled;
     }
 
     /**
@@ -171,31 +957,45 @@ export class SigilEngine {
     *
     * Benefits: Better precedence, cleaner code, foundation for advanced features
      */
-    private processTemplate(template: string): string {
-        if (this.depth >= (this.options.maxDepth || 10)) {
-            return template; // Prevent infinite recursion
+    private processTemplate(template: string, ctx: GenerationContext): string {
+        this.checkCancelled(ctx);
+        const maxDepth = this.options.maxDepth || DEFAULT_MAX_DEPTH;
+        if (ctx.depth >= maxDepth) {
+            // Prevent infinite recursion; graceful mode keeps the unexpanded text
+            return this.fail(new RecursionError([...ctx.chain], maxDepth), template);
         }
 
-        this.depth++;
+        ctx.depth++;
 
         try {
+            const { node, unbalanced } = this.parse(template);
+            if (unbalanced) {
+                // Graceful mode goes on and keeps the stray bracket as text
+                this.fail(new ResolveError('unbalanced_sigil',
+                    `${unbalanced.char === '[' || unbalanced.char === '{' ? 'Unterminated' : 'Unmatched'} "${unbalanced.char}" at column ${unbalanced.index + 1} in "${template}"`,
+                    { column: unbalanced.index + 1, table: ctx.chain[ctx.chain.length - 1] }));
+            }
+
             // Single-pass AST-based approach
-            const parsed = parseCompleteTemplate(template);
-            let result = this.evaluateTemplateNode(parsed);
+            let result = this.evaluateTemplateNode(node, ctx);
 
             // Post-processing: Handle indefinite articles with context
             // This needs to be done after AST evaluation to have proper word context
             result = this.processIndefiniteArticles(result);
 
-            this.depth--;
+            ctx.depth--;
             return result;
 
         } catch (error) {
+            if (this.options.errorMode === 'strict' || error === ctx.cancelled) {
+                ctx.depth--;
+                throw error;
+            }
             // Fallback to original template if parsing fails completely
             if (this.options.debug) {
                 console.error(`Template parsing failed for: "${template}"`, error);
             }
-            this.depth--;
+            ctx.depth--;
             return template;
         }
     }
@@ -228,8 +1028,22 @@ export class SigilEngine {
                         for (const c of (node as any).nodes || []) walk(c);
                         break;
                     case 'group':
+                    case 'capture':
                         walk((node as any).node);
                         break;
+                    case 'fallback':
+                        walk(node.node);
+                        walk(node.fallback);
+                        break;
+                    case 'conditional':
+                        walk(node.then);
+                        if (node.else) walk(node.else);
+                        break;
+                    case 'function':
+                        for (const arg of node.args) {
+                            if (arg.kind === 'template') walk(arg.node);
+                        }
+                        break;
                     // text, number_range, indefinite_article -> ignore for token extraction
                     default:
                         break;
@@ -244,7 +1058,7 @@ export class SigilEngine {
             while ((m = re.exec(template)) !== null) {
                 const content = m[1];
                 // Attempt to split off dot-suffixed modifiers (known list)
-                const knownModifiers = ['capitalize', 'lowercase', 'pluralForm', 'markov'];
+                const knownModifiers = ['capitalize', 'lowercase', 'pluralForm', 'markov', 'unique'];
                 const parts = content.split('.');
                 const mods: string[] = [];
                 while (parts.length > 1 && knownModifiers.includes(parts[parts.length - 1])) {
@@ -279,7 +1093,7 @@ export class SigilEngine {
         const val = getNestedValue(this.lists, path);
         if (Array.isArray(val)) {
             const weighted = parseWeightedList(val.map(i => typeof i === 'string' ? i : String(i)));
-            return selectWeighted(weighted);
+            return selectWeighted(weighted, this.random);
         }
         return val;
     }
@@ -303,6 +1117,133 @@ export class SigilEngine {
         return this.generate(template);
     }
 
+    /**
+     * Every table in the engine's data (tables from providers are not listed)
+     */
+    public tables(): TableInfo[] {
+        return listTables(this.lists).map(name => {
+            const value = getNestedValue(this.lists, name);
+            const kind = isRollTable(value) ? 'roll' : Array.isArray(getTableEntries(value) ?? value) ? 'list' : 'value';
+            let entries = 1;
+            if (kind !== 'value') {
+                try {
+                    entries = this.entries(name).length;
+                } catch (error) {
+                    entries = 0; // an invalid roll table
+                }
+            }
+            return { name, kind, entries, settings: getTableSettings(value) };
+        });
+    }
+
+    /**
+     * The entries of one table with their weights, tags and the chance of each
+     * being picked, without generating anything. Throws a ResolveError
+     * ('unknown_table', 'not_a_list' or 'invalid_roll_table') whatever the errorMode.
+     *
+     * @param tablePath Dot-notation path to the table
+     */
+    public entries(tablePath: string): EntryInfo[] {
+        const value = this.lookupTable(tablePath, this.createContext(this.random));
+        if (value === undefined) {
+            throw new ResolveError('unknown_table', `Unknown table "${tablePath}"`, { table: tablePath });
+        }
+
+        if (isRollTable(value)) {
+            let table: RollTable;
+            try {
+                table = parseRollTable(value);
+            } catch (error) {
+                throw new ResolveError('invalid_roll_table',
+                    `Invalid roll table "${tablePath}": ${error instanceof Error ? error.message : error}`, { table: tablePath });
+            }
+            const declared = getTableSettings(value).tags;
+            const weights = rollWeights(table, table.entries.map((_, index) => index));
+            const total = weights ? weights.reduce((sum, ways) => sum + ways, 0) : table.max - table.min + 1;
+            return table.entries.map((entry, index) => {
+                const { value: text, tags } = splitTags(entry.value, declared);
+                return {
+                    index,
+                    source: entry.value,
+                    text,
+                    weight: entry.max - entry.min + 1,
+                    probability: (weights ? weights[index] : entry.max - entry.min + 1) / total,
+                    tags,
+                    range: { min: entry.min, max: entry.max },
+                    references: referencesOf(text)
+                };
+            });
+        }
+
+        const list = getTableEntries(value) ?? (typeof value === 'object' && value !== null ? value : [value]);
+        if (!Array.isArray(list)) {
+            throw new ResolveError('not_a_list', `Table "${tablePath}" is not a list`, { table: tablePath });
+        }
+        // Missing items are left out; the others keep their position in the list
+        const positions = list.flatMap((item, index) => item === undefined || item === null ? [] : [index]);
+        const items = positions.map(index => String(list[index]));
+        const weighted = parseWeightedList(items, getTableSettings(value).tags);
+        const total = weighted.reduce((sum, item) => sum + Math.max(0, item.weight), 0);
+        return weighted.map((item, i) => ({
+            index: positions[i],
+            source: items[i],
+            text: item.value,
+            weight: item.weight,
+            probability: total > 0 ? Math.max(0, item.weight) / total : 0,
+            tags: item.tags || [],
+            references: referencesOf(item.value)
+        }));
+    }
+
+    /**
+     * Generate from one particular entry of a table, e.g. to preview it. Uses a
+     * random stream and .unique pool of its own, so the engine's are left as they were;
+     * the stream starts from options.seed, or the engine's seed, so a seeded preview repeats.
+     *
+     * @param tablePath Dot-notation path to the table
+     * @param index Position of the entry, as in entries()
+     * @param options Per-call options (seed, pre-set variables, tags, timeout)
+     * @returns The processed entry; empty (or an error in strict mode) when there is no such entry
+     */
+    public resolveEntry(tablePath: string, index: number, options: EntryOptions = {}): string {
+        let entries: EntryInfo[];
+        try {
+            entries = this.entries(tablePath);
+        } catch (error) {
+            return this.fail(error as Error);
+        }
+        const entry = entries.find(entry => entry.index === index);
+        if (!entry) {
+            return this.fail(new ResolveError('entry_out_of_range',
+                `Table "${tablePath}" has no entry ${index} (it has ${entries.length})`, { table: tablePath }));
+        }
+        const ctx = this.createContext(createRandom(options.seed ?? this.options.seed ?? randomSeed()), options);
+        ctx.drawn = new Map(); // a preview does not use up engine-scope .unique picks
+        ctx.chain.push(tablePath);
+        return this.run(entry.text, ctx);
+    }
+
+    /**
+     * The tables a template depends on, found by following every entry of every
+     * table it can reach without generating anything. Tables that are missing and
+     * references built at generation time ("dynamic") are listed separately;
+     * render the graph with dependencyGraphToDot().
+     *
+     * @param template Template text, e.g. "[encounter] in the [place.[biome]]"
+     */
+    public dependencies(template: string): DependencyGraph {
+        const ctx = this.createContext(this.random);
+        return findDependencies(template, reference => {
+            const table = this.splitModifiers(reference, undefined, ctx).tablePath;
+            try {
+                return { table, entries: this.entries(table).map(entry => entry.text) };
+            } catch (error) {
+                const code = error instanceof ResolveError ? error.code : undefined;
+                return code === 'invalid_roll_table' ? { table, entries: [] } : undefined;
+            }
+        });
+    }
+
     // Legacy resolveTableReference removed: all table logic is now handled by the parser and AST evaluation.
 
     /**
@@ -321,35 +1262,312 @@ export class SigilEngine {
      * - [missing] where missing doesn't exist → "" (empty string)
      * - [weapons!*] where all items filtered → "" (empty string)
      * 
+     * UNIQUE PICKS:
+     * - Entries already drawn for this table in the current context are skipped,
+     *   so their weight leaves the pool
+     * - Once everything is drawn: null (error mode) or the pool starts over (wrap mode)
+     *
+     * NO-REPEAT PICKS:
+     * - A pick of the same entry as the previous pick from this table is rerolled
+     *   up to repeatRerolls times; each reroll draws from the random stream
+     * - With a single candidate there is nothing else to pick, so nothing is rerolled
+     *
      * @param tablePath Dot-notation path to table in YAML data
      * @param exclusions Array of strings to exclude from selection
-     * @returns Selected item string or empty string if table missing/empty
+     * @param ctx Per-call generation state (random stream, unique pool)
+     * @param unique Draw without replacement
+     * @param tags Tag filter of the reference ([table #urban]); the call's default tags are added for tagged tables
+     * @param noRepeat Reroll a repeat of the previous pick ([table!]), also on for every pick with noImmediateRepeat
+     * @returns Selected item string, empty string if table missing/empty, null if no unique entries are left
      */
-    private selectFromTable(tablePath: string, exclusions: string[] = []): string {
-        const list = getNestedValue(this.lists, tablePath);
+    private selectFromTable(tablePath: string, exclusions: string[], ctx: GenerationContext, unique: boolean | Set<number> = false, tags?: TagFilter, noRepeat = false): string | null {
+        const value = this.lookupTable(tablePath, ctx);
+        const declared = getTableSettings(value).tags ?? [];
+        let list = getTableEntries(value) ?? value;
+
+        // Roll tables pick by die result; their entries behave like list items otherwise
+        let rollTable: RollTable | undefined;
+        if (isRollTable(list)) {
+            rollTable = this.getRollTable(tablePath, list);
+            if (!rollTable) return '';
+            list = rollTable.entries.map(entry => entry.value);
+        }
 
         if (!Array.isArray(list)) {
-            if (this.options.debug) {
-                console.warn(`Table not found or not array: ${tablePath}`);
-            }
-            return ''; // Graceful degradation - return empty string
+            // Graceful degradation - empty string (strict mode throws)
+            return this.fail(list === undefined
+                ? new ResolveError('unknown_table', `Unknown table or variable "${tablePath}"`, { table: tablePath })
+                : new ResolveError('not_a_list', `Table "${tablePath}" is not a list`, { table: tablePath }));
         }
 
-        // Filter out exclusions
-        let filteredList = list;
+        // Filter out exclusions (keeping original positions for unique tracking)
+        // Missing items (null or undefined in lists built in code) are never picked
+        const entries = this.prepareEntries(list, declared);
+        let candidates = list
+            .map((item, index) => ({ item, index }))
+            .filter(({ item }) => item !== undefined && item !== null);
         if (exclusions.length > 0) {
-            filteredList = list.filter(item => {
-                const cleanItem = item.replace(/ \^[\d.]+$/, ''); // Remove weight for comparison
+            candidates = candidates.filter(({ index }) => {
+                const cleanItem = entries[index].value; // Weight removed for comparison
                 return !exclusions.some(exc => cleanItem.toLowerCase().includes(exc.toLowerCase()));
             });
         }
 
-        if (filteredList.length === 0) {
+        // The reference's tags, plus the call's default ones when this table uses tags at all
+        const filter = combineTagFilters(tags, candidates.some(({ index }) => entries[index].tags) ? ctx.tags : undefined);
+        if (filter && candidates.length > 0) {
+            candidates = candidates.filter(({ index }) => matchesTags(entries[index].tags || [], filter));
+            if (candidates.length === 0) {
+                return this.fail(new ResolveError('no_tag_match',
+                    `No entries of table "${tablePath}" match ${describeTagFilter(filter)}`, { table: tablePath }));
+            }
+        }
+
+        if (candidates.length === 0) {
             return ''; // No items after filtering - return empty string
         }
 
-        const weightedItems = parseWeightedList(filteredList);
-        return selectWeighted(weightedItems);
+        let drawn: Set<number> | undefined;
+        if (unique) {
+            drawn = unique instanceof Set ? unique : ctx.drawn.get(tablePath);
+            if (!drawn) {
+                drawn = new Set();
+                ctx.drawn.set(tablePath, drawn);
+            }
+            let remaining = candidates.filter(({ index }) => !drawn!.has(index));
+            if (remaining.length === 0) {
+                if (this.options.uniqueExhausted !== 'wrap') {
+                    this.fail(new ResolveError('unique_exhausted',
+                        `No unique entries left in table "${tablePath}" (${candidates.length} available)`, { table: tablePath }));
+                    return null;
+                }
+                drawn.clear();
+                remaining = candidates;
+            }
+            candidates = remaining;
+        }
+
+        const previous = ctx.previous.get(tablePath);
+        const rerolls = (noRepeat || this.options.noImmediateRepeat) && previous !== undefined && candidates.length > 1
+            ? Math.max(0, this.options.repeatRerolls ?? 1) : 0;
+
+        if (rollTable) {
+            const table = rollTable;
+            const allowed = candidates.map(({ index }) => index);
+            let weights: number[] | number = allowed.length;
+            if (ctx.choices) {
+                const chances = rollWeights(table, allowed);
+                if (chances) weights = chances; else ctx.choices.inexact = true;
+            }
+            let roll: number | undefined;
+            const pick = () => allowed[this.choose(ctx, weights, () => {
+                const result = this.rollForCandidate(table, allowed, ctx.random);
+                roll = result.roll;
+                return allowed.indexOf(result.index);
+            })];
+            let index = pick();
+            for (let i = 0; i < rerolls && index === previous; i++) index = pick();
+            const entry = rollTable.entries[index];
+            drawn?.add(index);
+            ctx.previous.set(tablePath, index);
+            if (ctx.trace) ctx.pick = { index, weight: entry.max - entry.min + 1, roll };
+            return splitTags(entry.value, declared).value;
+        }
+
+        const weightedItems = candidates.map(({ index }) => entries[index]);
+        const pick = () => this.choose(ctx, weightedItems.map(item => item.weight), () => selectWeightedIndex(weightedItems, ctx.random));
+        let chosen = pick();
+        for (let i = 0; i < rerolls && chosen >= 0 && candidates[chosen].index === previous; i++) chosen = pick();
+        if (chosen < 0) {
+            return '';
+        }
+        drawn?.add(candidates[chosen].index);
+        ctx.previous.set(tablePath, candidates[chosen].index);
+        if (ctx.trace) ctx.pick = { index: candidates[chosen].index, weight: weightedItems[chosen].weight };
+        return weightedItems[chosen].value;
+    }
+
+    // A table's @count (or a reference's *1d4): a number, a range like "0-2" or a dice expression
+    private rollCount(count: string, tablePath: string, ctx: GenerationContext, label = `@count "${count}"`): number {
+        const text = count.trim();
+        const range = text.match(/^(\d+)\s*-\s*(\d+)$/);
+        if (/^\d+$/.test(text)) return parseInt(text);
+        if (range) {
+            const min = parseInt(range[1]);
+            const max = parseInt(range[2]);
+            return this.uniform(ctx, min, max);
+        }
+        try {
+            return Math.max(0, this.rollTotal(parseDice(text), ctx));
+        } catch (error) {
+            this.fail(new ResolveError('invalid_count', `Invalid ${label} on table "${tablePath}"`, { table: tablePath }));
+            return 1;
+        }
+    }
+
+    // Literal text, or the value of a variable; undefined variables are generation errors
+    private resolveOperand(operand: ConditionOperand, ctx: GenerationContext): string | undefined {
+        if (operand.kind === 'literal') return operand.value;
+        const value = ctx.vars.get(operand.name);
+        if (value === undefined) {
+            this.fail(new ResolveError('undefined_variable', `Undefined variable "${operand.name}" in condition`));
+        }
+        return value;
+    }
+
+    // Numbers compare numerically; other text only supports equality
+    private compare(left: string, operator: ComparisonOperator, right: string): boolean {
+        const isNumber = (text: string) => text.trim() !== '' && Number.isFinite(Number(text));
+        if (isNumber(left) && isNumber(right)) {
+            const a = Number(left);
+            const b = Number(right);
+            switch (operator) {
+                case '==': return a === b;
+                case '!=': return a !== b;
+                case '<': return a < b;
+                case '<=': return a <= b;
+                case '>': return a > b;
+                case '>=': return a >= b;
+            }
+        }
+        if (operator === '==') return left === right;
+        if (operator === '!=') return left !== right;
+        this.fail(new ResolveError('invalid_comparison', `Cannot compare "${left}" ${operator} "${right}": both sides must be numbers`));
+        return false;
+    }
+
+    // Each entry's weight and tags, worked out on a list's first pick rather than on every pick
+    private prepareEntries(list: unknown[], declared: string[]): WeightedItem[] {
+        let prepared = this.preparedLists.get(list);
+        if (!prepared || prepared.items.length !== list.length || prepared.items.some((item, index) => item !== list[index])
+            || prepared.declared.join(',') !== declared.join(',')) {
+            prepared = {
+                items: [...list],
+                declared,
+                entries: list.map(item => parseWeight(String(item ?? ''), declared))
+            };
+            this.preparedLists.set(list, prepared);
+        }
+        return prepared.entries;
+    }
+
+    // Parsed once per table object; invalid tables are reported and yield undefined
+    private getRollTable(tablePath: string, value: any): RollTable | undefined {
+        let table = this.rollTables.get(value);
+        if (!table) {
+            try {
+                table = parseRollTable(value);
+            } catch (error) {
+                this.fail(new ResolveError('invalid_roll_table',
+                    `Invalid roll table "${tablePath}": ${error instanceof Error ? error.message : error}`, { table: tablePath }));
+                return undefined;
+            }
+            this.rollTables.set(value, table);
+        }
+        return table;
+    }
+
+    // .words, .ordinal, .number and .currency; text that is not a suitable number is reported and kept
+    private formatNumberText(text: string, modifier: string): string {
+        const value = Number(text.trim());
+        let formatted: string | undefined;
+        if (text.trim() !== '' && Number.isFinite(value)) {
+            switch (modifier) {
+                case 'words': formatted = numberToWords(value, this.locale); break;
+                case 'ordinal': formatted = numberToOrdinal(value, this.locale); break;
+                case 'number': formatted = formatGrouped(value, this.locale); break;
+                case 'currency': formatted = this.locale.currency.replace('{n}', formatGrouped(value, this.locale, 2)); break;
+            }
+        }
+        if (formatted === undefined) {
+            const expected = modifier === 'words' || modifier === 'ordinal' ? 'a whole number' : 'a number';
+            return this.fail(new ResolveError('not_a_number', `.${modifier} needs ${expected}, got "${text}"`), text);
+        }
+        return formatted;
+    }
+
+    /**
+     * Make one random choice among weighted options (or `weights` equally likely
+     * ones). Normal generation just calls `sample`, so the random stream is used
+     * exactly as before; analyze() replays and records the choice instead.
+     */
+    private choose(ctx: GenerationContext, weights: number[] | number, sample: () => number): number {
+        const script = ctx.choices;
+        if (!script) return sample();
+
+        const size = typeof weights === 'number' ? weights : weights.length;
+        const weightOf = (i: number) => typeof weights === 'number' ? 1 : Math.max(0, weights[i]);
+        let total = 0;
+        for (let i = 0; i < size; i++) total += weightOf(i);
+        if (total <= 0) return -1;
+        if (size > MAX_BRANCHES) {
+            script.inexact = true;
+            return 0;
+        }
+
+        const depth = script.taken.length;
+        let index = depth < script.forced.length ? script.forced[depth] : 0;
+        while (weightOf(index) <= 0) index++;
+        script.taken.push({ index, weights });
+        script.probability *= weightOf(index) / total;
+        return index;
+    }
+
+    // A whole number from min to max, all equally likely
+    private uniform(ctx: GenerationContext, min: number, max: number): number {
+        const size = max - min + 1;
+        return this.choose(ctx, size, () => Math.floor(ctx.random() * size)) + min;
+    }
+
+    // Total of a dice roll; analyze() chooses among the possible totals
+    private rollTotal(spec: DiceSpec, ctx: GenerationContext): number {
+        if (!ctx.choices) return rollDice(spec, ctx.random).total;
+        const totals = diceDistribution(spec);
+        if (!totals) {
+            ctx.choices.inexact = true;
+            return spec.count + spec.modifier;
+        }
+        return totals[this.choose(ctx, totals.map(t => t.ways), () => 0)].total;
+    }
+
+    // Roll until an allowed entry comes up; after excluding most entries fall back to range widths
+    private rollForCandidate(table: RollTable, allowed: number[], random: RandomSource): { index: number; roll?: number } {
+        const attempts = allowed.length < table.entries.length ? 100 : 1;
+        for (let attempt = 0; attempt < attempts; attempt++) {
+            const { roll, entry } = rollOnTable(table, random);
+            const index = table.entries.indexOf(entry);
+            if (allowed.includes(index)) return { index, roll };
+        }
+        const widths = allowed.map(index => ({ value: String(index), weight: table.entries[index].max - table.entries[index].min + 1 }));
+        return { index: allowed[Math.max(0, selectWeightedIndex(widths, random))] };
+    }
+
+    // Open a structured node under the current one; a no-op outside generateStructured
+    private traceEnter(ctx: GenerationContext, type: GenerationNode['type'], name: string, details: Partial<GenerationNode> = {}): GenerationNode | undefined {
+        if (!ctx.trace) return undefined;
+        const node = createGenerationNode(type, name, details);
+        if (ctx.tree) ctx.trace[ctx.trace.length - 1].children.push(node);
+        ctx.trace.push(node);
+        return node;
+    }
+
+    private traceExit(ctx: GenerationContext, node: GenerationNode | undefined, text: string, status?: GenerationNode['status']): void {
+        if (!node || !ctx.trace) return;
+        node.text = text;
+        if (status) node.status = status;
+        const depth = ctx.trace.lastIndexOf(node);
+        ctx.trace.splice(depth);
+        if (ctx.onTrace) {
+            const event = { type: node.type, name: node.name } as TraceEvent;
+            if (node.index !== undefined) event.index = node.index;
+            if (node.weight !== undefined) event.weight = node.weight;
+            if (node.roll !== undefined) event.roll = node.roll;
+            event.depth = depth;
+            event.vars = Object.fromEntries(ctx.vars);
+            event.text = text;
+            if (status) event.status = status;
+            ctx.onTrace(event);
+        }
     }
 
     private processIndefiniteArticles(text: string): string {
@@ -367,6 +1585,92 @@ export class SigilEngine {
         }
     }
 
+    // Text for a reference to an undefined table, according to missingTable
+    private missingTable(tablePath: string): string {
+        switch (this.options.missingTable) {
+            case 'placeholder': return `«${tablePath}»`;
+            case 'empty': return '';
+            default: return this.fail(new ResolveError('unknown_table', `Unknown table or variable "${tablePath}"`, { table: tablePath }));
+        }
+    }
+
+    // Report a generation problem according to errorMode; returns the text to use instead
+    private fail(problem: Error, fallback: string = ''): string {
+        if (this.options.errorMode === 'strict') {
+            throw problem;
+        }
+        if (this.options.errorMode !== 'silent') {
+            this.warn(problem.message);
+        }
+        return fallback;
+    }
+
+    // Apply a chain of modifiers left-to-right; unknown names leave the text unchanged
+    // Modifiers are traced as steps inside the reference they apply to
+    // Modifier events have the depth of the reference they modify, given as its open trace node if it has one
+    private applyModifiers(text: string, modifiers: string[] | undefined, ctx: GenerationContext, traced?: GenerationNode): string {
+        if (!modifiers || modifiers.length === 0) return text;
+        let out = text;
+        for (const mod of modifiers) {
+            const fn = this.modifiers.get(mod);
+            if (fn) {
+                const input = out;
+                out = fn(out);
+                if (ctx.onTrace) {
+                    ctx.onTrace({
+                        type: 'modifier', name: mod, input, output: out,
+                        depth: !ctx.trace ? 1 : traced ? ctx.trace.lastIndexOf(traced) : ctx.trace.length,
+                        vars: Object.fromEntries(ctx.vars), text: out
+                    });
+                }
+            } else {
+                this.warn(`Unknown modifier "${mod}"`);
+            }
+        }
+        return out;
+    }
+
+    /**
+     * Peel registered modifiers off the end of a path the parser could not split,
+     * e.g. "monster.a" or "name.myCustom". Segments are only treated as modifiers
+     * when the full path does not name a table or variable.
+     */
+    private splitModifiers(tablePath: string, modifiers: string[] | undefined, ctx: GenerationContext): { tablePath: string; modifiers?: string[] } {
+        let path = tablePath;
+        const extra: string[] = [];
+        while (path.includes('.') && !ctx.vars.has(path) && this.lookupTable(path, ctx) === undefined) {
+            const dot = path.lastIndexOf('.');
+            const last = path.slice(dot + 1);
+            if (!this.modifiers.has(last)) break;
+            extra.unshift(last);
+            path = path.slice(0, dot);
+        }
+        if (extra.length === 0) {
+            return { tablePath, modifiers };
+        }
+        return { tablePath: path, modifiers: [...extra, ...(modifiers || [])] };
+    }
+
+    // Resolve nested sigils inside a table path, e.g. "loot.[1d3]" -> "loot.2"
+    private resolveTablePath(tablePath: string, ctx: GenerationContext): string {
+        if (!tablePath.includes('[') && !tablePath.includes('{')) {
+            return tablePath;
+        }
+        return this.evaluateTemplateNode(this.parse(tablePath).node, ctx).trim();
+    }
+
+    // Entries are parsed once per engine, not on every pick; the cache starts over when it gets large,
+    // except for what precompile() parsed
+    private parse(template: string): ParsedTemplate {
+        let parsed = this.compiled.get(template) ?? this.parsed.get(template);
+        if (!parsed) {
+            parsed = parseTemplate(template);
+            if (this.parsed.size >= PARSE_CACHE_SIZE) this.parsed.clear();
+            this.parsed.set(template, parsed);
+        }
+        return parsed;
+    }
+
     // Isolate non-sigil textual post-processing (e.g., articles) here
     private applyPostProcessors(text: string): string {
         return this.processIndefiniteArticles(text);
@@ -385,37 +1689,140 @@ export class SigilEngine {
      * - Evaluation proceeds from innermost to outermost expressions
      * 
      * @param node TemplateNode to evaluate (from parseCompleteTemplate)
+     * @param ctx Per-call generation state (random stream, depth)
      * @returns Fully resolved string result
      */
-    private evaluateTemplateNode(node: TemplateNode): string {
+    private evaluateTemplateNode(node: TemplateNode, ctx: GenerationContext): string {
         switch (node.type) {
             case 'text':
-                return node.value;
+                return unescapeSigils(node.value);
 
             case 'table': {
+                // A "??" fallback only covers this reference, not the tables its entries use
+                const fallback = ctx.fallback;
+                ctx.fallback = undefined;
+
                 // Handle optional tables - 50% chance to return empty
-                if (node.isOptional && Math.random() < 0.5) {
+                if (node.isOptional && this.choose(ctx, 2, () => ctx.random() < 0.5 ? 0 : 1) === 0) {
                     return '';
                 }
                 // Determine repetition count
                 let repetitionCount: number;
                 if (typeof node.repetition === 'number') {
                     repetitionCount = node.repetition;
+                } else if (typeof node.repetition === 'string') {
+                    repetitionCount = this.rollCount(node.repetition, node.tablePath, ctx, `count "*${node.repetition}"`);
                 } else {
                     const { min, max } = node.repetition;
-                    repetitionCount = Math.floor(Math.random() * (max - min + 1)) + min;
+                    repetitionCount = this.uniform(ctx, min, max);
                 }
                 // Generate the requested number of items
+                const split = this.splitModifiers(this.resolveTablePath(node.tablePath, ctx), node.modifiers, ctx);
+                const tablePath = split.tablePath;
+                const modifiers = split.modifiers;
                 const results: string[] = [];
+
+                // Table-level defaults (@count, @join, ...); an explicit *N wins over @count
+                const tableValue = this.lookupTable(tablePath, ctx);
+                const settings = getTableSettings(tableValue);
+                if (node.separator !== undefined || node.lastSeparator !== undefined) {
+                    // A separator given on the reference replaces both of the table's
+                    settings.join = node.separator ?? settings.join;
+                    settings.lastJoin = node.lastSeparator ?? (node.separator === undefined ? settings.lastJoin : undefined);
+                }
+                if (settings.count !== undefined && !node.explicitRepetition) {
+                    repetitionCount = this.rollCount(settings.count, tablePath, ctx);
+                }
+                let unique: boolean | Set<number> = !!modifiers?.includes('unique');
+                if (settings.unique && !unique) {
+                    // Unique within this reference only; never ask for more than the table holds
+                    unique = new Set<number>();
+                    repetitionCount = Math.min(repetitionCount, getTableEntries(tableValue)?.length ?? repetitionCount);
+                }
+
+                // Variables shadow tables and are already fully resolved
+                const variable = ctx.vars.get(tablePath);
+                if (variable !== undefined) {
+                    for (let i = 0; i < repetitionCount; i++) {
+                        const traced = this.traceEnter(ctx, 'variable', tablePath);
+                        results.push(this.applyModifiers(variable, modifiers, ctx, traced));
+                        this.traceExit(ctx, traced, results[results.length - 1]);
+                    }
+                    return modifiers?.includes('list') ? joinList(results, this.locale) : joinPicks(results, settings);
+                }
+
+                // [@name] macros expand in place, like their text written into the entry
+                if (tablePath.startsWith('@') && typeof tableValue === 'string') {
+                    for (let i = 0; i < repetitionCount; i++) {
+                        this.checkCancelled(ctx);
+                        const traced = this.traceEnter(ctx, 'macro', tablePath);
+                        ctx.chain.push(tablePath);
+                        let text: string;
+                        try {
+                            text = this.applyModifiers(this.processTemplate(tableValue, ctx), modifiers, ctx, traced);
+                        } finally {
+                            ctx.chain.pop();
+                        }
+                        this.traceExit(ctx, traced, text);
+                        results.push(text);
+                    }
+                    return modifiers?.includes('list') ? joinList(results, this.locale) : joinPicks(results, settings);
+                }
+
+                if (tableValue === undefined) {
+                    const traced = this.traceEnter(ctx, 'table', tablePath);
+                    let text = '';
+                    if (fallback) {
+                        fallback.missing = true;
+                    } else {
+                        text = this.missingTable(tablePath);
+                    }
+                    this.traceExit(ctx, traced, text, 'missing');
+                    return text;
+                }
+
+                let nothing = false;
                 for (let i = 0; i < repetitionCount; i++) {
-                    const item = this.selectFromTable(node.tablePath, node.exclusions);
-                    if (item) {
-                        let processedItem = this.processTemplate(item); // Recursive processing
-                        processedItem = applyModifiersChain(processedItem, node.modifiers);
+                    this.checkCancelled(ctx);
+                    ctx.pick = undefined;
+                    const item = this.selectFromTable(tablePath, node.exclusions, ctx, unique, node.tags, node.noRepeat);
+                    if (item === null) break; // unique pool exhausted
+                    if (isNothingEntry(item)) {
+                        this.traceExit(ctx, this.traceEnter(ctx, 'table', tablePath, ctx.pick), '', 'nothing');
+                        nothing = true;
+                    } else if (item) {
+                        const traced = this.traceEnter(ctx, 'table', tablePath, ctx.pick);
+                        ctx.chain.push(tablePath);
+                        let processedItem: string;
+                        ctx.nothing = false;
+                        try {
+                            processedItem = this.processTemplate(item, ctx); // Recursive processing
+                        } finally {
+                            ctx.chain.pop();
+                        }
+                        // An entry that only led to nothing is nothing too
+                        if (processedItem === '' && ctx.nothing) {
+                            this.traceExit(ctx, traced, '', 'nothing');
+                            nothing = true;
+                            continue;
+                        }
+                        processedItem = this.applyModifiers(processedItem, modifiers, ctx, traced);
+                        this.traceExit(ctx, traced, processedItem, processedItem === '' ? 'empty' : undefined);
+                        if (traced && ctx.tree) styleGenerationNode(traced, settings);
                         results.push(processedItem);
+                    } else if (ctx.pick) {
+                        // An empty entry was chosen
+                        this.traceExit(ctx, this.traceEnter(ctx, 'table', tablePath, ctx.pick), '', 'empty');
                     }
                 }
-                return results.join(', ');
+                ctx.nothing = nothing && results.length === 0;
+                if (ctx.nothing) {
+                    return '';
+                }
+                if (results.length === 0 && settings.empty !== undefined) {
+                    return this.processTemplate(settings.empty, ctx);
+                }
+                return modifiers?.includes('list') ? joinList(results, this.locale) : joinPicks(results, settings);
             }
 
             case 'and': {
@@ -423,9 +1830,9 @@ export class SigilEngine {
                 const andResults = node.nodes.map(n => {
                     if (n.type === 'table') {
                         const requiredTableNode = { ...n, isOptional: false };
-                        return this.evaluateTemplateNode(requiredTableNode);
+                        return this.evaluateTemplateNode(requiredTableNode, ctx);
                     } else {
-                        return this.evaluateTemplateNode(n);
+                        return this.evaluateTemplateNode(n, ctx);
                     }
                 });
                 return andResults.join('');
@@ -433,24 +1840,131 @@ export class SigilEngine {
 
             case 'or': {
                 const orOptions = node.nodes;
-                const chosen = orOptions[Math.floor(Math.random() * orOptions.length)];
-                return this.evaluateTemplateNode(chosen);
+                const chosen = orOptions[this.choose(ctx, orOptions.length, () => Math.floor(ctx.random() * orOptions.length))];
+                return this.evaluateTemplateNode(chosen, ctx);
             }
 
             case 'group':
-                return this.evaluateTemplateNode(node.node);
+                return this.evaluateTemplateNode(node.node, ctx);
 
             case 'number_range': {
-                const result = Math.floor(Math.random() * (node.max - node.min + 1)) + node.min;
-                return result.toString();
+                return this.uniform(ctx, node.min, node.max).toString();
+            }
+
+            case 'dice': {
+                // A table named like dice ("d6", "2d6") is looked up instead
+                if (this.lookupTable(node.expression, ctx) !== undefined) {
+                    return this.evaluateTemplateNode({ type: 'table', tablePath: node.expression, isOptional: false, exclusions: [], repetition: 1 }, ctx);
+                }
+                let spec: DiceSpec;
+                try {
+                    spec = parseDice(node.expression);
+                } catch (error) {
+                    // Kept as written, so a typo shows in the output rather than leaving a gap
+                    return this.fail(new ResolveError('invalid_dice', error instanceof Error ? error.message : String(error)), `[${node.expression}]`);
+                }
+                const total = this.rollTotal(spec, ctx);
+                this.traceExit(ctx, this.traceEnter(ctx, 'dice', node.expression, { roll: total }), total.toString());
+                return total.toString();
+            }
+
+            case 'fallback': {
+                const outer = ctx.fallback;
+                const fallback = { missing: false };
+                ctx.fallback = fallback;
+                let text: string;
+                try {
+                    text = this.evaluateTemplateNode(node.node, ctx);
+                } finally {
+                    ctx.fallback = outer;
+                }
+                return fallback.missing || text === '' ? this.evaluateTemplateNode(node.fallback, ctx) : text;
+            }
+
+            case 'capture': {
+                const value = this.evaluateTemplateNode(node.node, ctx);
+                ctx.vars.set(node.variable, value);
+                return value;
+            }
+
+            case 'expression': {
+                // Nested sigils ([2d6], {1-6}, [table]) become numbers first
+                const resolved = this.processTemplate(node.expression, ctx);
+                try {
+                    const value = evaluateExpression(resolved, name => ctx.vars.get(name));
+                    return this.applyModifiers(formatNumber(value), node.modifiers, ctx);
+                } catch (error) {
+                    const message = error instanceof Error ? error.message : String(error);
+                    return this.fail(new ResolveError('invalid_expression',
+                        resolved === node.expression ? message : `${message} (from "${node.expression}")`));
+                }
+            }
+
+            case 'function': {
+                const fn = this.functions.get(node.name);
+                if (!fn) {
+                    return this.fail(new ResolveError('unknown_function', `Unknown function "${node.name}"`));
+                }
+                const traced = this.traceEnter(ctx, 'function', node.name);
+                const args = node.args.map(arg => arg.kind === 'literal' ? arg.value : this.evaluateTemplateNode(arg.node, ctx));
+                const context: FunctionContext = {
+                    random: ctx.random,
+                    vars: Object.freeze(Object.fromEntries(ctx.vars)),
+                    table: ctx.chain[ctx.chain.length - 1]
+                };
+                let result: unknown;
+                try {
+                    result = fn(args, context);
+                } catch (error) {
+                    this.traceExit(ctx, traced, '');
+                    return this.fail(new FunctionError(node.name, args,
+                        `failed: ${error instanceof Error ? error.message : String(error)}`, error));
+                }
+                if (typeof result !== 'string' && (typeof result !== 'number' || !Number.isFinite(result))) {
+                    this.traceExit(ctx, traced, '');
+                    const kind = result instanceof Promise ? 'a Promise (functions must not be async)'
+                        : typeof result === 'object' && result !== null ? 'an object' : String(result);
+                    return this.fail(new FunctionError(node.name, args, `returned ${kind} instead of text`));
+                }
+                const text = this.applyModifiers(String(result), node.modifiers, ctx, traced);
+                this.traceExit(ctx, traced, text);
+                return text;
+            }
+
+            case 'conditional': {
+                const left = this.resolveOperand(node.left, ctx);
+                const right = this.resolveOperand(node.right, ctx);
+                if (left === undefined || right === undefined) return '';
+                const branch = this.compare(left, node.operator, right) ? node.then : node.else;
+                return branch ? this.evaluateTemplateNode(branch, ctx) : '';
             }
 
             case 'indefinite_article':
                 return '{a}';
 
             case 'mixed': {
-                const mixedResults = node.nodes.map(n => this.evaluateTemplateNode(n));
-                return mixedResults.join('');
+                if (this.options.tidyNothing === false) {
+                    const mixedResults = node.nodes.map(n => this.evaluateTemplateNode(n, ctx));
+                    return mixedResults.join('');
+                }
+                // Text on both sides of a reference that produced nothing is joined up again
+                let out = '';
+                let afterNothing = false;
+                for (const n of node.nodes) {
+                    ctx.nothing = false;
+                    const text = this.evaluateTemplateNode(n, ctx);
+                    if (text === '' && ctx.nothing) {
+                        afterNothing = true;
+                    } else if (text !== '') {
+                        // The article goes only where its phrase ends with the nothing: "You find a [loot]."
+                        if (afterNothing && PHRASE_END.test(text)) out = out.replace(ARTICLE_BEFORE_NOTHING, '$1');
+                        out = afterNothing ? joinAfterNothing(out, text) : out + text;
+                        afterNothing = false;
+                    }
+                }
+                if (afterNothing) out = out.replace(ARTICLE_BEFORE_NOTHING, '$1').replace(/[\s,;:]+$/, '');
+                ctx.nothing = afterNothing && out === '';
+                return out;
             }
 
             default:
@@ -458,3 +1972,89 @@ export class SigilEngine {
         }
     }
 }
+
+// "~" and "(nothing)" entries stand for no text at all
+function isNothingEntry(item: string): boolean {
+    const text = item.trim();
+    return text === '~' || text.toLowerCase() === '(nothing)';
+}
+
+// "You find a " in front of [treasure] when it picked "~"
+const ARTICLE_BEFORE_NOTHING = /(^|\s)(?:a|an|the|\{a\})\s*$/i;
+
+// Text after a reference that starts with punctuation ends the reference's phrase
+const PHRASE_END = /^\s*[.,;:!?]/;
+
+// "You find " + "." -> "You find."; "There is " + " here" -> "There is here"
+function joinAfterNothing(before: string, after: string): string {
+    if (PHRASE_END.test(after)) {
+        return before.replace(/[\s,;:]+$/, '') + after.trimStart();
+    }
+    if (before === '' || /\s$/.test(before)) {
+        return before + after.trimStart();
+    }
+    return before + after;
+}
+
+// The choices for the next expansion path after `taken`, or undefined when every path was visited
+function nextPath(taken: ChoiceScript['taken']): number[] | undefined {
+    for (let depth = taken.length - 1; depth >= 0; depth--) {
+        const { index, weights } = taken[depth];
+        const size = typeof weights === 'number' ? weights : weights.length;
+        for (let next = index + 1; next < size; next++) {
+            if (typeof weights === 'number' || weights[next] > 0) {
+                return [...taken.slice(0, depth).map(choice => choice.index), next];
+            }
+        }
+    }
+    return undefined;
+}
+
+// Chance of each allowed roll table entry coming up on the table's die
+function rollWeights(table: RollTable, allowed: number[]): number[] | undefined {
+    const totals = diceDistribution(table.die);
+    if (!totals) return undefined;
+    return allowed.map(index => {
+        const { min, max } = table.entries[index];
+        return totals.filter(t => t.total >= min && t.total <= max).reduce((sum, t) => sum + t.ways, 0);
+    });
+}
+
+function sortOutcomes(outcomes: Outcome[]): Outcome[] {
+    return outcomes.sort((a, b) => b.probability - a.probability || (a.text < b.text ? -1 : a.text > b.text ? 1 : 0));
+}
+
+// Render hints for renderMarkdown/renderHTML; @link's {text} becomes the node's text, URL-encoded
+function styleGenerationNode(node: GenerationNode, settings: TableSettings): void {
+    const style = settings.style?.split(/[\s,]+/).filter(Boolean);
+    if (style && style.length > 0) node.style = style;
+    if (settings.link !== undefined && node.text) {
+        node.link = settings.link.replace(/\{text\}/g, encodeURIComponent(node.text));
+    }
+}
+
+// Table paths an entry refers to; paths built from other sigils ([loot.[1d3]]) are left out
+function referencesOf(text: string): string[] {
+    const references: string[] = [];
+    try {
+        walkTemplateNodes(parseCompleteTemplate(text), node => {
+            if (node.type === 'table' && !/[\[{]/.test(node.tablePath) && !references.includes(node.tablePath)) {
+                references.push(node.tablePath);
+            }
+        });
+    } catch (error) {
+        // text that does not parse refers to nothing
+    }
+    return references;
+}
+
+// Build a node with keys in their documented JSON order
+function createGenerationNode(type: GenerationNode['type'], name: string, details: Partial<GenerationNode> = {}): GenerationNode {
+    const node = { type, name } as GenerationNode;
+    if (details.index !== undefined) node.index = details.index;
+    if (details.weight !== undefined) node.weight = details.weight;
+    if (details.roll !== undefined) node.roll = details.roll;
+    node.text = '';
+    node.children = [];
+    return node;
+}
@@ -21,14 +21,34 @@
 //    - {[table1]|[table2]} → processes table lookups, returns result
 //    - {red&large} → returns "redlarge" (AND operator - concatenation)
 //    - {[table1]&[table2]} → processes table lookups, returns concatenated result
+//    - {a [color] door|a blank wall} → alternatives may mix text and sigils, and nest
+//    - [name]{!|} → an empty alternative means "sometimes nothing"
+//    - \| \& \{ \} \[ \] \\ \# \( \) → escaped characters are output literally
+//      (\# keeps " #" in plain YAML entries from starting a comment, \( keeps a
+//      trailing "(word)" from being read as entry tags)
+//    - \n and \t → a newline and a tab
 //
 // 2. REFERENCE SIGILS: []
 //    - Performs table lookups in YAML data
 //    - [weapons] → looks up "weapons" table, returns random item
 //    - [missing_table] → table not found → returns "" + console warning
 //    - [weapons*3] → repetition modifier, returns 3 items from "weapons" table
+//    - [rooms*1d4+1, sep="; ", last=" and ", unique] → rolled count, separators and unique picks
 //    - [weapons?] → optional modifier, 50% chance of empty string
 //    - [weapons!broken] → exclusion modifier, filters out items containing "broken"
+//    - [adjective!] → rerolls a pick that repeats the previous pick from "adjective"
+//    - [encounters #urban #!winter] → only entries tagged (urban) and not (winter)
+//    - [2d6+3], [d%], [4d6kh3] → dice notation, rolled at generation time
+//    - [loot.[1d3]] → nested sigils in a table path are resolved before lookup
+//    - [name->n] → resolves [name] and also stores the result in variable "n";
+//      later [n] references reuse it (variables shadow tables of the same name)
+//    - [if t == "desert" then [desert.encounters] else [encounters]] → conditional on
+//      variables; supports == != < <= > >= (numeric when both sides are numbers),
+//      the else branch is optional and branches may hold further sigils or conditionals
+//    - [= [2d6] * 2], [= str / 2 - 5.floor] → arithmetic over numbers, variables and nested
+//      sigils; trailing .modifiers (round, floor, ceil, ...) format the result
+//    - [fn:date("YYYY", [era])] → calls a function registered with registerFunction;
+//      unquoted arguments are resolved first, quoted ones are passed as they are
 //
 // 3. GRACEFUL DEGRADATION:
 //    - Missing tables return empty string ("")
@@ -60,6 +80,10 @@
 // This eliminates ad-hoc string manipulation in favor of Abstract Syntax Tree (AST) evaluation,
 // providing robust handling of nested expressions and operator precedence.
 
+import { isDiceExpression } from './dice';
+import { parseTagFilter } from './tags';
+import type { TagFilter } from './tags';
+
 export type TemplateNode =
     | { type: 'text', value: string }
     | { type: 'and', nodes: TemplateNode[] }
@@ -71,319 +95,905 @@ export type TemplateNode =
         modifier?: string, // for backward compatibility
         isOptional: boolean,
         exclusions: string[],
-        repetition: number | { min: number, max: number }
+        repetition: number | { min: number, max: number } | string, // a string is a dice expression, e.g. "1d4+1"
+        explicitRepetition?: boolean, // set when the reference has *N, overriding a table's @count
+        noRepeat?: boolean, // [adjective!] rerolls a pick that repeats the table's previous pick
+        separator?: string, // [room*3, sep="; "] joins the picks with this instead of the table's @join
+        lastSeparator?: string, // [room*3, last=" and "] goes before the last pick
+        tags?: TagFilter // [table #urban #!winter] keeps entries with (and without) these tags
     }
     | { type: 'number_range', min: number, max: number }
+    | { type: 'dice', expression: string }
+    | { type: 'capture', variable: string, node: TemplateNode }
+    | { type: 'fallback', node: TemplateNode, fallback: TemplateNode }
+    | { type: 'expression', expression: string, modifiers?: string[] }
+    | { type: 'function', name: string, args: FunctionArgument[], modifiers?: string[] }
+    | {
+        type: 'conditional',
+        left: ConditionOperand,
+        operator: ComparisonOperator,
+        right: ConditionOperand,
+        then: TemplateNode,
+        else?: TemplateNode
+    }
     | { type: 'group', node: TemplateNode }
     | { type: 'indefinite_article' }
     | { type: 'mixed', nodes: TemplateNode[] };
 
+export type ComparisonOperator = '==' | '!=' | '<' | '<=' | '>' | '>=';
+
+// A condition side is either a literal ("desert", 3) or the name of a variable
+export type ConditionOperand = { kind: 'literal', value: string } | { kind: 'variable', name: string };
+
+// A function argument is quoted text, taken as is, or a template resolved before the call
+export type FunctionArgument = { kind: 'literal', value: string } | { kind: 'template', node: TemplateNode };
+
+// The parser makes a single pass over each template: brackets are paired once
+// up front (createSource), and every nested sigil is then parsed as a range of
+// the same string instead of being cut out and scanned again. Table paths and
+// modifier names go through a shared pool, so a large collection keeps one copy
+// of each name however many entries refer to it.
+
+interface Source {
+    text: string;
+    pairs: Int32Array;  // at each "[" and "{", the index of the bracket closing it (-1 when never closed)
+}
+
+const BACKSLASH = 92;
+const OPEN_SQUARE = 91;
+const CLOSE_SQUARE = 93;
+const OPEN_CURLY = 123;
+const CLOSE_CURLY = 125;
+
+// Parsing never re-enters itself, so every parse shares these buffers
+let pairs = new Int32Array(256);
+let openSquare = new Int32Array(256);  // stacks of the brackets still open
+let openCurly = new Int32Array(256);
+
+function createSource(text: string): Source {
+    if (pairs.length < text.length) {
+        pairs = new Int32Array(text.length * 2);
+        openSquare = new Int32Array(text.length * 2);
+        openCurly = new Int32Array(text.length * 2);
+    }
+    let squares = 0;
+    let curlies = 0;
+    for (let i = 0; i < text.length; i++) {
+        switch (text.charCodeAt(i)) {
+            case BACKSLASH:
+                i++; // escaped sigil characters are literal text
+                break;
+            case OPEN_SQUARE:
+                pairs[i] = -1;
+                openSquare[squares++] = i;
+                break;
+            case CLOSE_SQUARE:
+                if (squares > 0) pairs[openSquare[--squares]] = i;
+                break;
+            case OPEN_CURLY:
+                pairs[i] = -1;
+                openCurly[curlies++] = i;
+                break;
+            case CLOSE_CURLY:
+                if (curlies > 0) pairs[openCurly[--curlies]] = i;
+                break;
+        }
+    }
+    return { text, pairs };
+}
+
 /**
  * Parses a template string into a TemplateNode tree for bottom-up evaluation.
- * 
+ *
  * Uses recursive descent parsing to build an AST that respects operator precedence.
  * Currently handles top-level & and | operators with basic [table] and text support.
  *
  * @param input Template string to parse (e.g., "{[a*2]&{[b]|[c?]}}")
  * @returns TemplateNode AST ready for bottom-up evaluation
- * 
+ *
  * Example: "{[a*2]&{[b]|[c?]}}" -> and([table(a*2), or([table(b), table(c?)])])
  */
 export function parseTemplateExpression(input: string): TemplateNode {
-    // Remove outermost braces if present
-    let str = input.trim();
-    if (str.startsWith('{') && str.endsWith('}')) {
-        str = str.slice(1, -1);
+    return parseExpression(createSource(input), 0, input.length);
+}
+
+/**
+ * Parse a complete template that may contain mixed content.
+ *
+ * This is the main function for full AST-based parsing and evaluation.
+ * Unlike parseTemplateExpression which handles single expressions like "{a|b}",
+ * this function can parse complete templates like:
+ * "A {[weapons]&[materials]} {a} [creature] appears"
+ *
+ * Malformed sigils are kept as literal text rather than reported.
+ */
+export function parseCompleteTemplate(template: string): TemplateNode {
+    // Most entries are plain text
+    if (template.length > 0 && template.indexOf('[') === -1 && template.indexOf('{') === -1) {
+        return { type: 'text', value: template };
     }
+    return parseMixed(createSource(template), 0, template.length);
+}
+
+/**
+ * Turn escaped sigil characters (\| \& \{ \} \[ \] \\), \# and \( \) back into literal text;
+ * \n and \t become a newline and a tab.
+ */
+export function unescapeSigils(text: string): string {
+    return text.replace(/\\([|&{}\[\]\\#()nt])/g, (_, char) => char === 'n' ? '\n' : char === 't' ? '\t' : char);
+}
 
-    // Top-level split on & (AND)
-    let andParts = splitTopLevel(str, '&');
-    if (andParts.length > 1) {
-        return { type: 'and', nodes: andParts.map(parseTemplateExpression) };
+/**
+ * Sigil brackets that do not pair up: a [ or { that is never closed, or a ] or }
+ * with nothing open to close. Escaped characters are skipped.
+ *
+ * @returns Each bracket with its 0-based index, in the order found
+ */
+export function findUnbalancedSigils(text: string): Array<{ char: string; index: number }> {
+    const open: Array<{ char: string; index: number }> = [];
+    const stray: Array<{ char: string; index: number }> = [];
+    for (let i = 0; i < text.length; i++) {
+        const char = text[i];
+        if (char === '\\') {
+            i++; // escaped sigil characters are literal
+        } else if (char === '[' || char === '{') {
+            open.push({ char, index: i });
+        } else if (char === ']' || char === '}') {
+            const expected = char === ']' ? '[' : '{';
+            const match = open.map(bracket => bracket.char).lastIndexOf(expected);
+            if (match >= 0) {
+                open.splice(match);
+            } else {
+                stray.push({ char, index: i });
+            }
+        }
     }
+    return [...open, ...stray].sort((a, b) => a.index - b.index);
+}
 
-    // Top-level split on | (OR)
-    let orParts = splitTopLevel(str, '|');
-    if (orParts.length > 1) {
-        return { type: 'or', nodes: orParts.map(parseTemplateExpression) };
+/**
+ * Visit every node of a parsed template, parents before children.
+ */
+export function walkTemplateNodes(node: TemplateNode, visit: (node: TemplateNode) => void): void {
+    visit(node);
+    switch (node.type) {
+        case 'mixed':
+        case 'and':
+        case 'or':
+            for (const child of node.nodes) walkTemplateNodes(child, visit);
+            break;
+        case 'group':
+        case 'capture':
+            walkTemplateNodes(node.node, visit);
+            break;
+        case 'fallback':
+            walkTemplateNodes(node.node, visit);
+            walkTemplateNodes(node.fallback, visit);
+            break;
+        case 'conditional':
+            walkTemplateNodes(node.then, visit);
+            if (node.else) walkTemplateNodes(node.else, visit);
+            break;
+        case 'function':
+            for (const arg of node.args) {
+                if (arg.kind === 'template') walkTemplateNodes(arg.node, visit);
+            }
+            break;
+        default:
+            break;
     }
+}
 
-    // Table reference (with optional modifiers like ?, !, *, etc.)
-    if (str.startsWith('[') && str.includes(']')) {
-        // Extract table content up to the closing bracket, and any trailing modifiers
-        const closingBracket = str.indexOf(']');
-        const tableContent = str.slice(1, closingBracket);
-        const modifiers = str.slice(closingBracket + 1);
+// Names shared by the ASTs of every template parsed; starts over when it gets large
+const NAME_POOL_SIZE = 10000;
+const namePool = new Map<string, string>();
 
-        // Parse the table reference with all modifiers
-        return parseTableReference(tableContent, modifiers);
-    }
+function intern(name: string): string {
+    const known = namePool.get(name);
+    if (known !== undefined) return known;
+    if (namePool.size >= NAME_POOL_SIZE) namePool.clear();
+    namePool.set(name, name);
+    return name;
+}
 
-    // Grouped expression: ( ... )
-    if (str.startsWith('(') && str.endsWith(')')) {
-        // Remove outermost parentheses and parse as group
-        const inner = str.slice(1, -1).trim();
-        return { type: 'group', node: parseTemplateExpression(inner) };
-    }
-    // Braced group: { ... } (not parenthesized)
-    if (str.startsWith('{') && str.endsWith('}')) {
-        // Parse as normal expression (OR/AND)
-        return parseTemplateExpression(str.slice(1, -1));
-    }
+// The characters String.prototype.trim() removes, which are also what \s matches
+function isSpace(code: number): boolean {
+    return code === 32 || (code >= 9 && code <= 13) || code === 0xa0 || code === 0x1680
+        || (code >= 0x2000 && code <= 0x200a) || code === 0x2028 || code === 0x2029
+        || code === 0x202f || code === 0x205f || code === 0x3000 || code === 0xfeff;
+}
 
-    // Number range: 1-10, 5-20, etc.
-    const numberRangeMatch = str.match(/^(\d+)-(\d+)$/);
-    if (numberRangeMatch) {
-        const min = parseInt(numberRangeMatch[1]);
-        const max = parseInt(numberRangeMatch[2]);
-        return { type: 'number_range', min, max };
-    }
+function isDigit(code: number): boolean {
+    return code >= 48 && code <= 57;
+}
 
-    // Indefinite article: 'a'
-    if (str === 'a') {
-        return { type: 'indefinite_article' };
-    }
+function isLetter(code: number): boolean {
+    return (code >= 65 && code <= 90) || (code >= 97 && code <= 122);
+}
 
-    // Plain text
-    return { type: 'text', value: str };
+// \w
+function isWordChar(code: number): boolean {
+    return isLetter(code) || isDigit(code) || code === 95;
 }
 
-// Utility: split on a character, but only at top level (not inside braces/brackets)
-function splitTopLevel(str: string, sep: string): string[] {
-    let parts: string[] = [];
-    let depth = 0;
-    let last = 0;
-    for (let i = 0; i < str.length; i++) {
-        if (str[i] === '{' || str[i] === '[') depth++;
-        if (str[i] === '}' || str[i] === ']') depth--;
-        if (str[i] === sep && depth === 0) {
-            parts.push(str.slice(last, i));
-            last = i + 1;
+// [\w-], the characters of tag names and capture variables
+function isNameChar(code: number): boolean {
+    return isWordChar(code) || code === 45;
+}
+
+function trimStart(text: string, start: number, end: number): number {
+    while (start < end && isSpace(text.charCodeAt(start))) start++;
+    return start;
+}
+
+function trimEnd(text: string, start: number, end: number): number {
+    while (end > start && isSpace(text.charCodeAt(end - 1))) end--;
+    return end;
+}
+
+// The bracket pairing the one at open (a "[" or "{"), if it closes before end
+function closing(pairs: Int32Array, open: number, end: number): number {
+    const close = pairs[open];
+    return close !== -1 && close < end ? close : -1;
+}
+
+// First unescaped occurrence of a bracket from start, or -1
+function findOpening(text: string, start: number, end: number, bracket: number): number {
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (code === BACKSLASH) {
+            i++;
+        } else if (code === bracket) {
+            return i;
         }
     }
-    parts.push(str.slice(last));
-    return parts.map(s => s.trim()).filter(Boolean);
+    return -1;
 }
 
-/**
- * Phase 3: Parse a complete template that may contain mixed content.
- * 
- * This is the main function for full AST-based parsing and evaluation.
- * Unlike parseTemplateExpression which handles single expressions like "{a|b}",
- * this function can parse complete templates like:
- * "A {[weapons]&[materials]} {a} [creature] appears"
- * 
- * Strategy: Scan for SIGIL patterns and build an AST of mixed content.
- */
-export function parseCompleteTemplate(template: string): TemplateNode {
+// Text with [references] and {inline} sigils
+function parseMixed(source: Source, start: number, end: number): TemplateNode {
+    const { text } = source;
     const nodes: TemplateNode[] = [];
-    let currentPos = 0;
-
-    // Scan through the template looking for SIGIL patterns
-    while (currentPos < template.length) {
-        // Look for the next SIGIL pattern
-        const nextPattern = findNextSigilPattern(template, currentPos);
-
-        if (!nextPattern) {
-            // No more patterns, add remaining text
-            const remainingText = template.slice(currentPos);
-            if (remainingText) {
-                nodes.push({ type: 'text', value: remainingText });
-            }
-            break;
+    let position = start;
+    // The next "[" and "{", kept while they are still ahead (-2 until first looked for)
+    let square = -2;
+    let curly = -2;
+
+    while (position < end) {
+        if (square !== -1 && square < position) square = findOpening(text, position, end, OPEN_SQUARE);
+        if (curly !== -1 && curly < position) curly = findOpening(text, position, end, OPEN_CURLY);
+
+        // Only the first bracket of each kind counts; one that is never closed hides the rest
+        let open = -1;
+        let close = -1;
+        let inline = false;
+        const squareClose = square === -1 ? -1 : closing(source.pairs, square, end);
+        if (squareClose !== -1) {
+            open = square;
+            close = squareClose;
         }
-
-        // Add text before the pattern
-        if (nextPattern.start > currentPos) {
-            const textBefore = template.slice(currentPos, nextPattern.start);
-            if (textBefore) {
-                nodes.push({ type: 'text', value: textBefore });
-            }
+        const curlyClose = curly === -1 ? -1 : closing(source.pairs, curly, end);
+        if (curlyClose !== -1 && (open === -1 || curly < open)) {
+            open = curly;
+            close = curlyClose;
+            inline = true;
         }
 
-        // Parse the pattern itself
+        if (open === -1) {
+            nodes.push({ type: 'text', value: text.slice(position, end) });
+            break;
+        }
+        if (open > position) {
+            nodes.push({ type: 'text', value: text.slice(position, open) });
+        }
         try {
-            const patternNode = parseSigilPattern(nextPattern);
-            nodes.push(patternNode);
+            nodes.push(inline ? parseExpression(source, open, close + 1) : parseReference(source, open + 1, close, ''));
         } catch (error) {
             // If parsing fails, treat as literal text
-            nodes.push({ type: 'text', value: nextPattern.fullMatch });
+            nodes.push({ type: 'text', value: text.slice(open, close + 1) });
+        }
+        position = close + 1;
+    }
+
+    return nodes.length === 1 ? nodes[0] : { type: 'mixed', nodes };
+}
+
+// The content of an inline sigil: {a|b}, {a&b}, ranges, groups and text
+function parseExpression(source: Source, start: number, end: number): TemplateNode {
+    const { text } = source;
+    start = trimStart(text, start, end);
+    end = trimEnd(text, start, end);
+    // Remove outermost braces if present
+    if (wrapsWhole(source, start, end)) {
+        start++;
+        end--;
+    }
+
+    // Top-level & (AND) binds looser than | (OR); empty alternatives are kept so {word|} can yield nothing
+    const and: number[] = [];
+    const or: number[] = [];
+    let depth = 0;
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (code === BACKSLASH) {
+            i++; // escaped character never splits or nests
+        } else if (code === OPEN_CURLY || code === OPEN_SQUARE) {
+            depth++;
+        } else if (code === CLOSE_CURLY || code === CLOSE_SQUARE) {
+            depth--;
+        } else if (depth === 0 && code === 38) {
+            and.push(i);
+        } else if (depth === 0 && code === 124) {
+            or.push(i);
+        }
+    }
+    if (and.length > 0) {
+        const parts = splitAt(text, start, end, and).filter(([from, to]) => to > from);
+        if (parts.length > 1) {
+            return { type: 'and', nodes: parts.map(([from, to]) => parseExpression(source, from, to)) };
         }
+    }
+    if (or.length > 0) {
+        return { type: 'or', nodes: splitAt(text, start, end, or).map(([from, to]) => parseExpression(source, from, to)) };
+    }
 
-        currentPos = nextPattern.end;
+    // Table reference, optionally followed by ? marks
+    const first = text.charCodeAt(start);
+    if (first === OPEN_SQUARE) {
+        const close = closing(source.pairs, start, end);
+        if (close !== -1 && allQuestionMarks(text, close + 1, end)) {
+            return parseReference(source, start + 1, close, text.slice(close + 1, end));
+        }
     }
 
-    // If we only have one node and it's not text, return it directly
-    if (nodes.length === 1 && nodes[0].type !== 'text') {
-        return nodes[0];
+    // Grouped expression: ( ... )
+    if (first === 40 && start < end && text.charCodeAt(end - 1) === 41) {
+        // "(" on its own is both the opening and the closing
+        return { type: 'group', node: parseExpression(source, start + 1, Math.max(start + 1, end - 1)) };
+    }
+    // Braced group: { ... } (not parenthesized)
+    if (wrapsWhole(source, start, end)) {
+        return parseExpression(source, start + 1, end - 1);
     }
 
-    // If we only have one text node, return it directly
-    if (nodes.length === 1 && nodes[0].type === 'text') {
-        return nodes[0];
+    // Number range: 1-10, 5-20, etc.
+    const range = numberRange(text, start, end);
+    if (range) {
+        return range;
     }
 
-    // Multiple nodes - return as mixed content
-    return { type: 'mixed', nodes };
+    // Indefinite article: 'a'
+    if (end - start === 1 && first === 97) {
+        return { type: 'indefinite_article' };
+    }
+
+    // Text mixed with sigils, e.g. one alternative of {a [color] door|a blank wall}
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if ((code === OPEN_SQUARE || code === OPEN_CURLY) && (i === start || text.charCodeAt(i - 1) !== BACKSLASH)) {
+            return parseMixed(source, start, end);
+        }
+    }
+
+    // Plain text
+    return { type: 'text', value: text.slice(start, end) };
 }
 
-interface SigilPattern {
-    start: number;
-    end: number;
-    content: string;  // Content without brackets/braces
-    fullMatch: string; // Full match including brackets/braces
-    type: 'table' | 'inline';
+// True when the whole range is one balanced {...} (so "{a}{b}" is not)
+function wrapsWhole(source: Source, start: number, end: number): boolean {
+    return start < end && source.text.charCodeAt(start) === OPEN_CURLY && closing(source.pairs, start, end) === end - 1;
 }
 
+// The trimmed ranges between separator positions
+function splitAt(text: string, start: number, end: number, separators: number[]): Array<[number, number]> {
+    const parts: Array<[number, number]> = [];
+    let from = start;
+    for (let i = 0; i <= separators.length; i++) {
+        const at = i < separators.length ? separators[i] : end;
+        const partStart = trimStart(text, from, at);
+        parts.push([partStart, trimEnd(text, partStart, at)]);
+        from = at + 1;
+    }
+    return parts;
+}
+
+function allQuestionMarks(text: string, start: number, end: number): boolean {
+    for (let i = start; i < end; i++) {
+        if (text.charCodeAt(i) !== 63) return false;
+    }
+    return true;
+}
+
+// "1-6": digits, a dash and digits, nothing else
+function numberRange(text: string, start: number, end: number): TemplateNode | undefined {
+    let i = start;
+    while (i < end && isDigit(text.charCodeAt(i))) i++;
+    if (i === start || i >= end - 1 || text.charCodeAt(i) !== 45) return undefined;
+    for (let j = i + 1; j < end; j++) {
+        if (!isDigit(text.charCodeAt(j))) return undefined;
+    }
+    return { type: 'number_range', min: parseInt(text.slice(start, i)), max: parseInt(text.slice(i + 1, end)) };
+}
+
+// Known text modifiers that should be separated from table path (add more as needed)
+const KNOWN_MODIFIERS = new Set(['capitalize', 'lowercase', 'pluralForm', 'markov', 'unique']);
+
 /**
- * Find the next SIGIL pattern in the template string with proper nesting support
+ * Parse a table reference with all modifiers
+ * Examples: "table", "table.subtable", "table!item*3?", "table*{1-3}", "table*1d4, sep=\"; \""
+ * Note: Only known modifiers (capitalize, lowercase, pluralForm, markov, unique) are treated as modifiers
+ * Everything else is part of the table path
  */
-function findNextSigilPattern(template: string, startPos: number): SigilPattern | null {
-    let nearestMatch: SigilPattern | null = null;
-
-    // Look for table patterns [...]
-    const tableMatch = findBalancedPattern(template, startPos, '[', ']');
-    if (tableMatch) {
-        nearestMatch = {
-            start: tableMatch.start,
-            end: tableMatch.end,
-            content: tableMatch.content,
-            fullMatch: tableMatch.fullMatch,
-            type: 'table'
+function parseReference(source: Source, start: number, end: number, trailingModifiers: string): TemplateNode {
+    const { text } = source;
+    const trimmedStart = trimStart(text, start, end);
+    const trimmedEnd = trimEnd(text, trimmedStart, end);
+
+    // Conditional: [if <operand> <op> <operand> then <template> else <template>]
+    if (text.startsWith('if', trimmedStart) && trimmedStart + 2 < trimmedEnd && isSpace(text.charCodeAt(trimmedStart + 2))) {
+        return parseConditional(source, trimmedStart, trimmedEnd);
+    }
+
+    // Variable capture: [reference->name] stores whatever the reference resolves to
+    const arrow = text.lastIndexOf('->', end - 2);
+    if (arrow > start) {
+        const variable = captureName(text, arrow + 2, end);
+        if (variable !== undefined && !hasLineBreak(text, start, arrow)) {
+            const nodeStart = trimStart(text, start, arrow);
+            return {
+                type: 'capture',
+                variable: intern(variable),
+                node: parseReference(source, nodeStart, trimEnd(text, nodeStart, arrow), trailingModifiers)
+            };
+        }
+    }
+
+    // Fallback: [table ?? "text"] or [table ?? other_table] when the table is missing or comes up empty
+    const marks = text.indexOf('??', start);
+    const fallbackAt = marks !== -1 && marks < end ? keywordAt(text, '??', start, start, end, true) : -1;
+    if (fallbackAt !== -1) {
+        const fallbackStart = trimStart(text, fallbackAt + 2, end);
+        const fallbackEnd = trimEnd(text, fallbackStart, end);
+        const nodeStart = trimStart(text, start, fallbackAt);
+        return {
+            type: 'fallback',
+            node: parseReference(source, nodeStart, trimEnd(text, nodeStart, fallbackAt), trailingModifiers),
+            fallback: isQuoted(text, fallbackStart, fallbackEnd)
+                ? parseMixed(source, fallbackStart + 1, fallbackEnd - 1)
+                : parseReference(source, fallbackStart, fallbackEnd, '')
         };
     }
 
-    // Look for inline patterns {...}
-    const inlineMatch = findBalancedPattern(template, startPos, '{', '}');
-    if (inlineMatch && (!nearestMatch || inlineMatch.start < nearestMatch.start)) {
-        nearestMatch = {
-            start: inlineMatch.start,
-            end: inlineMatch.end,
-            content: inlineMatch.content,
-            fullMatch: inlineMatch.fullMatch,
-            type: 'inline'
+    // Arithmetic: [= expression], optionally followed by .modifier segments
+    if (trimmedStart < end && text.charCodeAt(trimmedStart) === 61) {
+        // The expression is as short as it can be, leaving the segments after it as modifiers
+        let modifiersAt = trimmedStart + 1;
+        let modifiersEnd = modifierSegments(text, modifiersAt, end);
+        while (modifiersEnd === -1) {
+            modifiersAt++;
+            modifiersEnd = modifierSegments(text, modifiersAt, end);
+        }
+        const expressionStart = trimStart(text, trimmedStart + 1, modifiersAt);
+        return {
+            type: 'expression',
+            expression: text.slice(expressionStart, trimEnd(text, expressionStart, modifiersAt)),
+            modifiers: splitModifiers(text, modifiersAt, modifiersEnd)
         };
     }
 
-    return nearestMatch;
-}
+    // Author functions: [fn:name(arg, "quoted arg")], optionally followed by .modifier segments
+    const call = functionCall(text, trimmedStart, end);
+    if (call) {
+        return {
+            type: 'function',
+            name: intern(text.slice(trimmedStart + 3, call.open)),
+            args: parseFunctionArguments(source, call.open + 1, call.close),
+            modifiers: splitModifiers(text, call.close + 1, call.modifiersEnd)
+        };
+    }
 
-/**
- * Find a balanced pattern with proper nesting support (e.g., {...} or [...])
- */
-function findBalancedPattern(template: string, startPos: number, openChar: string, closeChar: string): SigilPattern | null {
-    let openIndex = template.indexOf(openChar, startPos);
-    if (openIndex === -1) return null;
+    // Dice notation is rolled, not looked up; validation happens when it is rolled
+    const lead = trimmedStart < end ? text.charCodeAt(trimmedStart) : 0;
+    if ((isDigit(lead) || lead === 100 || lead === 68) && isDiceExpression(text.slice(start, end))) {
+        return { type: 'dice', expression: text.slice(trimmedStart, trimmedEnd) };
+    }
 
-    let depth = 0;
-    let closeIndex = -1;
+    // Options after the count: [room*1d4, sep="; ", last=" and ", unique]
+    const optionsAt = optionsStart(text, start, end);
+    if (optionsAt !== -1) {
+        const node = parseReference(source, start, optionsAt, trailingModifiers);
+        if (node.type === 'table') {
+            return { ...node, ...parseReferenceOptions(text.slice(optionsAt, end), node.modifiers) };
+        }
+    }
 
-    for (let i = openIndex; i < template.length; i++) {
-        if (template[i] === openChar) {
-            depth++;
-        } else if (template[i] === closeChar) {
-            depth--;
-            if (depth === 0) {
-                closeIndex = i;
-                break;
-            }
+    // Tag filter: [encounters #urban #!winter]
+    const tagged = tagFilterStart(text, start, end);
+    if (tagged) {
+        const node = parseReference(source, start, tagged.at, trailingModifiers);
+        if (node.type === 'table') {
+            return { ...node, tags: parseTagFilter(tagged.tags) };
         }
     }
 
-    if (closeIndex === -1) return null; // No matching close bracket
+    return parseTableCore(text, start, end, trailingModifiers);
+}
 
-    const fullMatch = template.slice(openIndex, closeIndex + 1);
-    const content = template.slice(openIndex + 1, closeIndex);
+// The [\w-]+ name after "->", allowing whitespace around it
+function captureName(text: string, start: number, end: number): string | undefined {
+    const nameStart = trimStart(text, start, end);
+    let nameEnd = nameStart;
+    while (nameEnd < end && isNameChar(text.charCodeAt(nameEnd))) nameEnd++;
+    if (nameEnd === nameStart || trimStart(text, nameEnd, end) !== end) return undefined;
+    return text.slice(nameStart, nameEnd);
+}
 
-    return {
-        start: openIndex,
-        end: closeIndex + 1,
-        content,
-        fullMatch,
-        type: openChar === '[' ? 'table' : 'inline'
-    };
+// Line terminators, which a "." in a regular expression does not match
+function hasLineBreak(text: string, start: number, end: number): boolean {
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (code === 10 || code === 13 || code === 0x2028 || code === 0x2029) return true;
+    }
+    return false;
+}
+
+// "..." or '...' with no quote of the same kind inside
+function isQuoted(text: string, start: number, end: number): boolean {
+    const quote = text.charCodeAt(start);
+    if (end - start < 2 || (quote !== 34 && quote !== 39) || text.charCodeAt(end - 1) !== quote) return false;
+    for (let i = start + 1; i < end - 1; i++) {
+        if (text.charCodeAt(i) === quote) return false;
+    }
+    return true;
 }
 
 /**
- * Parse a specific SIGIL pattern that was found
+ * Where a run of ".modifier" segments starting at start ends, or -1 when
+ * anything but whitespace follows them
  */
-function parseSigilPattern(pattern: SigilPattern): TemplateNode {
-    switch (pattern.type) {
-        case 'table':
-            return parseTableReference(pattern.content, '');
+function modifierSegments(text: string, start: number, end: number): number {
+    let i = start;
+    while (i + 1 < end && text.charCodeAt(i) === 46 && isLetter(text.charCodeAt(i + 1))) {
+        i += 2;
+        while (i < end && isWordChar(text.charCodeAt(i))) i++;
+    }
+    return trimStart(text, i, end) === end ? i : -1;
+}
 
-        case 'inline':
-            return parseTemplateExpression(pattern.fullMatch);
+function splitModifiers(text: string, start: number, end: number): string[] | undefined {
+    return end > start ? text.slice(start + 1, end).split('.').map(intern) : undefined;
+}
 
-        default:
-            return { type: 'text', value: pattern.fullMatch };
+// fn:name( ... ) followed only by modifier segments; the last ")" that allows this closes the call
+function functionCall(text: string, start: number, end: number): { open: number; close: number; modifiersEnd: number } | undefined {
+    if (start + 3 >= end || !text.startsWith('fn:', start)) return undefined;
+    let i = start + 3;
+    const first = text.charCodeAt(i);
+    if (!isLetter(first) && first !== 95) return undefined;
+    while (i < end && isNameChar(text.charCodeAt(i))) i++;
+    if (i >= end || text.charCodeAt(i) !== 40) return undefined;
+    for (let close = end - 1; close > i; close--) {
+        if (text.charCodeAt(close) !== 41) continue;
+        const modifiersEnd = modifierSegments(text, close + 1, end);
+        if (modifiersEnd !== -1) return { open: i, close, modifiersEnd };
     }
+    return undefined;
 }
 
 /**
- * Parse a table reference with all modifiers
- * Examples: "table", "table.subtable", "table!item*3?", "table*{1-3}"
- * Note: Only known text modifiers (capitalize, lowercase, pluralForm, markov) are treated as modifiers
- * Everything else is part of the table path
+ * Where a trailing tag filter (" #urban #!winter") starts, with its tags, or
+ * undefined. Read from the end, since it can only be the last thing in a reference.
  */
-function parseTableReference(content: string, trailingModifiers: string): TemplateNode {
-
-    // Known text modifiers that should be separated from table path (add more as needed)
-    const knownModifiers = ['capitalize', 'lowercase', 'pluralForm', 'markov'];
+function tagFilterStart(text: string, start: number, end: number): { at: number; tags: string[] } | undefined {
+    let found: { at: number; tags: string[] } | undefined;
+    const tags: string[] = [];
+    let tagEnd = trimEnd(text, start, end);
+    for (;;) {
+        let i = tagEnd;
+        while (i > start && isNameChar(text.charCodeAt(i - 1))) i--;
+        if (i === tagEnd) break;
+        if (i > start && text.charCodeAt(i - 1) === 33) i--;
+        if (i <= start || text.charCodeAt(i - 1) !== 35) break;
+        const hash = i - 1;
+        let space = hash;
+        while (space > start && isSpace(text.charCodeAt(space - 1))) space--;
+        if (space === hash) break;
+        tags.unshift(text.slice(hash, tagEnd));
+        found = { at: space, tags: [...tags] };
+        tagEnd = space;
+    }
+    return found;
+}
 
-    // Extract the core table reference (before any !, *, ?, ^)
-    const coreMatch = content.match(/^([^!*?^]+?)(?:\^(\d+))?(?:!([^*?]+))?(?:\*(\{[\d-]+\}|\d+))?(\?)?$/);
-    let tablePath = content;
+/**
+ * The plain reference: path.modifiers, then ^weight, !exclusions, *count and ?
+ * in that order, each optional
+ */
+function parseTableCore(text: string, start: number, end: number, trailingModifiers: string): TemplateNode {
+    let tablePath: string | undefined;
     let modifiers: string[] = [];
-    let weight: string | undefined = undefined;
     let exclusionPart: string | undefined = undefined;
     let repetitionPart: string | undefined = undefined;
-    let optional: string | undefined = undefined;
+    let optional = false;
 
-    if (coreMatch) {
-        let [, corePath, weightCapture, exclusionCapture, repetitionCapture, optionalCapture] = coreMatch;
-        weight = weightCapture;
-        exclusionPart = exclusionCapture;
-        repetitionPart = repetitionCapture;
-        optional = optionalCapture;
+    const core = splitCore(text, start, end);
+    if (core) {
+        exclusionPart = core.exclusions;
+        repetitionPart = core.repetition;
+        optional = core.optional;
 
         // ARCHITECTURAL DECISION:
         // Modifiers are applied left-to-right, matching user intuition and template order.
         // For [table.capitalize.uppercase], the result is: lookup, then capitalize, then uppercase.
         // We gobble from the right, but reverse the array so the leftmost in the template is first in the array.
-        let pathParts = corePath.split('.');
+        let pathEnd = core.pathEnd;
         const tempModifiers: string[] = [];
-        while (pathParts.length > 1 && knownModifiers.includes(pathParts[pathParts.length - 1])) {
-            tempModifiers.push(pathParts.pop()!);
+        for (let dot = text.lastIndexOf('.', pathEnd - 1); dot >= start; dot = text.lastIndexOf('.', pathEnd - 1)) {
+            const modifier = text.slice(dot + 1, pathEnd);
+            if (!KNOWN_MODIFIERS.has(modifier)) break;
+            tempModifiers.push(intern(modifier));
+            pathEnd = dot;
         }
         modifiers = tempModifiers.reverse(); // Leftmost in template is first in array (left-to-right application)
-        tablePath = pathParts.join('.');
+        tablePath = text.slice(start, pathEnd);
     }
 
-    // Parse exclusions
+    // Parse exclusions; a trailing "!" with nothing after it asks for no immediate repeat
     const exclusions = exclusionPart ? exclusionPart.split('!').filter(Boolean) : [];
+    const noRepeat = exclusionPart !== undefined && (exclusionPart === '' || exclusionPart.endsWith('!'));
 
     // Parse repetition
-    let repetition: number | { min: number, max: number } = 1;
+    let repetition: number | { min: number, max: number } | string = 1;
     if (repetitionPart) {
         if (repetitionPart.startsWith('{') && repetitionPart.endsWith('}')) {
             const range = repetitionPart.slice(1, -1);
             const [min, max] = range.split('-').map(Number);
             repetition = { min, max };
+        } else if (repetitionPart.includes('d')) {
+            repetition = repetitionPart; // rolled for each use
         } else {
             repetition = parseInt(repetitionPart);
         }
     }
 
     // Parse optional
-    const isOptional = !!optional || trailingModifiers.includes('?');
+    const isOptional = optional || trailingModifiers.includes('?');
 
     return {
         type: 'table',
-        tablePath,
+        tablePath: intern(tablePath ?? text.slice(start, end)),
         modifiers: modifiers.length > 0 ? modifiers : undefined,
         isOptional,
         exclusions,
-        repetition
+        repetition,
+        ...(repetitionPart ? { explicitRepetition: true } : {}),
+        ...(noRepeat ? { noRepeat: true } : {})
+    };
+}
+
+/**
+ * Split "path^2!a!b*1d4?" into its parts; undefined when the reference does not
+ * have that shape, which leaves the whole content as the table path
+ */
+function splitCore(text: string, start: number, end: number): { pathEnd: number; exclusions?: string; repetition?: string; optional: boolean } | undefined {
+    let i = start;
+    while (i < end && !isCoreMark(text.charCodeAt(i))) i++;
+    if (i === start) return undefined;
+    const pathEnd = i;
+
+    // A weight (^2) is allowed but has no effect in a reference
+    if (i < end && text.charCodeAt(i) === 94) {
+        const digits = ++i;
+        while (i < end && isDigit(text.charCodeAt(i))) i++;
+        if (i === digits) return undefined;
+    }
+
+    let exclusions: string | undefined;
+    if (i < end && text.charCodeAt(i) === 33) {
+        const from = ++i;
+        while (i < end && text.charCodeAt(i) !== 42 && text.charCodeAt(i) !== 63) i++;
+        exclusions = text.slice(from, i);
+    }
+
+    if (i < end && text.charCodeAt(i) === 42) {
+        const countEnd = text.charCodeAt(end - 1) === 63 && end - 1 > i ? end - 1 : end;
+        const repetition = text.slice(i + 1, countEnd);
+        if (!isRepetitionCount(repetition)) return undefined;
+        return { pathEnd, exclusions, repetition, optional: countEnd < end };
+    }
+
+    let optional = false;
+    if (i < end && text.charCodeAt(i) === 63) {
+        optional = true;
+        i++;
+    }
+    return i === end ? { pathEnd, exclusions, optional } : undefined;
+}
+
+// ! * ? ^ end the table path
+function isCoreMark(code: number): boolean {
+    return code === 33 || code === 42 || code === 63 || code === 94;
+}
+
+// {1-3}, a dice expression such as 1d4+1 or d%, or a whole number
+function isRepetitionCount(count: string): boolean {
+    if (count.length >= 3 && count.startsWith('{') && count.endsWith('}')) {
+        for (let i = 1; i < count.length - 1; i++) {
+            const code = count.charCodeAt(i);
+            if (!isDigit(code) && code !== 45) return false;
+        }
+        return true;
+    }
+    let i = 0;
+    while (i < count.length && isDigit(count.charCodeAt(i))) i++;
+    if (i === count.length) return i > 0;
+    if (count.charCodeAt(i) !== 100 || i + 1 >= count.length) return false;
+    const sides = count.charCodeAt(i + 1);
+    if (!isDigit(sides) && sides !== 37) return false;
+    for (let j = i + 2; j < count.length; j++) {
+        const code = count.charCodeAt(j);
+        if (!isWordChar(code) && code !== 43 && code !== 45) return false;
+    }
+    return true;
+}
+
+/**
+ * Split "a, [b], \"c, d\"" into arguments at commas outside quotes and nested
+ * sigils. Throws on an unclosed quote, which leaves the sigil as literal text.
+ */
+function parseFunctionArguments(source: Source, start: number, end: number): FunctionArgument[] {
+    const { text } = source;
+    if (trimStart(text, start, end) === end) return [];
+    const parts: Array<[number, number]> = [];
+    let depth = 0;
+    let quote = 0;
+    let partStart = start;
+    let blank = true;  // nothing but whitespace in the argument so far
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (quote) {
+            if (code === quote) quote = 0;
+        } else if ((code === 34 || code === 39) && blank) {
+            quote = code;
+        } else if (code === OPEN_SQUARE || code === OPEN_CURLY || code === 40) {
+            depth++;
+        } else if (code === CLOSE_SQUARE || code === CLOSE_CURLY || code === 41) {
+            depth--;
+        } else if (code === 44 && depth === 0) {
+            parts.push([partStart, i]);
+            partStart = i + 1;
+            blank = true;
+            continue;
+        }
+        if (!isSpace(code)) blank = false;
+    }
+    if (quote) {
+        throw new Error(`Unclosed quote in function arguments "${text.slice(start, end)}"`);
+    }
+    parts.push([partStart, end]);
+
+    return parts.map(([from, to]): FunctionArgument => {
+        const argStart = trimStart(text, from, to);
+        const argEnd = trimEnd(text, argStart, to);
+        return isQuoted(text, argStart, argEnd)
+            ? { kind: 'literal', value: text.slice(argStart + 1, argEnd - 1) }
+            : { kind: 'template', node: parseMixed(source, argStart, argEnd) };
+    });
+}
+
+const REFERENCE_OPTIONS_PATTERN = /^(?:\s*,\s*(?:(?:sep|last)\s*=\s*(?:"[^"]*"|'[^']*')|unique))+\s*$/;
+const REFERENCE_OPTION = /(sep|last)\s*=\s*(?:"([^"]*)"|'([^']*)')|unique/g;
+
+/**
+ * Where a reference's options (", sep=\"; \", last=\" and \", unique") start,
+ * or -1 when it has none. A comma followed by anything else is left alone.
+ */
+export function findReferenceOptions(content: string): number {
+    return optionsStart(content, 0, content.length);
+}
+
+function optionsStart(text: string, start: number, end: number): number {
+    let depth = 0;
+    for (let i = start; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (code === OPEN_SQUARE || code === OPEN_CURLY) {
+            depth++;
+        } else if (code === CLOSE_SQUARE || code === CLOSE_CURLY) {
+            depth--;
+        } else if (code === 44 && depth === 0 && REFERENCE_OPTIONS_PATTERN.test(text.slice(i, end))) {
+            return i;
+        }
+    }
+    return -1;
+}
+
+function parseReferenceOptions(text: string, modifiers: string[] | undefined): { separator?: string, lastSeparator?: string, modifiers?: string[] } {
+    const options: { separator?: string, lastSeparator?: string, modifiers?: string[] } = {};
+    for (const match of text.matchAll(REFERENCE_OPTION)) {
+        const value = match[2] ?? match[3];
+        if (match[1] === 'sep') {
+            options.separator = value;
+        } else if (match[1] === 'last') {
+            options.lastSeparator = value;
+        } else if (!modifiers?.includes('unique')) {
+            // the same as writing .unique
+            options.modifiers = [...(modifiers ?? []), 'unique'];
+        }
+    }
+    return options;
+}
+
+const CONDITION_PATTERN = /^("[^"]*"|'[^']*'|[^\s=!<>"']+)\s*(==|!=|<=|>=|<|>)\s*("[^"]*"|'[^']*'|[^\s=!<>"']+)$/;
+
+/**
+ * Parse the content of an [if ...] sigil. Throws when it is malformed, which
+ * leaves the sigil as literal text (graceful degradation).
+ */
+function parseConditional(source: Source, start: number, end: number): TemplateNode {
+    const { text } = source;
+    const thenAt = keywordAt(text, 'then', start, start, end, true);
+    if (thenAt === -1) {
+        throw new Error(`Conditional "${text.slice(start, end)}" is missing "then"`);
+    }
+    const elseAt = keywordAt(text, 'else', start, thenAt + 4, end, false);
+
+    const conditionStart = trimStart(text, start + 2, thenAt);
+    const condition = text.slice(conditionStart, trimEnd(text, conditionStart, thenAt));
+    const match = condition.match(CONDITION_PATTERN);
+    if (!match) {
+        throw new Error(`Invalid condition "${condition}"`);
+    }
+
+    const thenEnd = elseAt === -1 ? end : elseAt;
+    const thenStart = trimStart(text, thenAt + 4, thenEnd);
+    const elseStart = elseAt === -1 ? -1 : trimStart(text, elseAt + 4, end);
+
+    return {
+        type: 'conditional',
+        left: parseOperand(match[1]),
+        operator: match[2] as ComparisonOperator,
+        right: parseOperand(match[3]),
+        then: parseMixed(source, thenStart, trimEnd(text, thenStart, thenEnd)),
+        else: elseAt !== -1 ? parseMixed(source, elseStart, trimEnd(text, elseStart, end)) : undefined
     };
-}
\ No newline at end of file
+}
+
+function parseOperand(text: string): ConditionOperand {
+    if (/^(".*"|'.*')$/.test(text)) {
+        return { kind: 'literal', value: text.slice(1, -1) };
+    }
+    if (/^-?\d+(\.\d+)?$/.test(text)) {
+        return { kind: 'literal', value: text };
+    }
+    return { kind: 'variable', name: intern(text) };
+}
+
+// Position of a whole-word keyword outside nested sigils (and quotes, in conditions) or -1
+export function findKeyword(content: string, keyword: string, from: number, quotes: boolean): number {
+    return keywordAt(content, keyword, 0, from, content.length, quotes);
+}
+
+// findKeyword within text[start, end); the keyword must follow whitespace inside that range
+function keywordAt(text: string, keyword: string, start: number, from: number, end: number, quotes: boolean): number {
+    let depth = 0;
+    let quote = 0;
+    const first = keyword.charCodeAt(0);
+    for (let i = from; i < end; i++) {
+        const code = text.charCodeAt(i);
+        if (quote) {
+            if (code === quote) quote = 0;
+        } else if (code === BACKSLASH) {
+            i++;
+        } else if (quotes && (code === 34 || code === 39)) {
+            quote = code;
+        } else if (code === OPEN_SQUARE || code === OPEN_CURLY) {
+            depth++;
+        } else if (code === CLOSE_SQUARE || code === CLOSE_CURLY) {
+            depth--;
+        } else if (depth === 0 && code === first && i > start && isSpace(text.charCodeAt(i - 1))
+            && i + keyword.length <= end && text.startsWith(keyword, i)) {
+            return i;
+        }
+    }
+    return -1;
+}
@@ -0,0 +1,640 @@
+/**
+ * SIGIL Tokenizer
+ * Splits table file source into tokens for syntax highlighting:
+ *
+ *   monsters:                   table-header, punctuation
+ *     - "[size.upper] wolf ^2"    punctuation, reference, modifier, entry-text, weight
+ *
+ * Tokenizing is lossless (joining every token's text gives back the source)
+ * and never throws: unbalanced sigils become 'error' tokens and scanning goes
+ * on, so highlighting keeps working while a file is half typed.
+ */
+
+import { BUILT_IN_MODIFIERS, LOCALE_MODIFIERS } from './template-engine';
+import { findKeyword } from './template-parser';
+import { isDiceExpression } from './dice';
+
+export type TokenKind =
+    | 'table-header'  // a table (or group) name before ":"
+    | 'setting'       // "@"-prefixed keys: @count, @entries, @include, ...
+    | 'entry-text'    // literal text of an entry
+    | 'reference'     // the table path of a [reference]
+    | 'dice'          // dice notation, {1-6} ranges and *N counts
+    | 'weight'        // a trailing ^N entry weight
+    | 'comment'
+    | 'variable'      // captured names ([x->name]) and variables in conditions and expressions
+    | 'modifier'      // .capitalize, .plural, ...
+    | 'keyword'       // if/then/else, comparison operators, =, ??, {a}, entry tags and #tag filters
+    | 'punctuation'   // brackets, braces, quotes, ":", "-", |, &, ->, !, *, ?
+    | 'whitespace'    // spaces, indentation and line breaks
+    | 'error';        // unbalanced sigils, invalid weights
+
+export interface Token {
+    kind: TokenKind;
+    text: string;
+    /** Offset of the first character (a JavaScript string index) */
+    start: number;
+    /** Offset after the last character */
+    end: number;
+    /** 1-based line of the first character */
+    line: number;
+    /** 1-based column of the first character */
+    column: number;
+}
+
+export interface TokenizeOptions {
+    /** Names of custom modifiers (see registerModifier) to highlight as modifiers */
+    modifiers?: string[];
+}
+
+/**
+ * Split SIGIL YAML source into highlighting tokens, in source order
+ */
+export function tokenize(source: string, options: TokenizeOptions = {}): Token[] {
+    const scanner = new TokenScanner(source, options);
+    scanner.scan();
+    return scanner.tokens;
+}
+
+/**
+ * Token types and modifiers for editor semantic highlighting (the LSP
+ * SemanticTokensLegend shape, also used by Monaco's DocumentSemanticTokensProvider)
+ */
+export const SEMANTIC_TOKEN_LEGEND = {
+    tokenTypes: ['type', 'property', 'string', 'number', 'decorator', 'comment', 'variable', 'function', 'keyword'],
+    tokenModifiers: ['declaration']
+};
+
+// Token kind -> [index into tokenTypes, modifier bits]; kinds without an entry are not highlighted
+const SEMANTIC_TYPES: { [kind: string]: [number, number] } = {
+    'table-header': [0, 1],
+    'setting': [1, 0],
+    'entry-text': [2, 0],
+    'reference': [0, 0],
+    'dice': [3, 0],
+    'weight': [4, 0],
+    'comment': [5, 0],
+    'variable': [6, 0],
+    'modifier': [7, 0],
+    'keyword': [8, 0]
+};
+
+/**
+ * Encode tokens as LSP semantic token data: five numbers per token
+ * (line delta, start delta, length, type, modifiers) against SEMANTIC_TOKEN_LEGEND.
+ * Punctuation, whitespace and errors are left out; report errors with validateSigil.
+ */
+export function encodeSemanticTokens(tokens: Token[]): number[] {
+    const data: number[] = [];
+    let line = 0;
+    let column = 0;
+    for (const token of tokens) {
+        const type = SEMANTIC_TYPES[token.kind];
+        if (!type || token.text.length === 0) continue;
+        const tokenLine = token.line - 1;
+        const tokenColumn = token.column - 1;
+        data.push(tokenLine - line, tokenLine === line ? tokenColumn - column : tokenColumn, token.text.length, type[0], type[1]);
+        line = tokenLine;
+        column = tokenColumn;
+    }
+    return data;
+}
+
+// Line-by-line scanner over the YAML structure; entries are scanned for sigils
+class TokenScanner {
+    public readonly tokens: Token[] = [];
+    private source: string;
+    private modifiers: Set<string>;
+    private variables = new Set<string>();
+    private tags = new Set<string>();
+    private offset = 0;
+    private line = 1;
+    private column = 1;
+    private blockIndent?: number;                            // indentation of the key or item that opened a | or > block
+    private openQuote?: { char: string; weighted: boolean }; // quote left open at the end of the previous line
+
+    constructor(source: string, options: TokenizeOptions) {
+        this.source = source;
+        this.modifiers = new Set([...BUILT_IN_MODIFIERS, ...LOCALE_MODIFIERS, ...(options.modifiers || [])]);
+
+        // [name] reads a variable when the file captures that name anywhere
+        const capture = /->\s*([\w-]+)\s*\]/g;
+        let match: RegExpExecArray | null;
+        while ((match = capture.exec(source)) !== null) {
+            this.variables.add(match[1]);
+        }
+
+        // A trailing (group) reads as tags when the file declares all of them in
+        // an "@tags" setting, inline ("urban, night", [urban, night]) or as items below it
+        const declaration = /^([ \t]*)["']?@tags["']?[ \t]*:[ \t]*([^#\r\n]*)((?:\r?\n\1[ \t]*-[ \t]+[^#\r\n]*)*)/gm;
+        while ((match = declaration.exec(source)) !== null) {
+            for (const tag of `${match[2]},${match[3].replace(/^\s*-/gm, ',')}`.replace(/[\[\]"']/g, '').split(',')) {
+                if (tag.trim()) this.tags.add(tag.trim().toLowerCase());
+            }
+        }
+    }
+
+    public scan(): void {
+        const src = this.source;
+        for (let start = 0; start < src.length;) {
+            let end = src.indexOf('\n', start);
+            if (end === -1) end = src.length;
+            const contentEnd = end > start && src[end - 1] === '\r' ? end - 1 : end;
+            this.scanLine(start, contentEnd);
+            this.emit('whitespace', contentEnd, Math.min(end + 1, src.length));
+            start = end + 1;
+        }
+    }
+
+    private emit(kind: TokenKind, start: number, end: number): void {
+        if (end <= start) return;
+        const text = this.source.slice(start, end);
+        this.tokens.push({ kind, text, start, end, line: this.line, column: this.column });
+        for (const char of text) {
+            if (char === '\n') {
+                this.line++;
+                this.column = 1;
+            } else {
+                this.column += char.length;
+            }
+        }
+        this.offset = end;
+    }
+
+    private skipSpace(i: number, end: number): number {
+        while (i < end && (this.source[i] === ' ' || this.source[i] === '\t')) i++;
+        this.emit('whitespace', this.offset, i);
+        return i;
+    }
+
+    private scanLine(start: number, end: number): void {
+        const src = this.source;
+        let i = this.skipSpace(start, end);
+        const indent = i - start;
+
+        if (this.openQuote) {
+            i = this.scanQuoted(i, end, this.openQuote.char, this.openQuote.weighted);
+            this.scanTrailing(i, end);
+            return;
+        }
+        if (this.blockIndent !== undefined) {
+            if (i === end || indent > this.blockIndent) {
+                this.scanTemplate(i, end);
+                return;
+            }
+            this.blockIndent = undefined;
+        }
+        if (i === end) return;
+        if (src[i] === '#') {
+            this.emit('comment', i, end);
+            return;
+        }
+        if (/^(---|\.\.\.)(\s|$)/.test(src.slice(i, end))) {
+            this.emit('punctuation', i, i + 3);
+            this.scanTrailing(i + 3, end);
+            return;
+        }
+
+        // "- " list items, possibly nested on one line
+        let weighted = false;
+        while (src[i] === '-' && (i + 1 === end || src[i + 1] === ' ' || src[i + 1] === '\t')) {
+            this.emit('punctuation', i, i + 1);
+            i = this.skipSpace(i + 1, end);
+            weighted = true;
+        }
+
+        const key = this.matchKey(i, end);
+        if (key) {
+            const kind = src.slice(key.nameStart, key.nameEnd).startsWith('@') ? 'setting' : 'table-header';
+            this.emit('punctuation', i, key.nameStart);
+            this.emit(kind, key.nameStart, key.nameEnd);
+            this.emit('punctuation', key.nameEnd, key.quoteEnd);
+            this.skipSpace(key.quoteEnd, key.colon);
+            this.emit('punctuation', key.colon, key.colon + 1);
+            i = this.skipSpace(key.colon + 1, end);
+            weighted = false;
+        }
+        this.scanValue(i, end, weighted, indent);
+    }
+
+    // A mapping key at i: "name:", "\"@count\":" or "'1-3':"
+    private matchKey(i: number, end: number): { nameStart: number; nameEnd: number; quoteEnd: number; colon: number } | undefined {
+        const src = this.source;
+        const char = src[i];
+        if (char === '"' || char === "'") {
+            const close = this.closingQuote(i + 1, end, char);
+            if (close === -1) return undefined;
+            let colon = close + 1;
+            while (colon < end && (src[colon] === ' ' || src[colon] === '\t')) colon++;
+            if (src[colon] !== ':' || !(colon + 1 === end || /\s/.test(src[colon + 1]))) return undefined;
+            return { nameStart: i + 1, nameEnd: close, quoteEnd: close + 1, colon };
+        }
+        const match = src.slice(i, end).match(/^([^\s\[\]{}#"'|>][^#]*?)([ \t]*):(?=\s|$)/);
+        if (!match) return undefined;
+        const nameEnd = i + match[1].length;
+        return { nameStart: i, nameEnd, quoteEnd: nameEnd, colon: nameEnd + match[2].length };
+    }
+
+    private scanValue(i: number, end: number, weighted: boolean, indent: number): void {
+        const src = this.source;
+        if (i >= end) return;
+
+        const block = src.slice(i, end).match(/^[|>][+-]?\d*(?=\s|$)/);
+        if (block) {
+            this.emit('punctuation', i, i + block[0].length);
+            this.blockIndent = indent;
+            this.scanTrailing(i + block[0].length, end);
+            return;
+        }
+        if (src[i] === '"' || src[i] === "'") {
+            this.emit('punctuation', i, i + 1);
+            this.scanTrailing(this.scanQuoted(i + 1, end, src[i], weighted), end);
+            return;
+        }
+
+        // Plain value up to a " #" comment
+        let valueEnd = end;
+        const comment = src.slice(i, end).search(/[ \t]#/);
+        if (comment !== -1) valueEnd = i + comment;
+        let contentEnd = valueEnd;
+        while (contentEnd > i && (src[contentEnd - 1] === ' ' || src[contentEnd - 1] === '\t')) contentEnd--;
+        this.scanEntry(i, contentEnd, weighted);
+        this.scanTrailing(contentEnd, end);
+    }
+
+    // Quoted text from i (after the opening quote); returns the position after the closing quote
+    private scanQuoted(i: number, end: number, quote: string, weighted: boolean): number {
+        const close = this.closingQuote(i, end, quote);
+        if (close === -1) {
+            this.scanEntry(i, end, false);
+            this.openQuote = { char: quote, weighted };
+            return end;
+        }
+        this.openQuote = undefined;
+        this.scanEntry(i, close, weighted);
+        this.emit('punctuation', close, close + 1);
+        return close + 1;
+    }
+
+    private closingQuote(i: number, end: number, quote: string): number {
+        const src = this.source;
+        for (; i < end; i++) {
+            if (quote === '"' && src[i] === '\\') {
+                i++;
+            } else if (src[i] === quote) {
+                if (quote === "'" && src[i + 1] === "'") {
+                    i++; // '' is an escaped single quote
+                } else {
+                    return i;
+                }
+            }
+        }
+        return -1;
+    }
+
+    // Whitespace and a comment after a value; anything else there is an error
+    private scanTrailing(i: number, end: number): void {
+        i = this.skipSpace(i, end);
+        if (i < end) {
+            this.emit(this.source[i] === '#' ? 'comment' : 'error', i, end);
+        }
+    }
+
+    // An entry with its optional trailing (tags) and ^N weight (list items only)
+    private scanEntry(start: number, end: number, weighted: boolean): void {
+        const weight = weighted ? this.source.slice(start, end).match(/\s*\^(-?\d*\.?\d+)$/) : null;
+        if (weight && weight.index! > 0) {
+            const at = start + weight.index!;
+            this.scanEntryTags(start, at);
+            const caret = end - weight[0].trimStart().length;
+            this.emit('whitespace', at, caret);
+            this.emit(parseFloat(weight[1]) > 0 ? 'weight' : 'error', caret, end);
+            return;
+        }
+        if (weighted) {
+            this.scanEntryTags(start, end);
+        } else {
+            this.scanTemplate(start, end);
+        }
+    }
+
+    private scanEntryTags(start: number, end: number): void {
+        const tags = this.source.slice(start, end).match(/\s+\(\s*([A-Za-z][\w-]*(?:\s*,\s*[A-Za-z][\w-]*)*)\s*\)$/);
+        if (!tags || tags.index === 0 || !tags[1].split(',').every(tag => this.tags.has(tag.trim().toLowerCase()))) {
+            this.scanTemplate(start, end);
+            return;
+        }
+        this.scanTemplate(start, start + tags.index!);
+        this.emitTags(start + tags.index!, end);
+    }
+
+    // Tag names as keywords between "(", ",", ")", "#" and "!" punctuation
+    private emitTags(start: number, end: number): void {
+        const part = /\s+|[\w-]+|./g;
+        const text = this.source.slice(start, end);
+        let match: RegExpExecArray | null;
+        while ((match = part.exec(text)) !== null) {
+            const kind = /^\s/.test(match[0]) ? 'whitespace' : /^[\w-]/.test(match[0]) ? 'keyword' : 'punctuation';
+            this.emit(kind, start + match.index, start + match.index + match[0].length);
+        }
+    }
+
+    // Template text with [references] and {inline} sigils; in expressions bare names are variables
+    private scanTemplate(start: number, end: number, textKind: TokenKind = 'entry-text', expression = false): void {
+        const src = this.source;
+        let text = start;
+        const flush = (to: number) => (expression ? this.emitExpressionText(text, to) : this.emit(textKind, text, to));
+
+        for (let i = start; i < end; i++) {
+            const char = src[i];
+            if (char === '\\') {
+                i++; // escaped sigil characters are text
+            } else if (char === '[' || char === '{') {
+                const close = this.closing(i, end, char, char === '[' ? ']' : '}');
+                flush(i);
+                if (close === -1) {
+                    this.emit('error', i, i + 1);
+                } else {
+                    this.emit('punctuation', i, i + 1);
+                    if (char === '[') this.scanReference(i + 1, close);
+                    else this.scanInline(i + 1, close);
+                    this.emit('punctuation', close, close + 1);
+                    i = close;
+                }
+                text = i + 1;
+            } else if (char === ']' || char === '}') {
+                flush(i);
+                this.emit('error', i, i + 1);
+                text = i + 1;
+            }
+        }
+        flush(end);
+    }
+
+    private emitExpressionText(start: number, end: number): void {
+        const name = /[A-Za-z_]\w*/g;
+        const text = this.source.slice(start, end);
+        let match: RegExpExecArray | null;
+        while ((match = name.exec(text)) !== null) {
+            this.emit('entry-text', this.offset, start + match.index);
+            this.emit('variable', start + match.index, start + match.index + match[0].length);
+        }
+        this.emit('entry-text', this.offset, end);
+    }
+
+    // Matching close bracket before end, skipping escapes, or -1
+    private closing(open: number, end: number, openChar: string, closeChar: string): number {
+        let depth = 0;
+        for (let i = open; i < end; i++) {
+            const char = this.source[i];
+            if (char === '\\') {
+                i++;
+            } else if (char === openChar) {
+                depth++;
+            } else if (char === closeChar) {
+                depth--;
+                if (depth === 0) return i;
+            }
+        }
+        return -1;
+    }
+
+    // Inside [...]: conditionals, captures, fallbacks, expressions, dice or a table path
+    private scanReference(start: number, end: number): void {
+        const src = this.source;
+        const a = this.skipSpace(start, end);
+        let b = end;
+        while (b > a && (src[b - 1] === ' ' || src[b - 1] === '\t')) b--;
+        const content = src.slice(a, b);
+
+        if (/^if\s/.test(content)) {
+            this.scanConditional(a, b);
+        } else if (/^(.+)->\s*([\w-]+)$/.test(content)) {
+            const arrow = a + content.lastIndexOf('->');
+            this.scanReference(a, arrow);
+            this.emit('punctuation', arrow, arrow + 2);
+            this.emit('variable', this.skipSpace(arrow + 2, b), b);
+        } else if (findKeyword(content, '??', 0, true) !== -1) {
+            const at = a + findKeyword(content, '??', 0, true);
+            this.scanReference(a, at);
+            this.emit('keyword', at, at + 2);
+            const fallback = this.skipSpace(at + 2, b);
+            // Quoted text; inside a double-quoted YAML string the quotes are escaped (\")
+            const quoted = src.slice(fallback, b).match(/^(\\?["'])[^"']*\1$/);
+            if (quoted) {
+                const quote = quoted[1].length;
+                this.emit('punctuation', fallback, fallback + quote);
+                this.scanTemplate(fallback + quote, b - quote);
+                this.emit('punctuation', b - quote, b);
+            } else {
+                this.scanReference(fallback, b);
+            }
+        } else if (/^fn:[A-Za-z_][\w-]*\(/.test(content)) {
+            this.scanFunction(a, b);
+        } else if (content.startsWith('=')) {
+            this.emit('keyword', a, a + 1);
+            const modifiers = content.match(/(\.[A-Za-z]\w*)*$/)![0];
+            this.scanTemplate(a + 1, b - modifiers.length, 'entry-text', true);
+            this.emitModifiers(b - modifiers.length, b);
+        } else if (isDiceExpression(content)) {
+            this.emit('dice', a, b);
+        } else if (/\s+#!?[\w-]+(?:\s+#!?[\w-]+)*$/.test(content)) {
+            const tags = a + content.search(/\s+#!?[\w-]+(?:\s+#!?[\w-]+)*$/);
+            this.scanTablePath(a, tags);
+            this.emitTags(tags, b);
+        } else {
+            this.scanTablePath(a, b);
+        }
+        this.emit('whitespace', b, end);
+    }
+
+    // path.modifiers^weight!exclusion*count?, sep="...", last="...", unique
+    private scanTablePath(start: number, end: number): void {
+        const optionsAt = this.source.slice(start, end).search(/,\s*(?:(?:sep|last)\s*=\s*\\?["']|unique\s*(?:,|$))/);
+        if (optionsAt > 0) {
+            this.scanTablePath(start, start + optionsAt);
+            this.scanReferenceOptions(start + optionsAt, end);
+            return;
+        }
+        const content = this.source.slice(start, end);
+        const match = content.match(/^([^!*?^]+?)(\^\d+)?((?:![^!*?]*)*)(\*(?:\{[\d-]+\}|\d*d(?:\d+|%)[\w+-]*|\d+))?(\?)?$/);
+        if (!match) {
+            this.scanTemplate(start, end, 'reference');
+            return;
+        }
+        const [, path, weight = '', exclusions = '', count = '', optional = ''] = match;
+        let i = start;
+
+        if (/[\[{]/.test(path)) {
+            this.scanTemplate(i, i + path.length, 'reference'); // dynamic path like [[race].names]
+        } else {
+            const parts = path.split('.');
+            let modifierCount = 0;
+            while (parts.length - modifierCount > 1 && this.modifiers.has(parts[parts.length - 1 - modifierCount])) modifierCount++;
+            const base = parts.slice(0, parts.length - modifierCount).join('.');
+            this.emit(this.variables.has(base) ? 'variable' : 'reference', i, i + base.length);
+            this.emitModifiers(i + base.length, i + path.length);
+        }
+        i += path.length;
+
+        this.emit('weight', i, i + weight.length);
+        i += weight.length;
+        for (const exclusion of exclusions.split('!').slice(1)) {
+            this.emit('punctuation', i, i + 1);
+            this.emit('entry-text', i + 1, i + 1 + exclusion.length);
+            i += 1 + exclusion.length;
+        }
+        if (count) {
+            this.emit('punctuation', i, i + 1);
+            this.emit('dice', i + 1, i + count.length);
+            i += count.length;
+        }
+        this.emit('punctuation', i, i + optional.length);
+    }
+
+    // fn:name(argument, "text").modifiers; the closing parenthesis may still be missing while typing
+    private scanFunction(start: number, end: number): void {
+        const src = this.source;
+        const opening = src.slice(start, end).match(/^fn:([A-Za-z_][\w-]*)\(/)!;
+        this.emit('keyword', start, start + 2);
+        this.emit('punctuation', start + 2, start + 3);
+        this.emit('modifier', start + 3, start + 3 + opening[1].length);
+        this.emit('punctuation', start + opening[0].length - 1, start + opening[0].length);
+
+        const tail = src.slice(start, end).match(/\)((?:\.[A-Za-z]\w*)*)$/);
+        const close = tail ? end - tail[0].length : end;
+        let depth = 0;
+        let quote: string | null = null;
+        let arg = start + opening[0].length;
+        for (let i = arg; i <= close; i++) {
+            const char = src[i];
+            if (i < close && quote) {
+                if (char === quote) quote = null;
+            } else if (i < close && (char === '"' || char === "'")) {
+                quote = char;
+            } else if (i < close && '[{('.includes(char)) {
+                depth++;
+            } else if (i < close && ']})'.includes(char)) {
+                depth--;
+            } else if (i === close || (char === ',' && depth === 0)) {
+                this.scanFunctionArgument(arg, i);
+                if (i < close) this.emit('punctuation', i, i + 1);
+                arg = i + 1;
+            }
+        }
+        if (tail) {
+            this.emit('punctuation', close, close + 1);
+            this.emitModifiers(close + 1, end);
+        }
+    }
+
+    private scanFunctionArgument(start: number, end: number): void {
+        const from = this.skipSpace(start, end);
+        let to = end;
+        while (to > from && /\s/.test(this.source[to - 1])) to--;
+        // Quoted text; inside a double-quoted YAML string the quotes are escaped (\")
+        const quoted = this.source.slice(from, to).match(/^(\\?["'])[^"']*\1$/);
+        if (quoted) {
+            const quote = quoted[1].length;
+            this.emit('punctuation', from, from + quote);
+            this.emit('entry-text', from + quote, to - quote);
+            this.emit('punctuation', to - quote, to);
+        } else {
+            this.scanTemplate(from, to);
+        }
+        this.emit('whitespace', to, end);
+    }
+
+    // Quotes may be escaped (\") inside a double-quoted YAML string
+    private scanReferenceOptions(start: number, end: number): void {
+        const part = /\s+|[,=]|(\\?["'])[^"'\\]*\1|sep|last|unique|./g;
+        const text = this.source.slice(start, end);
+        let match: RegExpExecArray | null;
+        while ((match = part.exec(text)) !== null) {
+            const token = match[0];
+            const kind: TokenKind = /^\s/.test(token) ? 'whitespace'
+                : token === ',' || token === '=' ? 'punctuation'
+                : match[1] ? 'entry-text'
+                : /^(sep|last|unique)$/.test(token) ? 'keyword'
+                : 'error';
+            this.emit(kind, start + match.index, start + match.index + token.length);
+        }
+    }
+
+    // ".capitalize.plural" -> punctuation and modifier tokens
+    private emitModifiers(start: number, end: number): void {
+        const text = this.source.slice(start, end);
+        let i = start;
+        for (const name of text.split('.').slice(1)) {
+            this.emit('punctuation', i, i + 1);
+            this.emit('modifier', i + 1, i + 1 + name.length);
+            i += 1 + name.length;
+        }
+    }
+
+    // if <condition> then <template> else <template>
+    private scanConditional(start: number, end: number): void {
+        const content = this.source.slice(start, end);
+        this.emit('keyword', start, start + 2);
+        const thenAt = findKeyword(content, 'then', 0, true);
+        if (thenAt === -1) {
+            this.scanCondition(start + 2, end); // still being typed
+            return;
+        }
+        this.scanCondition(start + 2, start + thenAt);
+        this.emit('keyword', start + thenAt, start + thenAt + 4);
+
+        const elseAt = findKeyword(content, 'else', thenAt + 4, false);
+        this.scanTemplate(start + thenAt + 4, elseAt === -1 ? end : start + elseAt);
+        if (elseAt !== -1) {
+            this.emit('keyword', start + elseAt, start + elseAt + 4);
+            this.scanTemplate(start + elseAt + 4, end);
+        }
+    }
+
+    private scanCondition(start: number, end: number): void {
+        const part = /\s+|"[^"]*"?|'[^']*'?|==|!=|<=|>=|<|>|-?\d+(?:\.\d+)?(?![\w])|[^\s=!<>"']+|./g;
+        const text = this.source.slice(start, end);
+        let match: RegExpExecArray | null;
+        while ((match = part.exec(text)) !== null) {
+            const token = match[0];
+            const kind: TokenKind = /^\s/.test(token) ? 'whitespace'
+                : /^["'\d-]/.test(token) ? 'entry-text'
+                : /^[=!<>]=?$/.test(token) ? (token === '=' || token === '!' ? 'error' : 'keyword')
+                : 'variable';
+            this.emit(kind, start + match.index, start + match.index + token.length);
+        }
+    }
+
+    // Inside {...}: {a}, {1-6} or options split by | and &
+    private scanInline(start: number, end: number): void {
+        const src = this.source;
+        const content = src.slice(start, end);
+        const simple = content.match(/^(\s*)(a|\d+\s*-\s*\d+)(\s*)$/);
+        if (simple) {
+            const at = start + simple[1].length;
+            this.emit('whitespace', start, at);
+            this.emit(simple[2] === 'a' ? 'keyword' : 'dice', at, at + simple[2].length);
+            this.emit('whitespace', at + simple[2].length, end);
+            return;
+        }
+
+        let depth = 0;
+        let part = start;
+        for (let i = start; i < end; i++) {
+            const char = src[i];
+            if (char === '\\') {
+                i++;
+            } else if (char === '[' || char === '{') {
+                depth++;
+            } else if (char === ']' || char === '}') {
+                depth--;
+            } else if (depth === 0 && (char === '|' || char === '&')) {
+                this.scanTemplate(part, i);
+                this.emit('punctuation', i, i + 1);
+                part = i + 1;
+            }
+        }
+        this.scanTemplate(part, end);
+    }
+}
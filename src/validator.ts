@@ -0,0 +1,346 @@
+/**
+ * SIGIL Validator
+ * Static checks over YAML source so editors can point at problems before generation:
+ * - YAML syntax errors
+ * - duplicate table names
+ * - empty tables
+ * - invalid weights (^0, negative)
+ * - entries ending in a tag group their table does not declare in "@tags"
+ * - roll tables with gaps, overlaps or results their die cannot produce
+ * - unterminated [ and { sigils, and ] or } with nothing to close
+ * - references to tables that are not defined
+ * - "@extend" entries for tables that are not defined
+ * - [@name] macros that are not defined, not a single template, or never used
+ * - tables that no template can reach, in files that define templates
+ *
+ * Every issue carries a 1-based line and column in the original source.
+ */
+
+import * as YAML from 'yaml';
+import { SigilData, mergeLists, isMacroName, listTables } from './yaml-loader';
+import { getNestedValue } from './template-engine';
+import { parseWeight } from './weights';
+import { findDependencies } from './dependencies';
+import type { DependencyResolver } from './dependencies';
+import { parseCompleteTemplate, walkTemplateNodes, findUnbalancedSigils } from './template-parser';
+import { isRollTable, parseRollTable } from './roll-table';
+import { getTableEntries, getTableSettings } from './table-settings';
+import { trailingTagNames } from './tags';
+
+export type ValidationSeverity = 'error' | 'warning';
+
+export interface ValidationIssue {
+    severity: ValidationSeverity;
+    message: string;
+    line: number;
+    column: number;
+    table?: string;  // dotted path of the table the issue was found in
+}
+
+export interface ValidationOptions {
+    /** Tables loaded from other files; references into them are valid */
+    data?: SigilData;
+    /**
+     * The source is only part of the final table set. Unresolved references are
+     * reported as warnings instead of errors, since another file may define them.
+     */
+    incomplete?: boolean;
+}
+
+interface ScalarEntry {
+    node: YAML.Scalar;
+    table: string;
+}
+
+/**
+ * Validate SIGIL YAML source and return every issue found (empty array when clean).
+ * Never throws: YAML syntax errors are returned as issues too.
+ */
+export function validateSigil(source: string, options: ValidationOptions = {}): ValidationIssue[] {
+    const issues: ValidationIssue[] = [];
+    const lineCounter = new YAML.LineCounter();
+    const doc = YAML.parseDocument(source, { lineCounter, uniqueKeys: false });
+
+    for (const error of doc.errors) {
+        const pos = error.linePos ? error.linePos[0] : { line: 1, col: 1 };
+        issues.push({ severity: 'error', message: error.message, line: pos.line, column: pos.col });
+    }
+    if (doc.errors.length > 0) {
+        return issues;
+    }
+
+    const at = (offset: number | undefined) => {
+        const pos = lineCounter.linePos(offset || 0);
+        return { line: pos.line, column: pos.col };
+    };
+
+    // Structure: duplicate keys, empty tables, weights; collect every string entry for reference checks
+    const entries: ScalarEntry[] = [];
+    const listEntries: ScalarEntry[] = [];
+    const keyOffsets = new Map<string, number | undefined>();
+    const walk = (node: unknown, table: string) => {
+        if (YAML.isMap(node)) {
+            const seen = new Set<string>();
+            for (const pair of node.items) {
+                const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+                const path = table ? `${table}.${key}` : key;
+                const keyRange = YAML.isScalar(pair.key) ? pair.key.range : undefined;
+                if (seen.has(key)) {
+                    issues.push({ severity: 'error', message: `Duplicate table name "${path}"`, ...at(keyRange?.[0]), table: path });
+                }
+                seen.add(key);
+                keyOffsets.set(path, keyRange?.[0]);
+
+                const value = pair.value;
+                if (!table && key === '@include') {
+                    continue;
+                }
+                if (!table && key === '@extend') {
+                    walk(value, ''); // entries belong to the tables they extend
+                    continue;
+                }
+                if (!table && isMacroName(key) && !(YAML.isScalar(value) && typeof value.value === 'string')) {
+                    issues.push({ severity: 'error', message: `Macro "${key}" must be a single template string`, ...at(keyRange?.[0]), table: path });
+                    continue;
+                }
+                if (value === null || value === undefined || (YAML.isScalar(value) && value.value === null)) {
+                    issues.push({ severity: 'warning', message: `Table "${path}" has no entries`, ...at(keyRange?.[0]), table: path });
+                } else if (YAML.isScalar(value) && typeof value.value === 'string') {
+                    entries.push({ node: value, table: path });
+                } else {
+                    walk(value, path);
+                }
+            }
+        } else if (YAML.isSeq(node)) {
+            if (node.items.length === 0) {
+                issues.push({ severity: 'warning', message: `Table "${table}" is empty`, ...at(node.range?.[0]), table });
+            }
+            for (const item of node.items) {
+                if (YAML.isScalar(item) && typeof item.value === 'string') {
+                    const { value, weight } = parseWeight(item.value);
+                    if (!(weight > 0)) {
+                        issues.push({
+                            severity: 'error',
+                            message: `Invalid weight ^${weight} on "${value}": weights must be greater than zero`,
+                            ...at(item.range?.[0]),
+                            table
+                        });
+                    }
+                    entries.push({ node: item, table });
+                    listEntries.push({ node: item, table });
+                } else {
+                    walk(item, table);
+                }
+            }
+        }
+    };
+    walk(doc.contents, '');
+
+    // References resolve against this file plus anything else already loaded
+    const { '@include': includes, '@extend': extensions, ...local } = (doc.toJS() as SigilData) || {};
+    const known = mergeLists(options.data ? [options.data, local] : [local]);
+    // Included files define tables this source cannot see on its own
+    const incomplete = options.incomplete || (includes !== undefined && !options.data);
+
+    // Extended tables must exist somewhere in the set
+    const checkExtends = (node: unknown, value: any, table: string) => {
+        if (!YAML.isMap(node)) return;
+        for (const pair of node.items) {
+            const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+            const path = table ? `${table}.${key}` : key;
+            if (YAML.isMap(pair.value) && !isRollTable(value?.[key]) && !getTableEntries(value?.[key])) {
+                checkExtends(pair.value, value?.[key], path);
+                continue;
+            }
+            const target = getNestedValue(known, path);
+            const position = at(YAML.isScalar(pair.key) ? pair.key.range?.[0] : undefined);
+            if (target === undefined) {
+                issues.push({
+                    severity: incomplete ? 'warning' : 'error',
+                    message: `Cannot extend undefined table "${path}"`,
+                    ...position,
+                    table: path
+                });
+            } else if (isRollTable(target)) {
+                issues.push({ severity: 'error', message: `Cannot extend roll table "${path}"`, ...position, table: path });
+            } else if (typeof target === 'object' && target !== null && !Array.isArray(target) && !getTableEntries(target)) {
+                issues.push({ severity: 'error', message: `Cannot extend "${path}": it is a group of tables`, ...position, table: path });
+            }
+        }
+    };
+    if (extensions !== undefined && YAML.isMap(doc.contents)) {
+        const pair = doc.contents.items.find(item => YAML.isScalar(item.key) && item.key.value === '@extend');
+        checkExtends(pair?.value, extensions, '');
+    }
+
+    const checkRollTables = (node: unknown, value: any, table: string) => {
+        if (!YAML.isMap(node)) return;
+        if (isRollTable(value)) {
+            try {
+                parseRollTable(value);
+            } catch (error) {
+                issues.push({
+                    severity: 'error',
+                    message: `Invalid roll table "${table}": ${error instanceof Error ? error.message : error}`,
+                    ...at(node.range?.[0]),
+                    table
+                });
+            }
+            return;
+        }
+        for (const pair of node.items) {
+            const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+            checkRollTables(pair.value, value?.[key], table ? `${table}.${key}` : key);
+        }
+    };
+    checkRollTables(doc.contents, local, '');
+
+    // Only declared names are read as tags, so a group with other names stays in the text.
+    // Tables that declare none, or some of the group's names, probably meant tags.
+    for (const { node, table } of listEntries) {
+        const owner = table.replace(/\.@entries$/, '');
+        const { value } = parseWeight(String(node.value));
+        const names = trailingTagNames(value);
+        const declared = getTableSettings(getNestedValue(known, owner)).tags ?? [];
+        const undeclared = names?.filter(name => !declared.includes(name)) ?? [];
+        if (undeclared.length === 0 || (declared.length > 0 && undeclared.length === names!.length)) continue;
+        issues.push({
+            severity: 'warning',
+            message: declared.length === 0
+                ? `"${value}" ends in what looks like tags, but table "${owner}" has no "@tags"; the group stays in the text`
+                : `"${value}" has tags table "${owner}" does not declare (${undeclared.join(', ')}); the group stays in the text`,
+            ...at(node.range?.[0]),
+            table: owner
+        });
+    }
+
+    const usedMacros = new Set<string>();
+
+    for (const { node, table } of entries) {
+        const text = String(node.value);
+        const start = node.range ? node.range[0] : 0;
+        const raw = node.range ? source.slice(node.range[0], node.range[1]) : text;
+        const offsetOf = (needle: string) => {
+            const index = raw.indexOf(needle);
+            return start + (index >= 0 ? index : 0);
+        };
+
+        // The rest of the entry from a bracket on pins down which bracket it is
+        const bracketOffset = (index: number) => {
+            const found = raw.lastIndexOf(text.slice(index));
+            return found >= 0 ? start + found : offsetOf(text.slice(index, index + 20));
+        };
+
+        for (const bracket of findUnbalancedSigils(text)) {
+            const problem = bracket.char === '[' || bracket.char === '{' ? 'Unterminated' : 'Unmatched';
+            issues.push({
+                severity: 'error',
+                message: `${problem} "${bracket.char}" sigil in "${text}"`,
+                ...at(bracketOffset(bracket.index)),
+                table
+            });
+        }
+
+        // [table ?? "text"] may point at a table that is not loaded on purpose
+        const covered = new Set<unknown>();
+        walkTemplateNodes(parseCompleteTemplate(text), n => {
+            if (n.type === 'fallback') {
+                covered.add(n.node);
+            }
+            if (n.type !== 'table' || covered.has(n) || n.tablePath.includes('[') || n.tablePath.includes('{')) {
+                return; // dynamic paths can only be checked at generation time
+            }
+            const target = getNestedValue(known, n.tablePath);
+            const macro = n.tablePath.startsWith('@');
+            if (macro) {
+                usedMacros.add(n.tablePath);
+            }
+            if (Array.isArray(target) || isRollTable(target) || getTableEntries(target) || (macro && typeof target === 'string')) {
+                return;
+            }
+            const position = at(offsetOf(`[${n.tablePath}`));
+            if (target === undefined && macro) {
+                issues.push({
+                    severity: incomplete ? 'warning' : 'error',
+                    message: `Reference to undefined macro "${n.tablePath}"`,
+                    ...position,
+                    table
+                });
+            } else if (target === undefined) {
+                issues.push({
+                    severity: incomplete ? 'warning' : 'error',
+                    message: `Reference to undefined table "${n.tablePath}"`,
+                    ...position,
+                    table
+                });
+            } else {
+                issues.push({
+                    severity: 'warning',
+                    message: `Reference "${n.tablePath}" points at a group, not a list`,
+                    ...position,
+                    table
+                });
+            }
+        });
+    }
+
+    // Macros are there to be reused; one nothing in this file refers to is probably left over
+    if (YAML.isMap(doc.contents)) {
+        for (const pair of doc.contents.items) {
+            const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+            if (isMacroName(key) && typeof local[key] === 'string' && !usedMacros.has(key)) {
+                const keyRange = YAML.isScalar(pair.key) ? pair.key.range : undefined;
+                issues.push({ severity: 'warning', message: `Macro "${key}" is never used`, ...at(keyRange?.[0]), table: key });
+            }
+        }
+    }
+
+    // In a file with templates, a table no template reaches is probably left over; files
+    // without templates are libraries whose tables are used from code or other files
+    const templates = { ...options.data?.templates, ...local.templates };
+    if (local.templates && typeof local.templates === 'object' && Object.keys(local.templates).length > 0 && !incomplete) {
+        const reachable = reachableTables({ ...known, templates }, Object.keys(templates).map(name => `templates.${name}`));
+        for (const table of listTables(local)) {
+            if (!reachable(table)) {
+                issues.push({ severity: 'warning', message: `Table "${table}" is not used by any template`, ...at(keyOffsets.get(table)), table });
+            }
+        }
+    }
+
+    return issues.sort((a, b) => a.line - b.line || a.column - b.column);
+}
+
+/**
+ * Whether a table can be reached from the given tables. A dynamic reference such
+ * as [loot.[1d3]] could reach any table under "loot.", so those are followed too.
+ */
+function reachableTables(data: SigilData, roots: string[]): (table: string) => boolean {
+    const resolve: DependencyResolver = table => {
+        const value = getNestedValue(data, table);
+        if (typeof value === 'string') return { table, entries: [value] };
+        if (isRollTable(value)) {
+            try {
+                return { table, entries: parseRollTable(value).entries.map(entry => parseWeight(entry.value).value) };
+            } catch (error) {
+                return { table, entries: [] };
+            }
+        }
+        const list = getTableEntries(value) ?? value;
+        return Array.isArray(list) ? { table, entries: list.map(item => parseWeight(String(item ?? '')).value) } : undefined;
+    };
+
+    const all = listTables(data);
+    let found = new Set<string>();
+    let prefixes: string[] = [];
+    // Each round adds the tables under the dynamic paths found so far, until nothing new turns up
+    for (let starts = roots; ; ) {
+        const graph = findDependencies(starts.map(table => `[${table}]`).join(' '), resolve);
+        prefixes = graph.dynamic.map(path => path.slice(0, path.search(/[\[{]/)));
+        if (prefixes.includes('')) return () => true;
+        found = new Set(graph.tables);
+        const next = [...roots, ...all.filter(table => prefixes.some(prefix => table.startsWith(prefix)))];
+        if (next.length === starts.length) break;
+        starts = next;
+    }
+    return table => found.has(table);
+}
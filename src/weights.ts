@@ -0,0 +1,50 @@
+/**
+ * SIGIL Entry Weights
+ * An entry can end with a weight sigil that makes it more (or less) likely to
+ * be picked than the entries around it:
+ *
+ *   weapons:
+ *     - sword ^3          # three times as likely as a bow
+ *     - bow
+ *     - rusty dagger ^0.5
+ *
+ * Entries without a weight count as 1.
+ */
+
+import { splitTags } from './tags';
+
+export interface WeightedItem {
+    value: string;
+    weight: number;
+    tags?: string[];  // from a trailing "(urban, night)" group
+}
+
+// "sword ^2": the entry text, then the weight
+export const WEIGHT_PATTERN = /^(.+?)\s*\^(-?\d*\.?\d+)$/;
+
+/**
+ * Split a trailing weight sigil and tag group off a single entry.
+ * "sword ^2" -> { value: "sword", weight: 2 }, "sword" -> { value: "sword", weight: 1 }
+ * "thief (urban, night) ^2" -> { value: "thief", weight: 2, tags: ["urban", "night"] }
+ * when the table declares those tags (see splitTags)
+ *
+ * Zero and negative weights are returned as-is so callers can decide whether
+ * to reject them (the YAML loaders do) or simply never select them.
+ */
+export function parseWeight(item: string, declared: string[] = []): WeightedItem {
+    const match = item.match(WEIGHT_PATTERN);
+    const { value, tags } = splitTags(match ? match[1].trim() : item, declared);
+    return {
+        value,
+        weight: match ? parseFloat(match[2]) : 1.0,
+        ...(tags.length > 0 ? { tags } : {})
+    };
+}
+
+/**
+ * Parse weighted items from a list
+ * "sword ^2" -> { value: "sword", weight: 2 }
+ */
+export function parseWeightedList(items: string[], declared: string[] = []): WeightedItem[] {
+    return items.map(item => parseWeight(item, declared));
+}
@@ -1,4 +1,10 @@
 import * as YAML from 'yaml';
+import { parseWeight } from './weights';
+import { isRollTable, parseRollTable } from './roll-table';
+import { getTableEntries, ENTRIES_KEY } from './table-settings';
+import { ParseError, SigilError, withFile } from './errors';
+import { decodeSigilFile } from './encoding';
+import type { DecodedFile } from './encoding';
 
 // Conditional imports for Node.js environment only
 let fs: any = null;
@@ -40,6 +46,132 @@ export interface LoadedData {
     templates: SigilTemplate;
 }
 
+/**
+ * Parse YAML text and enforce SIGIL rules that plain YAML parsing cannot catch.
+ * Problems are thrown as ParseError with the 1-based line and column of the offending node.
+ */
+function parseSigilYaml(content: string): SigilData {
+    const lineCounter = new YAML.LineCounter();
+    const doc = YAML.parseDocument(content, { lineCounter });
+    if (doc.errors.length > 0) {
+        const error = doc.errors[0];
+        const position = error.linePos?.[0];
+        // yaml appends " at line X, column Y:" and a source excerpt; the position is kept separately
+        const description = error.message.split('\n')[0].replace(/ at line \d+, column \d+:?$/, '');
+        throw new ParseError('yaml_syntax', description, position ? { line: position.line, column: position.col } : {});
+    }
+
+    validateWeights(doc.contents, lineCounter);
+    validateMacros(doc.contents, lineCounter);
+    const data = normalizeEntries((doc.toJS() as SigilData) || {});
+    validateRollTables(doc.contents, data, '', lineCounter);
+    return data;
+}
+
+/**
+ * Tidy multi-line entries: block scalars (> and |) end with a line break that
+ * would leak into generated text, and a trailing "\" joins a line to the next.
+ * A "~" item, which YAML reads as null, is kept as the "~" nothing entry.
+ */
+function normalizeEntries(value: any): any {
+    if (typeof value === 'string') {
+        if (!value.includes('\n')) return value;
+        return value.replace(/\\\r?\n[ \t]*/g, '').replace(/(\r?\n)+$/, '');
+    }
+    if (Array.isArray(value)) {
+        return value.map(item => item === null ? '~' : normalizeEntries(item));
+    }
+    if (typeof value === 'object' && value !== null) {
+        for (const key of Object.keys(value)) {
+            value[key] = normalizeEntries(value[key]);
+        }
+    }
+    return value;
+}
+
+/**
+ * Reject range-keyed roll tables with gaps or overlaps (e.g. "1-3" and "3-6")
+ */
+function validateRollTables(node: unknown, value: any, table: string, lineCounter: YAML.LineCounter): void {
+    if (!YAML.isMap(node)) return;
+    if (isRollTable(value)) {
+        try {
+            parseRollTable(value);
+        } catch (error) {
+            throw new ParseError('invalid_roll_table',
+                `Invalid roll table "${table}": ${error instanceof Error ? error.message : error}`,
+                { ...position(node, lineCounter), table });
+        }
+        return;
+    }
+    for (const pair of node.items) {
+        const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+        validateRollTables(pair.value, value?.[key], table ? `${table}.${key}` : key, lineCounter);
+    }
+}
+
+/**
+ * Top-level "@name" keys other than the directives define [@name] macros, which are one template each
+ */
+function validateMacros(node: unknown, lineCounter: YAML.LineCounter): void {
+    if (!YAML.isMap(node)) return;
+    for (const pair of node.items) {
+        const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+        if (!isMacroName(key)) continue;
+        if (!YAML.isScalar(pair.value) || typeof pair.value.value !== 'string') {
+            throw new ParseError('invalid_macro', `Macro "${key}" must be a single template string`,
+                position(pair.key as YAML.Scalar, lineCounter));
+        }
+    }
+}
+
+/**
+ * Whether a top-level key names a [@name] macro
+ */
+export function isMacroName(key: string): boolean {
+    return key.startsWith('@') && key !== INCLUDE_KEY && key !== EXTEND_KEY;
+}
+
+/**
+ * Reject list entries whose weight sigil is zero or negative (e.g. "goblin ^0")
+ */
+function validateWeights(node: unknown, lineCounter: YAML.LineCounter, table = ''): void {
+    if (YAML.isMap(node)) {
+        for (const pair of node.items) {
+            const key = YAML.isScalar(pair.key) ? String(pair.key.value) : String(pair.key);
+            validateWeights(pair.value, lineCounter, table ? `${table}.${key}` : key);
+        }
+    } else if (YAML.isSeq(node)) {
+        for (const item of node.items) {
+            if (YAML.isScalar(item) && typeof item.value === 'string') {
+                const { value, weight } = parseWeight(item.value);
+                if (!(weight > 0)) {
+                    throw new ParseError('invalid_weight',
+                        `Invalid weight ^${weight} on "${value}": weights must be greater than zero`,
+                        { ...position(item, lineCounter), table: table || undefined });
+                }
+            } else {
+                validateWeights(item, lineCounter, table);
+            }
+        }
+    }
+}
+
+function position(node: { range?: [number, number, number] | null }, lineCounter: YAML.LineCounter): { line?: number; column?: number } {
+    if (!node.range) return {};
+    const { line, col } = lineCounter.linePos(node.range[0]);
+    return { line, column: col };
+}
+
+/**
+ * Read a table file in whatever encoding it was saved in (UTF-8 with or without
+ * BOM, UTF-16, Windows-1252), with the encoding and line endings it used
+ */
+export function readSigilFile(filePath: string): DecodedFile {
+    ensureNodeModules();
+    return decodeSigilFile(fs.readFileSync(filePath));
+}
+
 /**
  * Load a single YAML file and parse its contents
  */
@@ -47,11 +179,10 @@ export function loadYamlFile(filePath: string): SigilData {
     ensureNodeModules();
 
     try {
-        const fileContent = fs.readFileSync(filePath, 'utf-8');
-        const parsed = YAML.parse(fileContent);
-        return parsed || {};
+        const fileContent = readSigilFile(filePath).text;
+        return parseSigilYaml(fileContent);
     } catch (error) {
-        throw new Error(`Failed to load YAML file "${filePath}": ${error instanceof Error ? error.message : error}`);
+        throw withFile(error, filePath);
     }
 }
 
@@ -111,14 +242,18 @@ export function extractTemplates(dataObjects: SigilData[]): SigilTemplate {
  * Load multiple YAML files and merge them according to SIGIL rules
  */
 export function loadSigilData(filePaths: string[]): LoadedData {
-    const dataObjects: SigilData[] = [];
+    ensureNodeModules();
 
-    for (const filePath of filePaths) {
-        const data = loadYamlFile(filePath);
-        dataObjects.push(data);
-    }
+    const parsed = parseIncluding(filePaths, {
+        read: file => readSigilFile(file).text,
+        has: file => fs.existsSync(file),
+        resolve: (from, include) => path.join(path.dirname(from), include),
+        id: file => path.resolve(file)
+    });
+    const dataObjects = parsed.map(file => file.data);
 
     const lists = mergeLists(dataObjects);
+    applyExtensions(lists, parsed.flatMap(file => file.extensions));
     const templates = extractTemplates(dataObjects);
 
     return { lists, templates };
@@ -136,9 +271,9 @@ export function loadSingleFile(filePath: string): LoadedData {
  */
 export function parseYamlContent(yamlContent: string): SigilData {
     try {
-        const parsed = YAML.parse(yamlContent);
-        return parsed || {};
+        return parseSigilYaml(yamlContent);
     } catch (error) {
+        if (error instanceof SigilError) throw error;
         throw new Error(`Failed to parse YAML content: ${error instanceof Error ? error.message : error}`);
     }
 }
@@ -149,12 +284,19 @@ export function parseYamlContent(yamlContent: string): SigilData {
 export function createSigilData(yamlContents: string[]): LoadedData {
     const dataObjects: SigilData[] = [];
 
+    const extensions: Extension[] = [];
+
     for (const content of yamlContents) {
-        const data = parseYamlContent(content);
-        dataObjects.push(data);
+        const directives = takeDirectives(parseYamlContent(content), '');
+        if (directives.includes.length > 0) {
+            throw new ParseError('invalid_include', '"@include" needs named files; use createSigilDataFromFiles');
+        }
+        dataObjects.push(directives.data);
+        extensions.push(...directives.extensions);
     }
 
     const lists = mergeLists(dataObjects);
+    applyExtensions(lists, extensions);
     const templates = extractTemplates(dataObjects);
 
     return { lists, templates };
@@ -166,3 +308,412 @@ export function createSigilData(yamlContents: string[]): LoadedData {
 export function createSingleSigilData(yamlContent: string): LoadedData {
     return createSigilData([yamlContent]);
 }
+
+export interface DirectoryLoadOptions {
+    /**
+     * Prefix tables with their subdirectory, so tables in "fantasy/names.yaml"
+     * become "fantasy.<table>". Files at the root stay un-prefixed. Default: false
+     */
+    namespace?: boolean;
+    /** File extensions to load (default: .yaml and .yml) */
+    extensions?: string[];
+}
+
+const DEFAULT_EXTENSIONS = ['.yaml', '.yml'];
+
+/**
+ * Merge a set of named YAML sources into one table set (browser-friendly).
+ * Keys are relative paths using "/" (e.g. from a bundler glob import).
+ *
+ * Lists with the same name are merged as usual. A name that is a list in one
+ * file but a group or plain value in another cannot be merged and throws an
+ * error naming both files.
+ *
+ * A file can pull in others with "@include" (paths relative to the file) and
+ * add entries to tables defined elsewhere with "@extend":
+ *
+ *   "@include": base/fantasy-names.yaml
+ *   "@extend":
+ *     dwarf_names:
+ *       - Thrain ^2
+ *
+ * Extensions are applied once every file is merged, so load order does not matter.
+ */
+export function createSigilDataFromFiles(files: { [relativePath: string]: string }, options: DirectoryLoadOptions = {}): LoadedData {
+    const { data, extensions } = parseFileSet(files, options);
+    applyExtensions(data.lists, extensions);
+    return data;
+}
+
+interface ParsedFileSet {
+    /** Merged tables, before extensions are applied */
+    data: LoadedData;
+    extensions: Extension[];
+    tableFiles: Map<string, string[]>;     // table path -> files defining it
+    templateFiles: Map<string, string[]>;  // template name -> files defining it
+}
+
+// Parse one set of files, also noting which files define each table and template
+function parseFileSet(files: { [relativePath: string]: string }, options: DirectoryLoadOptions): ParsedFileSet {
+    const dataObjects: SigilData[] = [];
+    const extensions: Extension[] = [];
+    const origins = new Map<string, { kind: string; file: string }>();
+    const tableFiles = new Map<string, string[]>();
+    const templateFiles = new Map<string, string[]>();
+    const note = (map: Map<string, string[]>, name: string, file: string) => map.set(name, [...(map.get(name) || []), file]);
+
+    const parsed = parseIncluding(Object.keys(files).sort(), {
+        read: file => files[file],
+        has: file => Object.prototype.hasOwnProperty.call(files, file),
+        resolve: (from, include) => joinRelative(from, include)
+    });
+
+    for (const { file: relativePath, data: tables, extensions: fileExtensions } of parsed) {
+        let data = tables;
+        extensions.push(...fileExtensions);
+
+        if (options.namespace) {
+            const dirs = relativePath.split('/').slice(0, -1).filter(Boolean);
+            data = namespaceData(data, dirs);
+        }
+
+        recordOrigins(data, '', relativePath, origins);
+        forEachTable(data, '', tablePath => note(tableFiles, tablePath, relativePath));
+        if (data.templates && typeof data.templates === 'object') {
+            Object.keys(data.templates).forEach(name => note(templateFiles, name, relativePath));
+        }
+        dataObjects.push(data);
+    }
+
+    return { data: { lists: mergeLists(dataObjects), templates: extractTemplates(dataObjects) }, extensions, tableFiles, templateFiles };
+}
+
+const INCLUDE_KEY = '@include';
+const EXTEND_KEY = '@extend';
+
+interface Extension {
+    file: string;
+    /** Dotted path of the extended table */
+    table: string;
+    entries: unknown[];
+}
+
+interface ParsedFile {
+    file: string;
+    /** The file's tables and templates without its directives */
+    data: SigilData;
+    extensions: Extension[];
+}
+
+interface FileSource {
+    read: (file: string) => string;
+    has: (file: string) => boolean;
+    resolve: (from: string, include: string) => string;
+    /** Identity for spotting repeats and cycles, when two names can mean one file */
+    id?: (file: string) => string;
+}
+
+// Parse files in order, each one after the files it includes; every file is parsed once
+function parseIncluding(files: string[], source: FileSource): ParsedFile[] {
+    const parsed: ParsedFile[] = [];
+    const done = new Set<string>();
+    const id = source.id || ((file: string) => file);
+
+    const visit = (file: string, chain: string[]) => {
+        const start = chain.findIndex(previous => id(previous) === id(file));
+        if (start !== -1) {
+            throw new ParseError('include_cycle', `Circular include: ${[...chain.slice(start), file].join(' -> ')}`,
+                { file: chain[chain.length - 1] });
+        }
+        if (done.has(id(file))) return;
+
+        let directives: ReturnType<typeof takeDirectives>;
+        try {
+            directives = takeDirectives(parseSigilYaml(source.read(file)), file);
+        } catch (error) {
+            throw withFile(error, file);
+        }
+
+        for (const include of directives.includes) {
+            const target = source.resolve(file, include);
+            if (!source.has(target)) {
+                throw new ParseError('invalid_include', `Included file "${include}" not found`, { file });
+            }
+            visit(target, [...chain, file]);
+        }
+        done.add(id(file));
+        parsed.push({ file, data: directives.data, extensions: directives.extensions });
+    };
+
+    files.forEach(file => visit(file, []));
+    return parsed;
+}
+
+// Split a file's "@include" and "@extend" directives from its tables
+function takeDirectives(data: SigilData, file: string): { data: SigilData; includes: string[]; extensions: Extension[] } {
+    const { [INCLUDE_KEY]: include, [EXTEND_KEY]: extend, ...tables } = data;
+
+    const includes = include === undefined ? [] : (Array.isArray(include) ? include : [include]);
+    if (includes.some(item => typeof item !== 'string')) {
+        throw new ParseError('invalid_include', '"@include" must be a file path or a list of file paths');
+    }
+
+    const extensions: Extension[] = [];
+    if (extend !== undefined) {
+        if (typeof extend !== 'object' || extend === null || Array.isArray(extend)) {
+            throw new ParseError('invalid_extend', '"@extend" must map table names to entries');
+        }
+        forEachTable(extend, '', (table, value) => {
+            const entries = Array.isArray(value) ? value : getTableEntries(value) ?? (isRollTable(value) ? undefined : [value]);
+            if (!entries) {
+                throw new ParseError('invalid_extend', `Cannot extend "${table}" with a roll table`, { table });
+            }
+            extensions.push({ file, table, entries });
+        });
+    }
+
+    return { data: tables, includes, extensions };
+}
+
+// Append extension entries to the tables they name; the extended tables are copied, not changed in place
+function applyExtensions(lists: SigilData, extensions: Extension[]): void {
+    for (const { file, table, entries } of extensions) {
+        const keys = table.split('.');
+        const group = keys.length > 1 ? getTable(lists, keys.slice(0, -1).join('.')) : lists;
+        const last = keys[keys.length - 1];
+        const target = typeof group === 'object' && group !== null ? group[last] : undefined;
+        const location = { file: file || undefined, table };
+
+        if (target === undefined) {
+            throw new ParseError('invalid_extend', `Cannot extend "${table}": no file defines it`, location);
+        }
+        if (!isTableValue(target)) {
+            throw new ParseError('invalid_extend', `Cannot extend "${table}": it is a group of tables`, location);
+        }
+        if (isRollTable(target)) {
+            throw new ParseError('invalid_extend', `Cannot extend roll table "${table}"`, location);
+        }
+
+        if (Array.isArray(target)) {
+            group[last] = [...target, ...entries];
+        } else if (getTableEntries(target)) {
+            group[last] = { ...target, [ENTRIES_KEY]: [...getTableEntries(target)!, ...entries] };
+        } else {
+            group[last] = [target, ...entries];
+        }
+    }
+}
+
+// Resolve an include path against the including file's directory, both "/"-separated
+function joinRelative(from: string, include: string): string {
+    const parts: string[] = [];
+    for (const part of [...from.split('/').slice(0, -1), ...include.split('/')]) {
+        if (part === '..') parts.pop();
+        else if (part !== '.' && part !== '') parts.push(part);
+    }
+    return (from.startsWith('/') ? '/' : '') + parts.join('/');
+}
+
+/**
+ * Load every YAML file below a directory (recursively) as one table set
+ */
+export function loadSigilDirectory(dirPath: string, options: DirectoryLoadOptions = {}): LoadedData {
+    return createSigilDataFromFiles(readSigilDirectory(dirPath, options), options);
+}
+
+/**
+ * Read every YAML file below a directory into a { relativePath: content } map,
+ * the same shape createSigilDataFromFiles and createLayeredSigilData take
+ */
+export function readSigilDirectory(dirPath: string, options: { extensions?: string[] } = {}): { [relativePath: string]: string } {
+    ensureNodeModules();
+
+    const extensions = options.extensions || DEFAULT_EXTENSIONS;
+    const files: { [relativePath: string]: string } = {};
+
+    const walk = (relativeDir: string) => {
+        const absoluteDir = path.join(dirPath, relativeDir);
+        let entries: any[];
+        try {
+            entries = fs.readdirSync(absoluteDir, { withFileTypes: true });
+        } catch (error) {
+            throw new Error(`Failed to read directory "${absoluteDir}": ${error instanceof Error ? error.message : error}`);
+        }
+
+        for (const entry of entries) {
+            if (entry.name.startsWith('.')) continue;
+            const relativePath = relativeDir ? `${relativeDir}/${entry.name}` : entry.name;
+            if (entry.isDirectory()) {
+                walk(relativePath);
+            } else if (extensions.includes(path.extname(entry.name).toLowerCase())) {
+                files[relativePath] = readSigilFile(path.join(dirPath, relativePath)).text;
+            }
+        }
+    };
+    walk('');
+
+    return files;
+}
+
+export interface SigilLayer {
+    /** Label reported by origin(), e.g. "defaults" or "user" */
+    name: string;
+    /** YAML sources keyed by relative path, as for createSigilDataFromFiles */
+    files: { [relativePath: string]: string };
+}
+
+export interface TableOrigin {
+    layer: string;
+    /** Files in that layer defining the table (several when a list is merged) */
+    files: string[];
+    /** Definitions from earlier layers that this one replaced, earliest first */
+    shadowed: { layer: string; files: string[] }[];
+    /** Files that added entries with "@extend", in the order applied (omitted when none) */
+    extendedBy?: { layer: string; files: string[] }[];
+}
+
+export interface LayeredData extends LoadedData {
+    /** Where a table (dotted path) or template name came from; undefined when not defined */
+    origin(name: string): TableOrigin | undefined;
+}
+
+/**
+ * Stack several table sets, e.g. defaults bundled with an app under a user's
+ * own directory. Within a layer files merge as in createSigilDataFromFiles and
+ * conflicts throw; across layers a table or template from a later layer
+ * replaces the earlier one entirely, and groups merge table by table.
+ * "@extend" in a later layer adds to an earlier layer's table instead of replacing it.
+ */
+export function createLayeredSigilData(layers: SigilLayer[], options: DirectoryLoadOptions = {}): LayeredData {
+    const lists: SigilData = {};
+    const templates: SigilTemplate = {};
+    const origins = new Map<string, TableOrigin>();
+
+    const define = (name: string, layer: string, files: string[]) => {
+        const previous = origins.get(name);
+        const shadowed = previous ? [...previous.shadowed, { layer: previous.layer, files: previous.files }] : [];
+        origins.set(name, { layer, files, shadowed });
+    };
+
+    for (const layer of layers) {
+        const { data, extensions, tableFiles, templateFiles } = parseFileSet(layer.files, options);
+
+        for (const [tablePath, files] of tableFiles) {
+            setTable(lists, tablePath.split('.'), getTable(data.lists, tablePath), origins);
+            define(tablePath, layer.name, files);
+        }
+
+        // Extensions may target tables from this layer or any earlier one
+        applyExtensions(lists, extensions);
+        for (const { file, table } of extensions) {
+            const origin = origins.get(table);
+            if (!origin) continue;
+            const extendedBy = origin.extendedBy || [];
+            const current = extendedBy[extendedBy.length - 1];
+            if (current && current.layer === layer.name) {
+                if (!current.files.includes(file)) current.files.push(file);
+            } else {
+                extendedBy.push({ layer: layer.name, files: [file] });
+            }
+            origin.extendedBy = extendedBy;
+        }
+
+        for (const [name, files] of templateFiles) {
+            templates[name] = data.templates[name];
+            define(`templates.${name}`, layer.name, files);
+        }
+    }
+
+    return {
+        lists,
+        templates,
+        origin: (name: string) => origins.get(name) ?? origins.get(`templates.${name}`)
+    };
+}
+
+/**
+ * Dotted paths of every table in a table set (groups are walked, not listed)
+ */
+export function listTables(lists: SigilData): string[] {
+    const tables: string[] = [];
+    forEachTable(lists, '', tablePath => tables.push(tablePath));
+    return tables;
+}
+
+// Lists, plain values, roll tables and "@entries" tables are tables; other objects are groups
+function isTableValue(value: unknown): boolean {
+    return typeof value !== 'object' || value === null || Array.isArray(value)
+        || isRollTable(value) || getTableEntries(value) !== undefined;
+}
+
+function forEachTable(data: SigilData, prefix: string, visit: (tablePath: string, value: any) => void): void {
+    for (const [key, value] of Object.entries(data)) {
+        if (!prefix && (key === 'templates' || key.startsWith('@'))) continue;
+        const tablePath = prefix ? `${prefix}.${key}` : key;
+        if (isTableValue(value)) {
+            visit(tablePath, value);
+        } else {
+            forEachTable(value, tablePath, visit);
+        }
+    }
+}
+
+function getTable(lists: SigilData, tablePath: string): any {
+    return tablePath.split('.').reduce((value, key) => value?.[key], lists);
+}
+
+// Put a table in place, forgetting the origins of whatever it replaces
+function setTable(lists: SigilData, keys: string[], value: any, origins: Map<string, TableOrigin>): void {
+    let group = lists;
+    for (let i = 0; i < keys.length - 1; i++) {
+        const key = keys[i];
+        if (isTableValue(group[key])) {
+            // A table becomes a group of tables
+            origins.delete(keys.slice(0, i + 1).join('.'));
+            group[key] = {};
+        }
+        group = group[key];
+    }
+
+    const last = keys[keys.length - 1];
+    const tablePath = keys.join('.');
+    for (const name of [...origins.keys()]) {
+        if (name.startsWith(`${tablePath}.`)) origins.delete(name);
+    }
+    group[last] = value;
+}
+
+// Wrap a file's tables under its subdirectories; templates stay global
+function namespaceData(data: SigilData, dirs: string[]): SigilData {
+    if (dirs.length === 0) return data;
+
+    const { templates, ...tables } = data;
+    let wrapped: SigilData = tables;
+    for (const dir of [...dirs].reverse()) {
+        wrapped = { [dir]: wrapped };
+    }
+    return templates !== undefined ? { ...wrapped, templates } : wrapped;
+}
+
+// Remember which file first defined each path and whether it was a list, group or value
+function recordOrigins(data: SigilData, prefix: string, file: string, origins: Map<string, { kind: string; file: string }>): void {
+    for (const [key, value] of Object.entries(data)) {
+        if (!prefix && key === 'templates') continue;
+
+        const tablePath = prefix ? `${prefix}.${key}` : key;
+        const kind = Array.isArray(value) ? 'list' : (typeof value === 'object' && value !== null ? 'group' : 'value');
+        const previous = origins.get(tablePath);
+
+        if (previous && previous.kind !== kind) {
+            throw new ParseError('table_conflict',
+                `Table "${tablePath}" is a ${previous.kind} in "${previous.file}" but a ${kind} in "${file}"`,
+                { file, table: tablePath });
+        }
+        if (!previous) {
+            origins.set(tablePath, { kind, file });
+        }
+        if (kind === 'group') {
+            recordOrigins(value, tablePath, file, origins);
+        }
+    }
+}
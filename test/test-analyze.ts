@@ -0,0 +1,125 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { diceDistribution, parseDice } from '../src/dice';
+
+// Probability of one outcome (0 when it never comes up)
+function chance(distribution: { outcomes: { text: string; probability: number }[] }, text: string): number {
+    return distribution.outcomes.find(outcome => outcome.text === text)?.probability ?? 0;
+}
+
+describe('SIGIL Probability Analysis', () => {
+    describe('exact enumeration', () => {
+        it('should account for weights', () => {
+            const engine = new SigilEngine({ loot: ['gold ^3', 'a rock'] });
+            const result = engine.analyze('[loot]');
+            assert.strictEqual(result.exact, true);
+            assert.strictEqual(result.runs, 2);
+            assert.deepStrictEqual(result.outcomes, [
+                { text: 'gold', probability: 0.75 },
+                { text: 'a rock', probability: 0.25 }
+            ]);
+        });
+
+        it('should follow nested tables and combine equal texts', () => {
+            const engine = new SigilEngine({
+                beast: ['[size] wolf', 'bear'],
+                size: ['big', 'small']
+            });
+            const result = engine.analyze('A [beast] or a [beast]');
+            assert.strictEqual(result.exact, true);
+            assert.strictEqual(chance(result, 'A bear or a bear'), 0.25);
+            assert.strictEqual(chance(result, 'A big wolf or a small wolf'), 0.0625);
+            const total = result.outcomes.reduce((sum, outcome) => sum + outcome.probability, 0);
+            assert.ok(Math.abs(total - 1) < 1e-9, 'Probabilities should add up to 1');
+        });
+
+        it('should enumerate dice, inline choices, ranges and optional references', () => {
+            const engine = new SigilEngine({ hat: ['hat'] });
+            assert.strictEqual(chance(engine.analyze('[2d6]'), '7'), 6 / 36);
+            assert.strictEqual(chance(engine.analyze('[4d6kh3]'), '18'), 21 / 1296);
+            assert.strictEqual(chance(engine.analyze('{a|b|c}'), 'b'), 1 / 3);
+            assert.strictEqual(chance(engine.analyze('{1-4}'), '4'), 0.25);
+            assert.strictEqual(chance(engine.analyze('[hat?]'), ''), 0.5);
+        });
+
+        it('should weigh roll table entries by their ranges', () => {
+            const engine = new SigilEngine({ encounter: { '1-3': 'wolves', '4-5': 'bandits', '6': 'a dragon' } });
+            const result = engine.analyze('[encounter]');
+            assert.strictEqual(result.exact, true);
+            assert.strictEqual(chance(result, 'wolves'), 0.5);
+            assert.strictEqual(chance(result, 'a dragon'), 1 / 6);
+
+            const bell = new SigilEngine({ weather: { '@die': '2d6', '2-6': 'rain', '7': 'fog', '8-12': 'sun' } });
+            assert.strictEqual(chance(bell.analyze('[weather]'), 'fog'), 6 / 36);
+        });
+
+        it('should handle captures and conditionals', () => {
+            const engine = new SigilEngine({});
+            const result = engine.analyze('[1d4->hp][if hp >= 3 then strong else weak]');
+            assert.strictEqual(result.exact, true);
+            assert.strictEqual(chance(result, '4strong'), 0.25);
+            assert.strictEqual(chance(result, '2weak'), 0.25);
+        });
+
+        it('should draw unique picks without replacement', () => {
+            const engine = new SigilEngine({ gem: ['ruby', 'opal'] });
+            const result = engine.analyze('[gem.unique] [gem.unique]');
+            assert.deepStrictEqual(result.outcomes.map(outcome => outcome.text).sort(), ['opal ruby', 'ruby opal']);
+        });
+    });
+
+    describe('sampled fallback', () => {
+        it('should sample when there are more paths than maxPaths', () => {
+            const engine = new SigilEngine({ loot: ['gold ^3', 'a rock'] });
+            const result = engine.analyze('[loot] [loot] [loot]', { maxPaths: 4, samples: 2000, seed: 7 });
+            assert.strictEqual(result.exact, false);
+            assert.strictEqual(result.runs, 2000);
+            assert.strictEqual(result.outcomes.reduce((sum, outcome) => sum + (outcome.count || 0), 0), 2000);
+            assert.ok(Math.abs(chance(result, 'gold gold gold') - 27 / 64) < 0.05);
+        });
+
+        it('should sample recursive tables', () => {
+            const engine = new SigilEngine({ corridor: ['a corridor ^3', 'a corridor to [corridor]'] });
+            const result = engine.analyze('[corridor]', { samples: 500, maxPaths: 5 });
+            assert.strictEqual(result.exact, false);
+            assert.strictEqual(result.outcomes[0].text, 'a corridor');
+        });
+
+        it('should sample huge ranges instead of enumerating them', () => {
+            const engine = new SigilEngine({});
+            const result = engine.analyze('{1-1000000}', { samples: 100, seed: 1 });
+            assert.strictEqual(result.exact, false);
+        });
+
+        it('should reproduce a sampled distribution from its seed', () => {
+            const engine = new SigilEngine({});
+            const first = engine.analyze('[3d6] [3d6] [3d6]', { maxPaths: 10, samples: 200, seed: 'x' });
+            const second = engine.analyze('[3d6] [3d6] [3d6]', { maxPaths: 10, samples: 200, seed: 'x' });
+            assert.deepStrictEqual(first, second);
+        });
+    });
+
+    it('should leave generation untouched', () => {
+        const a = new SigilEngine({ loot: ['gold', 'rock', 'gem'] }, { seed: 3 });
+        const b = new SigilEngine({ loot: ['gold', 'rock', 'gem'] }, { seed: 3 });
+        a.analyze('[loot] [2d6]');
+        assert.deepStrictEqual(a.generateBatch('[loot] [2d6]', 5), b.generateBatch('[loot] [2d6]', 5));
+    });
+
+    describe('diceDistribution', () => {
+        it('should count the ways to roll each total', () => {
+            assert.deepStrictEqual(diceDistribution(parseDice('1d4+1')), [
+                { total: 2, ways: 1 }, { total: 3, ways: 1 }, { total: 4, ways: 1 }, { total: 5, ways: 1 }
+            ]);
+            const twoD6 = diceDistribution(parseDice('2d6'))!;
+            assert.strictEqual(twoD6.find(t => t.total === 7)!.ways, 6);
+            assert.strictEqual(twoD6.reduce((sum, t) => sum + t.ways, 0), 36);
+        });
+
+        it('should give up on huge expressions', () => {
+            assert.strictEqual(diceDistribution(parseDice('1000d1000')), undefined);
+            assert.strictEqual(diceDistribution(parseDice('20d6kh3')), undefined);
+        });
+    });
+});
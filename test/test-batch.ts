@@ -0,0 +1,54 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { DistinctBatchError } from '../src/errors';
+
+const data = { first: ['Al', 'Bo', 'Cy', 'Di'], last: ['ton', 'ric', 'wen'] };
+
+describe('SIGIL Batch Generation', () => {
+    it('should generate the requested number of results', () => {
+        const engine = new SigilEngine(data);
+        const names = engine.generateBatch('[first][last]', 50);
+        assert.strictEqual(names.length, 50);
+        assert.ok(names.every(name => /^(Al|Bo|Cy|Di)(ton|ric|wen)$/.test(name)));
+    });
+
+    it('should avoid duplicates when distinct', () => {
+        const engine = new SigilEngine(data);
+        const names = engine.generateBatch('[first][last]', 12, { distinct: true, maxAttempts: 10000 });
+        assert.strictEqual(new Set(names).size, 12);
+    });
+
+    it('should reproduce a whole batch from its seed', () => {
+        const engine = new SigilEngine(data);
+        const first = engine.generateBatch('[first][last] {1-100}', 20, { seed: 'npcs' });
+        const second = engine.generateBatch('[first][last] {1-100}', 20, { seed: 'npcs' });
+        assert.deepStrictEqual(first, second);
+    });
+
+    it('should stop with what it has when distinct results run out', () => {
+        const graceful = new SigilEngine(data);
+        assert.strictEqual(graceful.generateBatch('[last]', 5, { distinct: true, maxAttempts: 200 }).length, 3);
+
+        const strict = new SigilEngine(data, { errorMode: 'strict' });
+        assert.throws(() => strict.generateBatch('[last]', 5, { distinct: true, maxAttempts: 200 }), (error: unknown) => {
+            assert.ok(error instanceof DistinctBatchError);
+            assert.deepStrictEqual([...error.results].sort(), ['ric', 'ton', 'wen']);
+            assert.strictEqual(error.requested, 5);
+            return true;
+        });
+    });
+
+    it('should stream results and honour an abort signal', () => {
+        const engine = new SigilEngine(data);
+        const controller = new AbortController();
+        const received: string[] = [];
+        assert.throws(() => {
+            for (const name of engine.generateStream('[first]', 100, { signal: controller.signal })) {
+                received.push(name);
+                if (received.length === 3) controller.abort(new Error('stopped'));
+            }
+        }, /stopped/);
+        assert.strictEqual(received.length, 3);
+    });
+});
@@ -0,0 +1,249 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import * as fs from 'fs';
+import * as os from 'os';
+import * as path from 'path';
+import { runCli } from '../src/cli';
+
+// Runs the CLI and captures what it printed
+function run(args: string[], stdin = ''): { code: number; stdout: string; stderr: string } {
+    let stdout = '';
+    let stderr = '';
+    const code = runCli(args, {
+        stdout: text => { stdout += text; },
+        stderr: text => { stderr += text; },
+        readStdin: () => stdin
+    });
+    return { code, stdout, stderr };
+}
+
+const creatures = 'adjective:\n  - grim\n  - pale\ncreature:\n  - wolf\n  - bear\ntemplates:\n  beast:\n    - "A [adjective] [creature]"\n';
+
+describe('SIGIL CLI', () => {
+    describe('gen', () => {
+        it('should print one result per line from stdin tables', () => {
+            const { code, stdout } = run(['gen', '-t', 'A [adjective] [creature]', '-n', '5'], creatures);
+            assert.strictEqual(code, 0);
+            const lines = stdout.trimEnd().split('\n');
+            assert.strictEqual(lines.length, 5);
+            lines.forEach(line => assert.match(line, /^A (grim|pale) (wolf|bear)$/));
+        });
+
+        it('should reproduce output for the same seed', () => {
+            const first = run(['gen', '-t', '[creature]', '-n', '10', '--seed', '42'], creatures);
+            const second = run(['gen', '--template=[creature]', '--count=10', '--seed=42'], creatures);
+            assert.strictEqual(first.stdout, second.stdout);
+        });
+
+        it('should accept the name of a loaded template', () => {
+            const { code, stdout } = run(['gen', '-t', 'beast'], creatures);
+            assert.strictEqual(code, 0);
+            assert.match(stdout, /^A (grim|pale) (wolf|bear)\n$/);
+        });
+
+        it('should load files and directories', () => {
+            const { code, stdout } = run(['gen', '-f', 'test/fixtures/tables', '-t', '[monsters] and [colors]']);
+            assert.strictEqual(code, 0);
+            assert.match(stdout, /^(goblin|orc) and (red|blue|chrome)\n$/);
+        });
+
+        it('should emit the expansion tree as JSON lines', () => {
+            const { code, stdout } = run(['gen', '-t', '[creature]', '-n', '2', '--format', 'json'], creatures);
+            assert.strictEqual(code, 0);
+            const trees = stdout.trimEnd().split('\n').map(line => JSON.parse(line));
+            assert.strictEqual(trees.length, 2);
+            assert.strictEqual(trees[0].type, 'template');
+            assert.strictEqual(trees[0].children[0].name, 'creature');
+        });
+
+        it('should render Markdown and HTML', () => {
+            const styled = 'hero:\n  "@style": bold\n  "@entries":\n    - Ada & Bo\n';
+            assert.strictEqual(run(['gen', '-t', 'Hi [hero]', '--format', 'markdown'], styled).stdout, 'Hi **Ada & Bo**\n');
+            assert.strictEqual(run(['gen', '-t', 'Hi [hero]', '--format', 'html'], styled).stdout, 'Hi <strong>Ada &amp; Bo</strong>\n');
+            assert.match(run(['tables', '--format', 'html'], styled).stderr, /--format html is only for gen/);
+        });
+
+        it('should print trace events to stderr', () => {
+            const { code, stdout, stderr } = run(['gen', '-t', 'A [creature]', '--trace', '--seed', '1'], creatures);
+            assert.strictEqual(code, 0);
+            const events = stderr.trimEnd().split('\n').map(line => JSON.parse(line));
+            assert.strictEqual(events.length, 1);
+            assert.strictEqual(events[0].name, 'creature');
+            assert.strictEqual(stdout, `A ${events[0].text}\n`);
+            assert.match(run(['tables', '--trace'], creatures).stderr, /--trace is only for gen/);
+        });
+
+        it('should exit 1 on generation errors', () => {
+            const { code, stderr } = run(['gen', '-t', '[missing]'], creatures);
+            assert.strictEqual(code, 1);
+            assert.match(stderr, /Unknown table or variable "missing"/);
+        });
+    });
+
+    describe('validate', () => {
+        it('should exit 0 for clean files', () => {
+            const { code, stdout } = run(['validate', 'test/fixtures/tables']);
+            assert.strictEqual(code, 0);
+            assert.strictEqual(stdout, '');
+        });
+
+        it('should report problems with file, line and column', () => {
+            const { code, stdout } = run(['validate'], 'monsters:\n  - orc ^0\n  - "[nowhere]"\n');
+            assert.strictEqual(code, 1);
+            assert.match(stdout, /^<stdin>:2:5: error: .*weight/m);
+            assert.match(stdout, /^<stdin>:3:\d+: error: .*nowhere/m);
+        });
+
+        it('should report issues as JSON', () => {
+            const { code, stdout } = run(['validate', '--format', 'json'], 'monsters:\n  - "[nowhere]"\n');
+            assert.strictEqual(code, 1);
+            const issues = JSON.parse(stdout);
+            assert.strictEqual(issues[0].file, '<stdin>');
+            assert.strictEqual(issues[0].line, 2);
+        });
+
+        it('should report conflicts between files', () => {
+            const { code, stdout } = run(['validate', 'test/fixtures/conflict']);
+            assert.strictEqual(code, 1);
+            assert.match(stdout, /b\.yaml:1:1: error: Table "loot" is a list/);
+        });
+
+        it('should read included files and flag missing extend targets', () => {
+            assert.strictEqual(run(['validate', 'test/fixtures/includes/northern.yaml']).code, 0);
+            assert.match(run(['gen', '-f', 'test/fixtures/includes/northern.yaml', '-t', '[dwarf_names]']).stdout, /^(Durin|Balin|Thrain)\n$/);
+
+            const { code, stdout } = run(['validate'], '"@extend":\n  giants:\n    - Ymir\n');
+            assert.strictEqual(code, 1);
+            assert.match(stdout, /^<stdin>:2:3: error: Cannot extend undefined table "giants"/m);
+        });
+    });
+
+    describe('tables', () => {
+        it('should list tables and templates', () => {
+            const { code, stdout } = run(['tables'], creatures);
+            assert.strictEqual(code, 0);
+            assert.strictEqual(stdout, 'adjective\ncreature\ntemplates.beast\n');
+        });
+
+        it('should list nested tables as dotted paths in JSON', () => {
+            const { stdout } = run(['tables', '--format', 'json'], 'loot:\n  coins:\n    - copper\n  gems:\n    - ruby\n');
+            assert.deepStrictEqual(JSON.parse(stdout), { tables: ['loot.coins', 'loot.gems'], templates: [] });
+        });
+    });
+
+    describe('fmt', () => {
+        it('should print formatted stdin', () => {
+            const { code, stdout } = run(['fmt', '--order', 'alphabetical'], 'wolf:\n- grey ^2\n-  dire\nbear: [brown]\n');
+            assert.strictEqual(code, 0);
+            assert.strictEqual(stdout, 'bear: [brown]\nwolf:\n  - grey ^2\n  - dire\n');
+        });
+
+        it('should rewrite files in place with -w and leave broken ones alone', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'sigil-fmt-'));
+            const messy = path.join(dir, 'messy.yaml');
+            const broken = path.join(dir, 'broken.yaml');
+            fs.writeFileSync(messy, 'loot:\n    - gold^3\n');
+            fs.writeFileSync(broken, 'loot: [gold\n');
+
+            const { code, stdout, stderr } = run(['fmt', '-w', dir]);
+            assert.strictEqual(code, 1);
+            assert.strictEqual(stdout, `${messy}\n`);
+            assert.match(stderr, /broken\.yaml/);
+            assert.strictEqual(fs.readFileSync(messy, 'utf-8'), 'loot:\n  - gold ^3\n');
+            assert.strictEqual(fs.readFileSync(broken, 'utf-8'), 'loot: [gold\n');
+            fs.rmSync(dir, { recursive: true });
+        });
+
+        it('should keep a file\'s encoding and line endings when rewriting it', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'sigil-fmt-'));
+            const file = path.join(dir, 'menu.yaml');
+            fs.writeFileSync(file, Buffer.from('\ufeffmenu:\r\n    - caf\u00e9^2\r\n', 'utf16le'));
+            assert.strictEqual(run(['fmt', '-w', file]).code, 0);
+            assert.deepStrictEqual(fs.readFileSync(file), Buffer.from('\ufeffmenu:\r\n  - caf\u00e9 ^2\r\n', 'utf16le'));
+            fs.rmSync(dir, { recursive: true });
+        });
+
+        it('should warn about bytes that do not decode', () => {
+            const { code, stdout, stderr } = run(['fmt', 'test/fixtures/encodings/invalid-utf-8.yaml']);
+            assert.strictEqual(code, 0);
+            assert.match(stdout, /  - broken \uFFFD\uFFFD tea\n$/);
+            assert.strictEqual(stderr, 'test/fixtures/encodings/invalid-utf-8.yaml: warning: 2 invalid byte sequences replaced with U+FFFD\n');
+        });
+    });
+
+    describe('convert', () => {
+        it('should print converted tables and warn on stderr', () => {
+            const { code, stdout, stderr } = run(['convert', '--from', 'perchance', 'test/fixtures/import/tavern.txt']);
+            assert.strictEqual(code, 0);
+            assert.match(stdout, /^output:\n/);
+            assert.match(stdout, /\nanimal_bird:\n  - crow\n  - magpie\n/);
+            assert.match(stderr, /^test\/fixtures\/import\/tavern\.txt:7: warning: Dropped the dynamic weight/);
+        });
+
+        it('should write the output file with -o', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'sigil-convert-'));
+            const out = path.join(dir, 'wilderness.yaml');
+            const { code, stdout } = run(['convert', '--from=markdown', 'test/fixtures/import/wilderness.md', '-o', out]);
+            assert.strictEqual(code, 0);
+            assert.strictEqual(stdout, '');
+            assert.strictEqual(run(['validate', out]).code, 0);
+            fs.rmSync(dir, { recursive: true });
+        });
+
+        it('should name CSV tables after the file and read stdin', () => {
+            assert.strictEqual(run(['convert', '--from', 'csv'], 'text,weight\nsword,3\nbow,\n').stdout, 'table:\n  - sword ^3\n  - bow\n');
+            assert.strictEqual(run(['convert', '--from', 'markdown'], 'no tables here\n').code, 1);
+            assert.match(run(['convert', 'a.txt']).stderr, /convert needs an input format/);
+            assert.match(run(['convert', '--from', 'docx']).stderr, /--from must be perchance, markdown, csv or json/);
+            assert.match(run(['gen', '-t', 'x', '-o', 'out.yaml']).stderr, /-o is only for convert/);
+        });
+    });
+
+    describe('compile', () => {
+        it('should write a compiled file that gen and tables load', () => {
+            const dir = fs.mkdtempSync(path.join(os.tmpdir(), 'sigil-compile-'));
+            const out = path.join(dir, 'tables.sgc');
+            const { code, stdout } = run(['compile', 'test/fixtures/includes/northern.yaml', '-o', out]);
+            assert.strictEqual(code, 0);
+            assert.strictEqual(stdout, '');
+            assert.match(run(['gen', '-f', out, '-t', '[dwarf_names]']).stdout, /^(Durin|Balin|Thrain)\n$/);
+            assert.strictEqual(run(['tables', out]).stdout, run(['tables', 'test/fixtures/includes/northern.yaml']).stdout);
+            fs.rmSync(dir, { recursive: true });
+        });
+
+        it('should refuse to mix compiled and table files', () => {
+            const compiled = run(['compile'], creatures).stdout;
+            assert.match(compiled, /^\{"format":"sigil-compiled","version":1,/);
+            const { code, stderr } = run(['gen', '-f', '-', '-f', 'test/fixtures/tables', '-t', '[creature]'], compiled);
+            assert.strictEqual(code, 1);
+            assert.match(stderr, /cannot be loaded with other files/);
+            assert.match(run(['compile'], compiled).stderr, /Already a compiled table set/);
+        });
+    });
+
+    describe('usage', () => {
+        it('should exit 2 on bad usage', () => {
+            assert.strictEqual(run([]).code, 2);
+            assert.strictEqual(run(['roll']).code, 2);
+            assert.strictEqual(run(['gen']).code, 2);
+            assert.strictEqual(run(['gen', '-t', 'x', '-n', 'many']).code, 2);
+            assert.strictEqual(run(['gen', '-t', 'x', '--bogus']).code, 2);
+            assert.match(run(['gen', '-t', 'x', '--format', 'xml']).stderr, /--format must be text, json, markdown or html/);
+            assert.match(run(['fmt', '-w']).stderr, /-w needs table files/);
+            assert.match(run(['gen', '-t', 'x', '--addr', ':8080']).stderr, /--addr is only for serve/);
+            assert.match(run(['serve', '--addr', '8080']).stderr, /--addr must be \[host\]:port/);
+        });
+
+        it('should exit 2 for unreadable files', () => {
+            const { code, stderr } = run(['tables', '-f', 'test/fixtures/nope.yaml']);
+            assert.strictEqual(code, 2);
+            assert.match(stderr, /Cannot read "test\/fixtures\/nope\.yaml"/);
+        });
+
+        it('should print help', () => {
+            const { code, stdout } = run(['--help']);
+            assert.strictEqual(code, 0);
+            assert.match(stdout, /Usage: sigil/);
+        });
+    });
+});
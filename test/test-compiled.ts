@@ -0,0 +1,60 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { compileSigilData, loadCompiled, isCompiled, compiledSourceHash, COMPILED_FORMAT_VERSION } from '../src/compiled';
+import { createSigilDataFromFiles, readSigilDirectory } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+import { ParseError } from '../src/errors';
+
+const files = {
+    'base.yaml': 'gem:\n  - ruby ^3\n  - ~\nloot:\n  "1-4": "[gem]"\n  "5-6": gold\n',
+    'more.yaml': '"@include": base.yaml\n"@extend":\n  gem:\n    - opal (rare)\ntemplates:\n  hoard:\n    - "[loot] and [gem #rare]"\n'
+};
+
+function codeOf(run: () => unknown): string | undefined {
+    try {
+        run();
+    } catch (error) {
+        return error instanceof ParseError ? error.code : 'other';
+    }
+    return undefined;
+}
+
+describe('SIGIL Compiled Table Sets', () => {
+    it('should load the same tables as the source files', () => {
+        const compiled = compileSigilData(files);
+        assert.ok(isCompiled(compiled));
+        assert.deepStrictEqual(loadCompiled(compiled), createSigilDataFromFiles(files));
+    });
+
+    it('should generate the same results', () => {
+        const fromSource = new SigilEngine(createSigilDataFromFiles(files).lists, { seed: 7 });
+        const fromCompiled = new SigilEngine(loadCompiled(compileSigilData(files)).lists, { seed: 7 });
+        const template = '[loot*5, sep=" / "] [gem #rare]';
+        assert.deepStrictEqual(fromCompiled.generateBatch(template, 20), fromSource.generateBatch(template, 20));
+    });
+
+    it('should keep directory namespaces', () => {
+        const directory = readSigilDirectory('test/fixtures/tables');
+        const compiled = loadCompiled(compileSigilData(directory, { namespace: true }));
+        assert.deepStrictEqual(compiled, createSigilDataFromFiles(directory, { namespace: true }));
+        assert.notStrictEqual(compiledSourceHash(directory, { namespace: true }), compiledSourceHash(directory));
+    });
+
+    it('should detect stale compiled files', () => {
+        const compiled = compileSigilData(files);
+        assert.strictEqual(codeOf(() => loadCompiled(compiled, { sources: { 'more.yaml': files['more.yaml'], 'base.yaml': files['base.yaml'] } })), undefined);
+        const changed = { ...files, 'base.yaml': files['base.yaml'].replace('ruby', 'garnet') };
+        assert.strictEqual(codeOf(() => loadCompiled(compiled, { sources: changed })), 'stale_compiled');
+        assert.strictEqual(codeOf(() => loadCompiled(compiled, { sources: { 'base.yaml': files['base.yaml'] } })), 'stale_compiled');
+    });
+
+    it('should refuse other formats and versions', () => {
+        const compiled = JSON.parse(compileSigilData(files));
+        const future = JSON.stringify({ ...compiled, version: COMPILED_FORMAT_VERSION + 1 });
+        assert.ok(isCompiled(future));
+        assert.strictEqual(codeOf(() => loadCompiled(future)), 'invalid_compiled');
+        assert.strictEqual(codeOf(() => loadCompiled('gem:\n  - ruby\n')), 'invalid_compiled');
+        assert.strictEqual(codeOf(() => loadCompiled('{"gem": ["ruby"]}')), 'invalid_compiled');
+        assert.strictEqual(isCompiled('{"gem": ["ruby"]}'), false);
+    });
+});
@@ -0,0 +1,57 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+
+const data = {
+    terrain: ['desert'],
+    desert: { encounters: ['a sandworm'] },
+    encounters: ['a wolf'],
+    weather: [
+        '[if t == "desert" then scorching else mild]'
+    ]
+};
+
+describe('SIGIL Conditionals', () => {
+    it('should pick the branch matching a captured variable', () => {
+        const engine = new SigilEngine(data);
+        const result = engine.generate('[terrain->t]: [if t == "desert" then [desert.encounters] else [encounters]]');
+        assert.strictEqual(result, 'desert: a sandworm');
+        assert.strictEqual(engine.generate('[if t != "desert" then [desert.encounters] else [encounters]]', { vars: { t: 'forest' } }), 'a sandworm');
+    });
+
+    it('should compare numbers numerically', () => {
+        const engine = new SigilEngine({});
+        const check = (n: string, template: string) => engine.generate(template, { vars: { n } });
+        assert.strictEqual(check('10', '[if n > 9 then big else small]'), 'big');
+        assert.strictEqual(check('9', '[if n >= 10 then big else small]'), 'small');
+        assert.strictEqual(check('3.0', '[if n == 3 then three]'), 'three');
+        assert.strictEqual(check('3', '[if n < 2 then tiny]'), '');
+    });
+
+    it('should support conditionals inside table entries and other conditionals', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[weather]', { vars: { t: 'desert' } }), 'scorching');
+        assert.strictEqual(engine.generate('[weather]', { vars: { t: 'tundra' } }), 'mild');
+
+        const nested = '[if a == "x" then [if b == "y" then both else only a] else neither]';
+        assert.strictEqual(engine.generate(nested, { vars: { a: 'x', b: 'y' } }), 'both');
+        assert.strictEqual(engine.generate(nested, { vars: { a: 'x', b: 'z' } }), 'only a');
+        assert.strictEqual(engine.generate(nested, { vars: { a: 'q', b: 'y' } }), 'neither');
+    });
+
+    it('should keep apostrophes and inline choices in branches', () => {
+        const engine = new SigilEngine({});
+        assert.strictEqual(engine.generate("[if k == 'yes' then the king's hall else {a|a}]", { vars: { k: 'yes' } }), "the king's hall");
+    });
+
+    it('should report undefined variables with their name', () => {
+        const strict = new SigilEngine({}, { errorMode: 'strict' });
+        assert.throws(() => strict.generate('[if mood == "sad" then rain]'), /Undefined variable "mood"/);
+        assert.strictEqual(new SigilEngine({}).generate('[if mood == "sad" then rain]'), '');
+    });
+
+    it('should reject ordering comparisons on text in strict mode', () => {
+        const strict = new SigilEngine({}, { errorMode: 'strict' });
+        assert.throws(() => strict.generate('[if t < "b" then x]', { vars: { t: 'a' } }), /both sides must be numbers/);
+    });
+});
@@ -0,0 +1,107 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import * as fs from 'fs';
+import { importPerchance, importMarkdownTable } from '../src/converters';
+import { createSigilData, parseYamlContent } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+
+const fixture = (name: string) => fs.readFileSync(`test/fixtures/import/${name}`, 'utf-8');
+
+describe('SIGIL Format Converters', () => {
+    describe('importPerchance', () => {
+        it('should convert lists, weights and sublists', () => {
+            const { tables } = importPerchance(fixture('tavern.txt'));
+            const data = tables.toData();
+            assert.deepStrictEqual(data.adjective, ['drunken', 'gilded', 'laughing']);
+            assert.deepStrictEqual(data.intro, ['You push open the door.', 'Rain drips from your cloak. ^2', 'Music spills into the street.']);
+            assert.deepStrictEqual(data.animal, ['[animal_bird]', '[animal_beast]']);
+            assert.deepStrictEqual(data.animal_beast, ['badger', 'stag']);
+            assert.deepStrictEqual(data.output, ['[intro] The [name] is run by [keeper.capitalize].']);
+        });
+
+        it('should point sublist references at the flattened tables', () => {
+            const { tables } = importPerchance('pet\n  {A} [animal.bird.upperCase]\nanimal\n  bird\n    crow\n  fish\n    carp\n');
+            assert.deepStrictEqual(tables.toData().pet, ['{a} [animal_bird.upper]']);
+            const engine = new SigilEngine(tables.toData());
+            assert.strictEqual(engine.generate('[pet]'), 'a CROW');
+        });
+
+        it('should translate selectMany and selectUnique into counts', () => {
+            const { tables, warnings } = importPerchance('loot\n  [gem.selectMany(2)] and [coin.selectUnique(3)]\ngem\n  ruby\ncoin\n  gold\n');
+            assert.deepStrictEqual(tables.toData().loot, ['[gem*2] and [coin.unique*3]']);
+            assert.deepStrictEqual(warnings, []);
+        });
+
+        it('should drop what it cannot convert and say where', () => {
+            const { tables, warnings } = importPerchance(fixture('tavern.txt'));
+            assert.deepStrictEqual(tables.toData().keeper, ['a retired sailor', '{a} [animal_beast] in a waistcoat']);
+            assert.deepStrictEqual(tables.toData().name, ['[adjective] [animal]', '[animal]\'s [thing] ^0.5']);
+            assert.deepStrictEqual(warnings.map(warning => warning.line), [7, 10, 32, 33, 34]);
+            assert.match(warnings[0].message, /dynamic weight/);
+            assert.match(warnings[1].message, /titleCase/);
+            assert.match(warnings[3].message, /code SIGIL cannot run/);
+            assert.match(warnings[4].message, /imports/);
+        });
+
+        it('should skip comments and warn about empty lists', () => {
+            const { tables, warnings } = importPerchance('// tables\ncolor // the main list\n  red // warm\n  blue\nempty\n');
+            assert.deepStrictEqual(tables.toData(), { color: ['red', 'blue'] });
+            assert.deepStrictEqual(warnings, [{ line: 5, message: 'Dropped list "empty": it has no items' }]);
+        });
+
+        it('should produce source that loads and generates', () => {
+            const { tables } = importPerchance(fixture('tavern.txt'));
+            const engine = new SigilEngine(createSigilData([tables.toSource()]).lists);
+            for (let i = 0; i < 20; i++) {
+                assert.match(engine.generate('[output]'), / The .+ is run by [A-Z]/);
+            }
+        });
+    });
+
+    describe('importMarkdownTable', () => {
+        it('should turn dice columns into roll tables named after headings', () => {
+            const { tables } = importMarkdownTable(fixture('wilderness.md'));
+            assert.deepStrictEqual(tables.names(), ['wandering_monsters_forest', 'weather', 'treasure', 'weather_2']);
+            assert.deepStrictEqual(tables.lookup('wandering_monsters_forest')!.entries.map(entry => [entry.min, entry.max, entry.text]), [
+                [1, 2, '1d4 wolves'],
+                [3, 3, 'a lost pilgrim'],
+                [4, 5, 'an owlbear \\{hungry\\}'],
+                [6, 8, 'nothing']
+            ]);
+            assert.strictEqual(tables.lookup('wandering_monsters_forest')!.die, undefined);
+        });
+
+        it('should declare dice the ranges do not imply and read 00 as 100', () => {
+            const { tables } = importMarkdownTable(fixture('wilderness.md'));
+            assert.strictEqual(tables.lookup('weather')!.die, '2d6');
+            assert.strictEqual(tables.toData().weather['@die'], '2d6');
+            assert.deepStrictEqual(tables.toData().weather_2, { '1-50': 'a black cat', '51-99': 'a red moon', '100': 'a falling star' });
+        });
+
+        it('should use weight columns and warn about dropped columns and rows', () => {
+            const { tables, warnings } = importMarkdownTable(fixture('wilderness.md'));
+            assert.deepStrictEqual(tables.toData().treasure, ['copper coins ^5', 'a silver ring ^2']);
+            assert.deepStrictEqual(warnings, [
+                { line: 7, message: 'Dropped column "Notes" of table "wandering_monsters_forest"' },
+                { line: 10, message: 'Dropped a link target, keeping its text' },
+                { line: 28, message: 'Dropped "a cursed idol": invalid weight "none"' }
+            ]);
+        });
+
+        it('should name tables without a heading from the options', () => {
+            const { tables } = importMarkdownTable('| Result |\n| --- |\n| a \\| b |\n| [c] |\n', { name: 'misc' });
+            assert.deepStrictEqual(tables.toData(), { misc: ['a \\| b', '\\[c\\]'] });
+            const engine = new SigilEngine(tables.toData());
+            assert.strictEqual(engine.generate('[misc!a]'), '[c]');
+        });
+
+        it('should produce source that loads and generates', () => {
+            const { tables } = importMarkdownTable(fixture('wilderness.md'));
+            const data = parseYamlContent(tables.toSource());
+            assert.deepStrictEqual(Object.keys(data), ['wandering_monsters_forest', 'weather', 'treasure', 'weather_2']);
+            const engine = new SigilEngine(createSigilData([tables.toSource()]).lists);
+            assert.match(engine.generate('[weather]'), /^(thunderstorm|overcast|clear skies)$/);
+            assert.strictEqual(engine.rollOn('weather_2', 100), 'a falling star');
+        });
+    });
+});
@@ -0,0 +1,78 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createSigilData } from '../src/yaml-loader';
+import { dependencyGraphToDot } from '../src/dependencies';
+
+const source = `encounter:
+  - "{a} [monster.capitalize]"
+  - "[npc->who] and [who]"
+  - "[place.[biome]] ^2"
+monster:
+  - wolf
+  - "[size] bear"
+npc:
+  - a tinker
+biome:
+  - forest (wild)
+place:
+  forest:
+    - the Old Wood
+weather:
+  - rain
+`;
+
+function engine() {
+    return new SigilEngine(createSigilData([source]).lists);
+}
+
+describe('SIGIL Dependencies', () => {
+    it('should follow every entry of every table a template reaches', () => {
+        const graph = engine().dependencies('[encounter] under [weather]');
+        assert.deepStrictEqual(graph.tables, ['encounter', 'weather', 'monster', 'npc', 'biome']);
+        assert.deepStrictEqual(graph.missing, ['size']);
+        assert.deepStrictEqual(graph.dynamic, ['place.[biome]']);
+    });
+
+    it('should record the edges between tables', () => {
+        const graph = engine().dependencies('[encounter] under [weather]');
+        assert.deepStrictEqual(graph.edges, [
+            { from: '', to: 'encounter' },
+            { from: '', to: 'weather' },
+            { from: 'encounter', to: 'monster' },
+            { from: 'encounter', to: 'npc' },
+            { from: 'encounter', to: 'place.[biome]' },
+            { from: 'encounter', to: 'biome' },
+            { from: 'monster', to: 'size' }
+        ]);
+    });
+
+    it('should treat captured names as variables, not missing tables', () => {
+        const graph = engine().dependencies('[who] meets [npc->who], [2d6->gold] gold ([gold])');
+        assert.deepStrictEqual(graph.tables, ['npc']);
+        assert.deepStrictEqual(graph.missing, []);
+    });
+
+    it('should not generate anything', () => {
+        const seeded = () => new SigilEngine(createSigilData([source]).lists, { seed: 3 });
+        const first = seeded();
+        const second = seeded();
+        first.dependencies('[encounter]');
+        assert.strictEqual(first.generate('[encounter]'), second.generate('[encounter]'));
+    });
+
+    it('should write the graph as DOT', () => {
+        assert.strictEqual(dependencyGraphToDot(engine().dependencies('[monster] in [place.[biome]]')), [
+            'digraph dependencies {',
+            '    "template" [shape=box];',
+            '    "size" [style=dashed];',
+            '    "place.[biome]" [shape=diamond];',
+            '    "template" -> "monster";',
+            '    "template" -> "place.[biome]";',
+            '    "template" -> "biome";',
+            '    "monster" -> "size";',
+            '}',
+            ''
+        ].join('\n'));
+    });
+});
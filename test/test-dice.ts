@@ -0,0 +1,100 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseDice, rollDice, isDiceExpression } from '../src/dice';
+import { createRandom } from '../src/random';
+
+describe('SIGIL Dice Notation', () => {
+    describe('parseDice', () => {
+        it('should parse count, sides and modifiers', () => {
+            assert.deepStrictEqual(parseDice('2d6+3'), { expression: '2d6+3', count: 2, sides: 6, keep: undefined, modifier: 3 });
+            assert.strictEqual(parseDice('d20').count, 1);
+            assert.strictEqual(parseDice('d%').sides, 100);
+            assert.strictEqual(parseDice('1d8-1+2').modifier, 1);
+        });
+
+        it('should parse keep and drop', () => {
+            assert.deepStrictEqual(parseDice('4d6kh3').keep, { highest: true, count: 3 });
+            assert.deepStrictEqual(parseDice('2d20kl1').keep, { highest: false, count: 1 });
+            assert.deepStrictEqual(parseDice('4d6dl1').keep, { highest: true, count: 3 });
+            assert.deepStrictEqual(parseDice('3d6dh1').keep, { highest: false, count: 2 });
+        });
+
+        it('should reject invalid expressions naming the token', () => {
+            assert.throws(() => parseDice('2d0'), /"2d0"/);
+            assert.throws(() => parseDice('0d6'), /"0d6"/);
+            assert.throws(() => parseDice('4d6kh5'), /"4d6kh5"/);
+            assert.throws(() => parseDice('2d6x'), /"2d6x"/);
+        });
+
+        it('should only treat dice-like content as dice', () => {
+            assert.ok(isDiceExpression('2d6'));
+            assert.ok(isDiceExpression('0d6'), 'Out-of-range dice should be reported, not looked up');
+            assert.ok(!isDiceExpression('2d6x'));
+            assert.ok(!isDiceExpression('2dungeons'));
+            assert.ok(!isDiceExpression('3doors'));
+            assert.ok(!isDiceExpression('d20_results'));
+            assert.ok(!isDiceExpression('door'));
+        });
+    });
+
+    describe('rollDice', () => {
+        it('should keep the highest dice', () => {
+            const random = createRandom(3);
+            for (let i = 0; i < 200; i++) {
+                const roll = rollDice(parseDice('4d6kh3'), random);
+                assert.strictEqual(roll.rolls.length, 4);
+                assert.strictEqual(roll.kept.length, 3);
+                assert.strictEqual(Math.min(...roll.kept), [...roll.rolls].sort((a, b) => a - b)[1]);
+                assert.ok(roll.total >= 3 && roll.total <= 18);
+            }
+        });
+    });
+
+    describe('Templates', () => {
+        const engine = new SigilEngine({
+            loot: { '1': ['copper'], '2': ['silver'], '3': ['gold'] }
+        });
+
+        it('should roll dice inside templates', () => {
+            for (let i = 0; i < 100; i++) {
+                const match = engine.generate('You find [1d4] potions').match(/^You find (\d+) potions$/);
+                assert.ok(match, 'Should interpolate the roll');
+                const value = parseInt(match[1]);
+                assert.ok(value >= 1 && value <= 4, `${value} should be within 1d4`);
+
+                const total = parseInt(engine.generate('[2d6+3]'));
+                assert.ok(total >= 5 && total <= 15, `${total} should be within 2d6+3`);
+
+                const percentile = parseInt(engine.generate('[d%]'));
+                assert.ok(percentile >= 1 && percentile <= 100);
+            }
+        });
+
+        it('should interpolate rolls into nested lookups', () => {
+            for (let i = 0; i < 50; i++) {
+                assert.ok(['copper', 'silver', 'gold'].includes(engine.generate('[loot.[1d3]]')));
+            }
+        });
+
+        it('should be reproducible with a seed', () => {
+            const template = '[4d6kh3] [4d6kh3] [4d6kh3]';
+            assert.strictEqual(engine.generateWithSeed(template, 11).text, engine.generateWithSeed(template, 11).text);
+        });
+
+        it('should report invalid dice instead of passing them through', () => {
+            const strict = new SigilEngine({}, { errorMode: 'strict' });
+            assert.throws(() => strict.generate('Roll [3d0] now'), /"3d0"/);
+            assert.strictEqual(engine.generate('Roll [3d0] now'), 'Roll [3d0] now');
+            assert.strictEqual(engine.generate('Roll [0d6] now'), 'Roll [0d6] now');
+            assert.strictEqual(new SigilEngine({}, { errorMode: 'silent' }).generate('Roll [0d6] now'), 'Roll [0d6] now');
+        });
+
+        it('should look up tables named like dice', () => {
+            const named = new SigilEngine({ '2dungeons': ['the crypt'], '3doors': ['an oak door'], d6: ['a six-sided die'] }, { errorMode: 'strict' });
+            assert.strictEqual(named.generate('[2dungeons] behind [3doors]'), 'the crypt behind an oak door');
+            assert.strictEqual(named.generate('[d6]'), 'a six-sided die');
+            assert.match(named.generate('[2d6]'), /^\d+$/);
+        });
+    });
+});
@@ -0,0 +1,37 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { loadSigilDirectory, createSigilDataFromFiles } from '../src/yaml-loader';
+
+describe('Directory Loading', () => {
+    it('should merge every YAML file below a directory', () => {
+        const data = loadSigilDirectory('./test/fixtures/tables');
+        assert.deepStrictEqual([...data.lists.names].sort(), ['Aldric', 'Brenna', 'Unit-7', 'Vex']);
+        assert.deepStrictEqual(data.lists.monsters, ['goblin', 'orc']);
+        assert.deepStrictEqual([...data.lists.colors].sort(), ['blue', 'chrome', 'red']);
+        assert.ok(data.templates.greeting, 'Should collect templates');
+        assert.ok(!('notes' in data.lists), 'Should skip non-YAML files');
+    });
+
+    it('should namespace tables by subdirectory when asked', () => {
+        const data = loadSigilDirectory('./test/fixtures/tables', { namespace: true });
+        assert.deepStrictEqual(data.lists.fantasy.names, ['Aldric', 'Brenna']);
+        assert.deepStrictEqual(data.lists.fantasy.monsters, ['goblin', 'orc']);
+        assert.deepStrictEqual(data.lists.scifi.names, ['Unit-7', 'Vex']);
+        assert.deepStrictEqual(data.lists.colors, ['red', 'blue']);
+        assert.deepStrictEqual(data.lists.scifi.colors, ['chrome']);
+    });
+
+    it('should report conflicting definitions with both file paths', () => {
+        assert.throws(() => loadSigilDirectory('./test/fixtures/conflict'),
+            /Table "loot" is a list in "a\.yaml" but a group in "b\.yaml"/);
+    });
+
+    it('should build a table set from in-memory files', () => {
+        const data = createSigilDataFromFiles({
+            'horror/creatures.yaml': 'creatures:\n  - ghoul\n',
+            'broken.yaml': 'fine:\n  - yes\n'
+        }, { namespace: true });
+        assert.deepStrictEqual(data.lists.horror.creatures, ['ghoul']);
+        assert.throws(() => createSigilDataFromFiles({ 'bad.yaml': 'x: [unclosed\n' }), /bad\.yaml/);
+    });
+});
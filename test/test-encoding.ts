@@ -0,0 +1,52 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import * as fs from 'fs';
+import { decodeSigilFile, encodeSigilFile } from '../src/encoding';
+import { loadSingleFile, readSigilFile } from '../src/yaml-loader';
+
+const fixture = (name: string) => `test/fixtures/encodings/${name}`;
+const text = 'menu:\n  - café au lait\n  - crème brûlée ^2\n  - “naïve” soufflé — €5\n';
+
+describe('SIGIL File Encodings', () => {
+    it('should detect each encoding and decode the same text', () => {
+        const expected: [string, string, boolean, string][] = [
+            ['utf-8.yaml', 'utf-8', false, 'lf'],
+            ['utf-8-bom.yaml', 'utf-8', true, 'crlf'],
+            ['utf-16le.yaml', 'utf-16le', true, 'lf'],
+            ['utf-16be.yaml', 'utf-16be', true, 'lf'],
+            ['utf-16le-no-bom.yaml', 'utf-16le', false, 'lf'],
+            ['windows-1252.yaml', 'windows-1252', false, 'crlf']
+        ];
+        for (const [name, encoding, bom, lineEnding] of expected) {
+            assert.deepStrictEqual(readSigilFile(fixture(name)), { text, encoding, bom, lineEnding, replaced: 0 }, name);
+        }
+    });
+
+    it('should replace invalid bytes and count them', () => {
+        const file = readSigilFile(fixture('invalid-utf-8.yaml'));
+        assert.strictEqual(file.encoding, 'utf-8');
+        assert.strictEqual(file.replaced, 2);
+        assert.strictEqual(file.text.split('\n')[3], '  - broken \uFFFD\uFFFD tea');
+        const written = decodeSigilFile(new TextEncoder().encode('a: \uFFFD\n'));
+        assert.strictEqual(written.replaced, 0);
+    });
+
+    it('should write text back the way it was read', () => {
+        for (const name of fs.readdirSync('test/fixtures/encodings').filter(file => !file.startsWith('invalid'))) {
+            const original = fs.readFileSync(fixture(name));
+            const file = decodeSigilFile(original);
+            assert.deepStrictEqual(Buffer.from(encodeSigilFile(file.text, file)), original, name);
+        }
+    });
+
+    it('should encode as UTF-8 with LF by default and mark unmappable Windows-1252 characters', () => {
+        assert.deepStrictEqual([...encodeSigilFile('é\r\n')], [0xC3, 0xA9, 0x0A]);
+        assert.deepStrictEqual([...encodeSigilFile('€ ☃', { encoding: 'windows-1252' })], [0x80, 0x20, 0x3F]);
+    });
+
+    it('should load tables from files in any encoding', () => {
+        for (const name of ['utf-8-bom.yaml', 'utf-16be.yaml', 'windows-1252.yaml']) {
+            assert.deepStrictEqual(loadSingleFile(fixture(name)).lists.menu, ['café au lait', 'crème brûlée ^2', '“naïve” soufflé — €5']);
+        }
+    });
+});
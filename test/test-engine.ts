@@ -18,4 +18,44 @@ describe('SIGIL Template Engine', () => {
             engine = new SigilEngine(testData.lists);
         }
     };
+
+    it('should generate the same results once entries are cached', () => {
+        setupData();
+        const templates = [
+            ...Object.keys(testData.templates).map(name => `[templates.${name}]`),
+            '[device.capitalize] and {a} [creature*{1-3}]',
+            '[2d6->n] [if n > 6 then [weapon] else [armor?]]'
+        ];
+        const data = { ...testData.lists, templates: testData.templates };
+        const warm = new SigilEngine(data);
+        for (let round = 0; round < 3; round++) {
+            for (const [i, template] of templates.entries()) {
+                const seed = `golden-${round}-${i}`;
+                // A fresh engine parses everything from scratch
+                assert.strictEqual(warm.generateWithSeed(template, seed).text, new SigilEngine(data).generateWithSeed(template, seed).text);
+            }
+        }
+    });
+
+    it('should generate the same results after precompile', () => {
+        setupData();
+        const data = { ...testData.lists, templates: testData.templates };
+        const precompiled = new SigilEngine(data);
+        precompiled.precompile();
+        for (const [i, name] of Object.keys(testData.templates).entries()) {
+            const seed = `precompiled-${i}`;
+            assert.strictEqual(precompiled.generateWithSeed(`[templates.${name}]`, seed).text,
+                new SigilEngine(data).generateWithSeed(`[templates.${name}]`, seed).text);
+        }
+    });
+
+    it('should see changed data once precompiled again', () => {
+        const data: { [table: string]: string[] } = { metal: ['iron'] };
+        const precompiled = new SigilEngine(data);
+        precompiled.precompile();
+        data.metal = ['gold'];
+        assert.strictEqual(precompiled.generate('[metal]'), 'iron');
+        precompiled.precompile();
+        assert.strictEqual(precompiled.generate('[metal]'), 'gold');
+    });
 });
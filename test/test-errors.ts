@@ -0,0 +1,122 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseYamlContent, loadSigilData, loadSigilDirectory } from '../src/yaml-loader';
+import { SigilError, ParseError, ResolveError, RecursionError, DistinctBatchError } from '../src/errors';
+
+// Runs fn and returns what it threw
+function caught(fn: () => unknown): any {
+    try {
+        fn();
+    } catch (error) {
+        return error;
+    }
+    assert.fail('Expected an error to be thrown');
+}
+
+describe('SIGIL Typed Errors', () => {
+    describe('ParseError', () => {
+        it('should report invalid weights with line, column and table', () => {
+            const error = caught(() => parseYamlContent('monsters:\n  - goblin ^2\n  - orc ^0\n'));
+            assert.ok(error instanceof ParseError);
+            assert.ok(error instanceof SigilError);
+            assert.strictEqual(error.code, 'invalid_weight');
+            assert.strictEqual(error.line, 3);
+            assert.strictEqual(error.column, 5);
+            assert.strictEqual(error.table, 'monsters');
+            assert.strictEqual(error.description, 'Invalid weight ^0 on "orc": weights must be greater than zero');
+            assert.strictEqual(error.message, 'Invalid weight ^0 on "orc": weights must be greater than zero (at line 3, column 5)');
+        });
+
+        it('should report YAML syntax errors with a position', () => {
+            const error = caught(() => parseYamlContent('monsters:\n  - goblin\n bad: [\n'));
+            assert.ok(error instanceof ParseError);
+            assert.strictEqual(error.code, 'yaml_syntax');
+            assert.strictEqual(typeof error.line, 'number');
+            assert.ok(!error.description.includes('\n'), 'Description should be a single line');
+        });
+
+        it('should report invalid roll tables', () => {
+            const error = caught(() => parseYamlContent('encounters:\n  1-3: wolves\n  5-6: bandits\n'));
+            assert.strictEqual(error.code, 'invalid_roll_table');
+            assert.strictEqual(error.table, 'encounters');
+            assert.strictEqual(error.line, 2);
+        });
+
+        it('should name the file when loading from disk', () => {
+            const error = caught(() => loadSigilData(['./test/fixtures/does-not-exist.yaml']));
+            assert.ok(error instanceof ParseError);
+            assert.strictEqual(error.code, 'file_read');
+            assert.strictEqual(error.file, './test/fixtures/does-not-exist.yaml');
+        });
+
+        it('should report directory conflicts with both files', () => {
+            const error = caught(() => loadSigilDirectory('./test/fixtures/conflict'));
+            assert.strictEqual(error.code, 'table_conflict');
+            assert.strictEqual(error.table, 'loot');
+            assert.match(error.message, /a\.yaml.*b\.yaml/);
+        });
+    });
+
+    describe('ResolveError', () => {
+        const engine = new SigilEngine({
+            colors: ['red'],
+            group: { inner: ['x'] },
+            encounters: { '1-3': 'wolves', '4-6': 'bandits' }
+        }, { errorMode: 'strict' });
+
+        const cases: [string, () => unknown, string][] = [
+            ['unknown tables', () => engine.generate('[missing]'), 'unknown_table'],
+            ['groups used as lists', () => engine.generate('[group]'), 'not_a_list'],
+            ['bad expressions', () => engine.generate('[= 1 / 0]'), 'invalid_expression'],
+            ['undefined condition variables', () => engine.generate('[if hp > 3 then a]'), 'undefined_variable'],
+            ['rolls outside a roll table', () => engine.rollOn('encounters', 9), 'roll_out_of_range']
+        ];
+
+        for (const [name, fn, code] of cases) {
+            it(`should use code ${code} for ${name}`, () => {
+                const error = caught(fn);
+                assert.ok(error instanceof ResolveError);
+                assert.strictEqual(error.code, code);
+            });
+        }
+
+        it('should carry the table path', () => {
+            const error = caught(() => engine.generate('[missing]'));
+            assert.strictEqual(error.table, 'missing');
+            assert.strictEqual(error.message, 'Unknown table or variable "missing"');
+        });
+
+        it('should make RecursionError and DistinctBatchError resolve errors', () => {
+            const loop = new SigilEngine({ loop: ['[loop]'] }, { errorMode: 'strict' });
+            const recursion = caught(() => loop.generate('[loop]'));
+            assert.ok(recursion instanceof RecursionError);
+            assert.ok(recursion instanceof ResolveError);
+            assert.strictEqual(recursion.code, 'recursion_limit');
+            assert.strictEqual(recursion.table, 'loop');
+
+            const distinct = caught(() => engine.generateBatch('[colors]', 3, { distinct: true }));
+            assert.ok(distinct instanceof DistinctBatchError);
+            assert.strictEqual(distinct.code, 'distinct_exhausted');
+        });
+    });
+
+    describe('serialisation', () => {
+        it('should serialise to JSON with code and position', () => {
+            const error = caught(() => parseYamlContent('monsters:\n  - orc ^0\n'));
+            assert.deepStrictEqual(JSON.parse(JSON.stringify(error)), {
+                name: 'ParseError',
+                code: 'invalid_weight',
+                message: 'Invalid weight ^0 on "orc": weights must be greater than zero',
+                line: 2,
+                column: 5,
+                table: 'monsters'
+            });
+        });
+
+        it('should format file positions as file:line:column', () => {
+            const error = new ParseError('invalid_weight', 'Bad weight', { file: 'monsters.yaml', line: 3, column: 5 });
+            assert.strictEqual(error.message, 'Bad weight (at monsters.yaml:3:5)');
+        });
+    });
+});
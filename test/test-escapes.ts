@@ -0,0 +1,93 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createSigilData, parseYamlContent } from '../src/yaml-loader';
+import { formatSigil } from '../src/formatter';
+import { tokenize } from '../src/tokenizer';
+import { validateSigil } from '../src/validator';
+import { ResolveError } from '../src/errors';
+
+// Plain and single-quoted YAML keep backslashes for SIGIL to read
+const source = `inn:
+  - The \\[Red\\] Dragon
+path:
+  - 'C:\\\\Games\\\\[game]'
+note:
+  - first\\nsecond\\tthird
+door:
+  - Room \\#4 \\{closed\\}
+game:
+  - doom
+`;
+
+describe('SIGIL Escapes', () => {
+    it('should output escaped characters literally', () => {
+        const engine = new SigilEngine(createSigilData([source]).lists, { errorMode: 'strict' });
+        assert.strictEqual(engine.generate('[inn]'), 'The [Red] Dragon');
+        assert.strictEqual(engine.generate('[path]'), 'C:\\Games\\doom');
+        assert.strictEqual(engine.generate('[note]'), 'first\nsecond\tthird');
+        assert.strictEqual(engine.generate('[door]'), 'Room #4 {closed}');
+        assert.strictEqual(engine.generate('a \\] b \\\\n'), 'a ] b \\n');
+    });
+
+    it('should keep unescaped text in the structured result', () => {
+        const engine = new SigilEngine({ sign: ['The \\[Red\\] Dragon'] });
+        const root = engine.generateStructured('\\{[sign]\\}');
+        assert.strictEqual(root.text, '{The [Red] Dragon}');
+        assert.strictEqual(root.children[0].text, 'The [Red] Dragon');
+    });
+
+    it('should report a stray bracket with its column', () => {
+        const strict = new SigilEngine({ door: ['oak'] }, { errorMode: 'strict' });
+        for (const [template, description] of [
+            ['an [door] ] here', 'Unmatched "]" at column 11 in "an [door] ] here"'],
+            ['a [door', 'Unterminated "[" at column 3 in "a [door"'],
+            ['{a|b', 'Unterminated "{" at column 1 in "{a|b"'],
+            ['x } y', 'Unmatched "}" at column 3 in "x } y"']
+        ]) {
+            assert.throws(() => strict.generate(template), (error: unknown) => {
+                assert.ok(error instanceof ResolveError);
+                assert.strictEqual(error.code, 'unbalanced_sigil');
+                assert.strictEqual(error.description, description);
+                return true;
+            });
+        }
+        assert.strictEqual(strict.generate('a \\[door'), 'a [door');
+    });
+
+    it('should report stray brackets inside table entries', () => {
+        const strict = new SigilEngine({ sign: ['closed ]'] }, { errorMode: 'strict' });
+        assert.throws(() => strict.generate('[sign]'), (error: unknown) => {
+            assert.ok(error instanceof ResolveError);
+            assert.strictEqual(error.column, 8);
+            assert.strictEqual(error.table, 'sign');
+            return true;
+        });
+    });
+
+    it('should keep stray brackets as text in graceful mode', () => {
+        const silent = new SigilEngine({ door: ['oak'] }, { errorMode: 'silent' });
+        assert.strictEqual(silent.generate('[door] ] here'), 'oak ] here');
+    });
+
+    it('should keep escapes exactly through formatting', () => {
+        const formatted = formatSigil(source);
+        assert.deepStrictEqual(parseYamlContent(formatted), parseYamlContent(source));
+        assert.ok(formatted.includes('The \\[Red\\] Dragon'));
+        assert.ok(formatted.includes('first\\nsecond\\tthird'));
+        assert.strictEqual(formatSigil(formatted), formatted);
+    });
+
+    it('should tokenize escapes as text and stray brackets as errors', () => {
+        const tokens = tokenize('x:\n  - a \\[b\\] ] c\n').filter(token => token.kind === 'entry-text' || token.kind === 'error');
+        assert.deepStrictEqual(tokens.map(token => [token.kind, token.text]), [
+            ['entry-text', 'a \\[b\\] '],
+            ['error', ']'],
+            ['entry-text', ' c']
+        ]);
+    });
+
+    it('should validate escaped brackets without errors', () => {
+        assert.deepStrictEqual(validateSigil(source), []);
+    });
+});
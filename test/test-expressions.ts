@@ -0,0 +1,57 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { evaluateExpression } from '../src/expression';
+
+const noVars = () => undefined;
+
+describe('SIGIL Arithmetic Expressions', () => {
+    describe('evaluateExpression', () => {
+        it('should respect precedence, parentheses and unary minus', () => {
+            assert.strictEqual(evaluateExpression('1 + 2 * 3', noVars), 7);
+            assert.strictEqual(evaluateExpression('(1 + 2) * 3', noVars), 9);
+            assert.strictEqual(evaluateExpression('-4 + 10 / 4', noVars), -1.5);
+            assert.strictEqual(evaluateExpression('7 % 3 - -1', noVars), 2);
+        });
+
+        it('should read numeric variables', () => {
+            const vars: { [name: string]: string } = { str: '15', half: '0.5' };
+            assert.strictEqual(evaluateExpression('str / 2 - 5', name => vars[name]), 2.5);
+            assert.strictEqual(evaluateExpression('str*half', name => vars[name]), 7.5);
+        });
+
+        it('should name the expression in errors', () => {
+            assert.throws(() => evaluateExpression('10 / (5 - 5)', noVars), /Division by zero in expression "10 \/ \(5 - 5\)"/);
+            assert.throws(() => evaluateExpression('gold * 2', noVars), /Undefined variable "gold" in expression "gold \* 2"/);
+            assert.throws(() => evaluateExpression('x + 1', () => 'sword'), /Variable "x" is not a number/);
+            assert.throws(() => evaluateExpression('2 +', noVars), /Unexpected end/);
+            assert.throws(() => evaluateExpression('2 $ 3', noVars), /Non-numeric operand "\$"/);
+        });
+    });
+
+    describe('[= ...] sigil', () => {
+        it('should compute with dice results and captured variables', () => {
+            const engine = new SigilEngine({ stat: ['14'] });
+            const [roll, doubled] = engine.generate('[2d6->gold] [= gold * 2]').split(' ').map(Number);
+            assert.strictEqual(doubled, roll * 2);
+            assert.strictEqual(engine.generate('[stat->str][= str / 2 - 5]'), '142');
+            assert.strictEqual(engine.generate('[= [stat] + 1]'), '15');
+            assert.strictEqual(engine.generate('[= 0.1 + 0.2]'), '0.3');
+        });
+
+        it('should format results with modifiers', () => {
+            const engine = new SigilEngine({});
+            assert.strictEqual(engine.generate('[= 7 / 2.floor]'), '3');
+            assert.strictEqual(engine.generate('[= 7 / 2.ceil]'), '4');
+            assert.strictEqual(engine.generate('[= 10 / 4.round]'), '3');
+            assert.strictEqual(engine.generate('[= str / 2 - 5.floor]', { vars: { str: '13' } }), '1');
+        });
+
+        it('should raise generation errors in strict mode and degrade gracefully otherwise', () => {
+            const strict = new SigilEngine({ name: ['Vex'] }, { errorMode: 'strict' });
+            assert.throws(() => strict.generate('[= 1 / 0]'), /Division by zero in expression "1 \/ 0"/);
+            assert.throws(() => strict.generate('[= [name] * 2]'), /from "\[name\] \* 2"/);
+            assert.strictEqual(new SigilEngine({}).generate('gold: [= 1 / 0]'), 'gold: ');
+        });
+    });
+});
@@ -0,0 +1,142 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { createSigilData, createSigilDataFromFiles, createLayeredSigilData, loadSigilData } from '../src/yaml-loader';
+import { validateSigil } from '../src/validator';
+import { ParseError } from '../src/errors';
+
+const base = 'names:\n  - Durin\n  - Balin\nloot:\n  "@count": 2\n  "@entries":\n    - gold\n';
+
+// Runs fn and returns what it threw
+function caught(fn: () => unknown): any {
+    try {
+        fn();
+    } catch (error) {
+        return error;
+    }
+    assert.fail('Expected an error to be thrown');
+}
+
+describe('SIGIL Extend and Include', () => {
+    describe('@extend', () => {
+        it('should append entries with their weights to the base table', () => {
+            const data = createSigilDataFromFiles({
+                'base.yaml': base,
+                'north.yaml': '"@extend":\n  names:\n    - Thrain ^2\n'
+            });
+            assert.deepStrictEqual(data.lists.names, ['Durin', 'Balin', 'Thrain ^2']);
+            assert.strictEqual(data.lists['@extend'], undefined);
+        });
+
+        it('should work whichever file is loaded first', () => {
+            const data = createSigilDataFromFiles({
+                'a-north.yaml': '"@extend":\n  names:\n    - Thrain\n',
+                'z-base.yaml': base
+            });
+            assert.deepStrictEqual(data.lists.names, ['Durin', 'Balin', 'Thrain']);
+            assert.deepStrictEqual(createSigilData([
+                '"@extend":\n  names:\n    - Thrain\n',
+                base
+            ]).lists.names, ['Durin', 'Balin', 'Thrain']);
+        });
+
+        it('should extend nested, dotted and "@entries" tables, keeping settings', () => {
+            const data = createSigilDataFromFiles({
+                'base.yaml': base + 'fantasy:\n  elves:\n    - Legolas\n',
+                'more.yaml': '"@extend":\n  loot:\n    - silver\n  fantasy:\n    elves:\n      - Arwen\n  fantasy.elves:\n    - Elrond\n'
+            });
+            assert.deepStrictEqual(data.lists.loot, { '@count': 2, '@entries': ['gold', 'silver'] });
+            assert.deepStrictEqual(data.lists.fantasy.elves, ['Legolas', 'Arwen', 'Elrond']);
+        });
+
+        it('should reject extending a table that does not exist', () => {
+            const error = caught(() => createSigilDataFromFiles({ 'base.yaml': base, 'north.yaml': '"@extend":\n  giants:\n    - Ymir\n' }));
+            assert.ok(error instanceof ParseError);
+            assert.strictEqual(error.code, 'invalid_extend');
+            assert.strictEqual(error.file, 'north.yaml');
+            assert.strictEqual(error.table, 'giants');
+        });
+
+        it('should reject extending roll tables', () => {
+            const error = caught(() => createSigilDataFromFiles({
+                'base.yaml': 'encounters:\n  1-3: wolves\n  4-6: bandits\n',
+                'more.yaml': '"@extend":\n  encounters:\n    - trolls\n'
+            }));
+            assert.strictEqual(error.code, 'invalid_extend');
+            assert.match(error.message, /roll table "encounters"/);
+        });
+
+        it('should extend tables from earlier layers', () => {
+            const data = createLayeredSigilData([
+                { name: 'defaults', files: { 'base.yaml': base } },
+                { name: 'user', files: { 'north.yaml': '"@extend":\n  names:\n    - Thrain\n' } }
+            ]);
+            assert.deepStrictEqual(data.lists.names, ['Durin', 'Balin', 'Thrain']);
+            assert.deepStrictEqual(data.origin('names'), {
+                layer: 'defaults',
+                files: ['base.yaml'],
+                shadowed: [],
+                extendedBy: [{ layer: 'user', files: ['north.yaml'] }]
+            });
+        });
+    });
+
+    describe('@include', () => {
+        it('should load included files first, once each', () => {
+            const data = createSigilDataFromFiles({
+                'settings/north.yaml': '"@include": ../base/names.yaml\nprefer:\n  - "[names]"\n',
+                'settings/south.yaml': '"@include":\n  - ../base/names.yaml\n',
+                'base/names.yaml': base
+            });
+            assert.deepStrictEqual(data.lists.names, ['Durin', 'Balin']);
+            assert.deepStrictEqual(data.lists.prefer, ['[names]']);
+        });
+
+        it('should report missing included files', () => {
+            const error = caught(() => createSigilDataFromFiles({ 'north.yaml': '"@include": base.yaml\n' }));
+            assert.strictEqual(error.code, 'invalid_include');
+            assert.strictEqual(error.file, 'north.yaml');
+        });
+
+        it('should report circular includes with the chain', () => {
+            const error = caught(() => createSigilDataFromFiles({
+                'a.yaml': '"@include": b.yaml\n',
+                'b.yaml': '"@include": c.yaml\n',
+                'c.yaml': '"@include": a.yaml\n'
+            }));
+            assert.ok(error instanceof ParseError);
+            assert.strictEqual(error.code, 'include_cycle');
+            assert.strictEqual(error.description, 'Circular include: a.yaml -> b.yaml -> c.yaml -> a.yaml');
+        });
+
+        it('should read included files from disk', () => {
+            const data = loadSigilData(['./test/fixtures/includes/northern.yaml']);
+            assert.deepStrictEqual(data.lists.dwarf_names, ['Durin', 'Balin', 'Thrain ^2']);
+
+            const both = loadSigilData(['./test/fixtures/includes/fantasy-names.yaml', './test/fixtures/includes/northern.yaml']);
+            assert.deepStrictEqual(both.lists.dwarf_names, ['Durin', 'Balin', 'Thrain ^2'], 'A file given and included is loaded once');
+        });
+    });
+
+    describe('validation', () => {
+        it('should flag extending a table defined nowhere', () => {
+            const issues = validateSigil('"@extend":\n  giants:\n    - Ymir\n', { data: { names: ['Durin'] } });
+            assert.strictEqual(issues.length, 1);
+            assert.strictEqual(issues[0].severity, 'error');
+            assert.strictEqual(issues[0].message, 'Cannot extend undefined table "giants"');
+            assert.strictEqual(issues[0].line, 2);
+            assert.strictEqual(issues[0].column, 3);
+        });
+
+        it('should accept extensions of loaded tables and check their entries', () => {
+            const issues = validateSigil('"@extend":\n  names:\n    - Thrain ^0\n', { data: { names: ['Durin'] } });
+            assert.strictEqual(issues.length, 1);
+            assert.match(issues[0].message, /Invalid weight/);
+            assert.strictEqual(issues[0].table, 'names');
+        });
+
+        it('should only warn about unknown tables when the file includes others', () => {
+            const issues = validateSigil('"@include": base.yaml\n"@extend":\n  names:\n    - "[title] Thrain"\n');
+            assert.deepStrictEqual(issues.map(issue => issue.severity), ['warning', 'warning']);
+        });
+    });
+});
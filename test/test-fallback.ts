@@ -0,0 +1,95 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { validateSigil } from '../src/validator';
+import { ResolveError } from '../src/errors';
+
+const data = {
+    spices: ['saffron'],
+    herbs: ['thyme'],
+    nothing: [''],
+    dish: ['stew with [ghost_table]']
+};
+
+describe('SIGIL Fallbacks', () => {
+    describe('?? references', () => {
+        const engine = new SigilEngine(data, { errorMode: 'strict' });
+
+        it('should use the fallback text for missing tables', () => {
+            assert.strictEqual(engine.generate('[exotic-spices ?? "strange spices"]'), 'strange spices');
+            assert.strictEqual(engine.generate("[exotic-spices ?? 'odd spices']"), 'odd spices');
+        });
+
+        it('should keep the table result when it exists', () => {
+            assert.strictEqual(engine.generate('[spices ?? "strange spices"]'), 'saffron');
+        });
+
+        it('should use the fallback for empty results', () => {
+            assert.strictEqual(engine.generate('[nothing ?? "plain water"]'), 'plain water');
+        });
+
+        it('should fall back to other tables, in a chain', () => {
+            assert.strictEqual(engine.generate('[exotic ?? herbs]'), 'thyme');
+            assert.strictEqual(engine.generate('[exotic ?? rare ?? "salt"]'), 'salt');
+            assert.strictEqual(engine.generate('[exotic ?? "a pinch of [herbs]"]'), 'a pinch of thyme');
+        });
+
+        it('should work with modifiers and captures', () => {
+            assert.strictEqual(engine.generate('[exotic.capitalize ?? "salt"]'), 'salt');
+            assert.strictEqual(engine.generate('[exotic ?? "salt"->seasoning] and [seasoning]'), 'salt and salt');
+        });
+
+        it('should not cover missing tables inside the chosen entry', () => {
+            assert.throws(() => engine.generate('[dish ?? "soup"]'), (error: unknown) =>
+                error instanceof ResolveError && error.code === 'unknown_table' && error.table === 'ghost_table');
+        });
+    });
+
+    describe('missingTable option', () => {
+        it('should throw in strict mode by default', () => {
+            const engine = new SigilEngine(data, { errorMode: 'strict' });
+            assert.throws(() => engine.generate('[exotic-spices]'), /Unknown table or variable "exotic-spices"/);
+        });
+
+        it('should emit placeholders', () => {
+            const engine = new SigilEngine(data, { errorMode: 'strict', missingTable: 'placeholder' });
+            assert.strictEqual(engine.generate('A pinch of [exotic-spices]'), 'A pinch of «exotic-spices»');
+            assert.strictEqual(engine.generate('[exotic-spices ?? "salt"]'), 'salt', 'A fallback wins over the placeholder');
+        });
+
+        it('should emit empty text', () => {
+            const engine = new SigilEngine(data, { errorMode: 'strict', missingTable: 'empty' });
+            assert.strictEqual(engine.generate('A pinch of [exotic-spices]'), 'A pinch of ');
+        });
+
+        it('should still report groups used as lists', () => {
+            const engine = new SigilEngine({ group: { inner: ['x'] } }, { errorMode: 'strict', missingTable: 'empty' });
+            assert.throws(() => engine.generate('[group]'), /not a list/);
+        });
+    });
+
+    describe('structured output', () => {
+        it('should mark missing and empty tables differently', () => {
+            const engine = new SigilEngine(data, { missingTable: 'placeholder' });
+            const tree = engine.generateStructured('[exotic][nothing][spices]');
+            assert.deepStrictEqual(tree.children.map(node => [node.name, node.status, node.text]), [
+                ['exotic', 'missing', '«exotic»'],
+                ['nothing', 'empty', ''],
+                ['spices', undefined, 'saffron']
+            ]);
+        });
+
+        it('should show the fallback after the missing table', () => {
+            const engine = new SigilEngine(data);
+            const tree = engine.generateStructured('[exotic ?? herbs]');
+            assert.deepStrictEqual(tree.children.map(node => [node.name, node.status]), [['exotic', 'missing'], ['herbs', undefined]]);
+            assert.strictEqual(tree.text, 'thyme');
+        });
+    });
+
+    it('should not flag references covered by a fallback in validation', () => {
+        const issues = validateSigil('dish:\n  - "[exotic ?? \\"salt\\"] and [missing]"\n');
+        assert.strictEqual(issues.length, 1);
+        assert.match(issues[0].message, /undefined table "missing"/);
+    });
+});
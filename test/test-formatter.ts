@@ -0,0 +1,140 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { formatSigil } from '../src/formatter';
+import { parseYamlContent } from '../src/yaml-loader';
+import { ParseError } from '../src/errors';
+
+// Loaded tables, ignoring the spacing before weights
+const tablesOf = (source: string) => JSON.parse(JSON.stringify(parseYamlContent(source)).replace(/\s+\^/g, ' ^'));
+
+describe('SIGIL Formatter', () => {
+    it('should indent, normalise headers and line up weights', () => {
+        const source = [
+            '"monsters" :',
+            '- goblin^3',
+            '-   "[size] dragon   ^1"',
+            '- orc',
+            'loot:',
+            '      - gold    ^10   # common',
+            '      - ruby ^1',
+            ''
+        ].join('\n');
+        assert.strictEqual(formatSigil(source), [
+            'monsters:',
+            '  - goblin ^3',
+            '  - "[size] dragon   ^1"',
+            '  - orc',
+            'loot:',
+            '  - gold ^10   # common',
+            '  - ruby ^1',
+            ''
+        ].join('\n'));
+    });
+
+    it('should leave quoted weighted entries exactly as written', () => {
+        const source = 'weapons:\n  - "axe  ^3"\n  - \'bow ^2\'   # ranged\n  - sword ^1\n  - "[metal] spear ^10"\n';
+        const formatted = formatSigil(source);
+        assert.strictEqual(formatted, source);
+        assert.deepStrictEqual(parseYamlContent(formatted), parseYamlContent(source));
+    });
+
+    it('should keep comments and single blank lines between groups', () => {
+        const source = '# Monsters\n\n\n\nmonsters:   # by size\n    # small ones\n    - goblin\n\n    - ogre\n\n\n# Treasure\nloot: [gold, gems]\n\n';
+        assert.strictEqual(formatSigil(source),
+            '# Monsters\n\nmonsters: # by size\n  # small ones\n  - goblin\n\n  - ogre\n\n# Treasure\nloot: [gold, gems]\n');
+    });
+
+    it('should quote settings and nest groups and roll tables', () => {
+        const source = [
+            'treasure:',
+            "    '@count': 1d4",
+            '    "@entries":',
+            '    - gold ^2',
+            '    - silver',
+            'fantasy:',
+            '   elves:',
+            '   - Aerin',
+            'wandering:',
+            '  "1-3":   wolves',
+            '  4-6: bandits'
+        ].join('\n');
+        assert.strictEqual(formatSigil(source), [
+            'treasure:',
+            '  "@count": 1d4',
+            '  "@entries":',
+            '    - gold ^2',
+            '    - silver',
+            'fantasy:',
+            '  elves:',
+            '    - Aerin',
+            'wandering:',
+            '  "1-3": wolves',
+            '  4-6: bandits',
+            ''
+        ].join('\n'));
+    });
+
+    it('should keep block scalars and multi-line strings intact', () => {
+        const source = 'intro:\n- |\n    A quiet [place].\n\n    Nothing stirs.\n- "a long\n\n     entry"\nnext: x\n';
+        const formatted = formatSigil(source);
+        assert.deepStrictEqual(parseYamlContent(formatted), parseYamlContent(source));
+        assert.strictEqual(formatted, 'intro:\n  - |\n    A quiet [place].\n\n    Nothing stirs.\n  - "a long\n\n    entry"\nnext: x\n');
+    });
+
+    it('should sort tables alphabetically when asked, settings and roll tables in place', () => {
+        const source = [
+            '"@include": base.yaml',
+            '',
+            '# Weapons',
+            'weapons:',
+            '  - sword',
+            '',
+            'armor:',
+            '  zeta: [a]',
+            '  alpha: [b]',
+            '',
+            'Beasts:',
+            '  10-12: dragon',
+            '  1-9: rat',
+            ''
+        ].join('\n');
+        const sorted = formatSigil(source, { order: 'alphabetical' });
+        assert.strictEqual(sorted, [
+            '"@include": base.yaml',
+            '',
+            'armor:',
+            '  alpha: [b]',
+            '  zeta: [a]',
+            '',
+            'Beasts:',
+            '  10-12: dragon',
+            '  1-9: rat',
+            '',
+            '# Weapons',
+            'weapons:',
+            '  - sword',
+            ''
+        ].join('\n'));
+        assert.strictEqual(formatSigil(source), source, 'Preserve order is the default');
+    });
+
+    it('should be idempotent', () => {
+        const sources = [
+            'a:\n- x ^2\n-    yy\n-  "z ^4"  # note\nb:\n  c:\n  - 1\n',
+            '# header\nz: [1]\n\n# about a\na:\n  "@count": 2\n  "@entries": [q, r]\n# trailing\n',
+            'text: >\n    folded\n    lines\n\n\nlist:\n  - - nested\n    - pair\n'
+        ];
+        for (const source of sources) {
+            for (const order of ['preserve', 'alphabetical'] as const) {
+                const once = formatSigil(source, { order });
+                assert.strictEqual(formatSigil(once, { order }), once);
+                assert.deepStrictEqual(tablesOf(once), tablesOf(source));
+            }
+        }
+    });
+
+    it('should refuse files that do not load', () => {
+        assert.throws(() => formatSigil('monsters:\n  - orc ^0\n'), (error: unknown) => error instanceof ParseError && error.code === 'invalid_weight');
+        assert.throws(() => formatSigil('monsters: [orc\n'), ParseError);
+    });
+});
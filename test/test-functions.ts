@@ -0,0 +1,99 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import type { FunctionContext } from '../src/template-engine';
+import { parseCompleteTemplate } from '../src/template-parser';
+import { FunctionError, ResolveError } from '../src/errors';
+import { validateSigil } from '../src/validator';
+
+const data = {
+    monster: ['orc'],
+    era: ['1999'],
+    scene: ['[fn:shout([monster], "!")]']
+};
+
+function engineWithShout(options = {}): SigilEngine {
+    const engine = new SigilEngine(data, options);
+    engine.registerFunction('shout', ([text, mark = '']) => text.toUpperCase() + mark);
+    return engine;
+}
+
+describe('SIGIL Functions', () => {
+    it('should parse quoted and template arguments', () => {
+        const node = parseCompleteTemplate('[fn:date("a, b", [era], \'x\').capitalize]');
+        assert.ok(node.type === 'function');
+        assert.strictEqual(node.name, 'date');
+        assert.deepStrictEqual(node.modifiers, ['capitalize']);
+        assert.deepStrictEqual(node.args[0], { kind: 'literal', value: 'a, b' });
+        assert.ok(node.args[1].kind === 'template' && node.args[1].node.type === 'table' && node.args[1].node.tablePath === 'era');
+        assert.deepStrictEqual(node.args[2], { kind: 'literal', value: 'x' });
+        assert.deepStrictEqual(parseCompleteTemplate('[fn:now()]'), { type: 'function', name: 'now', args: [], modifiers: undefined });
+    });
+
+    it('should call functions with resolved arguments', () => {
+        const engine = engineWithShout();
+        assert.strictEqual(engine.generate('[scene]'), 'ORC!');
+        assert.strictEqual(engine.generate('[fn:shout("[monster]")]'), '[MONSTER]', 'quoted arguments are not resolved');
+        assert.strictEqual(engine.generate('[fn:shout(a [monster] {x|x})]'), 'A ORC X');
+        assert.strictEqual(engine.generate('[fn:shout([monster]).capitalize] [fn:shout([monster])->loud] [loud]'), 'Orc ORC ORC');
+    });
+
+    it('should pass the per-call context', () => {
+        const engine = engineWithShout({ seed: 3 });
+        const seen: FunctionContext[] = [];
+        engine.registerFunction('spy', (_args, context) => {
+            seen.push(context);
+            return Math.floor(context.random() * 1000);
+        });
+
+        const first = engine.generateWithSeed('[monster->m] [fn:spy()]', 'same').text;
+        assert.strictEqual(engine.generateWithSeed('[monster->m] [fn:spy()]', 'same').text, first, 'seeded results repeat');
+        assert.deepStrictEqual(seen[0].vars, { m: 'orc' });
+        assert.ok(Object.isFrozen(seen[0].vars));
+        assert.notStrictEqual(seen[0], seen[1], 'every call gets its own context');
+        assert.strictEqual(engine.analyze('[fn:spy()]').exact, false, 'random draws cannot be enumerated');
+
+        const tables = new SigilEngine({ inner: ['[fn:table()]'] });
+        tables.registerFunction('table', (_args, context) => context.table ?? '(template)');
+        assert.strictEqual(tables.generate('[fn:table()] [inner]'), '(template) inner');
+    });
+
+    it('should report function errors with the name and arguments', () => {
+        const engine = new SigilEngine(data, { errorMode: 'strict' });
+        engine.registerFunction('weather', () => {
+            throw new Error('service unavailable');
+        });
+        engine.registerFunction('later', async () => 'soon' as never);
+        assert.throws(() => engine.generate('[fn:weather("north", [era])]'), (error: unknown) => {
+            assert.ok(error instanceof FunctionError);
+            assert.strictEqual(error.code, 'function_error');
+            assert.strictEqual(error.functionName, 'weather');
+            assert.deepStrictEqual(error.args, ['north', '1999']);
+            assert.strictEqual((error.error as Error).message, 'service unavailable');
+            assert.match(error.message, /Function "weather"\("north", "1999"\) failed: service unavailable/);
+            return true;
+        });
+        assert.throws(() => engine.generate('[fn:later()]'), /returned a Promise/);
+        assert.throws(() => engine.generate('[fn:missing()]'),
+            (error: unknown) => error instanceof ResolveError && error.code === 'unknown_function');
+        assert.strictEqual(new SigilEngine(data).generate('a [fn:missing()] b'), 'a  b', 'graceful mode leaves it empty');
+    });
+
+    it('should refuse names of tables and invalid names', () => {
+        const engine = new SigilEngine(data);
+        assert.throws(() => engine.registerFunction('monster', () => ''), /same name as a table/);
+        assert.throws(() => engine.registerFunction('two words', () => ''), /Invalid function name/);
+        engine.registerFunction('rename', () => 'a');
+        engine.registerFunction('rename', () => 'b');
+        assert.strictEqual(engine.generate('[fn:rename()]'), 'b');
+    });
+
+    it('should trace calls and check references in arguments', () => {
+        const engine = engineWithShout();
+        const root = engine.generateStructured('[fn:shout([monster])]');
+        assert.deepStrictEqual(root.children.map(child => [child.type, child.name, child.text]), [['function', 'shout', 'ORC']]);
+        assert.strictEqual(root.children[0].children[0].name, 'monster');
+        assert.deepStrictEqual(engine.dependencies('[fn:shout([monster], [ghost])]').missing, ['ghost']);
+        assert.deepStrictEqual(validateSigil('a:\n  - "[fn:shout([ghost])]"\n').map(issue => issue.message), ['Reference to undefined table "ghost"']);
+    });
+});
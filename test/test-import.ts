@@ -0,0 +1,80 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { importCsv, importJson, importedTableToData, importedTableToSource } from '../src/importer';
+import { parseYamlContent } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+
+describe('SIGIL Table Import', () => {
+    describe('importCsv', () => {
+        it('should detect headers and default blank weights to 1', () => {
+            const table = importCsv('Entry,Weight\nsword,3\nbow,\n"dagger, rusty",1\n', { name: 'weapons' });
+            assert.deepStrictEqual(table.entries, [
+                { text: 'sword', weight: 3 },
+                { text: 'bow', weight: 1 },
+                { text: 'dagger, rusty', weight: 1 }
+            ]);
+            assert.deepStrictEqual(importedTableToData(table), ['sword ^3', 'bow', 'dagger, rusty']);
+        });
+
+        it('should keep quotes, brackets and line breaks inside quoted fields', () => {
+            const table = importCsv('text\n"a [metal] ""fine"" blade"\r\n"two\nlines"\n');
+            assert.deepStrictEqual(table.entries.map(e => e.text), ['a [metal] "fine" blade', 'two\nlines']);
+        });
+
+        it('should read headerless files by position', () => {
+            assert.deepStrictEqual(importCsv('goblin,2\norc\n').entries, [
+                { text: 'goblin', weight: 2 },
+                { text: 'orc', weight: 1 }
+            ]);
+            assert.deepStrictEqual(importedTableToData(importCsv('1-3,wolves\n4-5,bandits\n6,a dragon\n')),
+                { '1-3': 'wolves', '4-5': 'bandits', '6': 'a dragon' });
+        });
+
+        it('should build roll tables from min/max columns', () => {
+            const table = importCsv('min,max,result\n1,4,calm\n5,6,storm\n');
+            assert.deepStrictEqual(importedTableToData(table), { '1-4': 'calm', '5-6': 'storm' });
+        });
+
+        it('should mark tables of single numbers with their die', () => {
+            assert.deepStrictEqual(importedTableToData(importCsv('1,north\n2,south\n3,east\n')),
+                { '@die': 'd3', '1': 'north', '2': 'south', '3': 'east' });
+        });
+
+        it('should report bad rows by number', () => {
+            assert.throws(() => importCsv('text,weight\nsword,heavy\n'), /Row 2: invalid weight "heavy"/);
+            assert.throws(() => importCsv('text,weight\nsword,0\n'), /Row 2/);
+            assert.throws(() => importCsv('text\n"open\n'), /Unterminated quoted field/);
+            assert.throws(() => importCsv('range,text\n1-2,a\n,b\n'), /Entry 2 \("b"\) has no range/);
+        });
+
+        it('should honour explicit column options', () => {
+            const table = importCsv('id,label,odds\n1,elf,2\n2,dwarf,\n', { textColumn: 'label', weightColumn: 'odds' });
+            assert.deepStrictEqual(importedTableToData(table), ['elf ^2', 'dwarf']);
+        });
+    });
+
+    describe('importJson', () => {
+        it('should accept strings and entry objects', () => {
+            const table = importJson('["sword", {"text": "bow", "weight": 2}, {"entry": "axe", "weight": null}]');
+            assert.deepStrictEqual(importedTableToData(table), ['sword', 'bow ^2', 'axe']);
+            assert.deepStrictEqual(importedTableToData(importJson([{ range: '1-2', text: 'a' }, { min: 3, text: 'b' }])),
+                { '1-2': 'a', '3': 'b' });
+        });
+
+        it('should reject non-arrays and malformed JSON', () => {
+            assert.throws(() => importJson('{"a": 1}'), /JSON array/);
+            assert.throws(() => importJson('[1,'), /Invalid JSON/);
+            assert.throws(() => importJson([{ weight: 2 }]), /Item 1: missing entry text/);
+        });
+    });
+
+    describe('importedTableToSource', () => {
+        it('should write SIGIL YAML that loads back into the same table', () => {
+            const table = importCsv('text,weight\n"a [metal] sword",2\n"{red|blue} cloak",\n', { name: 'gear' });
+            const data = parseYamlContent(importedTableToSource(table));
+            assert.deepStrictEqual(data.gear, ['a [metal] sword ^2', '{red|blue} cloak']);
+            const engine = new SigilEngine({ ...data, metal: ['iron'] });
+            assert.ok(['a iron sword', 'red cloak', 'blue cloak'].includes(engine.generate('[gear]')));
+        });
+    });
+});
@@ -293,4 +293,51 @@ describe('SIGIL Template Engine - Inline and Cross-Sigil', () => {
             assert.ok(typeof result3 === 'string', 'Should handle optional markov in compound');
         });
     });
+
+    describe('Inline choice lists', () => {
+        const choiceEngine = () => new SigilEngine({ color: ['red'], size: ['huge'] }, { seed: 'choices' });
+
+        it('should resolve table references inside an alternative', () => {
+            const seen = new Set<string>();
+            const testEngine = choiceEngine();
+            for (let i = 0; i < 50; i++) {
+                seen.add(testEngine.generate('{a [color] door|a blank wall}'));
+            }
+            assert.deepStrictEqual([...seen].sort(), ['a blank wall', 'a red door']);
+        });
+
+        it('should support nested inline lists', () => {
+            const seen = new Set<string>();
+            const testEngine = choiceEngine();
+            for (let i = 0; i < 100; i++) {
+                seen.add(testEngine.generate('{{old|new} [size] ship|nothing}'));
+            }
+            assert.deepStrictEqual([...seen].sort(), ['new huge ship', 'nothing', 'old huge ship']);
+        });
+
+        it('should allow an empty alternative', () => {
+            const seen = new Set<string>();
+            const testEngine = choiceEngine();
+            for (let i = 0; i < 50; i++) {
+                seen.add(testEngine.generate('[color]{!|}'));
+            }
+            assert.deepStrictEqual([...seen].sort(), ['red', 'red!']);
+        });
+
+        it('should output escaped pipes and braces literally', () => {
+            const testEngine = choiceEngine();
+            assert.strictEqual(testEngine.generate('{left \\| right}'), 'left | right');
+            assert.strictEqual(testEngine.generate('\\{not inline\\} [color]'), '{not inline} red');
+            assert.strictEqual(testEngine.generate('{x\\}y|x\\}y}'), 'x}y');
+        });
+
+        it('should reproduce inline choices from a seed', () => {
+            const template = '{a|b|c|d} {e|f|g|h} {[color]|[size]}';
+            const first = new SigilEngine({ color: ['red'], size: ['huge'] }, { seed: 42 });
+            const second = new SigilEngine({ color: ['red'], size: ['huge'] }, { seed: 42 });
+            for (let i = 0; i < 10; i++) {
+                assert.strictEqual(first.generate(template), second.generate(template));
+            }
+        });
+    });
 });
@@ -0,0 +1,116 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createSigilData } from '../src/yaml-loader';
+import { ResolveError } from '../src/errors';
+
+const source = `loot:
+  "@tags": rare
+  "@entries":
+    - gold ^3
+    - "[gem] ring (rare)"
+gem:
+  - ruby
+  - opal
+  - "[metal] with [metal] inlay ^2"
+wandering:
+  "@die": 2d6
+  2-6: "[loot]"
+  7: nothing
+  8-12: monsters
+chest:
+  "@count": 2
+  "@entries":
+    - dagger
+    - map
+motto: Never give up
+places:
+  town:
+    - Brightwater
+metal:
+  - silver
+`;
+
+function engine(options = {}) {
+    return new SigilEngine(createSigilData([source]).lists, options);
+}
+
+describe('SIGIL Table Introspection', () => {
+    it('should list every table with its kind and size', () => {
+        assert.deepStrictEqual(engine().tables(), [
+            { name: 'loot', kind: 'list', entries: 2, settings: { tags: ['rare'] } },
+            { name: 'gem', kind: 'list', entries: 3, settings: {} },
+            { name: 'wandering', kind: 'roll', entries: 3, settings: {} },
+            { name: 'chest', kind: 'list', entries: 2, settings: { count: '2' } },
+            { name: 'motto', kind: 'value', entries: 1, settings: {} },
+            { name: 'places.town', kind: 'list', entries: 1, settings: {} },
+            { name: 'metal', kind: 'list', entries: 1, settings: {} }
+        ]);
+    });
+
+    it('should describe list entries with weights, chances, tags and references', () => {
+        assert.deepStrictEqual(engine().entries('loot'), [
+            { index: 0, source: 'gold ^3', text: 'gold', weight: 3, probability: 0.75, tags: [], references: [] },
+            { index: 1, source: '[gem] ring (rare)', text: '[gem] ring', weight: 1, probability: 0.25, tags: ['rare'], references: ['gem'] }
+        ]);
+        const gems = engine().entries('gem');
+        assert.deepStrictEqual(gems.map(entry => entry.probability), [0.25, 0.25, 0.5]);
+        assert.deepStrictEqual(gems[2].references, ['metal']);
+        assert.deepStrictEqual(engine().entries('chest').map(entry => entry.text), ['dagger', 'map']);
+        assert.deepStrictEqual(engine().entries('motto').map(entry => entry.text), ['Never give up']);
+    });
+
+    it('should give roll table entries their range and die chance', () => {
+        const entries = engine().entries('wandering');
+        assert.deepStrictEqual(entries.map(entry => [entry.text, entry.range, entry.weight, entry.probability]), [
+            ['[loot]', { min: 2, max: 6 }, 5, 15 / 36],
+            ['nothing', { min: 7, max: 7 }, 1, 6 / 36],
+            ['monsters', { min: 8, max: 12 }, 5, 15 / 36]
+        ]);
+        assert.deepStrictEqual(entries[0].references, ['loot']);
+    });
+
+    it('should throw for tables that cannot be listed', () => {
+        assert.throws(() => engine().entries('nowhere'), (error: unknown) => error instanceof ResolveError && error.code === 'unknown_table');
+        assert.throws(() => engine().entries('places'), (error: unknown) => error instanceof ResolveError && error.code === 'not_a_list');
+    });
+
+    it('should generate from one chosen entry', () => {
+        const sigil = engine({ errorMode: 'strict' });
+        assert.strictEqual(sigil.resolveEntry('loot', 0), 'gold');
+        assert.match(sigil.resolveEntry('loot', 1), /^(ruby|opal|silver with silver inlay) ring$/);
+        assert.strictEqual(sigil.resolveEntry('wandering', 2), 'monsters');
+        assert.strictEqual(sigil.resolveEntry('gem', 2, { vars: { metal: 'gold' } }), 'gold with gold inlay');
+        assert.throws(() => sigil.resolveEntry('loot', 5), /Table "loot" has no entry 5 \(it has 2\)/);
+        assert.strictEqual(engine({ errorMode: 'silent' }).resolveEntry('nowhere', 0), '');
+    });
+
+    it('should report an index past the last entry', () => {
+        const sigil = new SigilEngine({ holes: ['a'] }, { errorMode: 'strict' });
+        for (const index of [1, -1, 0.5]) {
+            assert.throws(() => sigil.resolveEntry('holes', index), (error: unknown) =>
+                error instanceof ResolveError && error.code === 'entry_out_of_range' && error.table === 'holes');
+        }
+        assert.strictEqual(new SigilEngine({ holes: ['a'] }).resolveEntry('holes', 1), '');
+    });
+
+    it('should preview from the engine\'s seed or the one given', () => {
+        const previews = (sigil: SigilEngine, options = {}) => Array.from({ length: 8 }, () => sigil.resolveEntry('loot', 1, options));
+        assert.deepStrictEqual(previews(engine({ seed: 3 })), previews(engine({ seed: 3 })));
+        assert.strictEqual(new Set(previews(engine({ seed: 3 }))).size, 1);
+        assert.deepStrictEqual(previews(engine(), { seed: 'x' }), previews(engine({ seed: 9 }), { seed: 'x' }));
+        const seeds = Array.from({ length: 30 }, (_, seed) => engine().resolveEntry('loot', 1, { seed }));
+        assert.ok(new Set(seeds).size > 1);
+    });
+
+    it('should leave the engine\'s random stream alone', () => {
+        const first = engine({ seed: 7 });
+        const second = engine({ seed: 7 });
+        first.entries('loot');
+        first.tables();
+        first.resolveEntry('loot', 1);
+        for (let i = 0; i < 10; i++) {
+            assert.strictEqual(first.generate('[loot] [gem]'), second.generate('[loot] [gem]'));
+        }
+    });
+});
@@ -0,0 +1,55 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+
+const deepFreeze = <T>(value: T): T => {
+    if (typeof value === 'object' && value !== null) {
+        Object.values(value).forEach(deepFreeze);
+        Object.freeze(value);
+    }
+    return value;
+};
+
+// Read-only table set shared by every engine below
+const data = deepFreeze({
+    name: ['Aldric', 'Brenna', 'Corin ^2'],
+    title: ['the [adjective.unique]', 'of [place]'],
+    adjective: ['bold', 'grim', 'wise'],
+    place: ['the north', 'the [1d3] towers'],
+    encounters: { '1-3': '[name->who] meets [who]', '4-6': 'nobody' }
+});
+
+const template = '[name->n] [title] [title] {a|b} [2d6] [encounters] [if n == "Aldric" then ARC]';
+
+describe('SIGIL Per-Call Isolation', () => {
+    it('should never write to the shared table data', () => {
+        const engine = new SigilEngine(data, { errorMode: 'strict' });
+        for (let i = 0; i < 200; i++) {
+            engine.generate(template);
+            engine.generateStructured(template);
+            engine.pickUnique('adjective', 3);
+        }
+    });
+
+    it('should give the same seeded results however calls are interleaved', async () => {
+        const expected = Array.from({ length: 100 }, (_, i) =>
+            new SigilEngine(data).generateWithSeed(template, i).text);
+
+        const shared = new SigilEngine(data);
+        const results = await Promise.all(expected.map(async (_, i) => {
+            await new Promise(resolve => setTimeout(resolve, (i * 7) % 5));
+            return shared.generateWithSeed(template, i).text;
+        }));
+
+        assert.deepStrictEqual(results, expected);
+    });
+
+    it('should not let one call see another call\'s variables or unique picks', () => {
+        const engine = new SigilEngine(data);
+        engine.generate('[name->n]', { vars: { x: 'leak' } });
+        assert.strictEqual(engine.generate('[x]'), '');
+        for (let i = 0; i < 10; i++) {
+            assert.strictEqual(new Set(engine.pickUnique('adjective', 3)).size, 3);
+        }
+    });
+});
@@ -0,0 +1,83 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createLayeredSigilData, readSigilDirectory } from '../src/yaml-loader';
+import { ParseError } from '../src/errors';
+
+const defaults = {
+    'creatures.yaml': 'creatures:\n  - wolf\n  - bear\nloot:\n  coins:\n    - copper\n  gems:\n    - ruby\n',
+    'templates.yaml': 'templates:\n  encounter:\n    - "A [creatures] guards [loot.coins]"\n'
+};
+
+describe('SIGIL Layered Sources', () => {
+    it('should let later layers replace tables from earlier ones', () => {
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: defaults },
+            { name: 'user', files: { 'mine.yaml': 'creatures:\n  - dragon\n' } }
+        ]);
+        assert.deepStrictEqual(data.lists.creatures, ['dragon'], 'Lists are replaced, not merged, across layers');
+        assert.deepStrictEqual(data.lists.loot.coins, ['copper']);
+    });
+
+    it('should merge groups table by table', () => {
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: defaults },
+            { name: 'user', files: { 'loot.yaml': 'loot:\n  gems:\n    - emerald\n' } }
+        ]);
+        assert.deepStrictEqual(data.lists.loot, { coins: ['copper'], gems: ['emerald'] });
+    });
+
+    it('should still merge lists within one layer', () => {
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: { 'a.yaml': 'creatures:\n  - wolf\n', 'b.yaml': 'creatures:\n  - bear\n' } }
+        ]);
+        assert.deepStrictEqual(data.lists.creatures, ['wolf', 'bear']);
+        assert.deepStrictEqual(data.origin('creatures')?.files, ['a.yaml', 'b.yaml']);
+    });
+
+    it('should keep conflicts within a layer as errors', () => {
+        assert.throws(() => createLayeredSigilData([
+            { name: 'user', files: { 'a.yaml': 'loot:\n  - coins\n', 'b.yaml': 'loot:\n  gems:\n    - ruby\n' } }
+        ]), (error: unknown) => error instanceof ParseError && error.code === 'table_conflict');
+    });
+
+    it('should allow a table to change shape across layers', () => {
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: defaults },
+            { name: 'user', files: { 'loot.yaml': 'loot:\n  - a sack of turnips\n' } }
+        ]);
+        assert.deepStrictEqual(data.lists.loot, ['a sack of turnips']);
+        assert.strictEqual(data.origin('loot.coins'), undefined);
+    });
+
+    it('should report origins and what they shadow', () => {
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: defaults },
+            { name: 'user', files: { 'mine.yaml': 'creatures:\n  - dragon\ntemplates:\n  encounter:\n    - "Beware the [creatures]"\n' } }
+        ]);
+        assert.deepStrictEqual(data.origin('creatures'), {
+            layer: 'user',
+            files: ['mine.yaml'],
+            shadowed: [{ layer: 'defaults', files: ['creatures.yaml'] }]
+        });
+        assert.deepStrictEqual(data.origin('loot.gems'), { layer: 'defaults', files: ['creatures.yaml'], shadowed: [] });
+        assert.strictEqual(data.origin('encounter')?.layer, 'user');
+        assert.strictEqual(data.origin('missing'), undefined);
+
+        const engine = new SigilEngine(data.lists);
+        assert.strictEqual(engine.generate(data.templates.encounter[0]), 'Beware the dragon');
+    });
+
+    it('should read a directory into a layer', () => {
+        const files = readSigilDirectory('./test/fixtures/tables');
+        assert.deepStrictEqual(Object.keys(files).sort(), ['common.yaml', 'fantasy/monsters.yml', 'fantasy/names.yaml', 'scifi/names.yaml']);
+
+        const data = createLayeredSigilData([
+            { name: 'defaults', files: { 'colors.yaml': 'colors:\n  - grey\nshapes:\n  - cube\n' } },
+            { name: 'user', files }
+        ], { namespace: true });
+        assert.deepStrictEqual(data.lists.colors, ['red', 'blue']);
+        assert.deepStrictEqual(data.lists.shapes, ['cube']);
+        assert.deepStrictEqual(data.origin('fantasy.names')?.files, ['fantasy/names.yaml']);
+    });
+});
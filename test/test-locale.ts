@@ -0,0 +1,99 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { LOCALES, LocaleData, getLocale, numberToWords, numberToOrdinal, formatGrouped, joinList } from '../src/locale';
+import { ResolveError } from '../src/errors';
+
+describe('SIGIL Locales', () => {
+    describe('number words', () => {
+        it('should write cardinal numbers in English', () => {
+            assert.strictEqual(numberToWords(0), 'zero');
+            assert.strictEqual(numberToWords(3), 'three');
+            assert.strictEqual(numberToWords(21), 'twenty-one');
+            assert.strictEqual(numberToWords(105), 'one hundred five');
+            assert.strictEqual(numberToWords(2500000), 'two million five hundred thousand');
+            assert.strictEqual(numberToWords(-7), 'minus seven');
+            assert.strictEqual(numberToWords(1.5), undefined);
+        });
+
+        it('should write ordinal numbers in English', () => {
+            assert.strictEqual(numberToOrdinal(1), 'first');
+            assert.strictEqual(numberToOrdinal(12), 'twelfth');
+            assert.strictEqual(numberToOrdinal(20), 'twentieth');
+            assert.strictEqual(numberToOrdinal(21), 'twenty-first');
+            assert.strictEqual(numberToOrdinal(100), 'one hundredth');
+        });
+
+        it('should follow German compound rules', () => {
+            const de = LOCALES.de;
+            assert.strictEqual(numberToWords(1, de), 'eins');
+            assert.strictEqual(numberToWords(21, de), 'einundzwanzig');
+            assert.strictEqual(numberToWords(101, de), 'einhunderteins');
+            assert.strictEqual(numberToWords(2021, de), 'zweitausendeinundzwanzig');
+            assert.strictEqual(numberToWords(3000000, de), 'drei Millionen');
+            assert.strictEqual(numberToOrdinal(3, de), 'dritte');
+            assert.strictEqual(numberToOrdinal(19, de), 'neunzehnte');
+            assert.strictEqual(numberToOrdinal(20, de), 'zwanzigste');
+        });
+    });
+
+    describe('formatting and joining', () => {
+        it('should group digits with the locale separators', () => {
+            assert.strictEqual(formatGrouped(1234567.5), '1,234,567.5');
+            assert.strictEqual(formatGrouped(1234.5, LOCALES.de, 2), '1.234,50');
+            assert.strictEqual(formatGrouped(-999), '-999');
+        });
+
+        it('should join lists with an Oxford comma in English', () => {
+            assert.strictEqual(joinList(['wolf']), 'wolf');
+            assert.strictEqual(joinList(['wolf', 'bear']), 'wolf and bear');
+            assert.strictEqual(joinList(['wolf', 'bear', 'owl']), 'wolf, bear, and owl');
+            assert.strictEqual(joinList(['Wolf', 'Bär', 'Eule'], LOCALES.de), 'Wolf, Bär und Eule');
+        });
+
+        it('should resolve locale codes', () => {
+            assert.strictEqual(getLocale('de-AT'), LOCALES.de);
+            assert.throws(() => getLocale('xx'), /Unknown locale "xx"/);
+        });
+    });
+
+    describe('modifiers', () => {
+        it('should format numbers from tables, variables and expressions', () => {
+            const engine = new SigilEngine({ gold: ['12345'] });
+            assert.strictEqual(engine.generate('[gold.number]'), '12,345');
+            assert.strictEqual(engine.generate('[gold.currency]'), '$12,345.00');
+            assert.strictEqual(engine.generate('[n.words] [n.ordinal]', { vars: { n: '3' } }), 'three third');
+            assert.strictEqual(engine.generate('[= 7 * 3.words]'), 'twenty-one');
+        });
+
+        it('should join repeated picks with .list', () => {
+            const engine = new SigilEngine({ animal: ['wolf', 'bear', 'owl'] });
+            assert.match(engine.generate('[animal.unique.list*3]'), /^\w+, \w+, and \w+$/);
+            assert.match(engine.generate('[animal.list*2]'), /^\w+ and \w+$/);
+            assert.match(engine.generate('[animal*3]'), /^\w+, \w+, \w+$/, 'Plain repetition keeps the default join');
+        });
+
+        it('should use the engine locale', () => {
+            const engine = new SigilEngine({ animal: ['Wolf', 'Bär', 'Eule'], gold: ['1234.5'] }, { locale: 'de' });
+            assert.strictEqual(engine.generate('[= 21.words], [gold.currency]'), 'einundzwanzig, 1.234,50 €');
+            assert.match(engine.generate('[animal.unique.list*3]'), /^\S+, \S+ und \S+$/);
+        });
+
+        it('should accept custom locale data', () => {
+            const pirate: LocaleData = { ...LOCALES.en, currency: '{n} doubloons', list: { join: ', ', lastJoin: ' an\' ', pairJoin: ' an\' ' } };
+            const engine = new SigilEngine({ loot: ['rum', 'gold'] }, { locale: pirate });
+            assert.strictEqual(engine.generate('[n.currency]', { vars: { n: '1200' } }), '1,200.00 doubloons');
+            assert.match(engine.generate('[loot.unique.list*2]'), /^\w+ an' \w+$/);
+        });
+
+        it('should leave non-numeric text alone, or throw in strict mode', () => {
+            const graceful = new SigilEngine({ word: ['many'] });
+            assert.strictEqual(graceful.generate('[word.words] [word.number]'), 'many many');
+
+            const strict = new SigilEngine({ word: ['many'] }, { errorMode: 'strict' });
+            assert.throws(() => strict.generate('[word.words]'),
+                (error: unknown) => error instanceof ResolveError && error.code === 'not_a_number');
+            assert.throws(() => strict.generate('[n.words]', { vars: { n: '2.5' } }), /needs a whole number/);
+        });
+    });
+});
@@ -0,0 +1,56 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createSigilData, listTables } from '../src/yaml-loader';
+import { validateSigil } from '../src/validator';
+import { ParseError, RecursionError } from '../src/errors';
+
+const source = `"@weapon-desc": "[adjective] [material] [weapon]"
+adjective: [sharp, rusty]
+material: [steel]
+weapon: [sword]
+loot:
+  - "a [@weapon-desc]"
+`;
+
+describe('SIGIL Macros', () => {
+    it('should expand a macro in place', () => {
+        const engine = new SigilEngine(createSigilData([source]).lists);
+        assert.match(engine.generate('[loot]'), /^a (sharp|rusty) steel sword$/);
+        assert.match(engine.generate('[@weapon-desc.upper]'), /^(SHARP|RUSTY) STEEL SWORD$/);
+        assert.match(engine.generate('[@weapon-desc*2]'), /^\w+ steel sword, \w+ steel sword$/);
+    });
+
+    it('should share variables with the entry that uses it', () => {
+        const data = createSigilData([`"@hero": "[name->hero] the [title]"\nname: [Aria]\ntitle: [Bold]\n`]).lists;
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[@hero], and [hero] again'), 'Aria the Bold, and Aria again');
+        assert.strictEqual(engine.generate('[@hero]', { vars: { title: 'Quiet' } }), 'Aria the Quiet');
+    });
+
+    it('should let macros use other macros, within the recursion limit', () => {
+        const data = createSigilData([`"@a": "<[@b]>"\n"@b": "b"\n"@loop": "[@loop]"\n`]).lists;
+        assert.strictEqual(new SigilEngine(data).generate('[@a]'), '<b>');
+        assert.throws(() => new SigilEngine(data, { errorMode: 'strict', maxDepth: 5 }).generate('[@loop]'),
+            (error: unknown) => error instanceof RecursionError && error.chain[0] === '@loop');
+    });
+
+    it('should not list macros as tables', () => {
+        assert.deepStrictEqual(listTables(createSigilData([source]).lists), ['adjective', 'material', 'weapon', 'loot']);
+    });
+
+    it('should reject macros that are not one template', () => {
+        assert.throws(() => createSigilData(['"@names":\n  - Aria\n']),
+            (error: unknown) => error instanceof ParseError && error.code === 'invalid_macro' && error.line === 1);
+    });
+
+    it('should validate macro definitions and references', () => {
+        const issues = validateSigil(`"@unused": "x"\n"@list": [a]\nloot:\n  - "[@nowhere]"\n`);
+        assert.deepStrictEqual(issues.map(issue => [issue.severity, issue.message, issue.line]), [
+            ['warning', 'Macro "@unused" is never used', 1],
+            ['error', 'Macro "@list" must be a single template string', 2],
+            ['error', 'Reference to undefined macro "@nowhere"', 4]
+        ]);
+        assert.deepStrictEqual(validateSigil(source), []);
+    });
+});
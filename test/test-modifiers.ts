@@ -0,0 +1,48 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine, pluralize, applyModifier } from '../src/template-engine';
+
+describe('SIGIL Text Modifiers', () => {
+    it('should choose a/an from the resolved text, not the reference name', () => {
+        const engine = new SigilEngine({ monster: ['orc'], beast: ['goblin'] });
+        assert.strictEqual(engine.generate('[monster.a] attacks'), 'an orc attacks');
+        assert.strictEqual(engine.generate('[beast.an] attacks'), 'a goblin attacks');
+    });
+
+    it('should chain modifiers left to right', () => {
+        const engine = new SigilEngine({ monster: ['orc'] });
+        assert.strictEqual(engine.generate('[monster.a.capitalize] attacks'), 'An orc attacks');
+        assert.strictEqual(engine.generate('[monster.plural.upper]'), 'ORCS');
+    });
+
+    it('should prefer real table paths over modifier names', () => {
+        const engine = new SigilEngine({ grades: { a: ['excellent'], b: ['good'] } });
+        assert.strictEqual(engine.generate('[grades.a]'), 'excellent');
+    });
+
+    it('should pluralize with irregular overrides', () => {
+        assert.strictEqual(pluralize('dire wolf'), 'dire wolves');
+        assert.strictEqual(pluralize('Mouse'), 'Mice');
+        assert.strictEqual(pluralize('city'), 'cities');
+        assert.strictEqual(pluralize('box'), 'boxes');
+        assert.strictEqual(pluralize('cactus', { cactus: 'cacti' }), 'cacti');
+        assert.strictEqual(applyModifier('sheep', 'plural'), 'sheep');
+
+        const engine = new SigilEngine({ plant: ['cactus'] }, { pluralOverrides: { cactus: 'cacti' } });
+        assert.strictEqual(engine.generate('[plant.plural]'), 'cacti');
+    });
+
+    it('should support registered custom modifiers', () => {
+        const engine = new SigilEngine({ monster: ['orc'], name: { first: ['bob'] } });
+        engine.registerModifier('shout', text => `${text}!`);
+        engine.registerModifier('reverse', text => text.split('').reverse().join(''));
+        assert.strictEqual(engine.generate('[monster.shout]'), 'orc!');
+        assert.strictEqual(engine.generate('[name.first.reverse.capitalize.shout]'), 'Bob!');
+        assert.strictEqual(engine.generate('[name.first.capitalize.shout]'), 'Bob!');
+    });
+
+    it('should apply modifiers to variables', () => {
+        const engine = new SigilEngine({ monster: ['ogre'] });
+        assert.strictEqual(engine.generate('[monster->m] / [m.a.upper]'), 'ogre / AN OGRE');
+    });
+});
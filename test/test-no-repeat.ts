@@ -0,0 +1,69 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseCompleteTemplate } from '../src/template-parser';
+
+const data = {
+    adjective: ['dusty', 'damp'],
+    only: ['solo'],
+    omen: { '1-3': 'crow', '4-6': 'comet' }
+};
+
+function probabilities(engine: SigilEngine, template: string): { [text: string]: number } {
+    return Object.fromEntries(engine.analyze(template).outcomes.map(outcome => [outcome.text, outcome.probability]));
+}
+
+describe('SIGIL No Immediate Repeat', () => {
+    it('should parse a trailing ! as the no-repeat flag', () => {
+        const table = (template: string) => parseCompleteTemplate(template) as { noRepeat?: boolean; exclusions: string[] };
+        assert.strictEqual(table('[adjective!]').noRepeat, true);
+        assert.deepStrictEqual(table('[adjective!dusty!]').exclusions, ['dusty']);
+        assert.strictEqual(table('[adjective!dusty!]').noRepeat, true);
+        assert.strictEqual(table('[adjective!dusty]').noRepeat, undefined);
+        assert.strictEqual(table('[adjective.capitalize!*2?]').noRepeat, true);
+    });
+
+    it('should reroll a repeat of the previous pick once', () => {
+        const engine = new SigilEngine(data);
+        // The second pick repeats half the time; the reroll repeats again half of that
+        assert.deepStrictEqual(probabilities(engine, '[adjective] [adjective!]'),
+            { 'dusty damp': 0.375, 'damp dusty': 0.375, 'dusty dusty': 0.125, 'damp damp': 0.125 });
+        assert.deepStrictEqual(probabilities(engine, '[omen] [omen!]'),
+            { 'crow comet': 0.375, 'comet crow': 0.375, 'crow crow': 0.125, 'comet comet': 0.125 });
+    });
+
+    it('should apply to every reference with the engine option', () => {
+        const engine = new SigilEngine(data, { noImmediateRepeat: true, repeatRerolls: 3 });
+        assert.strictEqual(probabilities(engine, '[adjective] [adjective]')['dusty dusty'], 1 / 32);
+        assert.strictEqual(probabilities(new SigilEngine(data, { noImmediateRepeat: true, repeatRerolls: 0 }), '[adjective] [adjective]')['dusty dusty'], 0.25);
+    });
+
+    it('should compare with the previous pick within repetitions', () => {
+        const engine = new SigilEngine(data, { seed: 9, repeatRerolls: 40 });
+        for (const text of engine.generateBatch('[adjective!*6, sep=" "]', 20)) {
+            assert.ok(text === 'dusty damp dusty damp dusty damp' || text === 'damp dusty damp dusty damp dusty', text);
+        }
+    });
+
+    it('should accept the repeat from a single-entry table', () => {
+        const engine = new SigilEngine(data, { noImmediateRepeat: true, repeatRerolls: 1000 });
+        assert.strictEqual(engine.generate('[only] [only] [only!]'), 'solo solo solo');
+        assert.strictEqual(engine.generate('[adjective!damp] [adjective!damp]'), 'dusty dusty');
+    });
+
+    it('should stay reproducible for a seed', () => {
+        const options = { seed: 'dungeon', noImmediateRepeat: true };
+        const first = new SigilEngine(data, options).generateBatch('[adjective] [adjective] [omen] [omen]', 30);
+        const second = new SigilEngine(data, options).generateBatch('[adjective] [adjective] [omen] [omen]', 30);
+        assert.deepStrictEqual(first, second);
+        // Rerolls draw from the same stream, so the option changes what follows a reroll
+        const plain = new SigilEngine(data, { seed: 'dungeon' }).generateBatch('[adjective] [adjective] [omen] [omen]', 30);
+        assert.notDeepStrictEqual(first, plain);
+    });
+
+    it('should start each generation call afresh', () => {
+        const engine = new SigilEngine({ only: ['solo'], pair: ['a', 'b'] }, { noImmediateRepeat: true, repeatRerolls: 40 });
+        const calls = Array.from({ length: 40 }, () => engine.generate('[pair]'));
+        assert.ok(calls.some((text, i) => i > 0 && text === calls[i - 1]), 'repeats across calls are allowed');
+    });
+});
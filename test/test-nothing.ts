@@ -0,0 +1,86 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createSigilData } from '../src/yaml-loader';
+
+const source = `nothing:
+  - ~
+pocket:
+  - rusty key
+  - (nothing) ^3
+hidden:
+  - "[nothing]"
+apple:
+  - apple
+`;
+
+function engine(options = {}) {
+    return new SigilEngine(createSigilData([source]).lists, options);
+}
+
+describe('SIGIL Nothing Entries', () => {
+    it('should drop the article and close up the space before punctuation', () => {
+        const sigil = engine();
+        assert.strictEqual(sigil.generate('You find a [nothing].'), 'You find.');
+        assert.strictEqual(sigil.generate('You find {a} [nothing].'), 'You find.');
+        assert.strictEqual(sigil.generate('You find {a} [apple].'), 'You find an apple.');
+        assert.strictEqual(sigil.generate('Gold, [nothing]!'), 'Gold!');
+    });
+
+    it('should collapse the space between the surrounding words', () => {
+        const sigil = engine();
+        assert.strictEqual(sigil.generate('There is [nothing] here.'), 'There is here.');
+        assert.strictEqual(sigil.generate('[nothing] The door opens.'), 'The door opens.');
+        assert.strictEqual(sigil.generate('Then [nothing] [nothing] end'), 'Then end');
+        assert.strictEqual(sigil.generate('You see [nothing], then leave.'), 'You see, then leave.');
+    });
+
+    it('should keep the article when the sentence goes on after the nothing', () => {
+        const sigil = engine();
+        assert.strictEqual(sigil.generate('You find a [nothing] here.'), 'You find a here.');
+        assert.strictEqual(sigil.generate('You find a [nothing] [nothing].'), 'You find.');
+        assert.strictEqual(sigil.generate('You find a [nothing], then leave.'), 'You find, then leave.');
+    });
+
+    it('should treat entries that only led to nothing as nothing', () => {
+        assert.strictEqual(engine().generate('You find a [hidden].'), 'You find.');
+    });
+
+    it('should weight nothing entries like any other', () => {
+        const outcomes = engine().analyze('You find a [pocket].').outcomes;
+        assert.deepStrictEqual(outcomes.map(outcome => [outcome.text, outcome.probability]), [
+            ['You find.', 0.75],
+            ['You find a rusty key.', 0.25]
+        ]);
+    });
+
+    it('should leave repeated picks out of the join', () => {
+        const sigil = new SigilEngine({ loot: ['gold', '~'] }, { seed: 1 });
+        for (let i = 0; i < 20; i++) {
+            assert.match(sigil.generate('[loot*3]'), /^(gold(, gold){0,2})?$/);
+        }
+    });
+
+    it('should skip missing items in lists built in code', () => {
+        const sigil = new SigilEngine({ holes: [null, 'a', undefined] as unknown as string[] }, { errorMode: 'strict' });
+        for (let i = 0; i < 20; i++) {
+            assert.strictEqual(sigil.generate('You find [holes].'), 'You find a.');
+        }
+        assert.deepStrictEqual(sigil.entries('holes').map(entry => [entry.index, entry.source]), [[1, 'a']]);
+        assert.strictEqual(sigil.resolveEntry('holes', 1), 'a');
+        assert.throws(() => sigil.resolveEntry('holes', 0), /Table "holes" has no entry 0 \(it has 1\)/);
+        assert.deepStrictEqual(createSigilData([source]).lists.nothing, ['~']);
+    });
+
+    it('should record the nothing in the structured result', () => {
+        const tree = engine().generateStructured('You find a [hidden].');
+        assert.strictEqual(tree.text, 'You find.');
+        assert.strictEqual(tree.children[0].status, 'nothing');
+        assert.strictEqual(tree.children[0].children[0].name, 'nothing');
+        assert.strictEqual(tree.children[0].children[0].status, 'nothing');
+    });
+
+    it('should keep the text as written with tidyNothing off', () => {
+        assert.strictEqual(engine({ tidyNothing: false }).generate('You find a [nothing].'), 'You find a .');
+    });
+});
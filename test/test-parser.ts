@@ -1,7 +1,21 @@
-import { parseTemplateExpression, TemplateNode } from '../src/template-parser';
+import { parseTemplateExpression, parseCompleteTemplate, TemplateNode } from '../src/template-parser';
 import assert from 'assert';
+import fs from 'fs';
 import { test, describe } from 'node:test';
 
+// [template, parseCompleteTemplate, parseTemplateExpression] as the regex-based
+// parser returned them, before the single-pass one replaced it
+const GOLDEN: Array<[string, unknown, unknown]> = JSON.parse(fs.readFileSync('./test/fixtures/parser/golden.json', 'utf8'));
+
+// The result as JSON would keep it (no undefined properties), or the message thrown
+function parsed(parse: (template: string) => TemplateNode, template: string): unknown {
+    try {
+        return JSON.parse(JSON.stringify(parse(template)));
+    } catch (error) {
+        return { error: (error as Error).message };
+    }
+}
+
 describe('SIGIL Template Parser', () => {
     test('parses plain text', () => {
         const node = parseTemplateExpression('hello');
@@ -81,7 +95,8 @@ describe('SIGIL Template Parser', () => {
                     modifiers: undefined,
                     isOptional: false,
                     exclusions: [],
-                    repetition: 2
+                    repetition: 2,
+                    explicitRepetition: true
                 },
                 {
                     type: 'or',
@@ -135,4 +150,12 @@ describe('SIGIL Template Parser', () => {
             }
         });
     });
-});
\ No newline at end of file
+
+    test('parses the golden fixtures exactly as the previous parser did', () => {
+        assert.ok(GOLDEN.length > 400);
+        for (const [template, complete, expression] of GOLDEN) {
+            assert.deepStrictEqual(parsed(parseCompleteTemplate, template), complete, `parseCompleteTemplate(${JSON.stringify(template)})`);
+            assert.deepStrictEqual(parsed(parseTemplateExpression, template), expression, `parseTemplateExpression(${JSON.stringify(template)})`);
+        }
+    });
+});
@@ -0,0 +1,86 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { SigilTable, TableSet } from '../src/table-builder';
+import type { TableProvider } from '../src/table-builder';
+import { importJson } from '../src/importer';
+import { parseYamlContent } from '../src/yaml-loader';
+
+// Counts lookups, the way a database-backed provider would count queries
+function countingProvider(tables: { [name: string]: string[] }) {
+    const calls: string[] = [];
+    const provider: TableProvider = {
+        lookup(name) {
+            calls.push(name);
+            return tables[name];
+        }
+    };
+    return { provider, calls };
+}
+
+describe('SIGIL Table Providers', () => {
+    it('should build weighted tables in code', () => {
+        const tables = new TableSet()
+            .register(new SigilTable('monster').add('goblin', 3).add('[size] dragon'))
+            .register(new SigilTable('size').add('huge'))
+            .register(new SigilTable('npc.names').add('Mira'));
+
+        assert.deepStrictEqual(tables.toData(), {
+            monster: ['goblin ^3', '[size] dragon'],
+            size: ['huge'],
+            npc: { names: ['Mira'] }
+        });
+        const engine = new SigilEngine(tables.toData());
+        assert.match(engine.generate('[monster]'), /^(goblin|huge dragon)$/);
+        assert.strictEqual(engine.generate('[npc.names]'), 'Mira');
+    });
+
+    it('should write tables out as SIGIL source', () => {
+        const tables = new TableSet()
+            .register(new SigilTable('monster').add('goblin', 3).add('[size] dragon'))
+            .register(new SigilTable('npc.names').add('Mira'));
+        assert.deepStrictEqual(parseYamlContent(tables.toSource()), tables.toData());
+    });
+
+    it('should reject bad weights, names and conflicting tables', () => {
+        assert.throws(() => new SigilTable('loot').add('gold', 0), /Invalid weight 0 on "gold"/);
+        assert.throws(() => new SigilTable('bad name'), /Invalid table name/);
+        const tables = new TableSet().register(new SigilTable('npc').add('Mira')).register(new SigilTable('npc.names').add('Oren'));
+        assert.throws(() => tables.toData(), /Table "npc.names" conflicts with table "npc"/);
+    });
+
+    it('should ask providers for tables the data does not define', () => {
+        const { provider, calls } = countingProvider({ tavern: ['The [adjective] Pony'], adjective: ['Prancing'] });
+        const engine = new SigilEngine({ town: ['Bree'] }, { providers: [provider] });
+        assert.strictEqual(engine.generate('[town]: [tavern]'), 'Bree: The Prancing Pony');
+        assert.deepStrictEqual(calls, ['tavern', 'adjective']);
+    });
+
+    it('should look each table up once per generate call', () => {
+        const { provider, calls } = countingProvider({ coin: ['heads', 'tails'] });
+        const engine = new SigilEngine({}, { providers: [provider] });
+        assert.match(engine.generate('[coin*5] [coin] [missing.capitalize]'), /^(heads|tails)(, (heads|tails)){4} (heads|tails) $/);
+        assert.deepStrictEqual(calls.filter(name => name === 'coin'), ['coin']);
+
+        engine.generate('[coin]');
+        assert.deepStrictEqual(calls.filter(name => name === 'coin'), ['coin', 'coin']);
+    });
+
+    it('should try providers in order and accept tables or imported data', () => {
+        const tables = new TableSet().register(new SigilTable('gem').add('ruby'));
+        const { provider } = countingProvider({ gem: ['opal'], metal: ['iron'] });
+        const wandering = importJson([{ range: '1-6', text: 'wolves' }]);
+        const engine = new SigilEngine({}, {
+            providers: [tables, provider, { lookup: name => name === 'wandering' ? wandering : undefined }]
+        });
+        assert.strictEqual(engine.generate('[gem] [metal] [wandering]'), 'ruby iron wolves');
+        assert.strictEqual(engine.rollOn('wandering', 4), 'wolves');
+    });
+
+    it('should report tables no provider knows as unknown', () => {
+        const { provider } = countingProvider({});
+        const engine = new SigilEngine({}, { providers: [provider], errorMode: 'strict' });
+        assert.throws(() => engine.generate('[nowhere]'), /Unknown table or variable "nowhere"/);
+        assert.strictEqual(new SigilEngine({}, { providers: [provider] }).generate('[nowhere ?? "no one"]'), 'no one');
+    });
+});
@@ -0,0 +1,67 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { RecursionError } from '../src/errors';
+
+describe('SIGIL Recursion Limits', () => {
+    it('should report the expansion chain for a cycle in strict mode', () => {
+        const engine = new SigilEngine({
+            a: ['[b] item'],
+            b: ['[a] thing']
+        }, { errorMode: 'strict', maxDepth: 6 });
+
+        assert.throws(() => engine.generate('[a]'), (error: unknown) => {
+            assert.ok(error instanceof RecursionError, 'Should throw a RecursionError');
+            assert.strictEqual(error.maxDepth, 6);
+            assert.deepStrictEqual(error.chain.slice(0, 3), ['a', 'b', 'a']);
+            assert.match(error.message, /: a -> b \(repeats ×\d\)/);
+            return true;
+        });
+    });
+
+    it('should write a long cycle once and keep the full chain on the error', () => {
+        const engine = new SigilEngine({ start: ['[a]'], a: ['[b]'], b: ['[a]'] }, { errorMode: 'strict' });
+        assert.throws(() => engine.generate('[start]'), (error: unknown) => {
+            assert.ok(error instanceof RecursionError);
+            assert.ok(error.chain.length >= 50);
+            assert.deepStrictEqual(error.chain.slice(0, 4), ['start', 'a', 'b', 'a']);
+            assert.match(error.message, /^Maximum recursion depth \(50\) exceeded: start -> a -> b \(repeats ×\d+\)( -> a)?$/);
+            return true;
+        });
+    });
+
+    it('should report direct self-reference', () => {
+        const engine = new SigilEngine({ loop: ['[loop]'] }, { errorMode: 'strict' });
+        assert.throws(() => engine.generate('[loop]'), RecursionError);
+    });
+
+    it('should keep graceful output for cycles by default', () => {
+        const engine = new SigilEngine({ a: ['[b] item'], b: ['[a] thing'] });
+        const result = engine.generate('[a]');
+        assert.ok(result.endsWith('item'), 'Should still return the partial expansion');
+    });
+
+    it('should allow bounded recursion up to the limit', () => {
+        const engine = new SigilEngine({
+            corridor: ['a corridor', 'a corridor leading to [corridor] ^3']
+        }, { errorMode: 'strict', maxDepth: 200, seed: 9 });
+
+        for (let i = 0; i < 50; i++) {
+            const result = engine.generate('[corridor]');
+            assert.ok(result.startsWith('a corridor'));
+            assert.ok(!result.includes('['), 'Should fully expand');
+        }
+    });
+
+    it('should count depth per call rather than per engine', () => {
+        const engine = new SigilEngine({
+            deep: ['[deeper]'],
+            deeper: ['[deepest]'],
+            deepest: ['bottom']
+        }, { errorMode: 'strict', maxDepth: 4 });
+
+        for (let i = 0; i < 20; i++) {
+            assert.strictEqual(engine.generate('[deep]'), 'bottom');
+        }
+    });
+});
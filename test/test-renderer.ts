@@ -0,0 +1,75 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import type { GenerationNode } from '../src/template-engine';
+import { createSigilData } from '../src/yaml-loader';
+import { renderMarkdown, renderHTML } from '../src/renderer';
+
+const source = `npc:
+  "@style": bold
+  "@link": https://wiki.example/npc/{text}
+  "@entries":
+    - "[title] Aldric"
+title:
+  "@style": italic
+  "@entries":
+    - Sir
+tavern:
+  "@style": bold, italic
+  "@entries":
+    - The <Red> Dragon
+item:
+  "@style": sparkly
+  "@entries":
+    - a *lucky* coin
+plain:
+  - rain & fog
+`;
+
+function tree(template: string): GenerationNode {
+    return new SigilEngine(createSigilData([source]).lists).generateStructured(template);
+}
+
+describe('SIGIL Renderers', () => {
+    it('should record @style and @link on table nodes', () => {
+        const root = tree('[npc]');
+        assert.deepStrictEqual(root.children[0].style, ['bold']);
+        assert.strictEqual(root.children[0].link, 'https://wiki.example/npc/Sir%20Aldric');
+        assert.deepStrictEqual(tree('[tavern]').children[0].style, ['bold', 'italic']);
+        assert.strictEqual(tree('[plain]').children[0].style, undefined);
+    });
+
+    it('should render styles as Markdown', () => {
+        assert.strictEqual(renderMarkdown(tree('Meet [tavern].')), 'Meet _**The \\<Red\\> Dragon**_.');
+        assert.strictEqual(renderMarkdown(tree('[plain]')), 'rain & fog');
+    });
+
+    it('should render styles as HTML with all text escaped', () => {
+        assert.strictEqual(renderHTML(tree('Meet [tavern] & [plain].')),
+            'Meet <em><strong>The &lt;Red&gt; Dragon</strong></em> &amp; rain &amp; fog.');
+        assert.strictEqual(renderHTML(tree('<b>"[plain]"</b>')), '&lt;b&gt;&quot;rain &amp; fog&quot;&lt;/b&gt;');
+    });
+
+    it('should nest styles inside links', () => {
+        assert.strictEqual(renderMarkdown(tree('Ask [npc]')), 'Ask [**_Sir_ Aldric**](https://wiki.example/npc/Sir%20Aldric)');
+        assert.strictEqual(renderHTML(tree('Ask [npc]')),
+            'Ask <a href="https://wiki.example/npc/Sir%20Aldric"><strong><em>Sir</em> Aldric</strong></a>');
+    });
+
+    it('should fall back to plain text for unknown styles', () => {
+        assert.strictEqual(renderMarkdown(tree('You find [item]')), 'You find a \\*lucky\\* coin');
+        assert.strictEqual(renderHTML(tree('You find [item]')), 'You find a *lucky* coin');
+    });
+
+    it('should keep text that changed after a reference made it plain', () => {
+        const engine = new SigilEngine({ name: { '@style': 'bold', '@entries': ['ada'] }, greeting: ['hi [name]'] });
+        assert.strictEqual(renderHTML(engine.generateStructured('[greeting.upper]')), 'HI ADA');
+        assert.strictEqual(renderHTML(engine.generateStructured('[greeting]')), 'hi <strong>ada</strong>');
+    });
+
+    it('should render repeated picks and leave generate() unchanged', () => {
+        const engine = new SigilEngine({ gem: { '@style': 'bold', '@entries': ['ruby'] } });
+        assert.strictEqual(renderMarkdown(engine.generateStructured('[gem*2]')), '**ruby**, **ruby**');
+        assert.strictEqual(engine.generate('[gem*2]'), 'ruby, ruby');
+    });
+});
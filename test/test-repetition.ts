@@ -0,0 +1,85 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseCompleteTemplate } from '../src/template-parser';
+import { ResolveError } from '../src/errors';
+
+const data = {
+    room: ['hall'],
+    rooms: ['hall', 'crypt', 'well'],
+    loot: { '@join': ' / ', '@last-join': ' & ', '@entries': ['coin'] }
+};
+
+describe('SIGIL Repetition', () => {
+    it('should parse dice counts and options', () => {
+        assert.deepStrictEqual(parseCompleteTemplate('[room*1d4+1, sep="; ", last=\' and \', unique]'), {
+            type: 'table',
+            tablePath: 'room',
+            modifiers: ['unique'],
+            isOptional: false,
+            exclusions: [],
+            repetition: '1d4+1',
+            explicitRepetition: true,
+            separator: '; ',
+            lastSeparator: ' and '
+        });
+    });
+
+    it('should roll the count', () => {
+        const engine = new SigilEngine(data);
+        for (let i = 0; i < 30; i++) {
+            const count = engine.generate('[room*1d4+1]').split(', ').length;
+            assert.ok(count >= 2 && count <= 5, `count ${count}`);
+        }
+        const distribution = engine.analyze('[room*d2]');
+        assert.deepStrictEqual(distribution.outcomes.map(outcome => outcome.text).sort(), ['hall', 'hall, hall']);
+    });
+
+    it('should join with the given separators', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[room*3, sep="; "]'), 'hall; hall; hall');
+        assert.strictEqual(engine.generate('[room*3, sep=", ", last=" and "]'), 'hall, hall and hall');
+        assert.strictEqual(engine.generate("[room*2, sep=' | ']"), 'hall | hall');
+    });
+
+    it('should override the table defaults', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[loot*3]'), 'coin / coin & coin');
+        assert.strictEqual(engine.generate('[loot*3, sep="-"]'), 'coin-coin-coin', 'sep replaces @last-join too');
+        assert.strictEqual(engine.generate('[loot*3, last=" or "]'), 'coin / coin or coin');
+    });
+
+    it('should produce nothing for a count of zero', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('Rooms: [room*0, sep="; "].'), 'Rooms: .');
+        assert.strictEqual(engine.generate('[room*1d4-4, sep="; "]'), '');
+    });
+
+    it('should pick unique entries', () => {
+        const engine = new SigilEngine(data);
+        for (let i = 0; i < 10; i++) {
+            assert.deepStrictEqual(engine.generate('[rooms*3, unique]').split(', ').sort(), ['crypt', 'hall', 'well']);
+        }
+    });
+
+    it('should apply modifiers to each pick', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[room.capitalize*2, sep=" "]'), 'Hall Hall');
+    });
+
+    it('should capture the joined result', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[room*2, sep="+" -> both] = [both]'), 'hall+hall = hall+hall');
+    });
+
+    it('should report invalid dice counts', () => {
+        const engine = new SigilEngine(data, { errorMode: 'strict' });
+        assert.throws(() => engine.generate('[room*1d4x]'),
+            (error: unknown) => error instanceof ResolveError && error.code === 'invalid_count' && /"\*1d4x"/.test(error.message));
+    });
+
+    it('should leave other commas alone', () => {
+        const engine = new SigilEngine({ 'a, b': ['ab'] });
+        assert.strictEqual(engine.generate('[a, b]'), 'ab');
+    });
+});
@@ -0,0 +1,93 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseYamlContent } from '../src/yaml-loader';
+import { isRollTable, parseRollTable } from '../src/roll-table';
+import { validateSigil } from '../src/validator';
+
+const encounters = { '1-3': 'wolves', '4-5': 'bandits', '6': 'a dragon' };
+
+describe('SIGIL Roll Tables', () => {
+    describe('parseRollTable', () => {
+        it('should infer the die from the highest number', () => {
+            const table = parseRollTable(encounters);
+            assert.strictEqual(table.die.expression, 'd6');
+            assert.deepStrictEqual(table.entries.map(e => [e.min, e.max, e.value]),
+                [[1, 3, 'wolves'], [4, 5, 'bandits'], [6, 6, 'a dragon']]);
+        });
+
+        it('should accept an explicit die', () => {
+            const table = parseRollTable({ '@die': '2d6', '2-6': 'calm', '7-12': 'storm' });
+            assert.strictEqual(table.min, 2);
+            assert.strictEqual(table.max, 12);
+        });
+
+        it('should list missing and overlapping numbers', () => {
+            assert.throws(() => parseRollTable({ '1-2': 'a', '5-6': 'b' }), /missing 3-4/);
+            assert.throws(() => parseRollTable({ '1-4': 'a', '3-6': 'b' }), /overlapping 3-4/);
+            assert.throws(() => parseRollTable({ '@die': 'd4', '1-6': 'a' }), /d4 rolls 1-4, but entries cover 5-6/);
+        });
+
+        it('should not mistake numbered groups of lists for roll tables', () => {
+            assert.strictEqual(isRollTable({ 1: ['coins'], 2: ['gems'] }), false);
+            assert.strictEqual(isRollTable(['a', 'b']), false);
+            assert.strictEqual(isRollTable(encounters), true);
+        });
+    });
+
+    describe('engine', () => {
+        it('should roll on the table when referenced normally', () => {
+            const engine = new SigilEngine({ encounters }, { seed: 'roll' });
+            const seen = new Set<string>();
+            for (let i = 0; i < 100; i++) {
+                seen.add(engine.generate('[encounters]'));
+            }
+            assert.deepStrictEqual([...seen].sort(), ['a dragon', 'bandits', 'wolves']);
+        });
+
+        it('should process entries and honour exclusions', () => {
+            const engine = new SigilEngine({ encounters: { '1-5': '[beast]', '6': 'a dragon' }, beast: ['bear'] });
+            for (let i = 0; i < 20; i++) {
+                assert.strictEqual(engine.generate('[encounters!dragon]'), 'bear');
+            }
+        });
+
+        it('should look up an externally rolled result with rollOn', () => {
+            const engine = new SigilEngine({ encounters, tables: { weather: { '@die': 'd2', '1': 'rain', '2': 'sun' } } });
+            assert.strictEqual(engine.rollOn('encounters', 2), 'wolves');
+            assert.strictEqual(engine.rollOn('encounters', 6), 'a dragon');
+            assert.strictEqual(engine.rollOn('tables.weather', 2), 'sun');
+            assert.strictEqual(engine.rollOn('encounters', 7), '');
+        });
+
+        it('should report bad rolls and invalid tables in strict mode', () => {
+            const engine = new SigilEngine({ encounters, broken: { '1': 'a', '3-4': 'c' } }, { errorMode: 'strict' });
+            assert.throws(() => engine.rollOn('encounters', 9), /Roll 9 is outside the 1-6 range of roll table "encounters"/);
+            assert.throws(() => engine.generate('[broken]'), /Invalid roll table "broken": .*missing 2/);
+        });
+    });
+
+    describe('loading', () => {
+        it('should reject gapped tables with the line number', () => {
+            const yaml = 'other:\n  - x\nencounters:\n  1-3: wolves\n  5-6: bandits\n';
+            assert.throws(() => parseYamlContent(yaml), /Invalid roll table "encounters": .*missing 4.*\(at line 4, column 3\)/);
+        });
+
+        it('should leave numbered maps without a die or range as groups', () => {
+            const data = parseYamlContent('years:\n  1990: old\n  2020: new\n');
+            const engine = new SigilEngine(data, { errorMode: 'strict' });
+            assert.deepStrictEqual(data.years, { 1990: 'old', 2020: 'new' });
+            assert.strictEqual(engine.resolveEntry('years.2020', 0), 'new');
+            assert.strictEqual(isRollTable(data.years), false);
+            assert.strictEqual(isRollTable({ '@die': 'd2', 1: 'a', 2: 'b' }), true);
+            assert.strictEqual(isRollTable({ '1-2': 'a', 3: 'b' }), true);
+        });
+
+        it('should report invalid tables from the validator', () => {
+            const issues = validateSigil('encounters:\n  1-3: wolves\n  2-6: bandits\n');
+            assert.strictEqual(issues.length, 1);
+            assert.match(issues[0].message, /overlapping 2-3/);
+            assert.deepStrictEqual(validateSigil('encounters:\n  1-3: wolves\n  4-6: "[encounters]"\nmain:\n  - "[encounters]"\n'), []);
+        });
+    });
+});
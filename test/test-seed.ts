@@ -0,0 +1,61 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { createRandom } from '../src/random';
+
+const data = {
+    creature: ['goblin', 'orc ^2', 'troll', 'wyvern'],
+    mood: ['angry', 'sleepy', 'hungry'],
+    encounter: ['{a} [mood] [creature]', '[creature*{1-3}] and {2-12} rats', '{[nested]|nothing}'],
+    nested: ['[mood] [creature?]', '[encounter]']
+};
+
+describe('SIGIL Template Engine - Seeded RNG', () => {
+    it('createRandom should replay the same stream for the same seed', () => {
+        const a = createRandom(42);
+        const b = createRandom(42);
+        for (let i = 0; i < 20; i++) {
+            const value = a();
+            assert.strictEqual(value, b());
+            assert.ok(value >= 0 && value < 1, 'Values should be in [0, 1)');
+        }
+        assert.notStrictEqual(createRandom('42')(), createRandom('43')());
+    });
+
+    it('generateWithSeed should be reproducible including nested references', () => {
+        const engine = new SigilEngine(data);
+        for (let seed = 0; seed < 50; seed++) {
+            const first = engine.generateWithSeed('[encounter] / [encounter]', seed);
+            const second = new SigilEngine(data).generateWithSeed('[encounter] / [encounter]', seed);
+            assert.strictEqual(first.text, second.text, `Seed ${seed} should reproduce`);
+            assert.strictEqual(first.seed, seed);
+        }
+    });
+
+    it('generateWithSeed should vary with the seed', () => {
+        const engine = new SigilEngine(data);
+        const outputs = new Set<string>();
+        for (let seed = 0; seed < 50; seed++) {
+            outputs.add(engine.generateWithSeed('[encounter]', seed).text);
+        }
+        assert.ok(outputs.size > 1, 'Different seeds should produce different results');
+    });
+
+    it('generateWithSeed without a seed should return one that reproduces the run', () => {
+        const engine = new SigilEngine(data);
+        const result = engine.generateWithSeed('[encounter] [encounter]');
+        assert.ok(result.seed !== undefined, 'Should return the seed used');
+        assert.strictEqual(engine.generateWithSeed('[encounter] [encounter]', result.seed).text, result.text);
+    });
+
+    it('seed option and setSeed should make generate() deterministic', () => {
+        const a = new SigilEngine(data, { seed: 'campaign-7' });
+        const b = new SigilEngine(data, { seed: 'campaign-7' });
+        const runA = [a.generate('[encounter]'), a.generate('[encounter]'), a.generate('[encounter]')];
+        const runB = [b.generate('[encounter]'), b.generate('[encounter]'), b.generate('[encounter]')];
+        assert.deepStrictEqual(runA, runB);
+
+        a.setSeed('campaign-7');
+        assert.deepStrictEqual([a.generate('[encounter]'), a.generate('[encounter]'), a.generate('[encounter]')], runA);
+    });
+});
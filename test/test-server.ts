@@ -0,0 +1,128 @@
+import { describe, it, before, after } from 'node:test';
+import assert from 'node:assert';
+import type { AddressInfo } from 'net';
+import type { Server } from 'http';
+import { createSigilServer } from '../src/server';
+import { createSigilDataFromFiles } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+
+// Twelve levels of four references each: far more picks than the time budget allows
+const slow = Array.from({ length: 12 }, (_, i) => `level${i}:\n  - "[level${i + 1}][level${i + 1}][level${i + 1}][level${i + 1}]"\n`).join('') + 'level12:\n  - x\n';
+
+const files = {
+    'tables.yaml': 'creature:\n  - wolf\n  - bear\n  - owl\nweather:\n  "@tags": wet, dry\n  "@entries":\n    - rain (wet)\n    - sun (dry)\ntemplates:\n  beast:\n    - "A [creature]"\n',
+    'slow.yaml': slow
+};
+
+describe('SIGIL HTTP Server', () => {
+    let server: Server;
+    let base: string;
+
+    before(async () => {
+        server = createSigilServer(createSigilDataFromFiles(files), { timeout: 100, engine: { maxDepth: 20 } });
+        await new Promise<void>(resolve => server.listen(0, '127.0.0.1', resolve));
+        base = `http://127.0.0.1:${(server.address() as AddressInfo).port}`;
+    });
+
+    after(async () => {
+        await new Promise(resolve => server.close(resolve));
+    });
+
+    async function post(route: string, body: unknown): Promise<{ status: number; json: any }> {
+        const response = await fetch(base + route, { method: 'POST', body: typeof body === 'string' ? body : JSON.stringify(body) });
+        return { status: response.status, json: await response.json() };
+    }
+
+    it('should generate seeded results', async () => {
+        const { status, json } = await post('/generate', { template: '[creature] in the [weather]', seed: 42, count: 5 });
+        assert.strictEqual(status, 200);
+        assert.strictEqual(json.seed, 42);
+        const engine = new SigilEngine(createSigilDataFromFiles(files).lists, { seed: 42 });
+        assert.deepStrictEqual(json.results, engine.generateBatch('[creature] in the [weather]', 5));
+    });
+
+    it('should take vars, tags, template names and structured results', async () => {
+        assert.deepStrictEqual((await post('/generate', { template: '[who] sees [weather]', vars: { who: 'Ana' }, tags: ['dry'] })).json.results, ['Ana sees sun']);
+        assert.match((await post('/generate', { template: 'beast' })).json.results[0], /^A (wolf|bear|owl)$/);
+        const { json } = await post('/generate', { template: '[creature]', seed: 1, structured: true });
+        assert.strictEqual(json.results[0].type, 'template');
+        assert.strictEqual(json.results[0].children[0].name, 'creature');
+        assert.strictEqual(typeof json.seed, 'number', 'a seed is chosen when none is given');
+    });
+
+    it('should answer concurrent requests independently', async () => {
+        const seeds = Array.from({ length: 20 }, (_, i) => i);
+        const responses = await Promise.all(seeds.map(seed => post('/generate', { template: '[creature.unique*3]', seed })));
+        const engineFor = (seed: number) => new SigilEngine(createSigilDataFromFiles(files).lists, { seed });
+        responses.forEach((response, seed) => assert.deepStrictEqual(response.json.results, [engineFor(seed).generate('[creature.unique*3]')]));
+    });
+
+    it('should list tables', async () => {
+        const { tables, templates } = await (await fetch(`${base}/tables`)).json();
+        assert.deepStrictEqual(tables.slice(0, 2), ['creature', 'level0']);
+        assert.strictEqual(tables.length, 15);
+        assert.deepStrictEqual(templates, ['beast']);
+    });
+
+    it('should validate sources against the loaded tables', async () => {
+        const { status, json } = await post('/validate', { source: 'scene:\n  - "[creature] meets [ghost]"\n' });
+        assert.strictEqual(status, 200);
+        assert.strictEqual(json.issues.length, 1);
+        assert.deepStrictEqual([json.issues[0].line, json.issues[0].severity], [2, 'error']);
+        assert.match(json.issues[0].message, /ghost/);
+    });
+
+    it('should report errors in the SigilError format', async () => {
+        const unknown = await post('/generate', { template: '[ghost]' });
+        assert.strictEqual(unknown.status, 422);
+        assert.strictEqual(unknown.json.error.code, 'unknown_table');
+        const slow = await post('/generate', { template: '[level0]' });
+        assert.strictEqual(slow.status, 503);
+        assert.strictEqual(slow.json.error.code, 'timeout');
+        assert.strictEqual((await post('/generate', '{not json')).json.error.code, 'bad_request');
+        assert.strictEqual((await post('/generate', { template: 'x', count: 1000 })).status, 400);
+        assert.strictEqual((await fetch(`${base}/generate`)).status, 405);
+        assert.strictEqual((await fetch(`${base}/nope`)).status, 404);
+    });
+
+    it('should leave the caller\'s tables as they were', () => {
+        const data = createSigilDataFromFiles(files);
+        createSigilServer(data);
+        assert.strictEqual(Object.isFrozen(data.lists), false);
+        assert.strictEqual(Object.isFrozen(data.lists.creature), false);
+        assert.strictEqual(Object.isFrozen(data.templates), false);
+        (data.lists.creature as string[]).push('fox');
+        assert.strictEqual((data.lists.creature as string[]).length, 4);
+    });
+
+    it('should keep one engine without sharing .unique picks between requests', async () => {
+        const shared = createSigilServer(createSigilDataFromFiles(files), { engine: { uniqueScope: 'engine' } });
+        await new Promise<void>(resolve => shared.listen(0, '127.0.0.1', resolve));
+        const url = `http://127.0.0.1:${(shared.address() as AddressInfo).port}/generate`;
+        const generate = async () => (await fetch(url, { method: 'POST', body: JSON.stringify({ template: '[creature.unique*3]', seed: 3 }) })).json();
+        try {
+            const first = await generate();
+            assert.deepStrictEqual(await generate(), first);
+            assert.deepStrictEqual(first.results, [new SigilEngine(createSigilDataFromFiles(files).lists, { seed: 3 }).generate('[creature.unique*3]')]);
+        } finally {
+            await new Promise(resolve => shared.close(resolve));
+        }
+    });
+
+    it('should give structured results one deadline for the whole request', async () => {
+        // Each result takes about 60ms: one fits the 100ms budget, five do not
+        const wait = () => { const until = Date.now() + 30; while (Date.now() < until); };
+        const busy = createSigilServer(createSigilDataFromFiles(files), { timeout: 100, engine: { trace: wait } });
+        await new Promise<void>(resolve => busy.listen(0, '127.0.0.1', resolve));
+        const url = `http://127.0.0.1:${(busy.address() as AddressInfo).port}/generate`;
+        const generate = (count: number) => fetch(url, { method: 'POST', body: JSON.stringify({ template: '[creature] and [creature]', count, structured: true }) });
+        try {
+            assert.strictEqual((await generate(1)).status, 200);
+            const response = await generate(5);
+            assert.strictEqual(response.status, 503);
+            assert.strictEqual((await response.json()).error.code, 'timeout');
+        } finally {
+            await new Promise(resolve => busy.close(resolve));
+        }
+    });
+});
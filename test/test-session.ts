@@ -0,0 +1,69 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { SigilSession } from '../src/session';
+
+const data = {
+    town_name: ['Ashford', 'Brindle'],
+    event: ['a fire', 'a wedding']
+};
+
+describe('SIGIL Sessions', () => {
+    it('should keep captured variables across calls', () => {
+        const session = new SigilSession(new SigilEngine(data));
+        const intro = session.generate('[town_name->town] lies by the river');
+        const town = session.get('town')!;
+        assert.ok(intro.startsWith(town));
+        assert.match(session.generate('Trouble in [town]: [event]'), new RegExp(`^Trouble in ${town}: a (fire|wedding)$`));
+    });
+
+    it('should let per-call variables win for that call only', () => {
+        const session = new SigilSession(new SigilEngine(data), { town: 'Ashford' });
+        assert.strictEqual(session.generate('[town]', { vars: { town: 'Crow Hill' } }), 'Crow Hill');
+        assert.strictEqual(session.get('town'), 'Ashford');
+
+        // Even when the template assigns the shadowed name
+        session.generate('[town_name->town]', { vars: { town: 'Crow Hill' } });
+        assert.strictEqual(session.get('town'), 'Ashford');
+        assert.strictEqual(session.generate('[town]'), 'Ashford');
+    });
+
+    it('should let captures shadow session variables for that call only', () => {
+        const session = new SigilSession(new SigilEngine(data), { town: 'Crow Hill' });
+        const text = session.generate('[town_name->town] lies by the river, far from [town]');
+        assert.match(text, /^(Ashford|Brindle) lies by the river, far from \1$/, 'The capture is used for the rest of the call');
+        assert.strictEqual(session.get('town'), 'Crow Hill');
+        assert.strictEqual(session.generate('[town]'), 'Crow Hill');
+    });
+
+    it('should list, set and delete variables', () => {
+        const session = new SigilSession(new SigilEngine(data));
+        session.set('mood', 'grim');
+        session.generate('[event->last_event]');
+        assert.deepStrictEqual(Object.keys(session.variables()).sort(), ['last_event', 'mood']);
+        assert.strictEqual(session.delete('mood'), true);
+        assert.strictEqual(session.delete('mood'), false);
+        session.clear();
+        assert.deepStrictEqual(session.variables(), {});
+    });
+
+    it('should save and restore state', () => {
+        const engine = new SigilEngine(data);
+        const session = new SigilSession(engine);
+        session.generate('[town_name->town]');
+        const saved = session.snapshot();
+
+        const reloaded = new SigilSession(engine);
+        reloaded.restore(saved);
+        assert.deepStrictEqual(reloaded.variables(), session.variables());
+        assert.strictEqual(reloaded.generate('[town]'), session.get('town'));
+    });
+
+    it('should reject invalid snapshots and keep its state', () => {
+        const session = new SigilSession(new SigilEngine(data), { town: 'Ashford' });
+        assert.throws(() => session.restore('not json'), /Invalid session snapshot/);
+        assert.throws(() => session.restore('{"version":99,"vars":{}}'), /expected version 1/);
+        assert.throws(() => session.restore('{"version":1,"vars":{"a":3}}'), /must map names to strings/);
+        assert.strictEqual(session.get('town'), 'Ashford');
+    });
+});
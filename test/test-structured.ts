@@ -0,0 +1,61 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+
+describe('SIGIL Structured Generation', () => {
+    it('should return the same text as generate with one child per reference', () => {
+        const data = { weapon: ['[metal] sword', 'bow'], metal: ['iron'] };
+        const structured = new SigilEngine(data, { seed: 7 }).generateStructured('A [weapon] and [weapon]');
+        const flat = new SigilEngine(data, { seed: 7 }).generate('A [weapon] and [weapon]');
+
+        assert.strictEqual(structured.type, 'template');
+        assert.strictEqual(structured.name, 'A [weapon] and [weapon]');
+        assert.strictEqual(structured.text, flat);
+        assert.strictEqual(structured.children.length, 2);
+        for (const child of structured.children) {
+            assert.strictEqual(child.type, 'table');
+            assert.strictEqual(child.name, 'weapon');
+            assert.strictEqual(child.text, child.index === 0 ? 'iron sword' : 'bow');
+        }
+    });
+
+    it('should nest references expanded inside entries', () => {
+        const engine = new SigilEngine({ weapon: ['[metal] sword ^3'], metal: ['iron'] });
+        const root = engine.generateStructured('[weapon.capitalize]');
+        const weapon = root.children[0];
+        assert.deepStrictEqual(weapon, {
+            type: 'table',
+            name: 'weapon',
+            index: 0,
+            weight: 3,
+            text: 'Iron sword',
+            children: [{ type: 'table', name: 'metal', index: 0, weight: 1, text: 'iron', children: [] }]
+        });
+    });
+
+    it('should record dice, roll tables and variables', () => {
+        const engine = new SigilEngine({ encounters: { '1-6': 'wolves' }, name: ['Vex'] });
+        const root = engine.generateStructured('[2d6] [encounters] [name->n] [n]');
+        assert.deepStrictEqual(root.children.map(c => c.type), ['dice', 'table', 'table', 'variable']);
+        assert.strictEqual(String(root.children[0].roll), root.children[0].text);
+        assert.ok(root.children[1].roll! >= 1 && root.children[1].roll! <= 6);
+        assert.strictEqual(root.children[1].weight, 6);
+        assert.strictEqual(root.children[3].text, 'Vex');
+    });
+
+    it('should serialise with a stable key order', () => {
+        const engine = new SigilEngine({ a: ['x'] });
+        assert.strictEqual(JSON.stringify(engine.generateStructured('[a]')),
+            '{"type":"template","name":"[a]","text":"x","children":[{"type":"table","name":"a","index":0,"weight":1,"text":"x","children":[]}]}');
+    });
+
+    it('should batch trees like generateBatch', () => {
+        const engine = new SigilEngine({ weapon: ['[metal] sword', 'bow', 'axe'], metal: ['iron', 'bronze'] });
+        const trees = engine.generateStructuredBatch('[weapon]', 4, { seed: 5 });
+        assert.deepStrictEqual(trees.map(tree => tree.text), engine.generateBatch('[weapon]', 4, { seed: 5 }));
+        assert.ok(trees.every(tree => tree.type === 'template' && tree.children.length === 1));
+
+        const distinct = engine.generateStructuredBatch('[weapon]', 4, { seed: 5, distinct: true });
+        assert.strictEqual(new Set(distinct.map(tree => tree.text)).size, 4);
+    });
+});
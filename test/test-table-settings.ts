@@ -0,0 +1,80 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { parseYamlContent } from '../src/yaml-loader';
+import { validateSigil } from '../src/validator';
+import { joinPicks } from '../src/table-settings';
+
+const chest = {
+    '@count': '3',
+    '@join': ', ',
+    '@last-join': ' and ',
+    '@entries': ['gold', 'gold', 'gold']
+};
+
+describe('SIGIL Table Settings', () => {
+    it('should join picks with a separate last joiner', () => {
+        const settings = { join: ', ', lastJoin: ' and ' };
+        assert.strictEqual(joinPicks([], settings), '');
+        assert.strictEqual(joinPicks(['a'], settings), 'a');
+        assert.strictEqual(joinPicks(['a', 'b'], settings), 'a and b');
+        assert.strictEqual(joinPicks(['a', 'b', 'c'], settings), 'a, b and c');
+    });
+
+    it('should expand a plain reference to the default count', () => {
+        const engine = new SigilEngine({ chest });
+        assert.strictEqual(engine.generate('[chest]'), 'gold, gold and gold');
+        assert.strictEqual(engine.generate('[chest*2]'), 'gold and gold', 'explicit count overrides @count');
+        assert.strictEqual(engine.generate('[chest*1]'), 'gold');
+    });
+
+    it('should roll dice and range counts', () => {
+        const engine = new SigilEngine({
+            dice: { '@count': '1d4', '@entries': ['x'] },
+            range: { '@count': '2-3', '@entries': ['x'] }
+        });
+        for (let i = 0; i < 30; i++) {
+            const dice = engine.generate('[dice]').split(', ').length;
+            const range = engine.generate('[range]').split(', ').length;
+            assert.ok(dice >= 1 && dice <= 4, `dice count ${dice}`);
+            assert.ok(range >= 2 && range <= 3, `range count ${range}`);
+        }
+    });
+
+    it('should return empty text or the fallback when the count is zero', () => {
+        const engine = new SigilEngine({
+            none: { '@count': '0', '@entries': ['x'] },
+            fallback: { '@count': '1d1-1', '@empty': 'an empty [container]', '@entries': ['x'] },
+            container: ['chest']
+        });
+        assert.strictEqual(engine.generate('[none]'), '');
+        assert.strictEqual(engine.generate('[fallback]'), 'an empty chest');
+    });
+
+    it('should pick without repeats within one reference when unique', () => {
+        const engine = new SigilEngine({ loot: { '@count': '5', '@unique': true, '@entries': ['a', 'b', 'c'] } });
+        for (let i = 0; i < 20; i++) {
+            const items = engine.generate('[loot] / [loot]').split(' / ').map(group => group.split(', ').sort());
+            assert.deepStrictEqual(items, [['a', 'b', 'c'], ['a', 'b', 'c']]);
+        }
+    });
+
+    it('should load settings tables from YAML and merge their entries', () => {
+        const data = parseYamlContent([
+            'chest:',
+            '  "@count": 2',
+            '  "@last-join": " and "',
+            '  "@entries":',
+            '    - "[coin]"',
+            'coin:',
+            '  - copper'
+        ].join('\n') + '\n');
+        assert.strictEqual(new SigilEngine(data).generate('[chest]'), 'copper and copper');
+        assert.deepStrictEqual(validateSigil('chest:\n  "@count": 2\n  "@entries":\n    - x\nmain:\n  - "[chest]"\n'), []);
+    });
+
+    it('should apply settings to roll tables', () => {
+        const engine = new SigilEngine({ hoard: { '@count': '2', '@join': ' + ', '1-6': 'gems' } });
+        assert.strictEqual(engine.generate('[hoard]'), 'gems + gems');
+    });
+});
@@ -0,0 +1,123 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine, parseWeight } from '../src/template-engine';
+import { createSigilData } from '../src/yaml-loader';
+import { tokenize } from '../src/tokenizer';
+import { ResolveError } from '../src/errors';
+
+const source = `encounters:
+  "@tags": urban, night, wild, winter
+  "@entries":
+    - a pickpocket (urban, night)
+    - a patrol of guards (urban) ^2
+    - wolves (wild, night, winter)
+    - a lost merchant (wild)
+weather:
+  - rain
+  - fog
+`;
+
+function engine(options = {}) {
+    return new SigilEngine(createSigilData([source]).lists, { errorMode: 'strict', ...options });
+}
+
+function outcomes(sigil: SigilEngine, template: string, options = {}): string[] {
+    return sigil.analyze(template, options).outcomes.map(outcome => outcome.text).sort();
+}
+
+describe('SIGIL Entry Tags', () => {
+    it('should split tags off entries after the weight', () => {
+        const declared = ['urban', 'night-time', 'rusty'];
+        assert.deepStrictEqual(parseWeight('a patrol (Urban, night-time) ^2', declared), { value: 'a patrol', weight: 2, tags: ['urban', 'night-time'] });
+        assert.deepStrictEqual(parseWeight('a pickpocket (urban)', declared), { value: 'a pickpocket', weight: 1, tags: ['urban'] });
+        assert.deepStrictEqual(parseWeight('(urban)', declared), { value: '(urban)', weight: 1 });
+        assert.deepStrictEqual(parseWeight('a sword \\(rusty)', declared), { value: 'a sword \\(rusty)', weight: 1 });
+    });
+
+    it('should keep a parenthesis of undeclared names as text', () => {
+        assert.deepStrictEqual(parseWeight('a sword (rusty) ^2'), { value: 'a sword (rusty)', weight: 2 });
+        assert.deepStrictEqual(parseWeight('a patrol (urban, tired)', ['urban']), { value: 'a patrol (urban, tired)', weight: 1 });
+
+        const sigil = new SigilEngine(createSigilData([`weapons:
+  - a sword (rusty)
+tagged:
+  "@tags": urban
+  "@entries":
+    - a pickpocket (urban)
+    - a sword (rusty)
+`]).lists, { errorMode: 'strict' });
+        assert.strictEqual(sigil.generate('[weapons]'), 'a sword (rusty)');
+        assert.deepStrictEqual(outcomes(sigil, '[tagged]'), ['a pickpocket', 'a sword (rusty)']);
+        assert.deepStrictEqual(sigil.entries('tagged').map(entry => entry.tags), [['urban'], []]);
+        assert.deepStrictEqual(outcomes(sigil, '[tagged #!urban]'), ['a sword (rusty)']);
+    });
+
+    it('should leave tags out of the generated text', () => {
+        assert.deepStrictEqual(outcomes(engine(), '[encounters]'), ['a lost merchant', 'a patrol of guards', 'a pickpocket', 'wolves']);
+        assert.strictEqual(engine().generate('a sword \\(rusty)'), 'a sword (rusty)');
+    });
+
+    it('should keep entries having all the tags of a reference', () => {
+        assert.deepStrictEqual(outcomes(engine(), '[encounters #urban #night]'), ['a pickpocket']);
+        assert.deepStrictEqual(outcomes(engine(), '[encounters #night]'), ['a pickpocket', 'wolves']);
+    });
+
+    it('should drop entries with a negated tag', () => {
+        assert.deepStrictEqual(outcomes(engine(), '[encounters #night #!winter]'), ['a pickpocket']);
+        assert.deepStrictEqual(outcomes(engine(), '[encounters #!urban]'), ['a lost merchant', 'wolves']);
+    });
+
+    it('should keep weights in proportion among the remaining entries', () => {
+        const result = engine().analyze('[encounters #urban]');
+        assert.deepStrictEqual(result.outcomes.map(outcome => [outcome.text, outcome.probability]), [
+            ['a patrol of guards', 2 / 3],
+            ['a pickpocket', 1 / 3]
+        ]);
+    });
+
+    it('should combine tag filters with other reference parts', () => {
+        const sigil = engine();
+        assert.deepStrictEqual(outcomes(sigil, '[encounters!merchant #wild]'), ['wolves']);
+        assert.deepStrictEqual(outcomes(sigil, '[encounters.capitalize #urban #night]'), ['A pickpocket']);
+        assert.strictEqual(sigil.generate('[encounters #urban #night->e], again [e]'), 'a pickpocket, again a pickpocket');
+        assert.strictEqual(sigil.generate('[encounters*2 #urban #night]'), 'a pickpocket, a pickpocket');
+    });
+
+    it('should apply the call\'s tags to every tagged table', () => {
+        const sigil = engine();
+        assert.deepStrictEqual(outcomes(sigil, '[encounters] in the [weather]', { tags: ['urban', 'night'] }),
+            ['a pickpocket in the fog', 'a pickpocket in the rain']);
+        assert.deepStrictEqual(outcomes(sigil, '[encounters]', { tags: ['!urban'] }), ['a lost merchant', 'wolves']);
+        assert.deepStrictEqual(outcomes(sigil, '[encounters #winter]', { tags: ['night'] }), ['wolves']);
+        for (let i = 0; i < 20; i++) {
+            assert.strictEqual(sigil.generate('[encounters]', { tags: ['urban', 'night'] }), 'a pickpocket');
+        }
+    });
+
+    it('should report the table and tags when nothing matches', () => {
+        assert.throws(() => engine().generate('[encounters #urban #winter]'), (error: unknown) => {
+            assert.ok(error instanceof ResolveError);
+            assert.strictEqual(error.code, 'no_tag_match');
+            assert.strictEqual(error.table, 'encounters');
+            assert.strictEqual(error.description, 'No entries of table "encounters" match #urban #winter');
+            return true;
+        });
+        assert.throws(() => engine().generate('[encounters #night]', { tags: ['!wild', '!urban'] }),
+            /No entries of table "encounters" match #night #!wild #!urban/);
+        assert.throws(() => engine().generate('[weather #wet]'), /No entries of table "weather" match #wet/);
+        assert.strictEqual(engine({ errorMode: 'silent' }).generate('Hmm [encounters #urban #winter].'), 'Hmm .');
+    });
+
+    it('should tokenize entry tags and tag filters as keywords', () => {
+        const tokens = tokenize('e:\n  "@tags": urban, night\n  "@entries":\n    - thief (urban, night) ^2\n    - a sword (rusty)\nt: "[e #urban #!winter]"\n')
+            .filter(token => token.kind === 'keyword' || token.kind === 'reference' || token.kind === 'weight');
+        assert.deepStrictEqual(tokens.map(token => [token.kind, token.text]), [
+            ['keyword', 'urban'],
+            ['keyword', 'night'],
+            ['weight', '^2'],
+            ['reference', 'e'],
+            ['keyword', 'urban'],
+            ['keyword', 'winter']
+        ]);
+    });
+});
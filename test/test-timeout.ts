@@ -0,0 +1,70 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import { SigilSession } from '../src/session';
+import { ResolveError } from '../src/errors';
+
+// A million picks: far longer than the budgets used below
+const data = {
+    slow: ['[slower*1000]'],
+    slower: ['[leaf*1000]'],
+    leaf: ['a', 'b'],
+    rumors: ['the mill burned', 'the well is dry', 'wolves at night']
+};
+
+function assertTimeout(run: () => unknown) {
+    assert.throws(run, (error: unknown) => error instanceof ResolveError && error.code === 'timeout');
+}
+
+describe('SIGIL Timeouts and Cancellation', () => {
+    it('should throw the abort reason of an aborted signal', () => {
+        const engine = new SigilEngine(data);
+        const controller = new AbortController();
+        controller.abort(new Error('closed'));
+        assert.throws(() => engine.generate('[leaf]', { signal: controller.signal }), /closed/);
+    });
+
+    it('should throw an aborted ResolveError for signals without a reason', () => {
+        // Signals on Node before 17.2 have neither reason nor throwIfAborted
+        const signal = { aborted: true } as AbortSignal;
+        const engine = new SigilEngine(data);
+        assert.throws(() => engine.generate('[leaf]', { signal }),
+            (error: unknown) => error instanceof ResolveError && error.code === 'aborted');
+        assert.throws(() => engine.generateBatch('[leaf]', 3, { signal }),
+            (error: unknown) => error instanceof ResolveError && error.code === 'aborted');
+    });
+
+    it('should stop a long generation once its timeout runs out, whatever the errorMode', () => {
+        for (const errorMode of ['graceful', 'strict', 'silent'] as const) {
+            const engine = new SigilEngine(data, { errorMode });
+            const started = Date.now();
+            assertTimeout(() => engine.generate('[slow]', { timeout: 20 }));
+            assert.ok(Date.now() - started < 2000, 'Should stop soon after the deadline');
+        }
+    });
+
+    it('should apply the engine timeout to every call unless overridden', () => {
+        const engine = new SigilEngine(data, { timeout: 20 });
+        assertTimeout(() => engine.generate('[slow]'));
+        assertTimeout(() => engine.generateStructured('[slow]'));
+        assert.match(engine.generate('[leaf]'), /^[ab]$/);
+        assert.match(engine.generate('[slower]', { timeout: 60000 }), /^[ab](, [ab]){999}$/);
+    });
+
+    it('should cover a whole batch with one timeout', () => {
+        const engine = new SigilEngine(data);
+        assertTimeout(() => engine.generateBatch('[slower]', 100000, { timeout: 20 }));
+    });
+
+    it('should leave session variables as they were when a call times out', () => {
+        const session = new SigilSession(new SigilEngine(data), { town: 'Ashford' });
+        assertTimeout(() => session.generate('[rumors->town][slow]', { timeout: 20 }));
+        assert.deepStrictEqual(session.variables(), { town: 'Ashford' });
+    });
+
+    it('should not use up engine-scope unique picks in a cancelled call', () => {
+        const engine = new SigilEngine(data, { uniqueScope: 'engine' });
+        assertTimeout(() => engine.generate('[rumors.unique*2][slow]', { timeout: 20 }));
+        assert.strictEqual(new Set(engine.generateBatch('[rumors.unique]', 3)).size, 3);
+    });
+});
@@ -0,0 +1,110 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { tokenize, encodeSemanticTokens, SEMANTIC_TOKEN_LEGEND } from '../src/tokenizer';
+import type { Token } from '../src/tokenizer';
+
+// [kind, text] pairs without whitespace, for compact expectations
+function kinds(tokens: Token[]): [string, string][] {
+    return tokens.filter(token => token.kind !== 'whitespace').map(token => [token.kind, token.text]);
+}
+
+const source = `# Monsters
+monsters:
+  - "[size.capitalize] wolf ^2"
+  - bear ^0
+  - '[name->hero] meets [hero]'
+"@count": 1d4
+plain: text # trailing
+`;
+
+describe('SIGIL Tokenizer', () => {
+    it('should be lossless', () => {
+        const inputs = [source, '', 'a:\r\n  - "[x] {a|b}"\r\n', 'broken: "[unclosed {a|\n  - ]] }', 'x: |\n  one [y]\n  two\nz: >-\n  folded\n'];
+        for (const input of inputs) {
+            assert.strictEqual(tokenize(input).map(token => token.text).join(''), input);
+        }
+    });
+
+    it('should classify headers, settings, entries, weights and comments', () => {
+        assert.deepStrictEqual(kinds(tokenize(source)), [
+            ['comment', '# Monsters'],
+            ['table-header', 'monsters'], ['punctuation', ':'],
+            ['punctuation', '-'], ['punctuation', '"'], ['punctuation', '['], ['reference', 'size'], ['punctuation', '.'],
+            ['modifier', 'capitalize'], ['punctuation', ']'], ['entry-text', ' wolf'], ['weight', '^2'], ['punctuation', '"'],
+            ['punctuation', '-'], ['entry-text', 'bear'], ['error', '^0'],
+            ['punctuation', '-'], ['punctuation', "'"], ['punctuation', '['], ['reference', 'name'], ['punctuation', '->'],
+            ['variable', 'hero'], ['punctuation', ']'], ['entry-text', ' meets '], ['punctuation', '['], ['variable', 'hero'],
+            ['punctuation', ']'], ['punctuation', "'"],
+            ['punctuation', '"'], ['setting', '@count'], ['punctuation', '"'], ['punctuation', ':'], ['entry-text', '1d4'],
+            ['table-header', 'plain'], ['punctuation', ':'], ['entry-text', 'text'], ['comment', '# trailing']
+        ]);
+    });
+
+    it('should report positions', () => {
+        const weight = tokenize(source).find(token => token.kind === 'weight')!;
+        assert.strictEqual(weight.line, 3);
+        assert.strictEqual(weight.column, 29);
+        assert.strictEqual(source.slice(weight.start, weight.end), '^2');
+    });
+
+    it('should tokenize dice, inline choices, conditionals and expressions', () => {
+        const tokens = kinds(tokenize('a: "[2d6] {1-6} {a} {x|[y*{1-3}?]} [if hp >= 3 then big else small] [= hp * 2.floor]"'));
+        const wanted: [string, string][] = [
+            ['dice', '2d6'], ['dice', '1-6'], ['keyword', 'a'], ['punctuation', '|'], ['reference', 'y'], ['dice', '{1-3}'],
+            ['keyword', 'if'], ['variable', 'hp'], ['keyword', '>='], ['keyword', 'then'], ['keyword', 'else'],
+            ['keyword', '='], ['modifier', 'floor']
+        ];
+        for (const token of wanted) {
+            assert.ok(tokens.some(([kind, text]) => kind === token[0] && text === token[1]), `Missing ${token.join(' ')}`);
+        }
+        assert.ok(tokens.filter(([kind, text]) => kind === 'variable' && text === 'hp').length === 2);
+    });
+
+    it('should tokenize fallbacks and custom modifiers', () => {
+        const tokens = kinds(tokenize("a: '[spices.shout ?? \"salt\"]'", { modifiers: ['shout'] }));
+        assert.deepStrictEqual(tokens.slice(4, 11), [
+            ['reference', 'spices'], ['punctuation', '.'], ['modifier', 'shout'], ['keyword', '??'],
+            ['punctuation', '"'], ['entry-text', 'salt'], ['punctuation', '"']
+        ]);
+    });
+
+    it('should tokenize repetition options', () => {
+        const tokens = kinds(tokenize('a: "[room*1d4+1, sep=\\"; \\", unique]"'));
+        assert.deepStrictEqual(tokens.slice(4), [
+            ['reference', 'room'], ['punctuation', '*'], ['dice', '1d4+1'], ['punctuation', ','], ['keyword', 'sep'],
+            ['punctuation', '='], ['entry-text', '\\"; \\"'], ['punctuation', ','], ['keyword', 'unique'], ['punctuation', ']'],
+            ['punctuation', '"']
+        ]);
+    });
+
+    it('should tokenize function calls', () => {
+        const tokens = kinds(tokenize("a: '[fn:date(\"YYYY\", [era]).upper]'"));
+        assert.deepStrictEqual(tokens.slice(4, 18), [
+            ['keyword', 'fn'], ['punctuation', ':'], ['modifier', 'date'], ['punctuation', '('],
+            ['punctuation', '"'], ['entry-text', 'YYYY'], ['punctuation', '"'], ['punctuation', ','],
+            ['punctuation', '['], ['reference', 'era'], ['punctuation', ']'], ['punctuation', ')'],
+            ['punctuation', '.'], ['modifier', 'upper']
+        ]);
+    });
+
+    it('should keep going after broken input', () => {
+        const tokens = kinds(tokenize('a:\n  - "[unclosed and } stray"\n  - fine [b]\n'));
+        assert.deepStrictEqual(tokens.filter(([kind]) => kind === 'error'), [['error', '['], ['error', '}']]);
+        assert.ok(tokens.some(([kind, text]) => kind === 'reference' && text === 'b'));
+    });
+
+    it('should continue block scalars and multi-line quotes', () => {
+        const tokens = tokenize('a: >\n  one [x]\n  two\nb: "open\n  [y] end"\nc: z\n');
+        assert.deepStrictEqual(kinds(tokens).filter(([kind]) => kind === 'reference' || kind === 'table-header'),
+            [['table-header', 'a'], ['reference', 'x'], ['table-header', 'b'], ['reference', 'y'], ['table-header', 'c']]);
+    });
+
+    it('should encode semantic tokens against the legend', () => {
+        const data = encodeSemanticTokens(tokenize('a:\n  - "[b]"\n'));
+        const type = (name: string) => SEMANTIC_TOKEN_LEGEND.tokenTypes.indexOf(name);
+        assert.deepStrictEqual(data, [
+            0, 0, 1, type('type'), 1,  // "a" declared
+            1, 6, 1, type('type'), 0   // "b" referenced
+        ]);
+    });
+});
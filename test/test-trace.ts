@@ -0,0 +1,83 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+import type { TraceEvent } from '../src/template-engine';
+
+function traced(data: object, options = {}): { engine: SigilEngine; events: TraceEvent[] } {
+    const events: TraceEvent[] = [];
+    const engine = new SigilEngine(data, { ...options, trace: event => events.push(event) });
+    return { engine, events };
+}
+
+describe('SIGIL Generation Trace', () => {
+    it('should report each step innermost first with its depth', () => {
+        const { engine, events } = traced({ weapon: ['[metal] sword ^3'], metal: ['iron'] });
+        assert.strictEqual(engine.generate('A [weapon.upper]'), 'A IRON SWORD');
+        assert.deepStrictEqual(events, [
+            { type: 'table', name: 'metal', index: 0, weight: 1, depth: 2, vars: {}, text: 'iron' },
+            { type: 'modifier', name: 'upper', input: 'iron sword', output: 'IRON SWORD', depth: 1, vars: {}, text: 'IRON SWORD' },
+            { type: 'table', name: 'weapon', index: 0, weight: 3, depth: 1, vars: {}, text: 'IRON SWORD' }
+        ]);
+    });
+
+    it('should report each modifier with its input and output at the depth of its reference', () => {
+        const { engine, events } = traced({ beast: ['owl'], nest: ['[beast.upper]'] });
+        engine.registerFunction('twice', ([text]) => `${text} ${text}`);
+        assert.strictEqual(engine.generate('[beast.plural.capitalize->b] and [b.upper], [=2+1.ordinal] [fn:twice(hoot).capitalize] [nest.lowercase]'),
+            'Owls and OWLS, third Hoot hoot owl');
+        assert.deepStrictEqual(events.map(event => [event.type, event.name, event.depth]), [
+            ['modifier', 'plural', 1],
+            ['modifier', 'capitalize', 1],
+            ['table', 'beast', 1],
+            ['modifier', 'upper', 1],
+            ['variable', 'b', 1],
+            ['modifier', 'ordinal', 1],
+            ['modifier', 'capitalize', 1],
+            ['function', 'twice', 1],
+            ['modifier', 'upper', 2],
+            ['table', 'beast', 2],
+            ['modifier', 'lowercase', 1],
+            ['table', 'nest', 1]
+        ]);
+        assert.deepStrictEqual(events.filter(event => event.type === 'modifier').slice(0, 4).map(event => [event.name, event.input, event.output]), [
+            ['plural', 'owl', 'owls'],
+            ['capitalize', 'owls', 'Owls'],
+            ['upper', 'Owls', 'OWLS'],
+            ['ordinal', '3', 'third']
+        ]);
+    });
+
+    it('should report dice rolls, roll tables and variables in scope', () => {
+        const { engine, events } = traced({ hero: ['Mira'], weather: { '1-6': 'rain' } }, { seed: 2 });
+        engine.generate('[hero->h] sees [weather] after [1d1] day, [h]', { vars: { time: 'dusk' } });
+        assert.deepStrictEqual(events.map(event => [event.type, event.name, event.roll, event.vars, event.text]), [
+            ['table', 'hero', undefined, { time: 'dusk' }, 'Mira'],
+            ['table', 'weather', events[1].roll, { time: 'dusk', h: 'Mira' }, 'rain'],
+            ['dice', '1d1', 1, { time: 'dusk', h: 'Mira' }, '1'],
+            ['variable', 'h', undefined, { time: 'dusk', h: 'Mira' }, 'Mira']
+        ]);
+        assert.ok(events[1].roll! >= 1 && events[1].roll! <= 6);
+    });
+
+    it('should mark missing tables', () => {
+        const { engine, events } = traced({}, { missingTable: 'empty' });
+        engine.generate('[nowhere]');
+        assert.deepStrictEqual(events, [{ type: 'table', name: 'nowhere', depth: 1, vars: {}, text: '', status: 'missing' }]);
+    });
+
+    it('should not change the results', () => {
+        const data = { loot: ['gold ^3', '[gem] ring'], gem: ['ruby', 'opal'] };
+        const { engine } = traced(data, { seed: 5 });
+        const plain = new SigilEngine(data, { seed: 5 });
+        for (let i = 0; i < 10; i++) {
+            assert.strictEqual(engine.generate('[loot] and [loot]'), plain.generate('[loot] and [loot]'));
+        }
+        assert.deepStrictEqual(engine.generateStructured('[gem]').children.length, 1);
+    });
+
+    it('should leave analyze untraced', () => {
+        const { engine, events } = traced({ coin: ['heads', 'tails'] });
+        assert.strictEqual(engine.analyze('[coin]').outcomes.length, 2);
+        assert.deepStrictEqual(events, []);
+    });
+});
@@ -0,0 +1,63 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+
+const rumors = ['the mayor is a ghoul', 'the well is poisoned', 'wolves in the hills', 'a dragon sleeps below'];
+
+describe('SIGIL Unique Selection', () => {
+    it('should not repeat entries within one reference', () => {
+        const engine = new SigilEngine({ rumors });
+        for (let i = 0; i < 50; i++) {
+            const picks = engine.generate('[rumors.unique*4]').split(', ');
+            assert.strictEqual(picks.length, 4);
+            assert.strictEqual(new Set(picks).size, 4, `Duplicates in ${picks.join(' / ')}`);
+        }
+    });
+
+    it('should not repeat entries across references in the same generation', () => {
+        const engine = new SigilEngine({ rumors });
+        for (let i = 0; i < 50; i++) {
+            const picks = engine.generate('[rumors.unique*2]|[rumors.unique]|[rumors.unique]').split(/, |\|/);
+            assert.strictEqual(new Set(picks).size, 4);
+        }
+    });
+
+    it('should stop at the table size when exhausted', () => {
+        const engine = new SigilEngine({ rumors });
+        assert.strictEqual(engine.generate('[rumors.unique*6]').split(', ').length, 4);
+        assert.strictEqual(engine.pickUnique('rumors', 6).length, 4);
+
+        const strict = new SigilEngine({ rumors }, { errorMode: 'strict' });
+        assert.throws(() => strict.generate('[rumors.unique*5]'), /No unique entries left in table "rumors"/);
+    });
+
+    it('should wrap around when configured', () => {
+        const engine = new SigilEngine({ rumors }, { uniqueExhausted: 'wrap' });
+        const picks = engine.pickUnique('rumors', 8);
+        assert.strictEqual(picks.length, 8);
+        assert.strictEqual(new Set(picks.slice(0, 4)).size, 4);
+        assert.strictEqual(new Set(picks.slice(4)).size, 4);
+    });
+
+    it('should persist the pool across calls with uniqueScope engine', () => {
+        const engine = new SigilEngine({ rumors }, { uniqueScope: 'engine' });
+        const seen = [engine.generate('[rumors.unique]'), engine.generate('[rumors.unique]'), ...engine.pickUnique('rumors', 2)];
+        assert.strictEqual(new Set(seen).size, 4);
+        assert.strictEqual(engine.generate('[rumors.unique]'), '', 'Pool should be exhausted');
+
+        engine.resetUnique();
+        assert.ok(rumors.includes(engine.generate('[rumors.unique]')));
+    });
+
+    it('should remove drawn weight from the pool', () => {
+        const engine = new SigilEngine({ monsters: ['dragon ^1000', 'goblin', 'orc'] }, { seed: 5 });
+        let dragonsSecond = 0;
+        for (let i = 0; i < 200; i++) {
+            const [first, second] = engine.pickUnique('monsters', 2);
+            assert.notStrictEqual(first, second);
+            if (second === 'dragon') dragonsSecond++;
+        }
+        // The dragon is almost always drawn first, so it should rarely be left for the second pick
+        assert.ok(dragonsSecond < 10, `dragon drawn second ${dragonsSecond} times`);
+    });
+});
@@ -0,0 +1,114 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { validateSigil } from '../src/validator';
+
+describe('SIGIL Validator', () => {
+    it('should return no issues for a clean file', () => {
+        const source = [
+            'creatures:',
+            '  - goblin ^2',
+            '  - orc',
+            'encounter:',
+            '  - "{a} [creatures] appears"',
+            ''
+        ].join('\n');
+        assert.deepStrictEqual(validateSigil(source), []);
+    });
+
+    it('should report undefined references with their position', () => {
+        const source = 'encounter:\n  - A [monstrs] appears\n';
+        const issues = validateSigil(source);
+        assert.strictEqual(issues.length, 1);
+        assert.strictEqual(issues[0].severity, 'error');
+        assert.match(issues[0].message, /undefined table "monstrs"/);
+        assert.strictEqual(issues[0].line, 2);
+        assert.strictEqual(issues[0].column, 7);
+        assert.strictEqual(issues[0].table, 'encounter');
+    });
+
+    it('should accept references defined in other loaded data', () => {
+        const source = 'encounter:\n  - A [monsters] appears\n';
+        assert.deepStrictEqual(validateSigil(source, { data: { monsters: ['orc'] } }), []);
+    });
+
+    it('should downgrade unresolved references to warnings for incomplete sets', () => {
+        const issues = validateSigil('encounter:\n  - A [monsters] appears\n', { incomplete: true });
+        assert.strictEqual(issues.length, 1);
+        assert.strictEqual(issues[0].severity, 'warning');
+    });
+
+    it('should report duplicate and empty tables', () => {
+        const source = 'monsters:\n  - orc\nloot: []\nmonsters:\n  - goblin\n';
+        const issues = validateSigil(source);
+        const duplicate = issues.find(i => /Duplicate table name "monsters"/.test(i.message));
+        assert.ok(duplicate, 'Should report duplicate table');
+        assert.strictEqual(duplicate!.line, 4);
+        const empty = issues.find(i => /"loot" is empty/.test(i.message));
+        assert.ok(empty, 'Should report empty table');
+        assert.strictEqual(empty!.severity, 'warning');
+    });
+
+    it('should report unterminated sigils', () => {
+        const issues = validateSigil('monsters:\n  - orc\nencounter:\n  - The [monsters appears\n');
+        const unterminated = issues.find(i => /Unterminated "\["/.test(i.message));
+        assert.ok(unterminated, 'Should report unterminated bracket');
+        assert.strictEqual(unterminated!.line, 4);
+        assert.strictEqual(unterminated!.column, 9);
+    });
+
+    it('should report a ] or } with nothing to close', () => {
+        const issues = validateSigil('door:\n  - oak ] \\] {a|b}}\n');
+        assert.deepStrictEqual(issues.map(i => [i.message, i.line, i.column]), [
+            ['Unmatched "]" sigil in "oak ] \\] {a|b}}"', 2, 9],
+            ['Unmatched "}" sigil in "oak ] \\] {a|b}}"', 2, 19]
+        ]);
+    });
+
+    it('should warn about tables no template reaches', () => {
+        const source = [
+            'templates:',
+            '  scene: "[weather] over the [place.[terrain]]"',
+            'weather:',
+            '  - rain',
+            'terrain:',
+            '  - hills',
+            'place:',
+            '  hills:',
+            '    - "[hill_name] Downs"',
+            'hill_name:',
+            '  - Barrow',
+            'old_weather:',
+            '  - "[weather] again"',
+            ''
+        ].join('\n');
+        assert.deepStrictEqual(validateSigil(source).map(i => [i.severity, i.message, i.line, i.column]), [
+            ['warning', 'Table "old_weather" is not used by any template', 12, 1]
+        ]);
+        // Without templates any table may be where generation starts
+        assert.deepStrictEqual(validateSigil('weather:\n  - rain\nold_weather:\n  - snow\n'), []);
+        assert.deepStrictEqual(validateSigil('templates:\n  any: "[[kind]]"\nkind:\n  - weather\nweather:\n  - rain\n'), []);
+    });
+
+    it('should report invalid weights and YAML syntax errors without throwing', () => {
+        const weights = validateSigil('monsters:\n  - orc ^0\n');
+        assert.ok(weights.some(i => i.severity === 'error' && i.line === 2 && /weight/.test(i.message)));
+
+        const syntax = validateSigil('monsters: [orc\n');
+        assert.strictEqual(syntax.length, 1);
+        assert.strictEqual(syntax[0].severity, 'error');
+    });
+
+    it('should warn about tag groups a table does not declare', () => {
+        const undeclared = validateSigil('encounters:\n  - a pickpocket (urban, night) ^2\n  - wolves\n');
+        assert.strictEqual(undeclared.length, 1);
+        assert.strictEqual(undeclared[0].severity, 'warning');
+        assert.strictEqual(undeclared[0].line, 2);
+        assert.match(undeclared[0].message, /table "encounters" has no "@tags"/);
+
+        const partly = validateSigil('encounters:\n  "@tags": urban\n  "@entries":\n    - a pickpocket (urban, night)\n    - a sword (rusty)\n    - a guard (urban)\n');
+        assert.deepStrictEqual(partly.map(i => [i.line, i.table]), [[4, 'encounters']]);
+        assert.match(partly[0].message, /does not declare \(night\)/);
+
+        assert.deepStrictEqual(validateSigil('loot:\n  - a pickpocket \\(urban)\n'), []);
+    });
+});
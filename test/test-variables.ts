@@ -0,0 +1,61 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine } from '../src/template-engine';
+
+const data = {
+    name: ['Aldric', 'Brenna', 'Corvin', 'Dara'],
+    hero: ['[name->n] the Brave'],
+    innkeeper: ['Old [name]']
+};
+
+describe('SIGIL Variables', () => {
+    it('should capture and reuse a value within one generation', () => {
+        const engine = new SigilEngine(data);
+        for (let i = 0; i < 20; i++) {
+            const result = engine.generate('[name->n] walks in. [n] sits down.');
+            const match = result.match(/^(\w+) walks in\. (\w+) sits down\.$/);
+            assert.ok(match, `Unexpected output: ${result}`);
+            assert.strictEqual(match[1], match[2]);
+        }
+    });
+
+    it('should make values captured in nested tables visible to later siblings', () => {
+        const engine = new SigilEngine(data);
+        for (let i = 0; i < 20; i++) {
+            const result = engine.generate('[hero] enters. Everyone cheers for [n].');
+            const match = result.match(/^(\w+) the Brave enters\. Everyone cheers for (\w+)\.$/);
+            assert.ok(match, `Unexpected output: ${result}`);
+            assert.strictEqual(match[1], match[2]);
+        }
+    });
+
+    it('should accept pre-set variables and apply modifiers to them', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[n.capitalize] waves', { vars: { n: 'eldra' } }), 'Eldra waves');
+        assert.strictEqual(engine.generateWithSeed('[n] [n]', 1, { vars: { n: 'x' } }).text, 'x x');
+    });
+
+    it('should let variables shadow tables and overwrite on reassignment', () => {
+        const engine = new SigilEngine(data);
+        assert.strictEqual(engine.generate('[name]', { vars: { name: 'Zed' } }), 'Zed');
+        const counter = new SigilEngine({ first: ['1'], second: ['2'] });
+        assert.strictEqual(counter.generate('[first->n] [second->n] [n]'), '1 2 2');
+    });
+
+    it('should capture dice and repeated references', () => {
+        const engine = new SigilEngine(data);
+        const gold = engine.generate('[2d6->gold] gold ([gold])').match(/^(\d+) gold \((\d+)\)$/);
+        assert.ok(gold);
+        assert.strictEqual(gold[1], gold[2]);
+
+        const names = engine.generate('[name*2->pair] / [pair]').split(' / ');
+        assert.strictEqual(names[0], names[1]);
+        assert.strictEqual(names[0].split(', ').length, 2);
+    });
+
+    it('should not leak variables between generations', () => {
+        const engine = new SigilEngine(data, { errorMode: 'strict' });
+        engine.generate('[name->n]');
+        assert.throws(() => engine.generate('[n]'), /Unknown table or variable "n"/);
+    });
+});
@@ -0,0 +1,62 @@
+import { describe, it } from 'node:test';
+import assert from 'node:assert';
+import { SigilEngine, parseWeight, selectWeighted, parseWeightedList } from '../src/template-engine';
+import { parseYamlContent } from '../src/yaml-loader';
+import { createRandom } from '../src/random';
+
+describe('SIGIL Weighted Entries', () => {
+    it('should parse weights and default to 1 when omitted', () => {
+        assert.deepStrictEqual(parseWeight('goblin ^5'), { value: 'goblin', weight: 5 });
+        assert.deepStrictEqual(parseWeight('goblin^0.5'), { value: 'goblin', weight: 0.5 });
+        assert.deepStrictEqual(parseWeight('goblin'), { value: 'goblin', weight: 1 });
+        assert.deepStrictEqual(parseWeight('Caret^'), { value: 'Caret^', weight: 1 });
+    });
+
+    it('should follow the weights over a large sample', () => {
+        const engine = new SigilEngine({
+            monsters: ['goblin ^5', 'orc', 'troll ^2', 'dragon ^0.5', 'kobold']
+        }, { seed: 'weights' });
+        const totalWeight = 5 + 1 + 2 + 0.5 + 1;
+        const expected: { [name: string]: number } = { goblin: 5, orc: 1, troll: 2, dragon: 0.5, kobold: 1 };
+        const counts: { [name: string]: number } = {};
+        const samples = 20000;
+
+        for (let i = 0; i < samples; i++) {
+            const result = engine.generate('[monsters]');
+            counts[result] = (counts[result] || 0) + 1;
+        }
+
+        assert.deepStrictEqual(Object.keys(counts).sort(), Object.keys(expected).sort());
+        for (const [name, weight] of Object.entries(expected)) {
+            const observed = counts[name] / samples;
+            const probability = weight / totalWeight;
+            assert.ok(Math.abs(observed - probability) < 0.02,
+                `${name}: observed ${observed.toFixed(3)}, expected ${probability.toFixed(3)}`);
+        }
+    });
+
+    it('should keep remaining entries in proportion when some are excluded', () => {
+        const random = createRandom(7);
+        const items = parseWeightedList(['goblin ^6', 'orc ^3', 'troll ^1']).filter(item => item.value !== 'goblin');
+        let orcs = 0;
+        const samples = 10000;
+        for (let i = 0; i < samples; i++) {
+            if (selectWeighted(items, random) === 'orc') orcs++;
+        }
+        assert.ok(Math.abs(orcs / samples - 0.75) < 0.02, `orc share ${orcs / samples} should be ~0.75`);
+    });
+
+    it('should never select entries with non-positive weights', () => {
+        const random = createRandom(1);
+        const items = parseWeightedList(['never ^0', 'always', 'nope ^-2']);
+        for (let i = 0; i < 500; i++) {
+            assert.strictEqual(selectWeighted(items, random), 'always');
+        }
+    });
+
+    it('should reject zero and negative weights with the line number', () => {
+        assert.throws(() => parseYamlContent('monsters:\n  - goblin ^2\n  - orc ^0\n'), /orc.*line 3/);
+        assert.throws(() => parseYamlContent('loot:\n  coins:\n    - copper\n    - silver ^-1\n'), /silver.*line 4/);
+        assert.doesNotThrow(() => parseYamlContent('monsters:\n  - goblin ^2\n  - orc\n'));
+    });
+});
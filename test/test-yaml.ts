@@ -1,6 +1,9 @@
 import { describe, it } from 'node:test';
 import assert from 'node:assert';
-import { loadSingleFile, loadSigilData } from '../src/yaml-loader';
+import { loadSingleFile, loadSigilData, parseYamlContent } from '../src/yaml-loader';
+import { SigilEngine } from '../src/template-engine';
+import { validateSigil } from '../src/validator';
+import { ParseError } from '../src/errors';
 
 describe('YAML Loader', () => {
     describe('loadSingleFile', () => {
@@ -60,4 +63,49 @@ describe('YAML Loader', () => {
             assert.ok(Object.keys(data.templates).length > 0, 'Should have template definitions');
         });
     });
-});
\ No newline at end of file
+
+    describe('comments and multi-line entries', () => {
+        const source = [
+            '# Room descriptions',
+            'rooms:  # comments after a table name are ignored',
+            '  - >',
+            '    A long hall lined',
+            '    with [statues]',
+            '  - |',
+            '    Two words \\',
+            '    joined',
+            '  - plain entry that',
+            '    wraps  # a note',
+            '  - "door #3"',
+            '  - door \\#4',
+            ''
+        ].join('\n');
+
+        it('should join continuation lines without leaking line breaks', () => {
+            const data = parseYamlContent(source);
+            assert.deepStrictEqual(data.rooms, [
+                'A long hall lined with [statues]',
+                'Two words joined',
+                'plain entry that wraps',
+                'door #3',
+                'door \\#4'
+            ]);
+            const engine = new SigilEngine({ ...data, statues: ['statues'] });
+            assert.strictEqual(engine.generate('[rooms!Two!plain!door]'), 'A long hall lined with statues');
+            assert.strictEqual(engine.generate('[rooms!hall!Two!plain!3]'), 'door #4');
+        });
+
+        it('should report positions on the original physical lines', () => {
+            const [issue] = validateSigil(source);
+            assert.match(issue.message, /undefined table "statues"/);
+            assert.strictEqual(issue.line, 5);
+        });
+    });
+
+    describe('weights', () => {
+        it('should report a weight of zero with its line', () => {
+            assert.throws(() => parseYamlContent('a:\n  - fine ^2\n  - never ^0\n'),
+                (error: unknown) => error instanceof ParseError && error.code === 'invalid_weight' && error.line === 3);
+        });
+    });
+});